@@ -0,0 +1,119 @@
+package apikey
+
+import (
+	"belajarGo2/service/apikey"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	logger    *slog.Logger
+	apiKeySvc apikey.Service
+}
+
+func NewController(logger *slog.Logger, s apikey.Service) *Controller {
+	return &Controller{
+		logger:    logger,
+		apiKeySvc: s,
+	}
+}
+
+type createRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Create godoc
+// @Summary      Create API key
+// @Description  Create an API key for the current user in the active organization, the key is only shown in this response. Send it in the X-API-Key header
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        request body createRequest true "API key request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/api-keys [post]
+func (ctrl *Controller) Create(c echo.Context) error {
+	var req createRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("apikey.Create Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("apikey.Create Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	// the key act in the active organization of the token
+	userID, _ := c.Get("id").(string)
+	organizationID, _ := c.Get("org_id").(string)
+	key, plaintext, err := ctrl.apiKeySvc.Create(userID, organizationID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidScope) || errors.Is(err, apikey.ErrInvalidExpiry) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+
+		ctrl.logger.Error("apikey.Create Service Error", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+		"api_key": key,
+		"key":     plaintext,
+	}})
+}
+
+// GetAll godoc
+// @Summary      List API keys
+// @Description  List the API keys of the current user, revoked and expired keys included
+// @Tags         API Keys
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/api-keys [get]
+func (ctrl *Controller) GetAll(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	keys, err := ctrl.apiKeySvc.GetAll(userID)
+	if err != nil {
+		ctrl.logger.Error("apikey.GetAll Service Error", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	if len(keys) == 0 {
+		keys = []apikey.APIKey{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": keys})
+}
+
+// Revoke godoc
+// @Summary      Revoke API key
+// @Description  Revoke an API key of the current user, it is refused from the next request
+// @Tags         API Keys
+// @Produce      json
+// @Param        id path string true "API key id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/api-keys/{id} [delete]
+func (ctrl *Controller) Revoke(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	if err := ctrl.apiKeySvc.Revoke(userID, c.Param("id")); err != nil {
+		if errors.Is(err, apikey.ErrKeyNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Data not found"})
+		}
+
+		ctrl.logger.Error("apikey.Revoke Service Error", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]string{}})
+}
@@ -2,6 +2,7 @@ package inventory
 
 import (
 	"belajarGo2/service/inventory"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -14,15 +15,24 @@ import (
 type Controller struct {
 	logger       *slog.Logger
 	inventorySvc inventory.Service
+	searchSvc    inventory.SearchService
 }
 
-func NewController(logger *slog.Logger, s inventory.Service) *Controller {
+func NewController(logger *slog.Logger, s inventory.Service, searchSvc inventory.SearchService) *Controller {
 	return &Controller{
 		logger:       logger,
 		inventorySvc: s,
+		searchSvc:    searchSvc,
 	}
 }
 
+// organizationID is the organization of the token or the api key, OrganizationPermissionMiddleware
+// refuse the requests without one
+func organizationID(c echo.Context) string {
+	organizationID, _ := c.Get("org_id").(string)
+	return organizationID
+}
+
 type InventoryRequest struct {
 	Code        string `json:"code" validate:"required"`
 	Name        string `json:"name" validate:"required"`
@@ -43,7 +53,7 @@ func (ctrl *Controller) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
 	}
 
-	if err := ctrl.inventorySvc.Create(inventory.Inventory{
+	if err := ctrl.inventorySvc.Create(organizationID(c), inventory.Inventory{
 		Code:        req.Code,
 		Name:        req.Name,
 		Stock:       req.Stock,
@@ -75,7 +85,7 @@ func (ctrl *Controller) GetAll(c echo.Context) error {
 		limit = 10
 	}
 
-	invs, err := ctrl.inventorySvc.GetAll(page, limit)
+	invs, err := ctrl.inventorySvc.GetAll(organizationID(c), page, limit)
 	if err != nil {
 		ctrl.logger.Error("inventory.GetAll Service Error", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
@@ -88,13 +98,45 @@ func (ctrl *Controller) GetAll(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": invs})
 }
 
+// Search godoc
+// @Summary      Search inventories
+// @Description  Fuzzy search on code, name and description of the active organization with highlights and status facets
+// @Tags         Inventories
+// @Produce      json
+// @Param        q      query string false "Search text"
+// @Param        status query string false "Filter by status" Enums(active, broken)
+// @Param        page   query int    false "Page"
+// @Param        limit  query int    false "Limit"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /inventories/search [get]
+func (ctrl *Controller) Search(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	result, err := ctrl.searchSvc.Search(organizationID(c), c.QueryParam("q"), c.QueryParam("status"), page, limit)
+	if err != nil {
+		ctrl.logger.Error("inventory.Search Service Error", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": result})
+}
+
 func (ctrl *Controller) GetByCode(c echo.Context) error {
 	code := c.Param("code")
 	if code == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Code parameter is required"})
 	}
 
-	inv, err := ctrl.inventorySvc.GetByCode(code)
+	inv, err := ctrl.inventorySvc.GetByCode(organizationID(c), code)
 	if err != nil {
 		ctrl.logger.Error("inventory.GetByCode Service Error", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
@@ -120,13 +162,17 @@ func (ctrl *Controller) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
 	}
 
-	if err := ctrl.inventorySvc.Update(inventory.Inventory{
+	if err := ctrl.inventorySvc.Update(organizationID(c), inventory.Inventory{
 		Code:        req.Code,
 		Name:        req.Name,
 		Stock:       req.Stock,
 		Description: req.Description,
 		Status:      req.Status,
 	}); err != nil {
+		if errors.Is(err, inventory.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Data not found"})
+		}
+
 		ctrl.logger.Error("inventory.Update Service Error", slog.Any("error", err))
 
 		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
@@ -145,7 +191,11 @@ func (ctrl *Controller) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Code parameter is required"})
 	}
 
-	if err := ctrl.inventorySvc.Delete(code); err != nil {
+	if err := ctrl.inventorySvc.Delete(organizationID(c), code); err != nil {
+		if errors.Is(err, inventory.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Data not found"})
+		}
+
 		ctrl.logger.Error("inventory.Delete Service Error", slog.Any("error", err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
 	}
@@ -0,0 +1,269 @@
+package organization
+
+import (
+	"belajarGo2/service/organization"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	logger *slog.Logger
+	orgSvc organization.Service
+}
+
+func NewController(logger *slog.Logger, s organization.Service) *Controller {
+	return &Controller{
+		logger: logger,
+		orgSvc: s,
+	}
+}
+
+type createRequest struct {
+	Name    string `json:"name" validate:"required,max=100"`
+	OwnerID string `json:"owner_id" validate:"required"`
+}
+
+type addMemberRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	Role   string `json:"role" validate:"required,max=40"`
+}
+
+type changeRoleRequest struct {
+	Role string `json:"role" validate:"required,max=40"`
+}
+
+func (ctrl *Controller) errorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, organization.ErrOrganizationNotFound), errors.Is(err, organization.ErrMemberNotFound), errors.Is(err, organization.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": err.Error()})
+	case errors.Is(err, organization.ErrInvalidName), errors.Is(err, organization.ErrInvalidRole), errors.Is(err, organization.ErrOwnMembership):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, organization.ErrMemberExists):
+		return c.JSON(http.StatusConflict, map[string]string{"message": err.Error()})
+	case errors.Is(err, organization.ErrRoleNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]string{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("organization."+action+" Service Error", slog.Any("error", err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+}
+
+// organizationID is the active organization of the token, OrganizationPermissionMiddleware refuse the requests without one
+func organizationID(c echo.Context) string {
+	organizationID, _ := c.Get("org_id").(string)
+	return organizationID
+}
+
+// organizationRole is the role of the user in the active organization, set by OrganizationPermissionMiddleware
+func organizationRole(c echo.Context) string {
+	role, _ := c.Get("org_role").(string)
+	return role
+}
+
+// Create godoc
+// @Summary      Create organization
+// @Description  Create an organization, the owner is its first member with the admin role
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        request body createRequest true "Organization request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/organizations [post]
+func (ctrl *Controller) Create(c echo.Context) error {
+	var req createRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("organization.Create Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("organization.Create Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	actorID, _ := c.Get("id").(string)
+	org, err := ctrl.orgSvc.Create(actorID, req.Name, req.OwnerID)
+	if err != nil {
+		return ctrl.errorResponse(c, "Create", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": org})
+}
+
+// GetAll godoc
+// @Summary      List organizations
+// @Tags         Organizations
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/organizations [get]
+func (ctrl *Controller) GetAll(c echo.Context) error {
+	orgs, err := ctrl.orgSvc.GetAll()
+	if err != nil {
+		return ctrl.errorResponse(c, "GetAll", err)
+	}
+
+	if len(orgs) == 0 {
+		orgs = []organization.Organization{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": orgs})
+}
+
+// GetByID godoc
+// @Summary      Get organization
+// @Tags         Organizations
+// @Produce      json
+// @Param        id path string true "Organization id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/organizations/{id} [get]
+func (ctrl *Controller) GetByID(c echo.Context) error {
+	org, err := ctrl.orgSvc.GetByID(c.Param("id"))
+	if err != nil {
+		return ctrl.errorResponse(c, "GetByID", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": org})
+}
+
+// GetMine godoc
+// @Summary      List my organizations
+// @Description  List the organizations the current user is a member of and the role in each, see POST /users/me/organizations/{id}/switch
+// @Tags         Organizations
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/organizations [get]
+func (ctrl *Controller) GetMine(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	members, err := ctrl.orgSvc.GetUserMemberships(userID)
+	if err != nil {
+		return ctrl.errorResponse(c, "GetMine", err)
+	}
+
+	if len(members) == 0 {
+		members = []organization.Member{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": members})
+}
+
+// GetMembers godoc
+// @Summary      List members
+// @Description  List the members of the active organization
+// @Tags         Organizations
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /organization/members [get]
+func (ctrl *Controller) GetMembers(c echo.Context) error {
+	members, err := ctrl.orgSvc.GetMembers(organizationID(c))
+	if err != nil {
+		return ctrl.errorResponse(c, "GetMembers", err)
+	}
+
+	if len(members) == 0 {
+		members = []organization.Member{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": members})
+}
+
+// AddMember godoc
+// @Summary      Add member
+// @Description  Add a user to the active organization with a role, the role grant its permissions inside the organization only. The role can not have a permission the role of the caller in the organization does not have
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        request body addMemberRequest true "Member request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /organization/members [post]
+func (ctrl *Controller) AddMember(c echo.Context) error {
+	var req addMemberRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("organization.AddMember Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("organization.AddMember Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	member, err := ctrl.orgSvc.AddMember(organizationRole(c), organizationID(c), req.UserID, req.Role)
+	if err != nil {
+		return ctrl.errorResponse(c, "AddMember", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": member})
+}
+
+// ChangeMemberRole godoc
+// @Summary      Change member role
+// @Description  Change the role of a member of the active organization, it apply from the next request of the member. Neither the new nor the current role can have a permission the role of the caller in the organization does not have
+// @Tags         Organizations
+// @Accept       json
+// @Produce      json
+// @Param        userId path string true "User id"
+// @Param        request body changeRoleRequest true "Role request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /organization/members/{userId}/role [put]
+func (ctrl *Controller) ChangeMemberRole(c echo.Context) error {
+	var req changeRoleRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("organization.ChangeMemberRole Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("organization.ChangeMemberRole Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.orgSvc.ChangeMemberRole(actorID, organizationRole(c), organizationID(c), c.Param("userId"), req.Role); err != nil {
+		return ctrl.errorResponse(c, "ChangeMemberRole", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]string{}})
+}
+
+// RemoveMember godoc
+// @Summary      Remove member
+// @Description  Remove a member of the active organization, the tokens of the member are refused for it from the next request
+// @Tags         Organizations
+// @Produce      json
+// @Param        userId path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /organization/members/{userId} [delete]
+func (ctrl *Controller) RemoveMember(c echo.Context) error {
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.orgSvc.RemoveMember(actorID, organizationID(c), c.Param("userId")); err != nil {
+		return ctrl.errorResponse(c, "RemoveMember", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]string{}})
+}
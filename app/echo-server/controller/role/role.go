@@ -0,0 +1,187 @@
+package role
+
+import (
+	"belajarGo2/service/role"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	logger  *slog.Logger
+	roleSvc role.Service
+}
+
+func NewController(logger *slog.Logger, s role.Service) *Controller {
+	return &Controller{
+		logger:  logger,
+		roleSvc: s,
+	}
+}
+
+type createRequest struct {
+	Name        string   `json:"name" validate:"required,max=40"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type updateRequest struct {
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+func (ctrl *Controller) errorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, role.ErrRoleNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Data not found"})
+	case errors.Is(err, role.ErrInvalidRoleName), errors.Is(err, role.ErrInvalidPermission), errors.Is(err, role.ErrSystemRole):
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+	case errors.Is(err, role.ErrPermissionNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]string{"message": err.Error()})
+	case errors.Is(err, role.ErrDuplicateRole):
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Role already exists"})
+	}
+
+	ctrl.logger.Error("role."+action+" Service Error", slog.Any("error", err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+}
+
+// Create godoc
+// @Summary      Create role
+// @Description  Create a role granting a set of permissions, see GET /admin/roles/permissions. Only the permissions of your role can be granted
+// @Tags         Roles
+// @Accept       json
+// @Produce      json
+// @Param        request body createRequest true "Role request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/roles [post]
+func (ctrl *Controller) Create(c echo.Context) error {
+	var req createRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("role.Create Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("role.Create Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	actorRole, _ := c.Get("role").(string)
+	created, err := ctrl.roleSvc.Create(actorRole, role.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		return ctrl.errorResponse(c, "Create", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": created})
+}
+
+// GetAll godoc
+// @Summary      List roles
+// @Tags         Roles
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/roles [get]
+func (ctrl *Controller) GetAll(c echo.Context) error {
+	roles, err := ctrl.roleSvc.GetAll()
+	if err != nil {
+		return ctrl.errorResponse(c, "GetAll", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": roles})
+}
+
+// GetPermissions godoc
+// @Summary      List permissions
+// @Description  List the permissions a role can grant
+// @Tags         Roles
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Router       /admin/roles/permissions [get]
+func (ctrl *Controller) GetPermissions(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": role.Permissions})
+}
+
+// GetByName godoc
+// @Summary      Get role
+// @Tags         Roles
+// @Produce      json
+// @Param        name path string true "Role name"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/roles/{name} [get]
+func (ctrl *Controller) GetByName(c echo.Context) error {
+	found, err := ctrl.roleSvc.GetByName(c.Param("name"))
+	if err != nil {
+		return ctrl.errorResponse(c, "GetByName", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": found})
+}
+
+// Update godoc
+// @Summary      Update role
+// @Description  Replace the description and the permissions of a role, the users having it get the new permissions on their next request. Only a role within the permissions of your role can be changed
+// @Tags         Roles
+// @Accept       json
+// @Produce      json
+// @Param        name path string true "Role name"
+// @Param        request body updateRequest true "Role request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/roles/{name} [put]
+func (ctrl *Controller) Update(c echo.Context) error {
+	var req updateRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("role.Update Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("role.Update Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	actorRole, _ := c.Get("role").(string)
+	updated, err := ctrl.roleSvc.Update(actorRole, c.Param("name"), req.Description, req.Permissions)
+	if err != nil {
+		return ctrl.errorResponse(c, "Update", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": updated})
+}
+
+// Delete godoc
+// @Summary      Delete role
+// @Description  Delete a role, the default roles can't be deleted. The users still having the role get no permission
+// @Tags         Roles
+// @Produce      json
+// @Param        name path string true "Role name"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/roles/{name} [delete]
+func (ctrl *Controller) Delete(c echo.Context) error {
+	if err := ctrl.roleSvc.Delete(c.Param("name")); err != nil {
+		return ctrl.errorResponse(c, "Delete", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]string{}})
+}
@@ -2,11 +2,14 @@ package user
 
 import (
 	"belajarGo2/service/user"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
@@ -22,6 +25,14 @@ func NewController(logger *slog.Logger, s user.Service) *Controller {
 	}
 }
 
+// clientInfo is recorded with the logins and the security events
+func clientInfo(c echo.Context) user.ClientInfo {
+	return user.ClientInfo{
+		IPAddress: c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
 type userRegisterRequest struct {
 	Email    string `json:"emai" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
@@ -55,6 +66,9 @@ func (ctrl *Controller) Register(c echo.Context) error {
 		Fullname: request.Fullname,
 	})
 	if err != nil {
+		if errors.Is(err, user.ErrWeakPassword) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+		}
 		if strings.Contains(err.Error(), "registered") {
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
 		}
@@ -71,7 +85,8 @@ type userLoginRequest struct {
 
 // Login godoc
 // @Summary      Login to system
-// @Description  Login to system and jwt/access token
+// @Description  Login to system, return a short lived jwt/access token and a refresh token.
+// @Description  When 2FA is enabled it return a two_factor_token to complete with /users/login/2fa
 // @Tags         Users
 // @Accept       json
 // @Produce      json
@@ -79,6 +94,8 @@ type userLoginRequest struct {
 // @Success      200 {object} map[string]interface{} "Status OK"
 // @Failure      400 {object} map[string]interface{} "Bad Request"
 // @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      429 {object} map[string]interface{} "Too Many Requests"
 // @Failure      500 {object} map[string]interface{} "Internal Server Error"
 // @Router       /users/login [post]
 func (ctrl *Controller) Login(c echo.Context) error {
@@ -91,8 +108,17 @@ func (ctrl *Controller) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
 	}
 
-	accessToken, err := ctrl.userSvc.Login(request.Email, request.Password)
+	token, err := ctrl.userSvc.Login(request.Email, request.Password, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, user.ErrLoginLocked) {
+			return c.JSON(http.StatusTooManyRequests, map[string]interface{}{"message": err.Error()})
+		}
+		if errors.Is(err, user.ErrUserDisabled) {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+		}
+		if errors.Is(err, user.ErrTwoFactorUnavailable) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+		}
 		// if strings.Contains(err.Error(), "wrong email") {
 		// 	return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": http.StatusText(http.StatusUnauthorized)})
 		if strings.Contains(err.Error(), "email address") {
@@ -101,13 +127,189 @@ func (ctrl *Controller) Login(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": accessToken})
+	// the password was right, the login is completed with /users/login/2fa
+	if token.TwoFactorToken != "" {
+		return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+			"two_factor_required": true,
+			"two_factor_token":    token.TwoFactorToken,
+		}})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": token})
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Revoke the access token and the refresh token of the current login
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/logout [post]
+func (ctrl *Controller) Logout(c echo.Context) error {
+	claims := user.Claims{}
+	claims.ID, _ = c.Get("id").(string)
+	claims.SessionID, _ = c.Get("sid").(string)
+	claims.RegisteredClaims.ID, _ = c.Get("jti").(string)
+	if expAt, ok := c.Get("exp").(time.Time); ok {
+		claims.ExpiresAt = jwt.NewNumericDate(expAt)
+	}
+
+	if err := ctrl.userSvc.Logout(claims, clientInfo(c)); err != nil {
+		ctrl.logger.Error("logout err", slog.Any("err", err.Error()))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// LogoutAll godoc
+// @Summary      Logout everywhere
+// @Description  Revoke every access token and refresh token of the current user
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/logout/all [post]
+func (ctrl *Controller) LogoutAll(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+
+	if err := ctrl.userSvc.LogoutAll(userID, clientInfo(c)); err != nil {
+		ctrl.logger.Error("logout all err", slog.Any("err", err.Error()))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshToken godoc
+// @Summary      Refresh the access token
+// @Description  Exchange a refresh token for a new access token and a new refresh token, the used refresh token stop working
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body refreshTokenRequest true "Refresh token request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/token/refresh [post]
+func (ctrl *Controller) RefreshToken(c echo.Context) error {
+	request := new(refreshTokenRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	token, err := ctrl.userSvc.RefreshToken(request.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidRefreshToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": token})
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPassword godoc
+// @Summary      Forgot password
+// @Description  Email a single use reset link, the response is the same whether the email is registered or not
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body forgotPasswordRequest true "Forgot password request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/password/forgot [post]
+func (ctrl *Controller) ForgotPassword(c echo.Context) error {
+	request := new(forgotPasswordRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := ctrl.userSvc.ForgotPassword(request.Email); err != nil {
+		ctrl.logger.Error("forgot password err", slog.Any("err", err.Error()))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "if the email is registered, a reset link has been sent"})
+}
+
+// resetPasswordRequest is sent as json or by the form of ResetPasswordForm
+type resetPasswordRequest struct {
+	Code     string `json:"code" form:"code" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password with the code of the reset link, every session of the user is ended
+// @Tags         Users
+// @Accept       json,x-www-form-urlencoded
+// @Produce      json
+// @Param        request body resetPasswordRequest true "Reset password request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/password/reset [post]
+func (ctrl *Controller) ResetPassword(c echo.Context) error {
+	request := new(resetPasswordRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	err := ctrl.userSvc.ResetPassword(request.Code, request.Password, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, user.ErrWeakPassword) {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+		}
+		if strings.Contains(err.Error(), "invalid or expired") {
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
 }
 
+// VerifyEmail godoc
+// @Summary      Verify email address
+// @Description  Open the link sent by email after the registration
+// @Tags         Users
+// @Produce      json
+// @Param        code path string true "Verification token"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/email-verification/{code} [get]
 func (ctrl *Controller) VerifyEmail(c echo.Context) error {
 	encCode := c.Param("code")
 
-	err := ctrl.userSvc.VerifyEmail(encCode)
+	err := ctrl.userSvc.VerifyEmail(encCode, clientInfo(c))
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid or expired") {
 			return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
@@ -117,3 +319,40 @@ func (ctrl *Controller) VerifyEmail(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
 }
+
+type resendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResendVerification godoc
+// @Summary      Resend the verification email
+// @Description  Email a new verification link, the response is the same whether the email is registered or not
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body resendVerificationRequest true "Resend verification request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      429 {object} map[string]interface{} "Too Many Requests"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/email-verification/resend [post]
+func (ctrl *Controller) ResendVerification(c echo.Context) error {
+	request := new(resendVerificationRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := ctrl.userSvc.ResendVerification(request.Email); err != nil {
+		if errors.Is(err, user.ErrTooManyRequests) {
+			return c.JSON(http.StatusTooManyRequests, map[string]interface{}{"message": err.Error()})
+		}
+		ctrl.logger.Error("resend verification err", slog.Any("err", err.Error()))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "if the email is registered and not verified yet, a new link has been sent"})
+}
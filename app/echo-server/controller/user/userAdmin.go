@@ -0,0 +1,178 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+func (ctrl *Controller) adminErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	case errors.Is(err, user.ErrInvalidRole), errors.Is(err, user.ErrOwnAccountOnly):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrRoleNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("user admin "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// AdminGetAll godoc
+// @Summary      List users
+// @Description  Search users by email or fullname, filter by role and disabled status
+// @Tags         Admin Users
+// @Produce      json
+// @Param        q        query string false "Part of the email or fullname"
+// @Param        role     query string false "Role" Enums(superadmin, admin, user)
+// @Param        disabled query bool   false "Disabled status"
+// @Param        page     query int    false "Page"
+// @Param        limit    query int    false "Limit"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users [get]
+func (ctrl *Controller) AdminGetAll(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	filter := user.Filter{
+		Query: c.QueryParam("q"),
+		Role:  c.QueryParam("role"),
+	}
+	if disabled, err := strconv.ParseBool(c.QueryParam("disabled")); err == nil {
+		filter.Disabled = &disabled
+	}
+
+	users, total, err := ctrl.userSvc.GetAll(filter, page, limit)
+	if err != nil {
+		return ctrl.adminErrorResponse(c, "get all", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": users, "total": total, "page": page, "limit": limit})
+}
+
+// AdminGetByID godoc
+// @Summary      Get a user
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id} [get]
+func (ctrl *Controller) AdminGetByID(c echo.Context) error {
+	usr, err := ctrl.userSvc.GetByID(c.Param("id"))
+	if err != nil {
+		return ctrl.adminErrorResponse(c, "get by id", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": usr})
+}
+
+type changeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=superadmin admin user"`
+}
+
+// AdminChangeRole godoc
+// @Summary      Change the role of a user
+// @Description  The user has to login again to get a token with the new role. A role with a permission your role does not have is refused
+// @Tags         Admin Users
+// @Accept       json
+// @Produce      json
+// @Param        id      path string            true "User id"
+// @Param        request body changeRoleRequest true "Change role request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id}/role [put]
+func (ctrl *Controller) AdminChangeRole(c echo.Context) error {
+	request := new(changeRoleRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	actorID, _ := c.Get("id").(string)
+	actorRole, _ := c.Get("role").(string)
+	if err := ctrl.userSvc.ChangeRole(actorID, actorRole, c.Param("id"), request.Role, clientInfo(c)); err != nil {
+		return ctrl.adminErrorResponse(c, "change role", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// AdminDisable godoc
+// @Summary      Disable a user
+// @Description  A disabled user can't login and the tokens already issued stop working
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id}/disable [post]
+func (ctrl *Controller) AdminDisable(c echo.Context) error {
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.SetDisabled(actorID, c.Param("id"), true, clientInfo(c)); err != nil {
+		return ctrl.adminErrorResponse(c, "disable", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// AdminEnable godoc
+// @Summary      Enable a disabled user
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id}/enable [post]
+func (ctrl *Controller) AdminEnable(c echo.Context) error {
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.SetDisabled(actorID, c.Param("id"), false, clientInfo(c)); err != nil {
+		return ctrl.adminErrorResponse(c, "enable", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// AdminDelete godoc
+// @Summary      Delete a user
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id} [delete]
+func (ctrl *Controller) AdminDelete(c echo.Context) error {
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.Delete(actorID, c.Param("id"), clientInfo(c)); err != nil {
+		return ctrl.adminErrorResponse(c, "delete", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
@@ -0,0 +1,71 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// the links sent by email open these forms, they post to the json endpoints which also accept a form body
+var resetPasswordForm = template.Must(template.New("reset-password").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset password</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="code" value="{{.Code}}">
+<label>New password <input type="password" name="password" required></label>
+<button type="submit">Reset password</button>
+</form>
+</body>
+</html>`))
+
+var acceptInvitationForm = template.Must(template.New("accept-invitation").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Accept invitation</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="code" value="{{.Code}}">
+<label>Full name <input type="text" name="fullname" maxlength="100" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit">Create account</button>
+</form>
+</body>
+</html>`))
+
+func renderForm(c echo.Context, form *template.Template, action string) error {
+	var out bytes.Buffer
+	if err := form.Execute(&out, map[string]string{"Action": action, "Code": c.QueryParam("code")}); err != nil {
+		return err
+	}
+
+	// the code is in the url, it must not leak to another site through the referer
+	c.Response().Header().Set("Referrer-Policy", "no-referrer")
+	return c.HTMLBlob(http.StatusOK, out.Bytes())
+}
+
+// ResetPasswordForm godoc
+// @Summary      Reset password form
+// @Description  Opened by the reset link sent by email, the form post the new password to the reset endpoint
+// @Tags         Users
+// @Produce      html
+// @Param        code query string true "Reset code"
+// @Success      200 {string} string "HTML form"
+// @Router       /users/password/reset [get]
+func (ctrl *Controller) ResetPasswordForm(c echo.Context) error {
+	return renderForm(c, resetPasswordForm, user.ResetPasswordPath)
+}
+
+// AcceptInvitationForm godoc
+// @Summary      Accept invitation form
+// @Description  Opened by the invitation link sent by email, the form post the account to the accept endpoint
+// @Tags         Users
+// @Produce      html
+// @Param        code query string true "Invitation code"
+// @Success      200 {string} string "HTML form"
+// @Router       /users/invitations/accept [get]
+func (ctrl *Controller) AcceptInvitationForm(c echo.Context) error {
+	return renderForm(c, acceptInvitationForm, user.InvitationAcceptPath)
+}
@@ -0,0 +1,162 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type inviteRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,max=40"`
+}
+
+// acceptInvitationRequest is sent as json or by the form of AcceptInvitationForm
+type acceptInvitationRequest struct {
+	Code     string `json:"code" form:"code" validate:"required"`
+	Fullname string `json:"fullname" form:"fullname" validate:"required,max=100"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
+
+func (ctrl *Controller) invitationErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrInvitationNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	case errors.Is(err, user.ErrInvalidRole), errors.Is(err, user.ErrInvalidFullname), errors.Is(err, user.ErrWeakPassword):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrRoleNotAllowed):
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrEmailRegistered), errors.Is(err, user.ErrInvitationPending):
+		return c.JSON(http.StatusConflict, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrTooManyRequests):
+		return c.JSON(http.StatusTooManyRequests, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrInvitationsUnavailable), errors.Is(err, user.ErrLinksUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("user invitation "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// AdminInvite godoc
+// @Summary      Invite a user
+// @Description  Email a link to create an account with the role, the email is verified once the invitation is accepted. A role with a permission your role does not have is refused
+// @Tags         Admin Users
+// @Accept       json
+// @Produce      json
+// @Param        request body inviteRequest true "Invite request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/invitations [post]
+func (ctrl *Controller) AdminInvite(c echo.Context) error {
+	request := new(inviteRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	actorID, _ := c.Get("id").(string)
+	actorRole, _ := c.Get("role").(string)
+	invitation, err := ctrl.userSvc.Invite(actorID, actorRole, request.Email, request.Role)
+	if err != nil {
+		return ctrl.invitationErrorResponse(c, "invite", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": invitation})
+}
+
+// AdminGetInvitations godoc
+// @Summary      List the pending invitations
+// @Tags         Admin Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/invitations [get]
+func (ctrl *Controller) AdminGetInvitations(c echo.Context) error {
+	invitations, err := ctrl.userSvc.GetInvitations()
+	if err != nil {
+		return ctrl.invitationErrorResponse(c, "get all", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": invitations})
+}
+
+// AdminResendInvitation godoc
+// @Summary      Resend an invitation
+// @Description  Email a new link and extend the expiry
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "Invitation id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      429 {object} map[string]interface{} "Too Many Requests"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/invitations/{id}/resend [post]
+func (ctrl *Controller) AdminResendInvitation(c echo.Context) error {
+	invitation, err := ctrl.userSvc.ResendInvitation(c.Param("id"))
+	if err != nil {
+		return ctrl.invitationErrorResponse(c, "resend", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": invitation})
+}
+
+// AdminRevokeInvitation godoc
+// @Summary      Revoke an invitation
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "Invitation id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/invitations/{id} [delete]
+func (ctrl *Controller) AdminRevokeInvitation(c echo.Context) error {
+	if err := ctrl.userSvc.RevokeInvitation(c.Param("id")); err != nil {
+		return ctrl.invitationErrorResponse(c, "revoke", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept an invitation
+// @Description  Create the invited account with the code of the invitation link
+// @Tags         Users
+// @Accept       json,x-www-form-urlencoded
+// @Produce      json
+// @Param        request body acceptInvitationRequest true "Accept invitation request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/invitations/accept [post]
+func (ctrl *Controller) AcceptInvitation(c echo.Context) error {
+	request := new(acceptInvitationRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	id, err := ctrl.userSvc.AcceptInvitation(request.Code, request.Fullname, request.Password)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid or expired") {
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+		}
+		return ctrl.invitationErrorResponse(c, "accept", err)
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": map[string]interface{}{"id": id}})
+}
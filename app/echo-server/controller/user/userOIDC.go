@@ -0,0 +1,81 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (ctrl *Controller) oidcErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrInvalidOIDCState), errors.Is(err, user.ErrOIDCLoginFailed),
+		errors.Is(err, user.ErrOIDCEmailNotVerified), errors.Is(err, user.ErrOIDCUserNotFound):
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrUserDisabled):
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrOIDCUnavailable), errors.Is(err, user.ErrTwoFactorUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("oidc "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// OIDCLogin godoc
+// @Summary      Single sign-on login
+// @Description  Redirect to the single sign-on provider, it redirect back to /users/oidc/callback
+// @Tags         Users
+// @Success      302
+// @Failure      503 {object} map[string]interface{} "Service Unavailable"
+// @Router       /users/oidc/login [get]
+func (ctrl *Controller) OIDCLogin(c echo.Context) error {
+	authURL, err := ctrl.userSvc.OIDCAuthURL()
+	if err != nil {
+		return ctrl.oidcErrorResponse(c, "login", err)
+	}
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary      Single sign-on callback
+// @Description  Complete the single sign-on, return the same tokens as /users/login.
+// @Description  The account is found, or created when enabled, by the email verified by the provider
+// @Tags         Users
+// @Produce      json
+// @Param        state query string true "Login state"
+// @Param        code query string true "Authorization code"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/oidc/callback [get]
+func (ctrl *Controller) OIDCCallback(c echo.Context) error {
+	// the user refused or the provider failed
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		ctrl.logger.Warn("oidc callback provider err", slog.String("err", providerErr), slog.String("description", c.QueryParam("error_description")))
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": user.ErrOIDCLoginFailed.Error()})
+	}
+
+	state, code := c.QueryParam("state"), c.QueryParam("code")
+	if state == "" || code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	token, err := ctrl.userSvc.OIDCCallback(state, code, clientInfo(c))
+	if err != nil {
+		return ctrl.oidcErrorResponse(c, "callback", err)
+	}
+
+	if token.TwoFactorToken != "" {
+		return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+			"two_factor_required": true,
+			"two_factor_token":    token.TwoFactorToken,
+		}})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": token})
+}
@@ -0,0 +1,41 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SwitchOrganization godoc
+// @Summary      Switch organization
+// @Description  Make the organization the active one of the current user, the returned access token is scoped to it. The refresh token is kept and the next refresh keep the organization
+// @Tags         Users
+// @Produce      json
+// @Param        id path string true "Organization id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Failure      503 {object} map[string]interface{} "Service Unavailable"
+// @Router       /users/me/organizations/{id}/switch [post]
+func (ctrl *Controller) SwitchOrganization(c echo.Context) error {
+	claims := user.Claims{}
+	claims.ID, _ = c.Get("id").(string)
+	claims.SessionID, _ = c.Get("sid").(string)
+	claims.TwoFactorPending, _ = c.Get("2fa_pending").(bool)
+
+	token, err := ctrl.userSvc.SwitchOrganization(claims, c.Param("id"))
+	switch {
+	case errors.Is(err, user.ErrNotMember):
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrOrganizationsUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	case err != nil:
+		ctrl.logger.Error("switch organization err", slog.Any("err", err.Error()))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": token})
+}
@@ -0,0 +1,80 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (ctrl *Controller) privacyErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	case errors.Is(err, user.ErrDeletionScheduled), errors.Is(err, user.ErrDeletionNotScheduled):
+		return c.JSON(http.StatusConflict, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("user "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// ExportData godoc
+// @Summary      Export my personal data
+// @Description  Download everything held about the current user as a JSON archive
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} user.DataExport "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/export [get]
+func (ctrl *Controller) ExportData(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	export, err := ctrl.userSvc.ExportData(userID)
+	if err != nil {
+		return ctrl.privacyErrorResponse(c, "export data", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="user-data-`+userID+`.json"`)
+	return c.JSON(http.StatusOK, export)
+}
+
+// DeleteMe godoc
+// @Summary      Delete my account
+// @Description  Schedule the anonymization of the account after the grace period, it can be cancelled until then
+// @Tags         Users
+// @Produce      json
+// @Success      202 {object} map[string]interface{} "Accepted"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me [delete]
+func (ctrl *Controller) DeleteMe(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	deleteAt, err := ctrl.userSvc.RequestDeletion(userID)
+	if err != nil {
+		return ctrl.privacyErrorResponse(c, "request deletion", err)
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{"message": "OK", "data": map[string]interface{}{"delete_at": deleteAt}})
+}
+
+// CancelDeletion godoc
+// @Summary      Cancel my account deletion
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/deletion/cancel [post]
+func (ctrl *Controller) CancelDeletion(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.CancelDeletion(userID); err != nil {
+		return ctrl.privacyErrorResponse(c, "cancel deletion", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
@@ -0,0 +1,176 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type updateProfileRequest struct {
+	Fullname string `json:"fullname" validate:"required,max=100"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type changeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (ctrl *Controller) profileErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	case errors.Is(err, user.ErrInvalidFullname), errors.Is(err, user.ErrWeakPassword), errors.Is(err, user.ErrSameEmail):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrWrongPassword):
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrEmailRegistered):
+		return c.JSON(http.StatusConflict, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrTooManyRequests):
+		return c.JSON(http.StatusTooManyRequests, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrLinksUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("user profile "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// GetMe godoc
+// @Summary      Get my profile
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me [get]
+func (ctrl *Controller) GetMe(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	getUser, err := ctrl.userSvc.GetByID(userID)
+	if err != nil {
+		return ctrl.profileErrorResponse(c, "get", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": getUser})
+}
+
+// UpdateMe godoc
+// @Summary      Update my profile
+// @Description  Change the fullname of the current user
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body updateProfileRequest true "Profile request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me [patch]
+func (ctrl *Controller) UpdateMe(c echo.Context) error {
+	request := new(updateProfileRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	userID, _ := c.Get("id").(string)
+	updated, err := ctrl.userSvc.UpdateProfile(userID, request.Fullname)
+	if err != nil {
+		return ctrl.profileErrorResponse(c, "update", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": updated})
+}
+
+// ChangePassword godoc
+// @Summary      Change my password
+// @Description  Change the password with the current one, every session is ended and the user must login again
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body changePasswordRequest true "Change password request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/password [post]
+func (ctrl *Controller) ChangePassword(c echo.Context) error {
+	request := new(changePasswordRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	userID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.ChangePassword(userID, request.CurrentPassword, request.NewPassword, clientInfo(c)); err != nil {
+		return ctrl.profileErrorResponse(c, "change password", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// RequestEmailChange godoc
+// @Summary      Change my email
+// @Description  Email a confirmation link to the new address and a notice to the current one, the email change once the link is opened
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body changeEmailRequest true "Change email request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      429 {object} map[string]interface{} "Too Many Requests"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/email [post]
+func (ctrl *Controller) RequestEmailChange(c echo.Context) error {
+	request := new(changeEmailRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	userID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.RequestEmailChange(userID, request.NewEmail, request.Password); err != nil {
+		return ctrl.profileErrorResponse(c, "request email change", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// ConfirmEmailChange godoc
+// @Summary      Confirm the new email
+// @Description  Open the link sent to the new address, the new email is verified
+// @Tags         Users
+// @Produce      json
+// @Param        code path string true "Email change code"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      409 {object} map[string]interface{} "Conflict"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/email-change/{code} [get]
+func (ctrl *Controller) ConfirmEmailChange(c echo.Context) error {
+	err := ctrl.userSvc.ConfirmEmailChange(c.Param("code"))
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid or expired") {
+			return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+		}
+		return ctrl.profileErrorResponse(c, "confirm email change", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
@@ -0,0 +1,130 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errInvalidTimeRange = errors.New("from and to must be RFC3339 times")
+
+func (ctrl *Controller) securityErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, errInvalidTimeRange):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrSecurityLogUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("security log "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// securityEventFilter read the filter of the query and the export from the query params
+func securityEventFilter(c echo.Context) (filter user.SecurityEventFilter, err error) {
+	filter = user.SecurityEventFilter{
+		EventType: c.QueryParam("event_type"),
+		UserID:    c.QueryParam("user_id"),
+		IPAddress: c.QueryParam("ip"),
+		Outcome:   c.QueryParam("outcome"),
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, errInvalidTimeRange
+		}
+		filter.From = &fromTime
+	}
+	if to := c.QueryParam("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, errInvalidTimeRange
+		}
+		filter.To = &toTime
+	}
+	return filter, nil
+}
+
+// AdminGetSecurityEvents godoc
+// @Summary      Query the security log
+// @Description  List the authentication and account security events, the newest first
+// @Tags         Admin Security
+// @Produce      json
+// @Param        event_type query string false "Event type, e.g. login_failed"
+// @Param        user_id    query string false "Actor or subject of the event"
+// @Param        ip         query string false "IP address"
+// @Param        outcome    query string false "Outcome" Enums(success, failure)
+// @Param        from       query string false "From, RFC3339 inclusive"
+// @Param        to         query string false "To, RFC3339 exclusive"
+// @Param        page       query int    false "Page"
+// @Param        limit      query int    false "Limit"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Failure      503 {object} map[string]interface{} "Service Unavailable"
+// @Router       /admin/security-events [get]
+func (ctrl *Controller) AdminGetSecurityEvents(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	filter, err := securityEventFilter(c)
+	if err != nil {
+		return ctrl.securityErrorResponse(c, "get all", err)
+	}
+
+	events, total, err := ctrl.userSvc.GetSecurityEvents(filter, page, limit)
+	if err != nil {
+		return ctrl.securityErrorResponse(c, "get all", err)
+	}
+
+	if len(events) == 0 {
+		events = []user.SecurityEvent{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": events, "total": total, "page": page, "limit": limit})
+}
+
+// AdminExportSecurityEvents godoc
+// @Summary      Export the security log
+// @Description  Download the security events matching the filter as csv, the newest first and at most 10000 rows
+// @Tags         Admin Security
+// @Produce      text/csv
+// @Param        event_type query string false "Event type, e.g. login_failed"
+// @Param        user_id    query string false "Actor or subject of the event"
+// @Param        ip         query string false "IP address"
+// @Param        outcome    query string false "Outcome" Enums(success, failure)
+// @Param        from       query string false "From, RFC3339 inclusive"
+// @Param        to         query string false "To, RFC3339 exclusive"
+// @Success      200 {string} string "CSV"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Failure      503 {object} map[string]interface{} "Service Unavailable"
+// @Router       /admin/security-events/export [get]
+func (ctrl *Controller) AdminExportSecurityEvents(c echo.Context) error {
+	filter, err := securityEventFilter(c)
+	if err != nil {
+		return ctrl.securityErrorResponse(c, "export", err)
+	}
+
+	// written to a buffer so an error is still reported with its status
+	var out bytes.Buffer
+	if err := ctrl.userSvc.ExportSecurityEvents(filter, &out); err != nil {
+		return ctrl.securityErrorResponse(c, "export", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="security-events.csv"`)
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", out.Bytes())
+}
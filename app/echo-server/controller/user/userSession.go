@@ -0,0 +1,68 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (ctrl *Controller) sessionErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrSessionNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrSessionsUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("session "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// GetSessions godoc
+// @Summary      List my sessions
+// @Description  List the devices the current user is logged in, the session of this token is marked current
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/sessions [get]
+func (ctrl *Controller) GetSessions(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	sessionID, _ := c.Get("sid").(string)
+
+	sessions, err := ctrl.userSvc.GetSessions(userID, sessionID)
+	if err != nil {
+		return ctrl.sessionErrorResponse(c, "get all", err)
+	}
+
+	if len(sessions) == 0 {
+		sessions = []user.Session{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": sessions})
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Logout a device of the current user, its tokens are refused from the next request
+// @Tags         Users
+// @Produce      json
+// @Param        id path string true "Session id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      403 {object} map[string]interface{} "Forbidden"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/sessions/{id} [delete]
+func (ctrl *Controller) RevokeSession(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+
+	if err := ctrl.userSvc.RevokeSession(userID, c.Param("id"), clientInfo(c)); err != nil {
+		return ctrl.sessionErrorResponse(c, "revoke", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
@@ -0,0 +1,174 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type loginTwoFactorRequest struct {
+	TwoFactorToken string `json:"two_factor_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+func (ctrl *Controller) twoFactorErrorResponse(c echo.Context, action string, err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, map[string]interface{}{"message": http.StatusText(http.StatusNotFound)})
+	case errors.Is(err, user.ErrInvalidTwoFactorCode), errors.Is(err, user.ErrInvalidTwoFactorToken):
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrTwoFactorAlreadyEnabled), errors.Is(err, user.ErrTwoFactorNotEnrolled),
+		errors.Is(err, user.ErrTwoFactorRequired), errors.Is(err, user.ErrOwnAccountOnly):
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrUserDisabled):
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrLoginLocked):
+		return c.JSON(http.StatusTooManyRequests, map[string]interface{}{"message": err.Error()})
+	case errors.Is(err, user.ErrTwoFactorUnavailable):
+		return c.JSON(http.StatusServiceUnavailable, map[string]interface{}{"message": err.Error()})
+	}
+
+	ctrl.logger.Error("two factor "+action+" err", slog.Any("err", err.Error()))
+	return c.JSON(http.StatusInternalServerError, map[string]interface{}{"message": http.StatusText(http.StatusInternalServerError)})
+}
+
+// EnrollTwoFactor godoc
+// @Summary      Enroll 2FA
+// @Description  Generate a TOTP secret, add the provisioning uri to an authenticator app then confirm with a code
+// @Tags         Users
+// @Produce      json
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/2fa/enroll [post]
+func (ctrl *Controller) EnrollTwoFactor(c echo.Context) error {
+	userID, _ := c.Get("id").(string)
+	secret, provisioningURI, err := ctrl.userSvc.EnrollTwoFactor(userID)
+	if err != nil {
+		return ctrl.twoFactorErrorResponse(c, "enroll", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+		"secret":           secret,
+		"provisioning_uri": provisioningURI,
+	}})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary      Confirm 2FA
+// @Description  Enable 2FA with a code of the authenticator app, the recovery codes are only shown in this response
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body twoFactorCodeRequest true "TOTP code"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/2fa/confirm [post]
+func (ctrl *Controller) ConfirmTwoFactor(c echo.Context) error {
+	request := new(twoFactorCodeRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	userID, _ := c.Get("id").(string)
+	recoveryCodes, err := ctrl.userSvc.ConfirmTwoFactor(userID, request.Code)
+	if err != nil {
+		return ctrl.twoFactorErrorResponse(c, "confirm", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+		"recovery_codes": recoveryCodes,
+	}})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable 2FA
+// @Description  Disable 2FA with a TOTP or recovery code, refused when the role require 2FA
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body twoFactorCodeRequest true "TOTP or recovery code"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/me/2fa/disable [post]
+func (ctrl *Controller) DisableTwoFactor(c echo.Context) error {
+	request := new(twoFactorCodeRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	userID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.DisableTwoFactor(userID, request.Code); err != nil {
+		return ctrl.twoFactorErrorResponse(c, "disable", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
+
+// LoginTwoFactor godoc
+// @Summary      Login second step
+// @Description  Complete a login which returned a two_factor_token with a TOTP or recovery code, the wrong codes lock the account like wrong passwords
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body loginTwoFactorRequest true "Two factor login request"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      401 {object} map[string]interface{} "Unauthorized"
+// @Failure      429 {object} map[string]interface{} "Too Many Requests"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /users/login/2fa [post]
+func (ctrl *Controller) LoginTwoFactor(c echo.Context) error {
+	request := new(loginTwoFactorRequest)
+	if err := c.Bind(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+	if err := validator.New().Struct(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": http.StatusText(http.StatusBadRequest)})
+	}
+
+	token, err := ctrl.userSvc.LoginTwoFactor(request.TwoFactorToken, request.Code, clientInfo(c))
+	if err != nil {
+		return ctrl.twoFactorErrorResponse(c, "login", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": token})
+}
+
+// AdminResetTwoFactor godoc
+// @Summary      Reset the 2FA of a user
+// @Description  Disable the 2FA of a user who lost the authenticator and the recovery codes
+// @Tags         Admin Users
+// @Produce      json
+// @Param        id path string true "User id"
+// @Success      200 {object} map[string]interface{} "Status OK"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      404 {object} map[string]interface{} "Not Found"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /admin/users/{id}/2fa/reset [post]
+func (ctrl *Controller) AdminResetTwoFactor(c echo.Context) error {
+	actorID, _ := c.Get("id").(string)
+	if err := ctrl.userSvc.ResetTwoFactor(actorID, c.Param("id"), clientInfo(c)); err != nil {
+		return ctrl.adminErrorResponse(c, "reset 2fa", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK"})
+}
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"belajarGo2/service/webhook"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	logger     *slog.Logger
+	webhookSvc webhook.Service
+}
+
+func NewController(logger *slog.Logger, s webhook.Service) *Controller {
+	return &Controller{
+		logger:     logger,
+		webhookSvc: s,
+	}
+}
+
+type subscriptionRequest struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,required"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16"`
+}
+
+// Create godoc
+// @Summary      Create webhook subscription
+// @Description  Subscribe an url to the inventory events of the active organization and to the user events of its members. The secret is only shown in this response
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        request body subscriptionRequest true "Webhook subscription request"
+// @Success      201 {object} map[string]interface{} "Created"
+// @Failure      400 {object} map[string]interface{} "Bad Request"
+// @Failure      500 {object} map[string]interface{} "Internal Server Error"
+// @Router       /webhooks [post]
+func (ctrl *Controller) Create(c echo.Context) error {
+	var req subscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		ctrl.logger.Error("webhook.Create Bind Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		ctrl.logger.Error("webhook.Create Validation Error", slog.Any("error", err))
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Validation error"})
+	}
+
+	userID, _ := c.Get("id").(string)
+	sub, err := ctrl.webhookSvc.CreateSubscription(webhook.Subscription{
+		OrganizationID: organizationID(c),
+		URL:            req.URL,
+		EventTypes:     req.EventTypes,
+		Secret:         req.Secret,
+		CreatedBy:      userID,
+	})
+	if err != nil {
+		ctrl.logger.Error("webhook.Create Service Error", slog.Any("error", err))
+
+		if errors.Is(err, webhook.ErrInvalidURL) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": err.Error()})
+		}
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+		"subscription": sub,
+		"secret":       sub.Secret,
+	}})
+}
+
+func (ctrl *Controller) GetAll(c echo.Context) error {
+	subs, err := ctrl.webhookSvc.GetSubscriptions(organizationID(c))
+	if err != nil {
+		ctrl.logger.Error("webhook.GetAll Service Error", slog.Any("error", err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+	}
+
+	if len(subs) == 0 {
+		subs = []webhook.Subscription{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": subs})
+}
+
+func (ctrl *Controller) GetByID(c echo.Context) error {
+	sub, err := ctrl.webhookSvc.GetSubscriptionByID(organizationID(c), c.Param("id"))
+	if err != nil {
+		return ctrl.errorResponse(c, "webhook.GetByID", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": sub})
+}
+
+func (ctrl *Controller) Delete(c echo.Context) error {
+	if err := ctrl.webhookSvc.DeleteSubscription(organizationID(c), c.Param("id")); err != nil {
+		return ctrl.errorResponse(c, "webhook.Delete", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]string{}})
+}
+
+func (ctrl *Controller) GetDeliveries(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	deliveries, err := ctrl.webhookSvc.GetDeliveries(organizationID(c), c.Param("id"), page, limit)
+	if err != nil {
+		return ctrl.errorResponse(c, "webhook.GetDeliveries", err)
+	}
+
+	if len(deliveries) == 0 {
+		deliveries = []webhook.Delivery{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": deliveries})
+}
+
+func (ctrl *Controller) Redeliver(c echo.Context) error {
+	delivery, err := ctrl.webhookSvc.Redeliver(organizationID(c), c.Param("deliveryId"))
+	if err != nil {
+		return ctrl.errorResponse(c, "webhook.Redeliver", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": delivery})
+}
+
+// organizationID is the active organization of the token, the webhook routes only manage its subscriptions
+func organizationID(c echo.Context) string {
+	organizationID, _ := c.Get("org_id").(string)
+	return organizationID
+}
+
+func (ctrl *Controller) errorResponse(c echo.Context, action string, err error) error {
+	if errors.Is(err, webhook.ErrSubscriptionNotFound) || errors.Is(err, webhook.ErrDeliveryNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Data not found"})
+	}
+
+	ctrl.logger.Error(action+" Service Error", slog.Any("error", err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Internal server error"})
+}
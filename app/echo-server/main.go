@@ -1,27 +1,52 @@
 package main
 
 import (
+	apiKeyController "belajarGo2/app/echo-server/controller/apikey"
 	invHandler "belajarGo2/app/echo-server/controller/inventory"
+	organizationController "belajarGo2/app/echo-server/controller/organization"
+	roleController "belajarGo2/app/echo-server/controller/role"
 	userController "belajarGo2/app/echo-server/controller/user"
+	webhookController "belajarGo2/app/echo-server/controller/webhook"
+	customMiddleware "belajarGo2/app/echo-server/middleware"
 	"belajarGo2/app/echo-server/router"
+	apiKeyRepo "belajarGo2/repository/apikey"
+	cacheRepository "belajarGo2/repository/cache"
 	invRepo "belajarGo2/repository/inventory"
 	"belajarGo2/repository/notification/mailjet"
+	oneTimeTokenRepo "belajarGo2/repository/onetimetoken"
+	organizationRepo "belajarGo2/repository/organization"
+	outboxRepo "belajarGo2/repository/outbox"
+	roleRepo "belajarGo2/repository/role"
+	signingKeyRepo "belajarGo2/repository/signingkey"
 	userRepo "belajarGo2/repository/user"
+	webhookRepo "belajarGo2/repository/webhook"
+	apiKeyService "belajarGo2/service/apikey"
 	invSvc "belajarGo2/service/inventory"
+	oneTimeTokenService "belajarGo2/service/onetimetoken"
+	organizationService "belajarGo2/service/organization"
+	roleService "belajarGo2/service/role"
+	"belajarGo2/service/signingkey"
 	userService "belajarGo2/service/user"
+	webhookService "belajarGo2/service/webhook"
 	"belajarGo2/util/database"
 	"context"
+	"crypto/aes"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/elastic/go-elasticsearch/v9"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/pobyzaarif/go-cache"
 	cfg "github.com/pobyzaarif/go-config"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
@@ -31,12 +56,68 @@ var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
 type Config struct {
 	// AppHost                 string `env:"APP_HOST"`
 	// AppPort                 string `env:"APP_PORT"`
-	AppVersion              string `env:"APP_VERSION"`
-	AppHost                 string `env:"APP_PHOST"`
-	AppPort                 string `env:"APP_PORT_ECHO_SERVER"`
-	AppDeploymentUrl        string `env:"APP_DEPLOYMENT_URL"`
-	AppEmailVerificationKey string `env:"APP_EMAIL_VERIFICATION_KEY"`
-	AppJWTSecret            string `env:"APP_JWT_SECRET"`
+	AppVersion       string `env:"APP_VERSION"`
+	AppHost          string `env:"APP_PHOST"`
+	AppPort          string `env:"APP_PORT_ECHO_SERVER"`
+	AppDeploymentUrl string `env:"APP_DEPLOYMENT_URL"`
+	// AppOrganizationID receive the inventories stored before the organizations
+	AppOrganizationID       string        `env:"APP_ORGANIZATION_ID" envDefault:"default"`
+	AppEmailVerificationKey string        `env:"APP_EMAIL_VERIFICATION_KEY"`
+	AppJWTSecret            string        `env:"APP_JWT_SECRET"`
+	AppAccessTokenTTL       time.Duration `env:"APP_ACCESS_TOKEN_TTL" envDefault:"15m"`
+	AppRefreshTokenTTL      time.Duration `env:"APP_REFRESH_TOKEN_TTL" envDefault:"168h"`
+
+	// the CIDR of the reverse proxies, X-Forwarded-For is only read from them. Empty use the connection ip
+	AppTrustedProxies []string `env:"APP_TRUSTED_PROXIES" envSeparator:","`
+
+	// HS256 sign with APP_JWT_SECRET, RS256 and EdDSA sign with the rotated keys published in /.well-known/jwks.json
+	AppJWTAlgorithm         string        `env:"APP_JWT_ALGORITHM" envDefault:"HS256"`
+	AppJWTRotationInterval  time.Duration `env:"APP_JWT_ROTATION_INTERVAL" envDefault:"720h"`
+	AppJWTKeyRetention      time.Duration `env:"APP_JWT_KEY_RETENTION" envDefault:"24h"`
+	AppJWTRotationSchedule  string        `env:"APP_JWT_ROTATION_SCHEDULE" envDefault:"@every 1h"`
+	AppJWTKeysEncryptionKey string        `env:"APP_JWT_KEYS_ENCRYPTION_KEY"`
+
+	AppEmailVerificationTTL            time.Duration `env:"APP_EMAIL_VERIFICATION_TTL" envDefault:"5m"`
+	AppEmailVerificationResendInterval time.Duration `env:"APP_EMAIL_VERIFICATION_RESEND_INTERVAL" envDefault:"1m"`
+
+	LoginMaxAttempts      int           `env:"LOGIN_MAX_ATTEMPTS" envDefault:"5"`
+	LoginMaxAttemptsPerIP int           `env:"LOGIN_MAX_ATTEMPTS_PER_IP" envDefault:"20"`
+	LoginAttemptWindow    time.Duration `env:"LOGIN_ATTEMPT_WINDOW" envDefault:"15m"`
+	LoginLockoutDuration  time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"15m"`
+	LoginBaseDelay        time.Duration `env:"LOGIN_BASE_DELAY" envDefault:"250ms"`
+	LoginMaxDelay         time.Duration `env:"LOGIN_MAX_DELAY" envDefault:"4s"`
+
+	RoleRefreshInterval time.Duration `env:"ROLE_REFRESH_INTERVAL" envDefault:"30s"`
+
+	InvitationTTL time.Duration `env:"INVITATION_TTL" envDefault:"72h"`
+
+	AccountDeletionGracePeriod time.Duration `env:"ACCOUNT_DELETION_GRACE_PERIOD" envDefault:"720h"`
+	AccountDeletionSchedule    string        `env:"ACCOUNT_DELETION_SCHEDULE" envDefault:"@every 1h"`
+	AccountDeletionBatchSize   int           `env:"ACCOUNT_DELETION_BATCH_SIZE" envDefault:"100"`
+
+	// the single sign-on is enabled when OIDC_ISSUER_URL is set
+	OIDCIssuerURL     string   `env:"OIDC_ISSUER_URL"`
+	OIDCClientID      string   `env:"OIDC_CLIENT_ID"`
+	OIDCClientSecret  string   `env:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL   string   `env:"OIDC_REDIRECT_URL"`
+	OIDCScopes        []string `env:"OIDC_SCOPES" envSeparator:","`
+	OIDCAutoProvision bool     `env:"OIDC_AUTO_PROVISION" envDefault:"false"`
+	OIDCDefaultRole   string   `env:"OIDC_DEFAULT_ROLE" envDefault:"user"`
+
+	TwoFactorIssuer        string        `env:"TWO_FACTOR_ISSUER" envDefault:"belajarGo"`
+	TwoFactorRequiredRoles []string      `env:"TWO_FACTOR_REQUIRED_ROLES" envSeparator:","`
+	TwoFactorChallengeTTL  time.Duration `env:"TWO_FACTOR_CHALLENGE_TTL" envDefault:"5m"`
+	// AppTOTPEncryptionKey encrypt the TOTP secrets, 16, 24 or 32 bytes
+	AppTOTPEncryptionKey string `env:"APP_TOTP_ENCRYPTION_KEY"`
+
+	PasswordHashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"argon2id"`
+	PasswordMinLength     int    `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	PasswordMaxLength     int    `env:"PASSWORD_MAX_LENGTH" envDefault:"72"`
+	PasswordRequireUpper  bool   `env:"PASSWORD_REQUIRE_UPPER" envDefault:"true"`
+	PasswordRequireLower  bool   `env:"PASSWORD_REQUIRE_LOWER" envDefault:"true"`
+	PasswordRequireDigit  bool   `env:"PASSWORD_REQUIRE_DIGIT" envDefault:"true"`
+	PasswordRequireSymbol bool   `env:"PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+	PasswordDenyListPath  string `env:"PASSWORD_DENY_LIST_PATH"`
 
 	DBDriver        string `env:"DB_DRIVER"`
 	DBMySQLHost     string `env:"DB_MYSQL_HOST"`
@@ -61,6 +142,21 @@ type Config struct {
 	MailjetBasicAuthPassword string `env:"MAILJET_BASIC_AUTH_PASSWORD"`
 	MailjetSenderEmail       string `env:"MAILJET_SENDER_EMAIL"`
 	MailjetSenderName        string `env:"MAILJET_SENDER_NAME"`
+
+	ElasticHost           string `env:"ELASTIC_HOST"`
+	ElasticUser           string `env:"ELASTIC_USER"`
+	ElasticPassword       string `env:"ELASTIC_PASSWORD"`
+	ElasticInventoryIndex string `env:"ELASTIC_INVENTORY_INDEX" envDefault:"inventories"`
+
+	CacheDriver     string `env:"CACHE_DRIVER" envDefault:"memory"`
+	CacheMemorySize int    `env:"CACHE_MEMORY_SIZE" envDefault:"10000"`
+	RedisHost       string `env:"REDIS_HOST"`
+	RedisPort       string `env:"REDIS_PORT"`
+	RedisPassword   string `env:"REDIS_PASSWORD"`
+	RedisDB         int    `env:"REDIS_DB"`
+
+	InventoryCacheItemTTL time.Duration `env:"INVENTORY_CACHE_ITEM_TTL" envDefault:"5m"`
+	InventoryCacheListTTL time.Duration `env:"INVENTORY_CACHE_LIST_TTL" envDefault:"1m"`
 }
 
 func main() {
@@ -96,6 +192,13 @@ func main() {
 	e.HideBanner = true
 	e.HidePort = true
 
+	// the client ip is used by the login protection and the security log
+	ipExtractor, err := customMiddleware.IPExtractor(config.AppTrustedProxies)
+	if err != nil {
+		log.Fatalf("invalid trusted proxies: %v", err)
+	}
+	e.IPExtractor = ipExtractor
+
 	e.Use(middleware.CORS())
 	e.Use(middleware.LoggerWithConfig(
 		middleware.LoggerConfig{
@@ -134,12 +237,58 @@ func main() {
 
 	dbMongo := databaseConfig.GetNoSQLDatabaseConnection()
 
+	// cache
+	var cacheRepo cache.Repository
+	// the token revocations must not be evicted, an evicted revocation reinstate the token
+	var revocationCacheRepo userService.Cache
+	switch config.CacheDriver {
+	case "redis":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     config.RedisHost + ":" + config.RedisPort,
+			Password: config.RedisPassword,
+			DB:       config.RedisDB,
+		})
+		// the go-cache redis repository hide the redis errors, the token revocation must see them to fail closed
+		cacheRepo = cacheRepository.NewRedisRepository(redisClient)
+		revocationCacheRepo = cacheRepo
+	default:
+		memoryCache, err := cache.NewMemoryARCCacheRepository(config.CacheMemorySize)
+		if err != nil {
+			log.Fatal("Failed to create memory cache", err)
+		}
+		cacheRepo = memoryCache
+		revocationCacheRepo = cacheRepository.NewMemoryRepository()
+	}
+
 	// inventory endpoint
-	inventoryMongoRepo := invRepo.NewMongoRepository(dbMongo)
+	inventoryMongoRepo := invRepo.NewMongoRepository(dbMongo, config.AppOrganizationID)
 	inventorySvc := invSvc.NewService(inventoryMongoRepo)
-	// inventoryRepo := invRepo.NewGormRepository(db)
+	// inventoryRepo := invRepo.NewGormRepository(db, config.AppOrganizationID)
 	// inventorySvc := invSvc.NewService(inventoryRepo)
-	inventoryCtrl := invHandler.NewController(logger, inventorySvc)
+
+	// inventory search, every write through the service is synced to elasticsearch
+	es, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{config.ElasticHost},
+		Username:  config.ElasticUser,
+		Password:  config.ElasticPassword,
+	})
+	if err != nil {
+		log.Fatal("Failed to create elasticsearch client", err)
+	}
+	inventoryElasticRepo := invRepo.NewElasticRepository(es, config.ElasticInventoryIndex)
+	if err := inventoryElasticRepo.CreateIndex(); err != nil {
+		logger.Error("Failed to ensure inventory index", slog.Any("err", err.Error()))
+	}
+	inventorySearchSvc := invSvc.NewSearchService(inventoryMongoRepo, inventoryElasticRepo)
+	inventorySvc = invSvc.NewIndexingService(logger, inventorySvc, inventoryElasticRepo)
+
+	inventoryCachedSvc := invSvc.NewCachedService(logger, inventorySvc, cacheRepo, invSvc.CacheConfig{
+		ItemTTL: config.InventoryCacheItemTTL,
+		ListTTL: config.InventoryCacheListTTL,
+	})
+	inventorySvc = inventoryCachedSvc
+
+	inventoryCtrl := invHandler.NewController(logger, inventorySvc, inventorySearchSvc)
 
 	// endpoint
 	// e.GET("/inventory", inventoryCtrl.GetAll)
@@ -163,15 +312,194 @@ func main() {
 	userMongoRepo := userRepo.NewMongoRepository(dbMongo)
 	// userRepo := userRepo.NewGormRepository(db)
 
-	userService := userService.NewService(logger, userMongoRepo, config.AppDeploymentUrl, config.AppJWTSecret, config.AppEmailVerificationKey, mailjetEmail)
+	// user events go through the same outbox as the inventory events
+	outboxMongoRepo := outboxRepo.NewMongoRepository(dbMongo)
+	refreshTokenMongoRepo := userRepo.NewMongoRefreshTokenRepository(dbMongo)
+	sessionMongoRepo := userRepo.NewMongoSessionRepository(dbMongo)
+	invitationMongoRepo := userRepo.NewMongoInvitationRepository(dbMongo)
+	// the tokens of the verification, reset password, email change and invitation links
+	oneTimeTokenSvc := oneTimeTokenService.NewService(logger, oneTimeTokenRepo.NewMongoRepository(dbMongo))
+	// logout are kept in the cache until the access token expire, use redis with more than one instance
+	tokenRevocation := userService.NewTokenRevocation(revocationCacheRepo)
+
+	// roles and their permissions, editable at runtime by the user admins
+	roleSvc := roleService.NewService(logger, roleRepo.NewMongoRepository(dbMongo), roleService.Config{
+		RefreshInterval: config.RoleRefreshInterval,
+	})
+	if err := roleSvc.EnsureDefaults(); err != nil {
+		log.Fatalf("failed to create the default roles: %v", err)
+	}
+	roleCtrl := roleController.NewController(logger, roleSvc)
+
+	// organizations scope the inventories, the role of a member grant its permissions in the organization only
+	organizationSvc := organizationService.NewService(logger, organizationRepo.NewMongoRepository(dbMongo), userMongoRepo, roleSvc, organizationService.Config{})
+	organizationCtrl := organizationController.NewController(logger, organizationSvc)
+
+	// access token signing, the verifiers resolve the key by the kid of the token
+	keyfunc := customMiddleware.HMACKeyfunc(config.AppJWTSecret)
+	userOpts := []userService.Option{}
+	if config.AppJWTAlgorithm != "HS256" {
+		keySet, err := signingkey.NewKeySet(logger, signingKeyRepo.NewMongoRepository(dbMongo), signingkey.Config{
+			Algorithm:        config.AppJWTAlgorithm,
+			RotationInterval: config.AppJWTRotationInterval,
+			Retention:        config.AppJWTKeyRetention,
+			EncryptionKey:    config.AppJWTKeysEncryptionKey,
+		})
+		if err != nil {
+			log.Fatal("Failed to create signing key set", err)
+		}
+		if _, err := keySet.Rotate(); err != nil {
+			log.Fatal("Failed to load signing keys", err)
+		}
+
+		// every instance rotate and reload, so they pick up the keys created by the others
+		var muRotate sync.Mutex
+		c := cron.New()
+		_, err = c.AddFunc(config.AppJWTRotationSchedule, func() {
+			if !muRotate.TryLock() {
+				return
+			}
+			defer muRotate.Unlock()
+
+			if _, err := keySet.Rotate(); err != nil {
+				logger.Error("signing key rotation err", slog.Any("err", err.Error()))
+			}
+		})
+		if err != nil {
+			log.Fatalf("invalid signing key rotation schedule: %v", err)
+		}
+		c.Start()
+		defer c.Stop()
+
+		keyfunc = keySet.Keyfunc
+		userOpts = append(userOpts, userService.WithSigner(keySet))
+		e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+			c.Response().Header().Set("Cache-Control", "public, max-age=300")
+			return c.JSON(http.StatusOK, keySet.JWKS())
+		})
+	}
+
+	var passwordDenyList []string
+	if config.PasswordDenyListPath != "" {
+		passwordDenyList, err = userService.ReadDenyList(config.PasswordDenyListPath)
+		if err != nil {
+			log.Fatalf("failed to read password deny list: %v", err)
+		}
+	}
+
+	if _, err := aes.NewCipher([]byte(config.AppTOTPEncryptionKey)); err != nil {
+		log.Fatal("invalid APP_TOTP_ENCRYPTION_KEY", err)
+	}
+
+	userOpts = append(userOpts,
+		userService.WithRoleValidator(roleSvc),
+		userService.WithEventRepository(outboxMongoRepo),
+		userService.WithRefreshTokenRepository(refreshTokenMongoRepo),
+		userService.WithSessionRepository(sessionMongoRepo),
+		userService.WithInvitationRepository(invitationMongoRepo, config.InvitationTTL),
+		userService.WithOneTimeTokens(oneTimeTokenSvc),
+		userService.WithOrganizations(organizationSvc),
+		userService.WithSecurityEventRepository(userRepo.NewMongoSecurityEventRepository(dbMongo)),
+		userService.WithTokenRevocation(tokenRevocation),
+		userService.WithTokenTTL(config.AppAccessTokenTTL, config.AppRefreshTokenTTL),
+		userService.WithCache(cacheRepo),
+		userService.WithEmailVerification(config.AppEmailVerificationTTL, config.AppEmailVerificationResendInterval),
+		userService.WithLoginProtection(userService.LoginProtectionConfig{
+			MaxAttempts:      config.LoginMaxAttempts,
+			MaxAttemptsPerIP: config.LoginMaxAttemptsPerIP,
+			Window:           config.LoginAttemptWindow,
+			LockoutDuration:  config.LoginLockoutDuration,
+			BaseDelay:        config.LoginBaseDelay,
+			MaxDelay:         config.LoginMaxDelay,
+		}),
+		userService.WithTwoFactor(userService.TwoFactorConfig{
+			Issuer:        config.TwoFactorIssuer,
+			RequiredRoles: config.TwoFactorRequiredRoles,
+			ChallengeTTL:  config.TwoFactorChallengeTTL,
+			EncryptionKey: config.AppTOTPEncryptionKey,
+		}),
+		userService.WithPasswordHash(userService.PasswordHashConfig{
+			Algorithm: config.PasswordHashAlgorithm,
+		}),
+		userService.WithPasswordPolicy(userService.PasswordPolicy{
+			MinLength:     config.PasswordMinLength,
+			MaxLength:     config.PasswordMaxLength,
+			RequireUpper:  config.PasswordRequireUpper,
+			RequireLower:  config.PasswordRequireLower,
+			RequireDigit:  config.PasswordRequireDigit,
+			RequireSymbol: config.PasswordRequireSymbol,
+			DenyList:      passwordDenyList,
+		}),
+	)
+	if config.OIDCIssuerURL != "" {
+		userOpts = append(userOpts, userService.WithOIDC(userService.OIDCConfig{
+			IssuerURL:     config.OIDCIssuerURL,
+			ClientID:      config.OIDCClientID,
+			ClientSecret:  config.OIDCClientSecret,
+			RedirectURL:   config.OIDCRedirectURL,
+			Scopes:        config.OIDCScopes,
+			AutoProvision: config.OIDCAutoProvision,
+			DefaultRole:   config.OIDCDefaultRole,
+		}))
+	}
+	// api keys for scripts, sent in the X-API-Key header instead of a bearer token
+	apiKeyMongoRepo := apiKeyRepo.NewMongoRepository(dbMongo)
+	apiKeySvc := apiKeyService.NewService(logger, apiKeyMongoRepo, userMongoRepo, apiKeyService.Config{})
+	apiKeyCtrl := apiKeyController.NewController(logger, apiKeySvc)
+
+	webhookMongoRepo := webhookRepo.NewMongoRepository(dbMongo)
+	webhookSvc := webhookService.NewService(logger, webhookMongoRepo, webhookService.Config{})
+
+	userOpts = append(userOpts,
+		userService.WithDataExporter("api_keys", apiKeySvc),
+		userService.WithDataExporter("organizations", organizationSvc),
+		userService.WithDataEraser("webhooks", webhookSvc),
+		userService.WithDeletionGracePeriod(config.AccountDeletionGracePeriod),
+	)
+	userService := userService.NewService(logger, userMongoRepo, config.AppDeploymentUrl, config.AppJWTSecret, config.AppEmailVerificationKey, mailjetEmail, userOpts...)
 	userCtrl := userController.NewController(logger, userService)
 
+	// the accounts are anonymized once the deletion grace period is over
+	var muDeletion sync.Mutex
+	deletionCron := cron.New()
+	_, err = deletionCron.AddFunc(config.AccountDeletionSchedule, func() {
+		if !muDeletion.TryLock() {
+			return
+		}
+		defer muDeletion.Unlock()
+
+		anonymized, err := userService.AnonymizeDueAccounts(config.AccountDeletionBatchSize)
+		if err != nil {
+			logger.Error("account deletion err", slog.Any("err", err.Error()), slog.Int("anonymized", anonymized))
+			return
+		}
+
+		if anonymized > 0 {
+			logger.Info("account deletion", slog.Int("anonymized", anonymized))
+		}
+	})
+	if err != nil {
+		log.Fatalf("invalid account deletion schedule: %v", err)
+	}
+	deletionCron.Start()
+	defer deletionCron.Stop()
+
 	// endpoint group user
 	// userEndpoint := e.Group("/users")
 	// userEndpoint.POST("/register", userCtrl.Register)
 	// userEndpoint.POST("/login", userCtrl.Login)
 
-	router.RegisterPath(e, config.AppJWTSecret, inventoryCtrl, userCtrl)
+	// webhook endpoint, deliveries are sent by the outbox relay
+	webhookCtrl := webhookController.NewController(logger, webhookSvc)
+
+	router.RegisterPath(e, keyfunc, tokenRevocation, apiKeySvc, roleSvc, organizationSvc, inventoryCtrl, userCtrl, webhookCtrl, apiKeyCtrl, roleCtrl, organizationCtrl)
+
+	// cache stats, only for the user admins
+	e.GET("/cache/stats", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{"message": "OK", "data": map[string]interface{}{
+			"inventory": inventoryCachedSvc.Stats(),
+		}})
+	}, customMiddleware.JWTMiddleware(keyfunc, tokenRevocation), customMiddleware.PermissionMiddleware(roleSvc, roleService.PermissionUserAdmin))
 
 	// Start server
 	address := config.AppHost + ":" + config.AppPort
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -15,7 +16,75 @@ func forbiddenResponse(c echo.Context) error {
 	return c.JSON(http.StatusForbidden, map[string]interface{}{"message": http.StatusText(http.StatusForbidden)})
 }
 
-func JWTMiddleware(jwtSign string) echo.MiddlewareFunc {
+// TokenRevocation report whether an access token was revoked by a logout, or its session by the user
+type TokenRevocation interface {
+	IsRevoked(jti string, userID string, issuedAt time.Time) (revoked bool, err error)
+	IsSessionRevoked(sessionID string) (revoked bool, err error)
+}
+
+func unauthorizedResponse(c echo.Context) error {
+	return c.JSON(http.StatusUnauthorized, map[string]interface{}{"message": http.StatusText(http.StatusUnauthorized)})
+}
+
+// isRevoked fail closed, a token is refused when the revocation store can't be read.
+// The disabled flag isn't read here: disabling a user revoke all of the user tokens and fail when
+// the revocation can't be written, so the disabled users are refused through the revocation
+func isRevoked(revocation TokenRevocation, claim jwt.MapClaims) bool {
+	if revocation == nil {
+		return false
+	}
+
+	jti, _ := claim["jti"].(string)
+	userID, _ := claim["id"].(string)
+	issuedAt, err := claim.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return true
+	}
+
+	revoked, err := revocation.IsRevoked(jti, userID, issuedAt.Time)
+	if err != nil || revoked {
+		return true
+	}
+
+	sessionID, _ := claim["sid"].(string)
+	revoked, err = revocation.IsSessionRevoked(sessionID)
+	return err != nil || revoked
+}
+
+// IPExtractor read the client ip from the connection, or from X-Forwarded-For when the request come
+// through one of the trusted proxies, given as CIDR. The headers are set by the client otherwise,
+// and the per ip login protection and the security log must not trust them
+func IPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// HMACKeyfunc verify the HS256 tokens signed with the shared jwt secret
+func HMACKeyfunc(jwtSign string) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return []byte(jwtSign), nil
+	}
+}
+
+// JWTMiddleware set id, role, org_id, jti, sid and exp of the access token in the context.
+// keyfunc resolve the verification key, HMACKeyfunc or a signing key set resolving the kid.
+// revocation is optional
+func JWTMiddleware(keyfunc jwt.Keyfunc, revocation TokenRevocation) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			if strings.Contains(c.Request().URL.Path, "/login") {
@@ -31,23 +100,11 @@ func JWTMiddleware(jwtSign string) echo.MiddlewareFunc {
 			}
 
 			claim := jwt.MapClaims{}
-			token, err := jwt.ParseWithClaims(signature[1], claim, func(t *jwt.Token) (interface{}, error) {
-				_, ok := t.Method.(*jwt.SigningMethodHMAC)
-				if !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-				}
-
-				return []byte(jwtSign), nil
-			})
+			_, err := jwt.ParseWithClaims(signature[1], claim, keyfunc)
 			if err != nil {
 				return forbiddenResponse(c)
 			}
 
-			method, ok := token.Method.(*jwt.SigningMethodHMAC)
-			if !ok || method != jwt.SigningMethodHS256 {
-				return forbiddenResponse(c)
-			}
-
 			expAt, err := claim.GetExpirationTime()
 			if err != nil {
 				return forbiddenResponse(c)
@@ -57,10 +114,56 @@ func JWTMiddleware(jwtSign string) echo.MiddlewareFunc {
 				return forbiddenResponse(c)
 			}
 
+			if isRevoked(revocation, claim) {
+				return forbiddenResponse(c)
+			}
+
 			userID, _ := claim["id"].(string)
 			role, _ := claim["role"].(string)
+			organizationID, _ := claim["org"].(string)
+			jti, _ := claim["jti"].(string)
+			sessionID, _ := claim["sid"].(string)
+			twoFactorPending, _ := claim["2fa_pending"].(bool)
 			c.Set("id", userID)
 			c.Set("role", role)
+			c.Set("org_id", organizationID)
+			c.Set("jti", jti)
+			c.Set("sid", sessionID)
+			c.Set("exp", expAt.Time)
+			c.Set("2fa_pending", twoFactorPending)
+
+			return next(c)
+		}
+	}
+}
+
+// APIKeyAuthenticator resolve an X-API-Key to the owner id and role, and the organization and scopes of the key
+type APIKeyAuthenticator interface {
+	Authenticate(plaintext string) (userID string, role string, organizationID string, scopes []string, err error)
+}
+
+// APIKeyMiddleware accept an X-API-Key header as an alternative to the bearer token checked by jwtMiddleware.
+// The id and role of the key owner and the organization of the key are set like a bearer token, and the scopes of the key
+// are set to restrict the permissions checked by PermissionMiddleware
+func APIKeyMiddleware(apiKeys APIKeyAuthenticator, jwtMiddleware echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtMiddleware(next)
+
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get("X-API-Key")
+			if key == "" {
+				return withJWT(c)
+			}
+
+			userID, role, organizationID, scopes, err := apiKeys.Authenticate(key)
+			if err != nil {
+				return forbiddenResponse(c)
+			}
+
+			c.Set("id", userID)
+			c.Set("role", role)
+			c.Set("org_id", organizationID)
+			c.Set("scopes", scopes)
 
 			return next(c)
 		}
@@ -94,25 +197,113 @@ func stringSliceContains(a []string, x string) bool {
 // 	}
 // }
 
-func ACLMiddleware(rolesMap map[string]bool) echo.MiddlewareFunc {
+// TwoFactorMiddleware refuse the tokens of a role which require 2FA when the login didn't use it,
+// the user can still reach the 2FA enrollment endpoints
+func TwoFactorMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if pending, _ := c.Get("2fa_pending").(bool); pending {
+				return forbiddenResponse(c)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Authorizer resolve the permissions of a role, role.Service read them from the roles stored in the database
+type Authorizer interface {
+	HasPermission(role string, permission string) (allowed bool, err error)
+}
+
+// PermissionMiddleware refuse the requests when the role doesn't have the permission, or when the
+// request use an api key without the permission in its scopes. It also apply TwoFactorMiddleware,
+// every permission check need the 2FA required by the role
+func PermissionMiddleware(authorizer Authorizer, permission string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if pending, _ := c.Get("2fa_pending").(bool); pending {
+				return forbiddenResponse(c)
+			}
+
+			// bearer token requests have no scopes
+			scopes, ok := c.Get("scopes").([]string)
+			if ok && !stringSliceContains(scopes, permission) {
+				return forbiddenResponse(c)
+			}
+
 			role, _ := c.Get("role").(string)
-			if role == "superadmin" {
-				return next(c)
+			allowed, err := authorizer.HasPermission(role, permission)
+			if err != nil || !allowed {
+				return forbiddenResponse(c)
 			}
 
-			if rolesMap[role] {
-				return next(c)
+			return next(c)
+		}
+	}
+}
+
+// MembershipResolver resolve the role of a user in an organization, it is empty when the user is not a member
+type MembershipResolver interface {
+	MemberRole(organizationID string, userID string) (role string, err error)
+}
+
+// OrganizationPermissionMiddleware is PermissionMiddleware for the routes scoped by the organization of the
+// request, the permission is checked against the role of the user in the organization instead of the
+// global role. The membership is resolved on every request so a removed member is refused at once.
+// The role in the organization is set in the context as org_role
+func OrganizationPermissionMiddleware(memberships MembershipResolver, authorizer Authorizer, permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if pending, _ := c.Get("2fa_pending").(bool); pending {
+				return forbiddenResponse(c)
+			}
+
+			scopes, ok := c.Get("scopes").([]string)
+			if ok && !stringSliceContains(scopes, permission) {
+				return forbiddenResponse(c)
+			}
+
+			userID, _ := c.Get("id").(string)
+			organizationID, _ := c.Get("org_id").(string)
+			if organizationID == "" {
+				return forbiddenResponse(c)
+			}
+
+			role, err := memberships.MemberRole(organizationID, userID)
+			if err != nil || role == "" {
+				return forbiddenResponse(c)
 			}
 
-			return forbiddenResponse(c)
+			allowed, err := authorizer.HasPermission(role, permission)
+			if err != nil || !allowed {
+				return forbiddenResponse(c)
+			}
+
+			c.Set("org_role", role)
+			return next(c)
 		}
 	}
 }
 
-func JwtEchoMiddleware(jwtSign string) echo.MiddlewareFunc {
-	return echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(jwtSign),
+func JwtEchoMiddleware(keyfunc jwt.Keyfunc, revocation TokenRevocation) echo.MiddlewareFunc {
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
+		KeyFunc: keyfunc,
 	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtMiddleware(func(c echo.Context) error {
+			token, ok := c.Get("user").(*jwt.Token)
+			if !ok {
+				return unauthorizedResponse(c)
+			}
+
+			claim, ok := token.Claims.(jwt.MapClaims)
+			if !ok || isRevoked(revocation, claim) {
+				return unauthorizedResponse(c)
+			}
+
+			return next(c)
+		})
+	}
 }
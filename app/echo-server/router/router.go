@@ -1,58 +1,157 @@
 package router
 
 import (
+	"belajarGo2/app/echo-server/controller/apikey"
 	"belajarGo2/app/echo-server/controller/inventory"
+	"belajarGo2/app/echo-server/controller/organization"
+	"belajarGo2/app/echo-server/controller/role"
 	"belajarGo2/app/echo-server/controller/user"
+	"belajarGo2/app/echo-server/controller/webhook"
 	"belajarGo2/app/echo-server/middleware"
+	roleService "belajarGo2/service/role"
 	"net/http"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterPath(e *echo.Echo, jwtSecret string, ctrlInv *inventory.Controller, ctrlUser *user.Controller) {
+func RegisterPath(e *echo.Echo, keyfunc jwt.Keyfunc, revocation middleware.TokenRevocation, apiKeys middleware.APIKeyAuthenticator, authorizer middleware.Authorizer, memberships middleware.MembershipResolver, ctrlInv *inventory.Controller, ctrlUser *user.Controller, ctrlWebhook *webhook.Controller, ctrlAPIKey *apikey.Controller, ctrlRole *role.Controller, ctrlOrg *organization.Controller) {
 	e.GET("/ping", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{
 			"meesage": "pong",
 		})
 	})
 
-	jwtMiddleware := middleware.JWTMiddleware(jwtSecret)
+	jwtMiddleware := middleware.JWTMiddleware(keyfunc, revocation)
+	// bearer token or X-API-Key, the permission checked by the routes must also be in the api key scopes
+	authMiddleware := middleware.APIKeyMiddleware(apiKeys, jwtMiddleware)
 	// userNAdmin := middleware.RBACMiddleware([]string{"user", "admin"})
 	// adminOnly := middleware.RBACMiddleware([]string{"admin"})
 	// superadminOnly := middleware.RBACMiddleware([]string{"superadmin"})
 
-	// init ACL
-	userNAdminAccess := middleware.ACLMiddleware(map[string]bool{
-		"admin": true,
-		"user":  true,
-	})
-	adminAccess := middleware.ACLMiddleware(map[string]bool{
-		"admin": true,
-	})
-	superadminAccess := middleware.ACLMiddleware(map[string]bool{
-		"superadmin": true,
-	})
+	// init permissions, granted by the roles stored in the database. The inventory, organization and webhook
+	// permissions are granted by the role of the user in the active organization
+	inventoryRead := middleware.OrganizationPermissionMiddleware(memberships, authorizer, roleService.PermissionInventoryRead)
+	inventoryWrite := middleware.OrganizationPermissionMiddleware(memberships, authorizer, roleService.PermissionInventoryWrite)
+	inventoryDelete := middleware.OrganizationPermissionMiddleware(memberships, authorizer, roleService.PermissionInventoryDelete)
+	organizationAdmin := middleware.OrganizationPermissionMiddleware(memberships, authorizer, roleService.PermissionOrganizationAdmin)
+	webhookAdmin := middleware.OrganizationPermissionMiddleware(memberships, authorizer, roleService.PermissionWebhookAdmin)
+	userAdmin := middleware.PermissionMiddleware(authorizer, roleService.PermissionUserAdmin)
+	securityAudit := middleware.PermissionMiddleware(authorizer, roleService.PermissionSecurityAudit)
+	// a token still waiting for the 2FA required by its role only reach the logout and the 2FA endpoints
+	twoFactor := middleware.TwoFactorMiddleware()
 
 	// user endpoint
 	userEndpoint := e.Group("/users")
 	userEndpoint.POST("/register", ctrlUser.Register)
 	userEndpoint.POST("/login", ctrlUser.Login)
+	userEndpoint.POST("/login/2fa", ctrlUser.LoginTwoFactor)
+	userEndpoint.GET("/oidc/login", ctrlUser.OIDCLogin)
+	userEndpoint.GET("/oidc/callback", ctrlUser.OIDCCallback)
+	userEndpoint.POST("/token/refresh", ctrlUser.RefreshToken)
+	userEndpoint.GET("/email-verification/:code", ctrlUser.VerifyEmail)
+	userEndpoint.POST("/email-verification/resend", ctrlUser.ResendVerification)
+	userEndpoint.GET("/email-change/:code", ctrlUser.ConfirmEmailChange)
+	userEndpoint.POST("/password/forgot", ctrlUser.ForgotPassword)
+	userEndpoint.GET("/password/reset", ctrlUser.ResetPasswordForm)
+	userEndpoint.POST("/password/reset", ctrlUser.ResetPassword)
+	userEndpoint.GET("/invitations/accept", ctrlUser.AcceptInvitationForm)
+	userEndpoint.POST("/invitations/accept", ctrlUser.AcceptInvitation)
+	userEndpoint.POST("/logout", ctrlUser.Logout, jwtMiddleware)
+	userEndpoint.POST("/logout/all", ctrlUser.LogoutAll, jwtMiddleware)
+	userEndpoint.GET("/me", ctrlUser.GetMe, jwtMiddleware, twoFactor)
+	userEndpoint.PATCH("/me", ctrlUser.UpdateMe, jwtMiddleware, twoFactor)
+	userEndpoint.DELETE("/me", ctrlUser.DeleteMe, jwtMiddleware, twoFactor)
+	userEndpoint.POST("/me/deletion/cancel", ctrlUser.CancelDeletion, jwtMiddleware, twoFactor)
+	userEndpoint.GET("/me/export", ctrlUser.ExportData, jwtMiddleware, twoFactor)
+	userEndpoint.POST("/me/password", ctrlUser.ChangePassword, jwtMiddleware, twoFactor)
+	userEndpoint.POST("/me/email", ctrlUser.RequestEmailChange, jwtMiddleware, twoFactor)
+	userEndpoint.GET("/me/sessions", ctrlUser.GetSessions, jwtMiddleware, twoFactor)
+	userEndpoint.DELETE("/me/sessions/:id", ctrlUser.RevokeSession, jwtMiddleware, twoFactor)
+	userEndpoint.GET("/me/organizations", ctrlOrg.GetMine, jwtMiddleware, twoFactor)
+	userEndpoint.POST("/me/organizations/:id/switch", ctrlUser.SwitchOrganization, jwtMiddleware, twoFactor)
+
+	// 2FA endpoint, reachable with a token still waiting for the 2FA required by its role
+	twoFactorEndpoint := e.Group("/users/me/2fa", jwtMiddleware)
+	twoFactorEndpoint.POST("/enroll", ctrlUser.EnrollTwoFactor)
+	twoFactorEndpoint.POST("/confirm", ctrlUser.ConfirmTwoFactor)
+	twoFactorEndpoint.POST("/disable", ctrlUser.DisableTwoFactor)
+
+	// api key endpoint, an api key can't manage the api keys
+	apiKeyEndpoint := e.Group("/users/me/api-keys", jwtMiddleware, middleware.TwoFactorMiddleware())
+	apiKeyEndpoint.POST("", ctrlAPIKey.Create)
+	apiKeyEndpoint.GET("", ctrlAPIKey.GetAll)
+	apiKeyEndpoint.DELETE("/:id", ctrlAPIKey.Revoke)
+
+	// admin user management endpoint
+	adminUserEndpoint := e.Group("/admin/users", jwtMiddleware, userAdmin)
+	adminUserEndpoint.GET("", ctrlUser.AdminGetAll)
+	adminUserEndpoint.GET("/:id", ctrlUser.AdminGetByID)
+	adminUserEndpoint.PUT("/:id/role", ctrlUser.AdminChangeRole)
+	adminUserEndpoint.POST("/:id/disable", ctrlUser.AdminDisable)
+	adminUserEndpoint.POST("/:id/enable", ctrlUser.AdminEnable)
+	adminUserEndpoint.DELETE("/:id", ctrlUser.AdminDelete)
+	adminUserEndpoint.POST("/:id/2fa/reset", ctrlUser.AdminResetTwoFactor)
 
-	// inventory endpoint
-	inventoryEndpoint := e.Group("/inventories", jwtMiddleware)
+	// admin invitation endpoint
+	adminInvitationEndpoint := e.Group("/admin/invitations", jwtMiddleware, userAdmin)
+	adminInvitationEndpoint.POST("", ctrlUser.AdminInvite)
+	adminInvitationEndpoint.GET("", ctrlUser.AdminGetInvitations)
+	adminInvitationEndpoint.POST("/:id/resend", ctrlUser.AdminResendInvitation)
+	adminInvitationEndpoint.DELETE("/:id", ctrlUser.AdminRevokeInvitation)
+
+	// security log endpoint
+	adminSecurityEndpoint := e.Group("/admin/security-events", jwtMiddleware, securityAudit)
+	adminSecurityEndpoint.GET("", ctrlUser.AdminGetSecurityEvents)
+	adminSecurityEndpoint.GET("/export", ctrlUser.AdminExportSecurityEvents)
+
+	// admin role endpoint
+	adminRoleEndpoint := e.Group("/admin/roles", jwtMiddleware, userAdmin)
+	adminRoleEndpoint.GET("", ctrlRole.GetAll)
+	adminRoleEndpoint.GET("/permissions", ctrlRole.GetPermissions)
+	adminRoleEndpoint.POST("", ctrlRole.Create)
+	adminRoleEndpoint.GET("/:name", ctrlRole.GetByName)
+	adminRoleEndpoint.PUT("/:name", ctrlRole.Update)
+	adminRoleEndpoint.DELETE("/:name", ctrlRole.Delete)
+
+	// admin organization endpoint
+	adminOrganizationEndpoint := e.Group("/admin/organizations", jwtMiddleware, userAdmin)
+	adminOrganizationEndpoint.POST("", ctrlOrg.Create)
+	adminOrganizationEndpoint.GET("", ctrlOrg.GetAll)
+	adminOrganizationEndpoint.GET("/:id", ctrlOrg.GetByID)
+
+	// member endpoint of the active organization
+	organizationEndpoint := e.Group("/organization/members", jwtMiddleware, organizationAdmin)
+	organizationEndpoint.GET("", ctrlOrg.GetMembers)
+	organizationEndpoint.POST("", ctrlOrg.AddMember)
+	organizationEndpoint.PUT("/:userId/role", ctrlOrg.ChangeMemberRole)
+	organizationEndpoint.DELETE("/:userId", ctrlOrg.RemoveMember)
+
+	// inventory endpoint, scoped by the active organization of the token or the organization of the api key
+	inventoryEndpoint := e.Group("/inventories", authMiddleware)
 	// inventoryEndpoint.GET("", ctrlInv.GetAll, userNAdmin)
 	// inventoryEndpoint.GET("/:code", ctrlInv.GetByCode, userNAdmin)
 	// inventoryEndpoint.POST("", ctrlInv.Create, adminOnly)
 	// inventoryEndpoint.PUT("/:code", ctrlInv.Update, adminOnly)
 	// inventoryEndpoint.DELETE("/:code", ctrlInv.Delete, superadminOnly)
-	inventoryEndpoint.GET("", ctrlInv.GetAll, userNAdminAccess)
-	inventoryEndpoint.GET("/:code", ctrlInv.GetByCode, userNAdminAccess)
-	inventoryEndpoint.POST("", ctrlInv.Create, adminAccess)
-	inventoryEndpoint.PUT("/:code", ctrlInv.Update, adminAccess)
-	inventoryEndpoint.DELETE("/:code", ctrlInv.Delete, superadminAccess)
+	inventoryEndpoint.GET("", ctrlInv.GetAll, inventoryRead)
+	inventoryEndpoint.GET("/search", ctrlInv.Search, inventoryRead)
+	inventoryEndpoint.GET("/:code", ctrlInv.GetByCode, inventoryRead)
+	inventoryEndpoint.POST("", ctrlInv.Create, inventoryWrite)
+	inventoryEndpoint.PUT("/:code", ctrlInv.Update, inventoryWrite)
+	inventoryEndpoint.DELETE("/:code", ctrlInv.Delete, inventoryDelete)
+
+	// webhook endpoint
+	webhookEndpoint := e.Group("/webhooks", authMiddleware, webhookAdmin)
+	webhookEndpoint.POST("", ctrlWebhook.Create)
+	webhookEndpoint.GET("", ctrlWebhook.GetAll)
+	webhookEndpoint.GET("/:id", ctrlWebhook.GetByID)
+	webhookEndpoint.DELETE("/:id", ctrlWebhook.Delete)
+	webhookEndpoint.GET("/:id/deliveries", ctrlWebhook.GetDeliveries)
+	webhookEndpoint.POST("/deliveries/:deliveryId/redeliver", ctrlWebhook.Redeliver)
 
 	// Explore endpoint
-	echoJWT := middleware.JwtEchoMiddleware(jwtSecret)
+	echoJWT := middleware.JwtEchoMiddleware(keyfunc, revocation)
 	exploreEndpoint := e.Group("/explore", echoJWT)
 	exploreEndpoint.GET("/rafly", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, echo.Map{"message": "testing"})
@@ -0,0 +1,153 @@
+package router_test
+
+import (
+	"belajarGo2/app/echo-server/controller/user"
+	"belajarGo2/app/echo-server/middleware"
+	"belajarGo2/app/echo-server/router"
+	mock_notification "belajarGo2/service/notification/mock"
+	userService "belajarGo2/service/user"
+	mock_user "belajarGo2/service/user/mock"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/pobyzaarif/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+func newEcho(userSvc userService.Service) *echo.Echo {
+	e := echo.New()
+	router.RegisterPath(e, middleware.HMACKeyfunc("secret"), nil, nil, nil, nil, nil, user.NewController(logger, userSvc), nil, nil, nil, nil)
+	return e
+}
+
+// the links sent by email are opened by a browser, so they must be GET routes
+func TestEmailLinksOpenAForm(t *testing.T) {
+	e := newEcho(nil)
+
+	for _, path := range []string{userService.ResetPasswordPath, userService.InvitationAcceptPath} {
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?code=abc%22def", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), `action="`+path+`"`)
+			// the code is escaped in the form
+			assert.Contains(t, rec.Body.String(), `value="abc&#34;def"`)
+		})
+	}
+}
+
+func TestForgedForwardedForDoNotResetTheIPCounter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	require.NoError(t, err)
+
+	userSvc := userService.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification.NewMockRepository(ctrl),
+		userService.WithCache(memoryCache),
+		userService.WithLoginProtection(userService.LoginProtectionConfig{
+			MaxAttempts:      10,
+			MaxAttemptsPerIP: 2,
+			BaseDelay:        time.Millisecond,
+			MaxDelay:         time.Millisecond,
+		}),
+	)
+	mock_userRepo.EXPECT().GetByEmail(gomock.Any()).Return(userService.User{}, nil).Times(2)
+
+	e := newEcho(userSvc)
+	extractor, err := middleware.IPExtractor(nil)
+	require.NoError(t, err)
+	e.IPExtractor = extractor
+
+	login := func(i int) int {
+		req := httptest.NewRequest(http.MethodPost, "/users/login", strings.NewReader(fmt.Sprintf(`{"email":"user%d@mail.com","password":"wrong"}`, i)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		// a new ip on every attempt
+		req.Header.Set(echo.HeaderXForwardedFor, fmt.Sprintf("198.51.100.%d", i))
+		req.Header.Set(echo.HeaderXRealIP, fmt.Sprintf("198.51.100.%d", i))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.NotEqual(t, http.StatusTooManyRequests, login(1))
+	assert.NotEqual(t, http.StatusTooManyRequests, login(2))
+	// the attempts are counted on the connection ip, the headers are ignored
+	assert.Equal(t, http.StatusTooManyRequests, login(3))
+}
+
+func TestIPExtractor(t *testing.T) {
+	_, err := middleware.IPExtractor([]string{"10.0.0.1"})
+	assert.Error(t, err)
+
+	extractor, err := middleware.IPExtractor([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	fromProxy := httptest.NewRequest(http.MethodGet, "/", nil)
+	fromProxy.RemoteAddr = "10.1.2.3:1234"
+	fromProxy.Header.Set(echo.HeaderXForwardedFor, "203.0.113.9")
+	assert.Equal(t, "203.0.113.9", extractor(fromProxy))
+
+	// not a trusted proxy, the header is ignored
+	direct := httptest.NewRequest(http.MethodGet, "/", nil)
+	direct.RemoteAddr = "192.0.2.1:1234"
+	direct.Header.Set(echo.HeaderXForwardedFor, "203.0.113.9")
+	assert.Equal(t, "192.0.2.1", extractor(direct))
+}
+
+// the role of the user require 2FA and the user didn't enroll yet
+func TestTwoFactorPendingTokenOnlyReachTheEnrollment(t *testing.T) {
+	e := newEcho(nil)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":          "user-1",
+		"role":        "admin",
+		"2fa_pending": true,
+		"iat":         time.Now().Unix(),
+		"exp":         time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/users/me"},
+		{http.MethodPatch, "/users/me"},
+		{http.MethodDelete, "/users/me"},
+		{http.MethodPost, "/users/me/deletion/cancel"},
+		{http.MethodGet, "/users/me/export"},
+		{http.MethodPost, "/users/me/password"},
+		{http.MethodPost, "/users/me/email"},
+		{http.MethodGet, "/users/me/sessions"},
+		{http.MethodDelete, "/users/me/sessions/session-1"},
+		{http.MethodGet, "/users/me/organizations"},
+		{http.MethodPost, "/users/me/organizations/org-1/switch"},
+		{http.MethodGet, "/users/me/api-keys"},
+	} {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		})
+	}
+}
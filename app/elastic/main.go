@@ -1,190 +1,89 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"time"
+	invRepo "belajarGo2/repository/inventory"
+	organizationRepo "belajarGo2/repository/organization"
+	invSvc "belajarGo2/service/inventory"
+	"belajarGo2/util/database"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/elastic/go-elasticsearch/v9"
-	"github.com/google/uuid"
 	cfg "github.com/pobyzaarif/go-config"
 )
 
-type Config struct {
-	ElasticHost     string `env:"ELASTIC_HOST"`
-	ElasticUser     string `env:"ELASTIC_USER"`
-	ElasticPassword string `env:"ELASTIC_PASSWORD"`
-}
-
-var esIndex = "default-index"
-
-func main() {
-	config := Config{}
-	cfg.LoadConfig(config)
-
-	cfgElastic := elasticsearch.Config{
-		Addresses: []string{
-			config.ElasticHost,
-		},
-		Username: config.ElasticUser,
-		Password: config.ElasticPassword,
-	}
-	es, err := elasticsearch.NewClient(cfgElastic)
-	if err != nil {
-		panic(err)
-	}
-
-	i, err := es.Info()
-	if err != nil {
-		panic(err)
-	}
-
-	spew.Dump(i)
-
-	// create index
-	err = createIndex(es, esIndex)
-	if err != nil {
-		panic(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// Insert new document
-	docID := uuid.NewString()
-	data := map[string]interface{}{
-		"id":      docID,
-		"title":   "test title",
-		"content": "test content",
-	}
-	err = insertDocument(es, esIndex, data)
-	if err != nil {
-		panic(err)
-	}
-
-	time.Sleep(1 * time.Second)
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
 
-	// get document by id
-	getRes, err := getDocument(es, esIndex, docID)
-	if err != nil {
-		panic(err)
-	}
-	spew.Dump(getRes)
-
-	time.Sleep(1 * time.Second)
-
-	// update document by id
-	updatedFields := map[string]interface{}{
-		"title": "update test title",
-	}
-	err = updateDocument(es, esIndex, docID, updatedFields)
-	if err != nil {
-		panic(err)
-	}
-
-	time.Sleep(1 * time.Second)
-
-	// Get updated document by ID
-	getRes, err = getDocument(es, esIndex, docID)
-	if err != nil {
-		panic(err)
-	}
-	spew.Dump(getRes)
-
-	time.Sleep(1 * time.Second)
-
-	// Delete document by ID
-	err = deleteDocument(es, esIndex, docID)
-	if err != nil {
-		panic(err)
-	}
+type Config struct {
+	ElasticHost           string `env:"ELASTIC_HOST"`
+	ElasticUser           string `env:"ELASTIC_USER"`
+	ElasticPassword       string `env:"ELASTIC_PASSWORD"`
+	ElasticInventoryIndex string `env:"ELASTIC_INVENTORY_INDEX" envDefault:"inventories"`
 
-	time.Sleep(1 * time.Second)
+	// AppOrganizationID receive the inventories stored before the organizations
+	AppOrganizationID string `env:"APP_ORGANIZATION_ID" envDefault:"default"`
 
-	// Try to get deleted document by ID
-	getRes, err = getDocument(es, esIndex, docID)
-	if err != nil {
-		panic(err)
-	}
-	spew.Dump(getRes)
-}
-
-func createIndex(es *elasticsearch.Client, indexName string) error {
-	res, err := es.Indices.Create(indexName)
-	if err != nil {
-		return err
-	}
-	return res.Body.Close()
+	DBMongoURI  string `env:"DB_MONGO_URI"`
+	DBMongoName string `env:"DB_MONGO_NAME"`
 }
 
-func insertDocument(es *elasticsearch.Client, indexName string, document map[string]interface{}) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(document); err != nil {
-		return err
-	}
-
-	res, err := es.Index(
-		indexName,
-		&buf,
-		es.Index.WithRefresh("true"),
-		es.Index.WithDocumentID(document["id"].(string)),
-	)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	return nil
-}
+// Full reindex of the inventories of every organization, the index is dropped and rebuilt from the database:
+//
+//	go run app/elastic/main.go
+//	go run app/elastic/main.go -keep-index -batch 1000
+func main() {
+	keepIndex := flag.Bool("keep-index", false, "index into the existing index instead of recreating it")
+	batchSize := flag.Int("batch", 500, "number of inventories per bulk request")
+	flag.Parse()
 
-func getDocument(es *elasticsearch.Client, indexName string, documentID string) (map[string]interface{}, error) {
-	var getRes map[string]interface{}
-	res, err := es.Get(indexName, documentID)
+	config := Config{}
+	cfg.LoadConfig(&config)
+	logger.Info("Config loaded")
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{config.ElasticHost},
+		Username:  config.ElasticUser,
+		Password:  config.ElasticPassword,
+	})
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
-	defer res.Body.Close()
 
-	if err := json.NewDecoder(res.Body).Decode(&getRes); err != nil {
-		return nil, err
+	databaseConfig := database.Config{
+		DBMongoURI:  config.DBMongoURI,
+		DBMongoName: config.DBMongoName,
 	}
+	dbMongo := databaseConfig.GetNoSQLDatabaseConnection()
+	logger.Info("Database client connected!")
 
-	return getRes, nil
-}
-
-func updateDocument(es *elasticsearch.Client, indexName string, documentID string, updatedFields map[string]interface{}) error {
-	updateBody := map[string]interface{}{
-		"doc": updatedFields,
+	inventoryElasticRepo := invRepo.NewElasticRepository(es, config.ElasticInventoryIndex)
+	if *keepIndex {
+		err = inventoryElasticRepo.CreateIndex()
+	} else {
+		err = inventoryElasticRepo.RecreateIndex()
 	}
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(updateBody); err != nil {
-		return err
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	res, err := es.Update(
-		indexName,
-		documentID,
-		&buf,
-		es.Update.WithRefresh("true"),
-	)
+	orgs, err := organizationRepo.NewMongoRepository(dbMongo).GetAll()
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
-	defer res.Body.Close()
 
-	return nil
-}
-
-func deleteDocument(es *elasticsearch.Client, indexName string, documentID string) error {
-	res, err := es.Delete(
-		indexName,
-		documentID,
-		es.Delete.WithRefresh("true"),
-	)
-	if err != nil {
-		return err
+	inventorySearchSvc := invSvc.NewSearchService(invRepo.NewMongoRepository(dbMongo, config.AppOrganizationID), inventoryElasticRepo)
+	total := 0
+	for _, org := range orgs {
+		indexed, err := inventorySearchSvc.Reindex(org.ID, *batchSize)
+		total += indexed
+		if err != nil {
+			logger.Error("Reindex failed", slog.String("organization_id", org.ID), slog.Int("indexed", total), slog.Any("err", err.Error()))
+			os.Exit(1)
+		}
 	}
-	defer res.Body.Close()
 
-	return nil
+	logger.Info("Reindex done", slog.Int("indexed", total), slog.Int("organizations", len(orgs)), slog.String("index", config.ElasticInventoryIndex))
 }
@@ -1,12 +1,22 @@
 package inventory
 
 import (
+	"belajarGo2/service/role"
 	"context"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// MethodPermissions is the permission a bearer token role need to call each method
+var MethodPermissions = map[string]string{
+	InventoryService_Create_FullMethodName: role.PermissionInventoryWrite,
+	InventoryService_Get_FullMethodName:    role.PermissionInventoryRead,
+	InventoryService_List_FullMethodName:   role.PermissionInventoryRead,
+	InventoryService_Update_FullMethodName: role.PermissionInventoryWrite,
+	InventoryService_Delete_FullMethodName: role.PermissionInventoryDelete,
+}
+
 type inventoryServiceServer struct {
 	UnimplementedInventoryServiceServer
 }
@@ -6,9 +6,14 @@ import (
 	"net"
 	"os"
 	"strings"
+	"time"
 
 	pb "belajarGo2/app/grpc-server/controller/inventory"
 	"belajarGo2/app/grpc-server/middleware"
+	roleRepo "belajarGo2/repository/role"
+	"belajarGo2/service/role"
+	"belajarGo2/service/signingkey"
+	"belajarGo2/util/database"
 
 	cfg "github.com/pobyzaarif/go-config"
 
@@ -21,6 +26,14 @@ var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
 type Config struct {
 	AppPort      string `env:"APP_PORT_GRPC_SERVER"`
 	AppBasicAuth string `env:"APP_BASIC_AUTH"`
+	// the access tokens are verified with the keys published by the echo server
+	JWKSURL               string `env:"JWKS_URL"`
+	EndpointURLEchoServer string `env:"ENDPOINT_URL_ECHO_SERVER"`
+
+	// the roles are read from the database of the echo server
+	DBMongoURI          string        `env:"DB_MONGO_URI"`
+	DBMongoName         string        `env:"DB_MONGO_NAME"`
+	RoleRefreshInterval time.Duration `env:"ROLE_REFRESH_INTERVAL" envDefault:"30s"`
 }
 
 func main() {
@@ -36,6 +49,20 @@ func main() {
 		basicAuthMap[basicAuthPair[0]] = basicAuthPair[1]
 	}
 
+	jwksURL := config.JWKSURL
+	if jwksURL == "" {
+		jwksURL = config.EndpointURLEchoServer + "/.well-known/jwks.json"
+	}
+	remoteKeySet := signingkey.NewRemoteKeySet(jwksURL, nil)
+
+	databaseConfig := database.Config{
+		DBMongoURI:  config.DBMongoURI,
+		DBMongoName: config.DBMongoName,
+	}
+	roleSvc := role.NewService(logger, roleRepo.NewMongoRepository(databaseConfig.GetNoSQLDatabaseConnection()), role.Config{
+		RefreshInterval: config.RoleRefreshInterval,
+	})
+
 	// Listen grpc with port from config
 	lis, err := net.Listen("tcp", ":"+config.AppPort)
 	if err != nil {
@@ -46,8 +73,14 @@ func main() {
 	// grpcServer := grpc.NewServer()
 
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(middleware.BasicAuthUnaryInterceptor(basicAuthMap)),
-		grpc.StreamInterceptor(middleware.BasicAuthStreamInterceptor(basicAuthMap)),
+		grpc.ChainUnaryInterceptor(
+			middleware.AuthUnaryInterceptor(basicAuthMap, remoteKeySet.Keyfunc),
+			middleware.PermissionUnaryInterceptor(roleSvc, pb.MethodPermissions),
+		),
+		grpc.ChainStreamInterceptor(
+			middleware.AuthStreamInterceptor(basicAuthMap, remoteKeySet.Keyfunc),
+			middleware.PermissionStreamInterceptor(roleSvc, pb.MethodPermissions),
+		),
 	)
 
 	// Register the service implementation
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"strings"
 
+	"github.com/golang-jwt/jwt/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -70,3 +71,119 @@ func checkBasicAuthAgainstMap(ctx context.Context, allowed map[string]string) bo
 	}
 	return false
 }
+
+type claimsContextKey struct{}
+
+// ClaimsFromContext return the access token claims of a request authenticated with a bearer token
+func ClaimsFromContext(ctx context.Context) (claims jwt.MapClaims, ok bool) {
+	claims, ok = ctx.Value(claimsContextKey{}).(jwt.MapClaims)
+	return
+}
+
+// AuthUnaryInterceptor accept a bearer access token, verified with the key of its kid, or the basic auth credentials
+func AuthUnaryInterceptor(allowed map[string]string, keyfunc jwt.Keyfunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, ok := authenticate(ctx, allowed, keyfunc)
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "unauthenticated")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthStreamInterceptor(allowed map[string]string, keyfunc jwt.Keyfunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, ok := authenticate(ss.Context(), allowed, keyfunc)
+		if !ok {
+			return status.Errorf(codes.Unauthenticated, "unauthenticated")
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, allowed map[string]string, keyfunc jwt.Keyfunc) (context.Context, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, false
+	}
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return ctx, false
+	}
+
+	parts := strings.SplitN(vals[0], " ", 2)
+	if len(parts) != 2 || strings.ToLower(strings.TrimSpace(parts[0])) != "bearer" {
+		return ctx, len(allowed) > 0 && checkBasicAuthAgainstMap(ctx, allowed)
+	}
+	if keyfunc == nil {
+		return ctx, false
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(strings.TrimSpace(parts[1]), claims, keyfunc, jwt.WithExpirationRequired()); err != nil {
+		return ctx, false
+	}
+	return context.WithValue(ctx, claimsContextKey{}, claims), true
+}
+
+// Authorizer resolve the permissions of a role, role.Service is the policy also used by the echo server
+type Authorizer interface {
+	HasPermission(role string, permission string) (allowed bool, err error)
+}
+
+// PermissionUnaryInterceptor check the role of the bearer token has the permission of the called method,
+// it must run after AuthUnaryInterceptor. The basic auth clients are trusted services and are not checked,
+// a method missing from permissions is refused
+func PermissionUnaryInterceptor(authorizer Authorizer, permissions map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, authorizer, permissions, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func PermissionStreamInterceptor(authorizer Authorizer, permissions map[string]string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), authorizer, permissions, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, authorizer Authorizer, permissions map[string]string, fullMethod string) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	// the 2FA required by the role was not completed
+	if pending, _ := claims["2fa_pending"].(bool); pending {
+		return status.Errorf(codes.PermissionDenied, "permission denied")
+	}
+
+	permission, ok := permissions[fullMethod]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "permission denied")
+	}
+
+	role, _ := claims["role"].(string)
+	allowed, err := authorizer.HasPermission(role, permission)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "permission check failed")
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "permission denied")
+	}
+	return nil
+}
@@ -15,14 +15,17 @@ import (
 )
 
 type Controller struct {
-	logger       *slog.Logger
-	inventorySvc inventory.Service
+	logger         *slog.Logger
+	inventorySvc   inventory.Service
+	organizationID string
 }
 
-func NewController(logger *slog.Logger, s inventory.Service) *Controller {
+// NewController serve the inventories of a single organization
+func NewController(logger *slog.Logger, s inventory.Service, organizationID string) *Controller {
 	return &Controller{
-		logger:       logger,
-		inventorySvc: s,
+		logger:         logger,
+		inventorySvc:   s,
+		organizationID: organizationID,
 	}
 }
 
@@ -51,7 +54,7 @@ func (c *Controller) Create(w http.ResponseWriter, r *http.Request, _ httprouter
 		return
 	}
 
-	if err := c.inventorySvc.Create(inventory.Inventory{
+	if err := c.inventorySvc.Create(c.organizationID, inventory.Inventory{
 		Code:        req.Code,
 		Name:        req.Name,
 		Stock:       req.Stock,
@@ -95,7 +98,7 @@ func (c *Controller) GetAll(w http.ResponseWriter, r *http.Request, _ httprouter
 		limit = 10
 	}
 
-	invs, err := c.inventorySvc.GetAll(page, limit)
+	invs, err := c.inventorySvc.GetAll(c.organizationID, page, limit)
 	if err != nil {
 		c.logger.Error("inventory.GetAll Error", slog.Any("error", err))
 		// http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -124,7 +127,7 @@ func (c *Controller) GetByCode(w http.ResponseWriter, r *http.Request, p httprou
 		return
 	}
 
-	inv, err := c.inventorySvc.GetByCode(code)
+	inv, err := c.inventorySvc.GetByCode(c.organizationID, code)
 	if err != nil {
 		c.logger.Error("inventory.GetByCode Error", slog.Any("error", err))
 
@@ -166,13 +169,18 @@ func (c *Controller) Update(w http.ResponseWriter, r *http.Request, p httprouter
 		return
 	}
 
-	if err := c.inventorySvc.Update(inventory.Inventory{
+	if err := c.inventorySvc.Update(c.organizationID, inventory.Inventory{
 		Code:        req.Code,
 		Name:        req.Name,
 		Stock:       req.Stock,
 		Description: req.Description,
 		Status:      req.Status,
 	}); err != nil {
+		if errors.Is(err, inventory.ErrNotFound) {
+			common.ErrorDataNotFound(w)
+			return
+		}
+
 		c.logger.Error("inventory.Update error", slog.Any("error", err))
 
 		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
@@ -195,7 +203,12 @@ func (c *Controller) Delete(w http.ResponseWriter, r *http.Request, p httprouter
 		return
 	}
 
-	if err := c.inventorySvc.Delete(code); err != nil {
+	if err := c.inventorySvc.Delete(c.organizationID, code); err != nil {
+		if errors.Is(err, inventory.ErrNotFound) {
+			common.ErrorDataNotFound(w)
+			return
+		}
+
 		c.logger.Error("inventory.Delete Error", slog.Any("error", err))
 
 		common.ErrorInternal(w)
@@ -24,6 +24,8 @@ type Config struct {
 	AppVersion string `env:"APP_VERSION"`
 	AppHost    string `env:"APP_HOST"`
 	AppPort    string `env:"APP_PORT_HTTP_SERVER"`
+	// AppOrganizationID is the organization of every inventory request, this server has no login
+	AppOrganizationID string `env:"APP_ORGANIZATION_ID" envDefault:"default"`
 
 	DBDriver        string `env:"DB_DRIVER"`
 	DBMySQLHost     string `env:"DB_MYSQL_HOST"`
@@ -55,9 +57,9 @@ func main() {
 	logger.Info("Database client connected!")
 
 	// Dependency Injection
-	inventoryRepo := invRepo.NewGormRepository(db)
+	inventoryRepo := invRepo.NewGormRepository(db, config.AppOrganizationID)
 	inventorySvc := invSvc.NewService(inventoryRepo)
-	inventoryCtrl := invCtrl.NewController(logger, inventorySvc)
+	inventoryCtrl := invCtrl.NewController(logger, inventorySvc, config.AppOrganizationID)
 
 	// Setup router
 	router := httprouter.New()
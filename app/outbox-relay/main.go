@@ -0,0 +1,179 @@
+package main
+
+import (
+	organizationRepo "belajarGo2/repository/organization"
+	outboxRepo "belajarGo2/repository/outbox"
+	"belajarGo2/repository/outbox/rabbitmq"
+	webhookRepo "belajarGo2/repository/webhook"
+	outboxSvc "belajarGo2/service/outbox"
+	webhookSvc "belajarGo2/service/webhook"
+	"belajarGo2/util/database"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	cfg "github.com/pobyzaarif/go-config"
+	"github.com/robfig/cron/v3"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+type Config struct {
+	OutboxRelaySchedule  string `env:"OUTBOX_RELAY_SCHEDULE" envDefault:"@every 5s"`
+	OutboxRelayBatchSize int    `env:"OUTBOX_RELAY_BATCH_SIZE" envDefault:"100"`
+
+	WebhookDeliverySchedule  string        `env:"WEBHOOK_DELIVERY_SCHEDULE" envDefault:"@every 5s"`
+	WebhookDeliveryBatchSize int           `env:"WEBHOOK_DELIVERY_BATCH_SIZE" envDefault:"50"`
+	WebhookMaxAttempts       int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	WebhookRetryBackoff      time.Duration `env:"WEBHOOK_RETRY_BACKOFF" envDefault:"30s"`
+
+	RabbitMQURL      string `env:"RABBITMQ_URL"`
+	RabbitMQExchange string `env:"RABBITMQ_EXCHANGE" envDefault:"inventory-events"`
+
+	DBDriver        string `env:"DB_DRIVER"`
+	DBMySQLHost     string `env:"DB_MYSQL_HOST"`
+	DBMySQLPort     string `env:"DB_MYSQL_PORT"`
+	DBMySQLUser     string `env:"DB_MYSQL_USER"`
+	DBMySQLPassword string `env:"DB_MYSQL_PASSWORD"`
+	DBMySQLName     string `env:"DB_MYSQL_NAME"`
+
+	DBSQLiteName string `env:"DB_SQLITE_NAME"`
+
+	DBPostgreSQLHost     string `env:"DB_POSTGRESQL_HOST"`
+	DBPostgreSQLPort     string `env:"DB_POSTGRESQL_PORT"`
+	DBPostgreSQLUser     string `env:"DB_POSTGRESQL_USER"`
+	DBPostgreSQLPassword string `env:"DB_POSTGRESQL_PASSWORD"`
+	DBPostgreSQLName     string `env:"DB_POSTGRESQL_NAME"`
+
+	DBMongoURI  string `env:"DB_MONGO_URI"`
+	DBMongoName string `env:"DB_MONGO_NAME"`
+}
+
+func main() {
+	config := Config{}
+	cfg.LoadConfig(&config)
+	logger.Info("Config loaded")
+
+	databaseConfig := database.Config{
+		DBDriver:             config.DBDriver,
+		DBMySQLHost:          config.DBMySQLHost,
+		DBMySQLPort:          config.DBMySQLPort,
+		DBMySQLUser:          config.DBMySQLUser,
+		DBMySQLPassword:      config.DBMySQLPassword,
+		DBMySQLName:          config.DBMySQLName,
+		DBSQLiteName:         config.DBSQLiteName,
+		DBPostgreSQLHost:     config.DBPostgreSQLHost,
+		DBPostgreSQLPort:     config.DBPostgreSQLPort,
+		DBPostgreSQLUser:     config.DBPostgreSQLUser,
+		DBPostgreSQLPassword: config.DBPostgreSQLPassword,
+		DBPostgreSQLName:     config.DBPostgreSQLName,
+		DBMongoURI:           config.DBMongoURI,
+		DBMongoName:          config.DBMongoName,
+	}
+
+	// the relay must read every outbox the servers write to: the http server writes to the DB_DRIVER database
+	// and the echo server writes to mongo
+	outboxRepos := map[string]outboxSvc.Repository{}
+	var webhookRepository webhookSvc.Repository
+	webhookOpts := []webhookSvc.Option{}
+	switch config.DBDriver {
+	case "mysql", "sqlite", "postgres":
+		db := databaseConfig.GetDatabaseConnection()
+		outboxRepos[config.DBDriver] = outboxRepo.NewGormRepository(db)
+		webhookRepository = webhookRepo.NewGormRepository(db)
+	}
+
+	if config.DBMongoURI != "" {
+		dbMongo := databaseConfig.GetNoSQLDatabaseConnection()
+		outboxRepos["mongo"] = outboxRepo.NewMongoRepository(dbMongo)
+		// the webhook subscriptions and the organizations are managed by the echo server
+		webhookRepository = webhookRepo.NewMongoRepository(dbMongo)
+		webhookOpts = append(webhookOpts, webhookSvc.WithMembershipRepository(organizationRepo.NewMongoRepository(dbMongo)))
+	}
+
+	if len(outboxRepos) == 0 {
+		log.Fatalf("no outbox database: set DB_DRIVER or DB_MONGO_URI")
+	}
+	logger.Info("Database client connected!")
+
+	publisher, err := rabbitmq.NewRabbitMQPublisher(logger, rabbitmq.RabbitMQConfig{
+		RabbitMQURL:      config.RabbitMQURL,
+		RabbitMQExchange: config.RabbitMQExchange,
+	})
+	if err != nil {
+		log.Fatalf("rabbitmq publisher err: %v", err)
+	}
+	defer publisher.Close()
+
+	webhookService := webhookSvc.NewService(logger, webhookRepository, webhookSvc.Config{
+		MaxAttempts:  config.WebhookMaxAttempts,
+		RetryBackoff: config.WebhookRetryBackoff,
+	}, webhookOpts...)
+
+	// every event goes to rabbitmq and is queued for the matching webhook subscriptions
+	relaySvcs := map[string]outboxSvc.Service{}
+	for name, repo := range outboxRepos {
+		relaySvcs[name] = outboxSvc.NewService(logger, repo, outboxSvc.NewMultiPublisher(publisher, webhookService))
+	}
+
+	// skip the tick when the previous relay is still running
+	var mu sync.Mutex
+	c := cron.New()
+	_, err = c.AddFunc(config.OutboxRelaySchedule, func() {
+		if !mu.TryLock() {
+			return
+		}
+		defer mu.Unlock()
+
+		for name, relaySvc := range relaySvcs {
+			published, err := relaySvc.Relay(config.OutboxRelayBatchSize)
+			if err != nil {
+				logger.Error("outbox relay err", slog.String("outbox", name), slog.Any("err", err.Error()), slog.Int("published", published))
+				continue
+			}
+
+			if published > 0 {
+				logger.Info("outbox relay", slog.String("outbox", name), slog.Int("published", published))
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("invalid relay schedule: %v", err)
+	}
+
+	var muWebhook sync.Mutex
+	_, err = c.AddFunc(config.WebhookDeliverySchedule, func() {
+		if !muWebhook.TryLock() {
+			return
+		}
+		defer muWebhook.Unlock()
+
+		delivered, err := webhookService.DeliverDue(config.WebhookDeliveryBatchSize)
+		if err != nil {
+			logger.Error("webhook delivery err", slog.Any("err", err.Error()), slog.Int("delivered", delivered))
+			return
+		}
+
+		if delivered > 0 {
+			logger.Info("webhook delivery", slog.Int("delivered", delivered))
+		}
+	})
+	if err != nil {
+		log.Fatalf("invalid webhook delivery schedule: %v", err)
+	}
+
+	c.Start()
+	logger.Info("Outbox relay running with schedule " + config.OutboxRelaySchedule)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	<-c.Stop().Done()
+	logger.Info("Outbox relay stopped")
+}
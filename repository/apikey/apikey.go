@@ -0,0 +1,47 @@
+package apikey
+
+import (
+	"belajarGo2/service/apikey"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db,
+	}
+}
+
+func (r *GormRepository) apiKeys() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_api_keys")
+}
+
+func (r *GormRepository) Create(key apikey.APIKey) (err error) {
+	return r.apiKeys().Create(&key).Error
+}
+
+func (r *GormRepository) GetByHash(keyHash string) (key apikey.APIKey, err error) {
+	err = r.apiKeys().Where("key_hash = ?", keyHash).Limit(1).Find(&key).Error
+	return
+}
+
+func (r *GormRepository) GetByUserID(userID string) (keys []apikey.APIKey, err error) {
+	err = r.apiKeys().Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return
+}
+
+func (r *GormRepository) Revoke(id string, revokedAt time.Time) (err error) {
+	return r.apiKeys().Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", revokedAt).Error
+}
+
+func (r *GormRepository) UpdateLastUsed(id string, lastUsedAt time.Time) (err error) {
+	return r.apiKeys().Where("id = ?", id).Update("last_used_at", lastUsedAt).Error
+}
@@ -0,0 +1,87 @@
+package apikey
+
+import (
+	"belajarGo2/service/apikey"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createAPIKeyIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "api_key_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+type MongoRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection("api_keys")
+
+	if err := createAPIKeyIndex(col); err != nil {
+		fmt.Println("Error ensuring api key index:", err)
+	}
+
+	return &MongoRepository{
+		col: col,
+	}
+}
+
+func (r *MongoRepository) Create(key apikey.APIKey) (err error) {
+	_, err = r.col.InsertOne(context.Background(), key)
+	return
+}
+
+func (r *MongoRepository) GetByHash(keyHash string) (key apikey.APIKey, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"key_hash": keyHash}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoRepository) GetByUserID(userID string) (keys []apikey.APIKey, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.col.Find(context.Background(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &keys)
+	return
+}
+
+func (r *MongoRepository) Revoke(id string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"api_key_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
+
+func (r *MongoRepository) UpdateLastUsed(id string, lastUsedAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"api_key_id": id},
+		bson.M{"$set": bson.M{"last_used_at": lastUsedAt}},
+	)
+	return
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Set remove the expired entries
+const sweepInterval = time.Minute
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryRepository is a memory cache without size bound, an entry only leave it once expired or deleted.
+// The go-cache memory repository is an ARC cache, a busy cache evict the entries before their expiration,
+// which is fine for a cache but not for the token revocations. Every entry must have an expiration
+type MemoryRepository struct {
+	mu      sync.Mutex
+	items   map[string]memoryItem
+	sweptAt time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		items:   map[string]memoryItem{},
+		sweptAt: time.Now(),
+	}
+}
+
+func (r *MemoryRepository) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	byteValue, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timeNow := time.Now()
+	if timeNow.Sub(r.sweptAt) >= sweepInterval {
+		for k, item := range r.items {
+			if !timeNow.Before(item.expiresAt) {
+				delete(r.items, k)
+			}
+		}
+		r.sweptAt = timeNow
+	}
+
+	r.items[key] = memoryItem{value: byteValue, expiresAt: timeNow.Add(expiration)}
+	return nil
+}
+
+// Get leave data unchanged for a missing or expired key
+func (r *MemoryRepository) Get(key string, data interface{}) (err error) {
+	r.mu.Lock()
+	item, ok := r.items[key]
+	r.mu.Unlock()
+
+	if !ok || !time.Now().Before(item.expiresAt) {
+		return nil
+	}
+	return json.Unmarshal(item.value, data)
+}
+
+func (r *MemoryRepository) Delete(key string) {
+	r.mu.Lock()
+	delete(r.items, key)
+	r.mu.Unlock()
+}
@@ -0,0 +1,54 @@
+package cache_test
+
+import (
+	cacheRepo "belajarGo2/repository/cache"
+	"belajarGo2/service/user"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pobyzaarif/go-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repo := cacheRepo.NewMemoryRepository()
+
+	var value string
+	require.NoError(t, repo.Get("missing", &value))
+	assert.Empty(t, value)
+
+	require.NoError(t, repo.Set("key", "value", time.Minute))
+	require.NoError(t, repo.Get("key", &value))
+	assert.Equal(t, "value", value)
+
+	repo.Delete("key")
+	value = ""
+	require.NoError(t, repo.Get("key", &value))
+	assert.Empty(t, value)
+
+	require.NoError(t, repo.Set("expired", "value", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, repo.Get("expired", &value))
+	assert.Empty(t, value)
+}
+
+func TestMemoryRepositoryKeepTheRevocations(t *testing.T) {
+	// the ARC cache shared with the inventory evict the revocations of a busy server
+	arc, err := cache.NewMemoryARCCacheRepository(10)
+	require.NoError(t, err)
+
+	for name, repo := range map[string]user.Cache{"arc": arc, "memory": cacheRepo.NewMemoryRepository()} {
+		revocation := user.NewTokenRevocation(repo)
+		require.NoError(t, revocation.RevokeSession("session-1", time.Hour))
+
+		for i := 0; i < 100; i++ {
+			require.NoError(t, repo.Set(fmt.Sprintf("inventory:%d", i), i, time.Hour))
+		}
+
+		revoked, err := revocation.IsSessionRevoked("session-1")
+		require.NoError(t, err)
+		assert.Equal(t, name == "memory", revoked, name)
+	}
+}
@@ -0,0 +1,52 @@
+// Package cache hold the cache repositories not covered by github.com/pobyzaarif/go-cache
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRepository is the go-cache redis repository, except Get return the redis errors. go-cache
+// return nil on any error, so a redis outage look like a miss and the token revocation can't fail closed
+type RedisRepository struct {
+	client *redis.Client
+}
+
+func NewRedisRepository(client *redis.Client) *RedisRepository {
+	return &RedisRepository{
+		client: client,
+	}
+}
+
+func (r *RedisRepository) Set(key string, value interface{}, expiration time.Duration) (err error) {
+	byteValue, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	return r.client.Set(context.TODO(), key, string(byteValue), expiration).Err()
+}
+
+// Get leave data unchanged for a missing key
+func (r *RedisRepository) Get(key string, data interface{}) (err error) {
+	result, err := r.client.Get(context.TODO(), key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if result == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(result), data)
+}
+
+func (r *RedisRepository) Delete(key string) {
+	r.client.Del(context.TODO(), key)
+}
@@ -0,0 +1,28 @@
+package cache_test
+
+import (
+	cacheRepo "belajarGo2/repository/cache"
+	"belajarGo2/service/user"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisRepositoryUnavailable(t *testing.T) {
+	// nothing listen on the port
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+	repo := cacheRepo.NewRedisRepository(client)
+
+	var revoked bool
+	assert.Error(t, repo.Get("auth:revoked:sid:session-1", &revoked))
+
+	// the revocation fail closed, the jwt middleware refuse the token
+	revocation := user.NewTokenRevocation(repo)
+	_, err := revocation.IsRevoked("jti-1", "user-1", time.Now())
+	assert.Error(t, err)
+	_, err = revocation.IsSessionRevoked("session-1")
+	assert.Error(t, err)
+}
@@ -1,8 +1,13 @@
 package inventory
 
 import (
+	outboxRepo "belajarGo2/repository/outbox"
 	"belajarGo2/service/inventory"
+	"belajarGo2/service/outbox"
+	"belajarGo2/util/database"
 	"context"
+	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 )
@@ -13,37 +18,117 @@ type (
 	}
 )
 
-func NewGormRepository(db *gorm.DB) *GormRepository {
+// NewGormRepository migrate the inventories without organization to defaultOrganizationID
+func NewGormRepository(db *gorm.DB, defaultOrganizationID string) *GormRepository {
+	if err := migrateInventoryOrganization(db, defaultOrganizationID); err != nil {
+		fmt.Println("Error migrating inventory organization:", err)
+	}
+
 	return &GormRepository{
 		// db.Table("inventories"),
 		db.Table("bg_inventories"),
 	}
 }
 
-func (r *GormRepository) Create(inv inventory.Inventory) (err error) {
+// migrateInventoryOrganization add the organization_id column of sql/inventory.sql to a table created before the
+// organizations, give the existing inventories to the default organization and make the code unique per organization
+func migrateInventoryOrganization(db *gorm.DB, defaultOrganizationID string) error {
+	if db.Migrator().HasColumn("bg_inventories", "organization_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE bg_inventories ADD COLUMN organization_id VARCHAR(40) NOT NULL DEFAULT ''").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("UPDATE bg_inventories SET organization_id = ?", defaultOrganizationID).Error; err != nil {
+			return err
+		}
+
+		switch tx.Dialector.Name() {
+		case "mysql":
+			return tx.Exec("ALTER TABLE bg_inventories DROP PRIMARY KEY, ADD PRIMARY KEY (organization_id, code)").Error
+		case "postgres":
+			return tx.Exec("ALTER TABLE bg_inventories DROP CONSTRAINT bg_inventories_pkey, ADD PRIMARY KEY (organization_id, code)").Error
+		default:
+			// sqlite can not change a primary key, the code stay unique across the organizations until the table is recreated
+			return tx.Exec("CREATE UNIQUE INDEX idx_bg_inventories_organization_code ON bg_inventories (organization_id, code)").Error
+		}
+	})
+}
+
+func (r *GormRepository) Create(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
 	ctx := context.Background()
-	return r.DB.WithContext(ctx).Create(&inv).Error
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inv).Error; err != nil {
+			if database.IsDuplicateKey(tx, err) {
+				return inventory.ErrDuplicateCode
+			}
+			return err
+		}
+
+		return outboxRepo.CreateEvents(tx, evts...)
+	})
 }
 
-func (r *GormRepository) ReadAll(page int, limit int) (invs []inventory.Inventory, err error) {
+func (r *GormRepository) ReadAll(organizationID string, page int, limit int) (invs []inventory.Inventory, err error) {
+	if organizationID == "" {
+		return nil, inventory.ErrOrganizationRequired
+	}
+
 	ctx := context.Background()
 	// r.DB.WithContext(ctx).Offset((page - 1) * limit).Limit(limit).Find(&invs)
-	r.DB.WithContext(ctx).Order("code DESC").Offset((page - 1) * limit).Limit(limit).Find(&invs)
+	err = r.DB.WithContext(ctx).Where("organization_id = ?", organizationID).Order("code DESC").Offset((page - 1) * limit).Limit(limit).Find(&invs).Error
 	return
 }
 
-func (r *GormRepository) ReadByCode(code string) (inv inventory.Inventory, err error) {
+func (r *GormRepository) ReadByCode(organizationID string, code string) (inv inventory.Inventory, err error) {
+	if organizationID == "" {
+		return inv, inventory.ErrOrganizationRequired
+	}
+
 	ctx := context.Background()
-	r.DB.WithContext(ctx).First(&inv, "code = ?", code)
+	err = r.DB.WithContext(ctx).First(&inv, "organization_id = ? AND code = ?", organizationID, code).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
 	return
 }
 
-func (r *GormRepository) Update(inv inventory.Inventory) (err error) {
+func (r *GormRepository) Update(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
 	ctx := context.Background()
-	return r.DB.WithContext(ctx).Where("code = ?", inv.Code).Save(inv).Error
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Save would insert a missing record, Select("*") keep the zero values in the update
+		if err := tx.Where("organization_id = ? AND code = ?", organizationID, inv.Code).Select("*").Updates(&inv).Error; err != nil {
+			return err
+		}
+
+		return outboxRepo.CreateEvents(tx, evts...)
+	})
 }
 
-func (r *GormRepository) Delete(code string) (err error) {
+func (r *GormRepository) Delete(organizationID string, code string, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+
 	ctx := context.Background()
-	return r.DB.WithContext(ctx).Where("code = ?", code).Delete(inventory.Inventory{}).Error
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND code = ?", organizationID, code).Delete(inventory.Inventory{}).Error; err != nil {
+			return err
+		}
+
+		return outboxRepo.CreateEvents(tx, evts...)
+	})
 }
@@ -0,0 +1,303 @@
+package inventory
+
+import (
+	"belajarGo2/service/inventory"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/esapi"
+)
+
+const inventoryIndexMapping = `{
+	"settings": {
+		"number_of_shards": 1
+	},
+	"mappings": {
+		"properties": {
+			"organization_id": {"type": "keyword"},
+			"code": {"type": "keyword", "fields": {"text": {"type": "text"}}},
+			"name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+			"description": {"type": "text"},
+			"stock": {"type": "integer"},
+			"status": {"type": "keyword"}
+		}
+	}
+}`
+
+type ElasticRepository struct {
+	es    *elasticsearch.Client
+	index string
+}
+
+func NewElasticRepository(es *elasticsearch.Client, index string) *ElasticRepository {
+	return &ElasticRepository{
+		es:    es,
+		index: index,
+	}
+}
+
+// documentID keep the same code of two organizations apart in the shared index
+func documentID(organizationID string, code string) string {
+	return organizationID + "/" + code
+}
+
+// CreateIndex create the index with the inventory mapping when it does not exist yet
+func (r *ElasticRepository) CreateIndex() (err error) {
+	res, err := r.es.Indices.Exists([]string{r.index})
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode == 200 {
+		return nil
+	}
+
+	res, err = r.es.Indices.Create(r.index, r.es.Indices.Create.WithBody(strings.NewReader(inventoryIndexMapping)))
+	if err != nil {
+		return
+	}
+
+	return responseError(res)
+}
+
+// RecreateIndex drop the index and create it again with the current mapping, used by the full reindex
+func (r *ElasticRepository) RecreateIndex() (err error) {
+	res, err := r.es.Indices.Delete([]string{r.index}, r.es.Indices.Delete.WithIgnoreUnavailable(true))
+	if err != nil {
+		return
+	}
+
+	if err = responseError(res); err != nil {
+		return
+	}
+
+	return r.CreateIndex()
+}
+
+func (r *ElasticRepository) Index(inv inventory.Inventory) (err error) {
+	if inv.OrganizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(inv); err != nil {
+		return
+	}
+
+	res, err := r.es.Index(
+		r.index,
+		&buf,
+		r.es.Index.WithDocumentID(documentID(inv.OrganizationID, inv.Code)),
+		r.es.Index.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return
+	}
+
+	return responseError(res)
+}
+
+func (r *ElasticRepository) BulkIndex(invs []inventory.Inventory) (err error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, inv := range invs {
+		if inv.OrganizationID == "" {
+			return inventory.ErrOrganizationRequired
+		}
+
+		meta := map[string]interface{}{"index": map[string]interface{}{"_id": documentID(inv.OrganizationID, inv.Code)}}
+		if err = enc.Encode(meta); err != nil {
+			return
+		}
+		if err = enc.Encode(inv); err != nil {
+			return
+		}
+	}
+
+	res, err := r.es.Bulk(&buf, r.es.Bulk.WithIndex(r.index), r.es.Bulk.WithRefresh("true"))
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch bulk error: %s", res.String())
+	}
+
+	var bulkRes struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string `json:"_id"`
+			Error struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&bulkRes); err != nil {
+		return
+	}
+
+	if bulkRes.Errors {
+		for _, item := range bulkRes.Items {
+			for _, result := range item {
+				if result.Error.Reason != "" {
+					return fmt.Errorf("elasticsearch bulk error on %v: %v", result.ID, result.Error.Reason)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *ElasticRepository) Delete(organizationID string, code string) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+
+	res, err := r.es.Delete(r.index, documentID(organizationID, code), r.es.Delete.WithRefresh("wait_for"))
+	if err != nil {
+		return
+	}
+
+	// already gone is fine
+	if res.StatusCode == 404 {
+		res.Body.Close()
+		return nil
+	}
+
+	return responseError(res)
+}
+
+func (r *ElasticRepository) Search(organizationID string, query string, status string, page int, limit int) (result inventory.SearchResult, err error) {
+	if organizationID == "" {
+		return result, inventory.ErrOrganizationRequired
+	}
+
+	var q map[string]interface{}
+	if strings.TrimSpace(query) == "" {
+		q = map[string]interface{}{"match_all": map[string]interface{}{}}
+	} else {
+		q = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{"code^3", "code.text^3", "name^2", "description"},
+				"fuzziness": "AUTO",
+				"lenient":   true,
+			},
+		}
+	}
+
+	body := map[string]interface{}{
+		"from": (page - 1) * limit,
+		"size": limit,
+		// the organization filter apply to the facets too
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   q,
+				"filter": map[string]interface{}{"term": map[string]interface{}{"organization_id": organizationID}},
+			},
+		},
+		"highlight": map[string]interface{}{
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]interface{}{
+				"name":        map[string]interface{}{},
+				"description": map[string]interface{}{},
+				"code.text":   map[string]interface{}{},
+			},
+		},
+		// facets are computed before the status filter so every status keep its count
+		"aggs": map[string]interface{}{
+			"status": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "status"},
+			},
+		},
+	}
+	if status != "" {
+		body["post_filter"] = map[string]interface{}{
+			"term": map[string]interface{}{"status": status},
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(body); err != nil {
+		return
+	}
+
+	res, err := r.es.Search(
+		r.es.Search.WithContext(context.Background()),
+		r.es.Search.WithIndex(r.index),
+		r.es.Search.WithBody(&buf),
+		r.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return result, fmt.Errorf("elasticsearch search error: %s", res.String())
+	}
+
+	var searchRes struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source    inventory.Inventory `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			Status struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"status"`
+		} `json:"aggregations"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&searchRes); err != nil {
+		return
+	}
+
+	result.Total = searchRes.Hits.Total.Value
+	result.Hits = []inventory.SearchHit{}
+	for _, hit := range searchRes.Hits.Hits {
+		highlights := map[string][]string{}
+		for field, fragments := range hit.Highlight {
+			highlights[strings.TrimSuffix(field, ".text")] = fragments
+		}
+
+		result.Hits = append(result.Hits, inventory.SearchHit{
+			Inventory:  hit.Source,
+			Highlights: highlights,
+		})
+	}
+
+	result.Facets = map[string]int64{}
+	for _, bucket := range searchRes.Aggregations.Status.Buckets {
+		result.Facets[bucket.Key] = bucket.DocCount
+	}
+
+	return result, nil
+}
+
+func responseError(res *esapi.Response) error {
+	defer res.Body.Close()
+
+	if res.IsError() {
+		b, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("elasticsearch return negative response %v: %s", res.StatusCode, b)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package inventory
+
+import (
+	"belajarGo2/service/inventory"
+	"belajarGo2/service/outbox"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository keep the inventories in a map per organization, for tests and local runs without a database.
+// The outbox events are kept in memory too and can be read with Events
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	invs   map[string]map[string]inventory.Inventory
+	events []outbox.Event
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		invs: map[string]map[string]inventory.Inventory{},
+	}
+}
+
+func (r *MemoryRepository) Create(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invs[organizationID][inv.Code]; ok {
+		return inventory.ErrDuplicateCode
+	}
+
+	if r.invs[organizationID] == nil {
+		r.invs[organizationID] = map[string]inventory.Inventory{}
+	}
+	r.invs[organizationID][inv.Code] = inv
+	r.events = append(r.events, evts...)
+	return
+}
+
+func (r *MemoryRepository) ReadAll(organizationID string, page int, limit int) (invs []inventory.Inventory, err error) {
+	if organizationID == "" {
+		return nil, inventory.ErrOrganizationRequired
+	}
+
+	r.mu.RLock()
+	all := make([]inventory.Inventory, 0, len(r.invs[organizationID]))
+	for _, inv := range r.invs[organizationID] {
+		all = append(all, inv)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Code > all[j].Code
+	})
+
+	offset := max((page-1)*limit, 0)
+	if offset >= len(all) {
+		return
+	}
+
+	end := min(offset+limit, len(all))
+	invs = all[offset:end]
+	return
+}
+
+func (r *MemoryRepository) ReadByCode(organizationID string, code string) (inv inventory.Inventory, err error) {
+	if organizationID == "" {
+		return inv, inventory.ErrOrganizationRequired
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inv = r.invs[organizationID][code]
+	return
+}
+
+func (r *MemoryRepository) Update(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invs[organizationID][inv.Code]; ok {
+		r.invs[organizationID][inv.Code] = inv
+	}
+	r.events = append(r.events, evts...)
+	return
+}
+
+func (r *MemoryRepository) Delete(organizationID string, code string, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.invs[organizationID], code)
+	r.events = append(r.events, evts...)
+	return
+}
+
+// Events return a copy of the outbox events written so far
+func (r *MemoryRepository) Events() []outbox.Event {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]outbox.Event(nil), r.events...)
+}
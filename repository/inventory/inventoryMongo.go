@@ -1,7 +1,9 @@
 package inventory
 
 import (
+	outboxRepo "belajarGo2/repository/outbox"
 	"belajarGo2/service/inventory"
+	"belajarGo2/service/outbox"
 	"context"
 	"fmt"
 	"strings"
@@ -11,9 +13,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func createInventoryIndex(col *mongo.Collection) error {
+// createInventoryIndex give the inventories stored before the organizations to the default organization,
+// then replace the unique code with a code unique per organization
+func createInventoryIndex(col *mongo.Collection, defaultOrganizationID string) error {
 	ctx := context.TODO()
 
+	// backfill first, the org-scoped queries and the new unique index skip the inventories without organization
+	_, err := col.UpdateMany(ctx,
+		bson.M{"organization_id": bson.M{"$in": bson.A{"", nil}}},
+		bson.M{"$set": bson.M{"organization_id": defaultOrganizationID}},
+	)
+	if err != nil {
+		return err
+	}
+
 	// Check existing indexes
 	cur, err := col.Indexes().List(ctx)
 	if err != nil {
@@ -22,15 +35,26 @@ func createInventoryIndex(col *mongo.Collection) error {
 	defer cur.Close(ctx)
 
 	indexExists := false
+	globalCodeIndex := false
 	for cur.Next(ctx) {
 		var idx bson.M
 		if err := cur.Decode(&idx); err != nil {
 			return err
 		}
 
-		if name, ok := idx["name"].(string); ok && name == "code_1" {
+		name, _ := idx["name"].(string)
+		switch name {
+		case "organization_id_1_code_1":
 			indexExists = true
-			break
+		case "code_1":
+			globalCodeIndex = true
+		}
+	}
+
+	// the code was unique across the organizations before, it would refuse the same code in two organizations
+	if globalCodeIndex {
+		if _, err := col.Indexes().DropOne(ctx, "code_1"); err != nil {
+			return err
 		}
 	}
 
@@ -38,9 +62,9 @@ func createInventoryIndex(col *mongo.Collection) error {
 		return nil // already exists
 	}
 
-	// Create unique index on "code"
+	// Create unique index on "organization_id" and "code"
 	model := mongo.IndexModel{
-		Keys:    bson.D{{Key: "code", Value: 1}},
+		Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "code", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	}
 
@@ -48,14 +72,17 @@ func createInventoryIndex(col *mongo.Collection) error {
 	return err
 }
 
+// MongoRepository store the outbox events in a multi-document transaction,
+// so mongo must run as a replica set (a single node replica set is enough)
 type MongoRepository struct {
 	col *mongo.Collection
 }
 
-func NewMongoRepository(db *mongo.Database) *MongoRepository {
+// NewMongoRepository migrate the inventories without organization to defaultOrganizationID
+func NewMongoRepository(db *mongo.Database, defaultOrganizationID string) *MongoRepository {
 	col := db.Collection("inventories")
 
-	if err := createInventoryIndex(col); err != nil {
+	if err := createInventoryIndex(col, defaultOrganizationID); err != nil {
 		fmt.Println("Error ensuring unique index:", err)
 	}
 
@@ -64,13 +91,45 @@ func NewMongoRepository(db *mongo.Database) *MongoRepository {
 	}
 }
 
-func (r *MongoRepository) Create(inv inventory.Inventory) (err error) {
-	_, err = r.col.InsertOne(context.Background(), inv)
-	return err
+func (r *MongoRepository) withTransaction(fn func(sc mongo.SessionContext) error) (err error) {
+	session, err := r.col.Database().Client().StartSession()
+	if err != nil {
+		return
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return
 }
 
-func (r *MongoRepository) ReadAll(page int, limit int) (invs []inventory.Inventory, err error) {
-	cursor, err := r.col.Find(context.Background(), bson.M{}, nil)
+func (r *MongoRepository) Create(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
+	return r.withTransaction(func(sc mongo.SessionContext) error {
+		if _, err := r.col.InsertOne(sc, inv); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return inventory.ErrDuplicateCode
+			}
+			return err
+		}
+
+		return outboxRepo.InsertEvents(sc, r.col.Database(), evts...)
+	})
+}
+
+func (r *MongoRepository) ReadAll(organizationID string, page int, limit int) (invs []inventory.Inventory, err error) {
+	if organizationID == "" {
+		return nil, inventory.ErrOrganizationRequired
+	}
+
+	// same order and paging as the gorm repository, the reindex rely on it
+	opts := options.Find().SetSort(bson.D{{Key: "code", Value: -1}}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := r.col.Find(context.Background(), bson.M{"organization_id": organizationID}, opts)
 	if err != nil {
 		return
 	}
@@ -86,8 +145,12 @@ func (r *MongoRepository) ReadAll(page int, limit int) (invs []inventory.Invento
 	return
 }
 
-func (r *MongoRepository) ReadByCode(code string) (inv inventory.Inventory, err error) {
-	err = r.col.FindOne(context.Background(), bson.M{"code": code}).Decode(&inv)
+func (r *MongoRepository) ReadByCode(organizationID string, code string) (inv inventory.Inventory, err error) {
+	if organizationID == "" {
+		return inv, inventory.ErrOrganizationRequired
+	}
+
+	err = r.col.FindOne(context.Background(), bson.M{"organization_id": organizationID, "code": code}).Decode(&inv)
 	if err != nil {
 		if strings.Contains(err.Error(), "no documents") {
 			err = nil
@@ -97,12 +160,31 @@ func (r *MongoRepository) ReadByCode(code string) (inv inventory.Inventory, err
 	return
 }
 
-func (r *MongoRepository) Update(inv inventory.Inventory) (err error) {
-	_, err = r.col.UpdateOne(context.Background(), bson.M{"code": inv.Code}, bson.M{"$set": inv})
-	return
+func (r *MongoRepository) Update(organizationID string, inv inventory.Inventory, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+	inv.OrganizationID = organizationID
+
+	return r.withTransaction(func(sc mongo.SessionContext) error {
+		if _, err := r.col.UpdateOne(sc, bson.M{"organization_id": organizationID, "code": inv.Code}, bson.M{"$set": inv}); err != nil {
+			return err
+		}
+
+		return outboxRepo.InsertEvents(sc, r.col.Database(), evts...)
+	})
 }
 
-func (r *MongoRepository) Delete(code string) (err error) {
-	_, err = r.col.DeleteOne(context.Background(), bson.M{"code": code})
-	return
+func (r *MongoRepository) Delete(organizationID string, code string, evts ...outbox.Event) (err error) {
+	if organizationID == "" {
+		return inventory.ErrOrganizationRequired
+	}
+
+	return r.withTransaction(func(sc mongo.SessionContext) error {
+		if _, err := r.col.DeleteOne(sc, bson.M{"organization_id": organizationID, "code": code}); err != nil {
+			return err
+		}
+
+		return outboxRepo.InsertEvents(sc, r.col.Database(), evts...)
+	})
 }
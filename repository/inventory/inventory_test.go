@@ -0,0 +1,51 @@
+package inventory_test
+
+import (
+	invRepo "belajarGo2/repository/inventory"
+	"belajarGo2/repository/inventory/inventorytest"
+	"belajarGo2/service/inventory"
+	"belajarGo2/util/database/databasetest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	inventorytest.RunRepositorySuite(t, func(t *testing.T) inventory.Repository {
+		return invRepo.NewMemoryRepository()
+	})
+}
+
+func TestGormRepository(t *testing.T) {
+	inventorytest.RunRepositorySuite(t, func(t *testing.T) inventory.Repository {
+		return invRepo.NewGormRepository(databasetest.NewSQLite(t, "inventory.sql", "outbox.sql"), "default")
+	})
+}
+
+func TestGormRepositoryMigrateOrganization(t *testing.T) {
+	// the table of sql/inventory.sql before the organizations
+	db := databasetest.NewSQLite(t)
+	require.NoError(t, db.Exec(`CREATE TABLE bg_inventories (
+		code VARCHAR(50) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		stock INT NOT NULL DEFAULT 0,
+		description TEXT,
+		status VARCHAR(50) NOT NULL DEFAULT ''
+	)`).Error)
+	require.NoError(t, db.Exec("INSERT INTO bg_inventories (code, name, stock) VALUES ('INV001', 'Laptop', 25)").Error)
+
+	repo := invRepo.NewGormRepository(db, "org-1")
+	assert.True(t, db.Migrator().HasColumn("bg_inventories", "organization_id"))
+
+	inv, err := repo.ReadByCode("org-1", "INV001")
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", inv.Name)
+	assert.Equal(t, "org-1", inv.OrganizationID)
+
+	// a second start find the column and keep the data
+	repo = invRepo.NewGormRepository(db, "org-2")
+	inv, err = repo.ReadByCode("org-1", "INV001")
+	require.NoError(t, err)
+	assert.Equal(t, "INV001", inv.Code)
+}
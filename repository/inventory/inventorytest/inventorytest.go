@@ -0,0 +1,180 @@
+// Package inventorytest hold the conformance suite every inventory.Repository backend must pass
+package inventorytest
+
+import (
+	"belajarGo2/service/inventory"
+	"belajarGo2/service/outbox"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunRepositorySuite run the suite, newRepo must return an empty repository on every call
+func RunRepositorySuite(t *testing.T, newRepo func(t *testing.T) inventory.Repository) {
+	const org = "org-1"
+	item := inventory.Inventory{OrganizationID: org, Code: "INV001", Name: "Laptop", Stock: 25, Description: "Dell Latitude 5420", Status: "active"}
+
+	t.Run("read missing code return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.ReadByCode(org, "NOPE")
+		assert.NoError(t, err)
+		assert.Equal(t, inventory.Inventory{}, got)
+	})
+
+	t.Run("create then read by code", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, item))
+
+		got, err := repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, item, got)
+	})
+
+	t.Run("create with events", func(t *testing.T) {
+		repo := newRepo(t)
+
+		evt, err := outbox.NewEvent(inventory.EventAggregateType, item.Code, inventory.EventCreated, item)
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(org, item, evt))
+
+		got, err := repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, item, got)
+	})
+
+	t.Run("create duplicate code", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, item))
+
+		err := repo.Create(org, item)
+		assert.ErrorIs(t, err, inventory.ErrDuplicateCode)
+	})
+
+	t.Run("read all is sorted by code desc and paged", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for i := 1; i <= 5; i++ {
+			inv := item
+			inv.Code = fmt.Sprintf("INV%03d", i)
+			require.NoError(t, repo.Create(org, inv))
+		}
+
+		pages := map[int][]string{
+			1: {"INV005", "INV004"},
+			2: {"INV003", "INV002"},
+			3: {"INV001"},
+			4: {},
+		}
+		for page, want := range pages {
+			invs, err := repo.ReadAll(org, page, 2)
+			assert.NoError(t, err)
+
+			codes := []string{}
+			for _, inv := range invs {
+				codes = append(codes, inv.Code)
+			}
+			assert.Equal(t, want, codes, "page %d", page)
+		}
+	})
+
+	t.Run("update keep zero values", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, item))
+
+		updated := inventory.Inventory{OrganizationID: org, Code: item.Code, Name: "Laptop Pro", Stock: 0, Description: "", Status: "broken"}
+		require.NoError(t, repo.Update(org, updated))
+
+		got, err := repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, updated, got)
+	})
+
+	t.Run("update missing code does not create it", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.NoError(t, repo.Update(org, item))
+
+		got, err := repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, inventory.Inventory{}, got)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, item))
+		require.NoError(t, repo.Delete(org, item.Code))
+
+		got, err := repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, inventory.Inventory{}, got)
+	})
+
+	t.Run("delete missing code", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.NoError(t, repo.Delete(org, "NOPE"))
+	})
+
+	t.Run("organizations are isolated", func(t *testing.T) {
+		repo := newRepo(t)
+
+		other := item
+		other.OrganizationID = "org-2"
+		other.Name = "Other Laptop"
+		require.NoError(t, repo.Create(org, item))
+		// the same code in another organization is not a duplicate
+		require.NoError(t, repo.Create("org-2", other))
+
+		got, err := repo.ReadByCode("org-2", item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, other, got)
+
+		invs, err := repo.ReadAll("org-3", 1, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, invs)
+
+		updated := other
+		updated.Stock = 1
+		require.NoError(t, repo.Update("org-2", updated))
+		require.NoError(t, repo.Delete("org-3", item.Code))
+
+		got, err = repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, item, got)
+	})
+
+	t.Run("the organization of the argument is stored", func(t *testing.T) {
+		repo := newRepo(t)
+
+		spoofed := item
+		spoofed.OrganizationID = "org-2"
+		require.NoError(t, repo.Create(org, spoofed))
+
+		got, err := repo.ReadByCode("org-2", item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, inventory.Inventory{}, got)
+
+		got, err = repo.ReadByCode(org, item.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, item, got)
+	})
+
+	t.Run("empty organization is refused", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.ErrorIs(t, repo.Create("", item), inventory.ErrOrganizationRequired)
+		_, err := repo.ReadAll("", 1, 10)
+		assert.ErrorIs(t, err, inventory.ErrOrganizationRequired)
+		_, err = repo.ReadByCode("", item.Code)
+		assert.ErrorIs(t, err, inventory.ErrOrganizationRequired)
+		assert.ErrorIs(t, repo.Update("", item), inventory.ErrOrganizationRequired)
+		assert.ErrorIs(t, repo.Delete("", item.Code), inventory.ErrOrganizationRequired)
+	})
+}
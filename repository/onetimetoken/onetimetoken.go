@@ -0,0 +1,47 @@
+package onetimetoken
+
+import (
+	"belajarGo2/service/onetimetoken"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db,
+	}
+}
+
+func (r *GormRepository) tokens() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_one_time_tokens")
+}
+
+func (r *GormRepository) Create(token onetimetoken.Token) (err error) {
+	return r.tokens().Create(&token).Error
+}
+
+func (r *GormRepository) GetByHash(tokenHash string) (token onetimetoken.Token, err error) {
+	err = r.tokens().First(&token, "token_hash = ?", tokenHash).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormRepository) Consume(id string, consumedAt time.Time) (consumed bool, err error) {
+	res := r.tokens().Where("id = ? AND consumed_at IS NULL", id).Update("consumed_at", consumedAt)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormRepository) ConsumeBySubject(purpose string, subject string, consumedAt time.Time) (err error) {
+	return r.tokens().Where("purpose = ? AND subject = ? AND consumed_at IS NULL", purpose, subject).Update("consumed_at", consumedAt).Error
+}
@@ -0,0 +1,61 @@
+package onetimetoken
+
+import (
+	"belajarGo2/service/onetimetoken"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keep the tokens in a map keyed by hash, for tests and local runs without a database
+type MemoryRepository struct {
+	mu     sync.Mutex
+	tokens map[string]onetimetoken.Token
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		tokens: map[string]onetimetoken.Token{},
+	}
+}
+
+func (r *MemoryRepository) Create(token onetimetoken.Token) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.TokenHash] = token
+	return
+}
+
+func (r *MemoryRepository) GetByHash(tokenHash string) (token onetimetoken.Token, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.tokens[tokenHash], nil
+}
+
+func (r *MemoryRepository) Consume(id string, consumedAt time.Time) (consumed bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.ID == id && token.ConsumedAt == nil {
+			token.ConsumedAt = &consumedAt
+			r.tokens[hash] = token
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryRepository) ConsumeBySubject(purpose string, subject string, consumedAt time.Time) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.Purpose == purpose && token.Subject == subject && token.ConsumedAt == nil {
+			token.ConsumedAt = &consumedAt
+			r.tokens[hash] = token
+		}
+	}
+	return
+}
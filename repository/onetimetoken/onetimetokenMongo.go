@@ -0,0 +1,79 @@
+package onetimetoken
+
+import (
+	"belajarGo2/service/onetimetoken"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "purpose", Value: 1}, {Key: "subject", Value: 1}},
+		},
+		{
+			// expired tokens are useless, let mongo drop them
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+type MongoRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection("one_time_tokens")
+
+	if err := createIndex(col); err != nil {
+		fmt.Println("Error ensuring one-time token index:", err)
+	}
+
+	return &MongoRepository{
+		col: col,
+	}
+}
+
+func (r *MongoRepository) Create(token onetimetoken.Token) (err error) {
+	_, err = r.col.InsertOne(context.Background(), token)
+	return
+}
+
+func (r *MongoRepository) GetByHash(tokenHash string) (token onetimetoken.Token, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"token_hash": tokenHash}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoRepository) Consume(id string, consumedAt time.Time) (consumed bool, err error) {
+	res, err := r.col.UpdateOne(context.Background(),
+		bson.M{"one_time_token_id": id, "consumed_at": nil},
+		bson.M{"$set": bson.M{"consumed_at": consumedAt}},
+	)
+	if err != nil {
+		return
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoRepository) ConsumeBySubject(purpose string, subject string, consumedAt time.Time) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"purpose": purpose, "subject": subject, "consumed_at": nil},
+		bson.M{"$set": bson.M{"consumed_at": consumedAt}},
+	)
+	return
+}
@@ -0,0 +1,21 @@
+package onetimetoken_test
+
+import (
+	oneTimeTokenRepo "belajarGo2/repository/onetimetoken"
+	"belajarGo2/repository/onetimetoken/onetimetokentest"
+	"belajarGo2/service/onetimetoken"
+	"belajarGo2/util/database/databasetest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	onetimetokentest.RunRepositorySuite(t, func(t *testing.T) onetimetoken.Repository {
+		return oneTimeTokenRepo.NewMemoryRepository()
+	})
+}
+
+func TestGormRepository(t *testing.T) {
+	onetimetokentest.RunRepositorySuite(t, func(t *testing.T) onetimetoken.Repository {
+		return oneTimeTokenRepo.NewGormRepository(databasetest.NewSQLite(t, "one_time_token.sql"))
+	})
+}
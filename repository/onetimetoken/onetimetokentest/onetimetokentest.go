@@ -0,0 +1,80 @@
+// Package onetimetokentest hold the conformance suite every onetimetoken.Repository backend must pass
+package onetimetokentest
+
+import (
+	"belajarGo2/service/onetimetoken"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunRepositorySuite run the suite, newRepo must return an empty repository on every call
+func RunRepositorySuite(t *testing.T, newRepo func(t *testing.T) onetimetoken.Repository) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newToken := func(id string, purpose string, subject string) onetimetoken.Token {
+		return onetimetoken.Token{
+			ID:        id,
+			TokenHash: "hash-" + id,
+			Purpose:   purpose,
+			Subject:   subject,
+			Payload:   "new@mail.com",
+			ExpiresAt: now.Add(time.Hour),
+			CreatedAt: now,
+		}
+	}
+
+	t.Run("get missing hash return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetByHash("missing")
+		assert.NoError(t, err)
+		assert.Equal(t, onetimetoken.Token{}, got)
+	})
+
+	t.Run("create then consume once", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(newToken("token-1", "email_change", "user-1")))
+
+		got, err := repo.GetByHash("hash-token-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "token-1", got.ID)
+		assert.Equal(t, "email_change", got.Purpose)
+		assert.Equal(t, "user-1", got.Subject)
+		assert.Equal(t, "new@mail.com", got.Payload)
+		assert.True(t, now.Add(time.Hour).Equal(got.ExpiresAt))
+		assert.Nil(t, got.ConsumedAt)
+
+		consumed, err := repo.Consume("token-1", now)
+		assert.NoError(t, err)
+		assert.True(t, consumed)
+
+		consumed, err = repo.Consume("token-1", now)
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+
+		got, err = repo.GetByHash("hash-token-1")
+		assert.NoError(t, err)
+		require.NotNil(t, got.ConsumedAt)
+		assert.True(t, now.Equal(*got.ConsumedAt))
+	})
+
+	t.Run("consume by subject only touch the purpose and the subject", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(newToken("token-1", "email_verification", "user-1")))
+		require.NoError(t, repo.Create(newToken("token-2", "email_verification", "user-1")))
+		require.NoError(t, repo.Create(newToken("token-3", "reset_password", "user-1")))
+		require.NoError(t, repo.Create(newToken("token-4", "email_verification", "user-2")))
+
+		require.NoError(t, repo.ConsumeBySubject("email_verification", "user-1", now))
+
+		for id, wantConsumed := range map[string]bool{"token-1": true, "token-2": true, "token-3": false, "token-4": false} {
+			got, err := repo.GetByHash("hash-" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, wantConsumed, got.ConsumedAt != nil, id)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package organization
+
+import (
+	"belajarGo2/service/organization"
+	"belajarGo2/util/database"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db,
+	}
+}
+
+func (r *GormRepository) organizations() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_organizations")
+}
+
+func (r *GormRepository) members() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_organization_members")
+}
+
+func (r *GormRepository) Create(org organization.Organization, owner organization.Member) (err error) {
+	return r.DB.WithContext(context.Background()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("bg_organizations").Create(&org).Error; err != nil {
+			return err
+		}
+
+		return tx.Table("bg_organization_members").Create(&owner).Error
+	})
+}
+
+func (r *GormRepository) GetByID(id string) (org organization.Organization, err error) {
+	err = r.organizations().First(&org, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormRepository) GetAll() (orgs []organization.Organization, err error) {
+	err = r.organizations().Order("name").Find(&orgs).Error
+	return
+}
+
+func (r *GormRepository) AddMember(member organization.Member) (err error) {
+	err = r.members().Create(&member).Error
+	if database.IsDuplicateKey(r.DB, err) {
+		return organization.ErrDuplicateMember
+	}
+	return
+}
+
+func (r *GormRepository) GetMember(organizationID string, userID string) (member organization.Member, err error) {
+	err = r.members().First(&member, "organization_id = ? AND user_id = ?", organizationID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormRepository) GetMembers(organizationID string) (members []organization.Member, err error) {
+	err = r.members().Where("organization_id = ?", organizationID).Order("created_at").Find(&members).Error
+	return
+}
+
+func (r *GormRepository) GetUserMemberships(userID string) (members []organization.Member, err error) {
+	err = r.members().Where("user_id = ?", userID).Order("created_at").Find(&members).Error
+	return
+}
+
+func (r *GormRepository) UpdateMemberRole(organizationID string, userID string, role string) (err error) {
+	return r.members().Where("organization_id = ? AND user_id = ?", organizationID, userID).Update("role", role).Error
+}
+
+func (r *GormRepository) RemoveMember(organizationID string, userID string) (err error) {
+	return r.members().Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&organization.Member{}).Error
+}
@@ -0,0 +1,141 @@
+package organization
+
+import (
+	"belajarGo2/service/organization"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createOrganizationIndex(orgs *mongo.Collection, members *mongo.Collection) error {
+	_, err := orgs.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "organization_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = members.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+// MongoRepository create the organization and its owner in a multi-document transaction,
+// so mongo must run as a replica set like for the inventories
+type MongoRepository struct {
+	orgs    *mongo.Collection
+	members *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	orgs := db.Collection("organizations")
+	members := db.Collection("organization_members")
+
+	if err := createOrganizationIndex(orgs, members); err != nil {
+		fmt.Println("Error ensuring organization index:", err)
+	}
+
+	return &MongoRepository{
+		orgs:    orgs,
+		members: members,
+	}
+}
+
+func (r *MongoRepository) Create(org organization.Organization, owner organization.Member) (err error) {
+	session, err := r.orgs.Database().Client().StartSession()
+	if err != nil {
+		return
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := r.orgs.InsertOne(sc, org); err != nil {
+			return nil, err
+		}
+
+		_, err := r.members.InsertOne(sc, owner)
+		return nil, err
+	})
+	return
+}
+
+func (r *MongoRepository) GetByID(id string) (org organization.Organization, err error) {
+	err = r.orgs.FindOne(context.Background(), bson.M{"organization_id": id}).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoRepository) GetAll() (orgs []organization.Organization, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.orgs.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &orgs)
+	return
+}
+
+func (r *MongoRepository) AddMember(member organization.Member) (err error) {
+	_, err = r.members.InsertOne(context.Background(), member)
+	if mongo.IsDuplicateKeyError(err) {
+		return organization.ErrDuplicateMember
+	}
+	return
+}
+
+func (r *MongoRepository) GetMember(organizationID string, userID string) (member organization.Member, err error) {
+	err = r.members.FindOne(context.Background(), bson.M{"organization_id": organizationID, "user_id": userID}).Decode(&member)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoRepository) findMembers(filter bson.M) (members []organization.Member, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.members.Find(context.Background(), filter, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &members)
+	return
+}
+
+func (r *MongoRepository) GetMembers(organizationID string) (members []organization.Member, err error) {
+	return r.findMembers(bson.M{"organization_id": organizationID})
+}
+
+func (r *MongoRepository) GetUserMemberships(userID string) (members []organization.Member, err error) {
+	return r.findMembers(bson.M{"user_id": userID})
+}
+
+func (r *MongoRepository) UpdateMemberRole(organizationID string, userID string, role string) (err error) {
+	_, err = r.members.UpdateOne(context.Background(),
+		bson.M{"organization_id": organizationID, "user_id": userID},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	return
+}
+
+func (r *MongoRepository) RemoveMember(organizationID string, userID string) (err error) {
+	_, err = r.members.DeleteOne(context.Background(), bson.M{"organization_id": organizationID, "user_id": userID})
+	return
+}
@@ -0,0 +1,15 @@
+package organization_test
+
+import (
+	organizationRepo "belajarGo2/repository/organization"
+	"belajarGo2/repository/organization/organizationtest"
+	"belajarGo2/service/organization"
+	"belajarGo2/util/database/databasetest"
+	"testing"
+)
+
+func TestGormRepository(t *testing.T) {
+	organizationtest.RunRepositorySuite(t, func(t *testing.T) organization.Repository {
+		return organizationRepo.NewGormRepository(databasetest.NewSQLite(t, "organization.sql"))
+	})
+}
@@ -0,0 +1,92 @@
+// Package organizationtest hold the conformance suite every organization.Repository backend must pass
+package organizationtest
+
+import (
+	"belajarGo2/service/organization"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunRepositorySuite run the suite, newRepo must return an empty repository on every call
+func RunRepositorySuite(t *testing.T, newRepo func(t *testing.T) organization.Repository) {
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	org := organization.Organization{ID: "org-1", Name: "Warehouse", CreatedBy: "superadmin-1", CreatedAt: createdAt}
+	owner := organization.Member{OrganizationID: "org-1", UserID: "user-1", Role: "admin", CreatedAt: createdAt}
+
+	t.Run("missing organization and member return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetByID("NOPE")
+		assert.NoError(t, err)
+		assert.Equal(t, organization.Organization{}, got)
+
+		member, err := repo.GetMember("NOPE", "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, organization.Member{}, member)
+	})
+
+	t.Run("create store the owner", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, owner))
+
+		got, err := repo.GetByID(org.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, org.Name, got.Name)
+		assert.Equal(t, org.CreatedBy, got.CreatedBy)
+
+		member, err := repo.GetMember(org.ID, owner.UserID)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", member.Role)
+
+		orgs, err := repo.GetAll()
+		assert.NoError(t, err)
+		assert.Len(t, orgs, 1)
+	})
+
+	t.Run("members", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(org, owner))
+		other := organization.Organization{ID: "org-2", Name: "Office", CreatedAt: createdAt}
+		require.NoError(t, repo.Create(other, organization.Member{OrganizationID: "org-2", UserID: "user-2", Role: "admin", CreatedAt: createdAt}))
+
+		member := organization.Member{OrganizationID: "org-2", UserID: "user-1", Role: "user", CreatedAt: createdAt.Add(time.Second)}
+		require.NoError(t, repo.AddMember(member))
+		assert.ErrorIs(t, repo.AddMember(member), organization.ErrDuplicateMember)
+
+		members, err := repo.GetMembers("org-2")
+		assert.NoError(t, err)
+		require.Len(t, members, 2)
+		assert.Equal(t, "user-2", members[0].UserID)
+		assert.Equal(t, "user-1", members[1].UserID)
+
+		memberships, err := repo.GetUserMemberships("user-1")
+		assert.NoError(t, err)
+		require.Len(t, memberships, 2)
+		assert.Equal(t, "org-1", memberships[0].OrganizationID)
+		assert.Equal(t, "org-2", memberships[1].OrganizationID)
+
+		require.NoError(t, repo.UpdateMemberRole("org-2", "user-1", "admin"))
+		got, err := repo.GetMember("org-2", "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", got.Role)
+
+		// the role in the other organization is untouched
+		got, err = repo.GetMember("org-1", "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", got.Role)
+
+		require.NoError(t, repo.RemoveMember("org-2", "user-1"))
+		got, err = repo.GetMember("org-2", "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, organization.Member{}, got)
+
+		got, err = repo.GetMember("org-1", "user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", got.UserID)
+	})
+}
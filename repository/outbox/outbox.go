@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"belajarGo2/service/outbox"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const TableName = "bg_outbox_events"
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db.Table(TableName),
+	}
+}
+
+// CreateEvents write events with the given db/transaction, used by other repositories
+// to store the event in the same transaction as the data change
+func CreateEvents(tx *gorm.DB, evts ...outbox.Event) (err error) {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	return tx.Table(TableName).Create(&evts).Error
+}
+
+func (r *GormRepository) Create(evt outbox.Event) (err error) {
+	return r.DB.WithContext(context.Background()).Create(&evt).Error
+}
+
+func (r *GormRepository) GetUnpublished(limit int) (evts []outbox.Event, err error) {
+	err = r.DB.WithContext(context.Background()).Where("published_at IS NULL").Order("created_at ASC").Limit(limit).Find(&evts).Error
+	return
+}
+
+func (r *GormRepository) MarkPublished(id string, publishedAt time.Time) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Update("published_at", publishedAt).Error
+}
+
+func (r *GormRepository) MarkFailed(id string, lastError string) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": lastError,
+	}).Error
+}
+
+func (r *GormRepository) RedactAggregate(aggregateType string, aggregateID string, payload string) (err error) {
+	return r.DB.WithContext(context.Background()).Where("aggregate_type = ? AND aggregate_id = ?", aggregateType, aggregateID).Update("payload", payload).Error
+}
@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"belajarGo2/service/outbox"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollectionName = "outbox_events"
+
+func createOutboxIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "aggregate_type", Value: 1}, {Key: "aggregate_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+type MongoRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection(CollectionName)
+
+	if err := createOutboxIndex(col); err != nil {
+		fmt.Println("Error ensuring outbox index:", err)
+	}
+
+	return &MongoRepository{
+		col: col,
+	}
+}
+
+// InsertEvents write events with the given context, pass a mongo.SessionContext
+// to store the event in the same transaction as the data change
+func InsertEvents(ctx context.Context, db *mongo.Database, evts ...outbox.Event) (err error) {
+	if len(evts) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(evts))
+	for _, evt := range evts {
+		docs = append(docs, evt)
+	}
+
+	_, err = db.Collection(CollectionName).InsertMany(ctx, docs)
+	return
+}
+
+func (r *MongoRepository) Create(evt outbox.Event) (err error) {
+	_, err = r.col.InsertOne(context.Background(), evt)
+	return
+}
+
+func (r *MongoRepository) GetUnpublished(limit int) (evts []outbox.Event, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.col.Find(context.Background(), bson.M{"published_at": nil}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &evts)
+	return
+}
+
+func (r *MongoRepository) MarkPublished(id string, publishedAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"event_id": id}, bson.M{"$set": bson.M{"published_at": publishedAt}})
+	return
+}
+
+func (r *MongoRepository) MarkFailed(id string, lastError string) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"event_id": id}, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"last_error": lastError},
+	})
+	return
+}
+
+func (r *MongoRepository) RedactAggregate(aggregateType string, aggregateID string, payload string) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"aggregate_type": aggregateType, "aggregate_id": aggregateID},
+		bson.M{"$set": bson.M{"payload": payload}},
+	)
+	return
+}
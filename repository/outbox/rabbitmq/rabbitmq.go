@@ -0,0 +1,108 @@
+package rabbitmq
+
+import (
+	"belajarGo2/service/outbox"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type RabbitMQConfig struct {
+	RabbitMQURL      string
+	RabbitMQExchange string
+	PublishTimeout   time.Duration
+}
+
+type RabbitMQPublisher struct {
+	logger *slog.Logger
+	config RabbitMQConfig
+	conn   *amqp.Connection
+	ch     *amqp.Channel
+	mu     sync.Mutex
+}
+
+// NewRabbitMQPublisher connect to rabbitmq, declare a durable topic exchange and put the channel in confirm mode
+func NewRabbitMQPublisher(logger *slog.Logger, cfg RabbitMQConfig) (*RabbitMQPublisher, error) {
+	if cfg.PublishTimeout == 0 {
+		cfg.PublishTimeout = 5 * time.Second
+	}
+
+	conn, err := amqp.Dial(cfg.RabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.ExchangeDeclare(cfg.RabbitMQExchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &RabbitMQPublisher{
+		logger: logger,
+		config: cfg,
+		conn:   conn,
+		ch:     ch,
+	}, nil
+}
+
+// Publish send the event with the event type as routing key and wait for the broker confirmation
+func (p *RabbitMQPublisher) Publish(evt outbox.Event) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.PublishTimeout)
+	defer cancel()
+
+	confirmation, err := p.ch.PublishWithDeferredConfirmWithContext(ctx,
+		p.config.RabbitMQExchange,
+		evt.EventType,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    evt.ID,
+			Type:         evt.EventType,
+			Timestamp:    evt.CreatedAt,
+			Headers: amqp.Table{
+				"aggregate_type": evt.AggregateType,
+				"aggregate_id":   evt.AggregateID,
+			},
+			Body: []byte(evt.Payload),
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return
+	}
+
+	if !acked {
+		return errors.New("message nacked by broker")
+	}
+
+	return nil
+}
+
+func (p *RabbitMQPublisher) Close() {
+	_ = p.ch.Close()
+	_ = p.conn.Close()
+}
@@ -0,0 +1,46 @@
+package role
+
+import (
+	"belajarGo2/service/role"
+	"belajarGo2/util/database"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db,
+	}
+}
+
+func (r *GormRepository) roles() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_roles")
+}
+
+func (r *GormRepository) Create(rl role.Role) (err error) {
+	err = r.roles().Create(&rl).Error
+	if database.IsDuplicateKey(r.DB, err) {
+		return role.ErrDuplicateRole
+	}
+	return
+}
+
+func (r *GormRepository) GetAll() (roles []role.Role, err error) {
+	err = r.roles().Order("name").Find(&roles).Error
+	return
+}
+
+func (r *GormRepository) Update(rl role.Role) (err error) {
+	return r.roles().Where("name = ?", rl.Name).Select("description", "permissions", "updated_at").Updates(&rl).Error
+}
+
+func (r *GormRepository) Delete(name string) (err error) {
+	return r.roles().Where("name = ?", name).Delete(&role.Role{}).Error
+}
@@ -0,0 +1,71 @@
+package role
+
+import (
+	"belajarGo2/service/role"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+type MongoRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection("roles")
+
+	if err := createIndex(col); err != nil {
+		fmt.Println("Error ensuring role index:", err)
+	}
+
+	return &MongoRepository{
+		col: col,
+	}
+}
+
+func (r *MongoRepository) Create(rl role.Role) (err error) {
+	_, err = r.col.InsertOne(context.Background(), rl)
+	if mongo.IsDuplicateKeyError(err) {
+		return role.ErrDuplicateRole
+	}
+	return
+}
+
+func (r *MongoRepository) GetAll() (roles []role.Role, err error) {
+	cursor, err := r.col.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &roles)
+	return
+}
+
+func (r *MongoRepository) Update(rl role.Role) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"name": rl.Name},
+		bson.M{"$set": bson.M{
+			"description": rl.Description,
+			"permissions": rl.Permissions,
+			"updated_at":  rl.UpdatedAt,
+		}},
+	)
+	return
+}
+
+func (r *MongoRepository) Delete(name string) (err error) {
+	_, err = r.col.DeleteOne(context.Background(), bson.M{"name": name})
+	return
+}
@@ -0,0 +1,39 @@
+package signingkey
+
+import (
+	"belajarGo2/service/signingkey"
+	"belajarGo2/util/database"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db.Table("bg_signing_keys"),
+	}
+}
+
+func (r *GormRepository) Create(key signingkey.Key) (err error) {
+	err = r.DB.WithContext(context.Background()).Create(&key).Error
+	if database.IsDuplicateKey(r.DB, err) {
+		return signingkey.ErrDuplicateKey
+	}
+	return
+}
+
+func (r *GormRepository) GetAll() (keys []signingkey.Key, err error) {
+	err = r.DB.WithContext(context.Background()).Order("created_at desc").Find(&keys).Error
+	return
+}
+
+func (r *GormRepository) DeleteCreatedBefore(createdBefore time.Time) (err error) {
+	return r.DB.WithContext(context.Background()).Where("created_at < ?", createdBefore).Delete(&signingkey.Key{}).Error
+}
@@ -0,0 +1,60 @@
+package signingkey
+
+import (
+	"belajarGo2/service/signingkey"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "kid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+type MongoRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection("signing_keys")
+
+	if err := createIndex(col); err != nil {
+		fmt.Println("Error ensuring signing key index:", err)
+	}
+
+	return &MongoRepository{
+		col: col,
+	}
+}
+
+func (r *MongoRepository) Create(key signingkey.Key) (err error) {
+	_, err = r.col.InsertOne(context.Background(), key)
+	if mongo.IsDuplicateKeyError(err) {
+		return signingkey.ErrDuplicateKey
+	}
+	return
+}
+
+func (r *MongoRepository) GetAll() (keys []signingkey.Key, err error) {
+	cursor, err := r.col.Find(context.Background(), bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &keys)
+	return
+}
+
+func (r *MongoRepository) DeleteCreatedBefore(createdBefore time.Time) (err error) {
+	_, err = r.col.DeleteMany(context.Background(), bson.M{"created_at": bson.M{"$lt": createdBefore}})
+	return
+}
@@ -2,7 +2,11 @@ package user
 
 import (
 	"belajarGo2/service/user"
+	"belajarGo2/util/database"
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -19,16 +23,128 @@ func NewGormRepository(db *gorm.DB) *GormRepository {
 	}
 }
 
-func (r *GormRepository) Create(user user.User) (err error) {
-	return r.DB.WithContext(context.Background()).Create(&user).Error
+func (r *GormRepository) Create(usr user.User) (err error) {
+	err = r.DB.WithContext(context.Background()).Create(&usr).Error
+	if database.IsDuplicateKey(r.DB, err) {
+		err = user.ErrDuplicateEmail
+	}
+	return
 }
 
 func (r *GormRepository) GetByEmail(email string) (user user.User, err error) {
-	r.DB.WithContext(context.Background()).First(&user, "email = ?", email)
+	err = r.DB.WithContext(context.Background()).First(&user, "email = ?", email).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormRepository) GetByID(id string) (user user.User, err error) {
+	err = r.DB.WithContext(context.Background()).First(&user, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
 	return
 }
 
 func (r *GormRepository) UpdateEmailVerification(user user.User) (err error) {
-	err = r.DB.WithContext(context.Background()).Updates(&user).Error
+	// struct Updates skip false, so the column is set explicitly
+	err = r.DB.WithContext(context.Background()).Where("email = ?", user.Email).Update("is_email_verified", user.IsEmailVerified).Error
+	return
+}
+
+func (r *GormRepository) UpdatePassword(user user.User) (err error) {
+	err = r.DB.WithContext(context.Background()).Where("email = ?", user.Email).Update("password", user.Password).Error
+	return
+}
+
+func (r *GormRepository) GetAll(filter user.Filter, page int, limit int) (users []user.User, total int64, err error) {
+	ctx := context.Background()
+	filterScope := func(db *gorm.DB) *gorm.DB {
+		if filter.Query != "" {
+			like := "%" + strings.ToLower(filter.Query) + "%"
+			db = db.Where("LOWER(email) LIKE ? OR LOWER(fullname) LIKE ?", like, like)
+		}
+		if filter.Role != "" {
+			db = db.Where("role = ?", filter.Role)
+		}
+		if filter.Disabled != nil {
+			db = db.Where("is_disabled = ?", *filter.Disabled)
+		}
+		return db
+	}
+
+	if err = r.DB.WithContext(ctx).Scopes(filterScope).Count(&total).Error; err != nil {
+		return
+	}
+
+	err = r.DB.WithContext(ctx).Scopes(filterScope).Order("email ASC").Offset((page - 1) * limit).Limit(limit).Find(&users).Error
+	return
+}
+
+func (r *GormRepository) UpdateRole(id string, role string) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Update("role", role).Error
+}
+
+func (r *GormRepository) UpdateDisabled(id string, disabled bool) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Update("is_disabled", disabled).Error
+}
+
+// patchFields return the non nil fields of the patch, the sql columns and the mongo fields have the same name
+func patchFields(patch user.Patch) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if patch.Email != nil {
+		fields["email"] = *patch.Email
+	}
+	if patch.Fullname != nil {
+		fields["fullname"] = *patch.Fullname
+	}
+	if patch.Password != nil {
+		fields["password"] = *patch.Password
+	}
+	if patch.Role != nil {
+		fields["role"] = *patch.Role
+	}
+	if patch.IsEmailVerified != nil {
+		fields["is_email_verified"] = *patch.IsEmailVerified
+	}
+	if patch.IsDisabled != nil {
+		fields["is_disabled"] = *patch.IsDisabled
+	}
+	if patch.OrganizationID != nil {
+		fields["organization_id"] = *patch.OrganizationID
+	}
+	return fields
+}
+
+func (r *GormRepository) Update(id string, patch user.Patch) (err error) {
+	fields := patchFields(patch)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	err = r.DB.WithContext(context.Background()).Where("id = ?", id).Updates(fields).Error
+	if database.IsDuplicateKey(r.DB, err) {
+		err = user.ErrDuplicateEmail
+	}
+	return
+}
+
+func (r *GormRepository) UpdateTwoFactor(usr user.User) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", usr.ID).
+		Select("totp_secret", "is_totp_enabled", "recovery_codes").Updates(&usr).Error
+}
+
+func (r *GormRepository) Delete(id string) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Delete(&user.User{}).Error
+}
+
+func (r *GormRepository) ScheduleDeletion(id string, deleteAt *time.Time) (err error) {
+	return r.DB.WithContext(context.Background()).Where("id = ?", id).Update("delete_at", deleteAt).Error
+}
+
+func (r *GormRepository) GetDueDeletions(now time.Time, limit int) (users []user.User, err error) {
+	err = r.DB.WithContext(context.Background()).Where("delete_at IS NOT NULL AND delete_at <= ?", now).
+		Order("delete_at").Limit(limit).Find(&users).Error
 	return
 }
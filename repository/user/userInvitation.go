@@ -0,0 +1,73 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormInvitationRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormInvitationRepository(db *gorm.DB) *GormInvitationRepository {
+	return &GormInvitationRepository{
+		db,
+	}
+}
+
+func (r *GormInvitationRepository) invitations() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_invitations")
+}
+
+func (r *GormInvitationRepository) CreateInvitation(invitation user.Invitation) (err error) {
+	return r.invitations().Create(&invitation).Error
+}
+
+func (r *GormInvitationRepository) GetInvitationByID(id string) (invitation user.Invitation, err error) {
+	err = r.invitations().First(&invitation, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormInvitationRepository) GetPendingInvitationByEmail(email string) (invitation user.Invitation, err error) {
+	err = r.invitations().First(&invitation, "email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormInvitationRepository) GetPendingInvitations() (invitations []user.Invitation, err error) {
+	err = r.invitations().Where("accepted_at IS NULL AND revoked_at IS NULL").Order("created_at DESC").Find(&invitations).Error
+	return
+}
+
+func (r *GormInvitationRepository) UpdateInvitationExpiry(id string, expiresAt time.Time) (err error) {
+	return r.invitations().Where("id = ?", id).Update("expires_at", expiresAt).Error
+}
+
+func (r *GormInvitationRepository) AcceptInvitation(id string, acceptedAt time.Time) (accepted bool, err error) {
+	res := r.invitations().Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).Update("accepted_at", acceptedAt)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormInvitationRepository) RevokeInvitation(id string, revokedAt time.Time) (err error) {
+	return r.invitations().Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).Update("revoked_at", revokedAt).Error
+}
+
+func (r *GormInvitationRepository) GetInvitationsByInviter(userID string) (invitations []user.Invitation, err error) {
+	err = r.invitations().Where("invited_by = ?", userID).Order("created_at DESC").Find(&invitations).Error
+	return
+}
+
+func (r *GormInvitationRepository) AnonymizeInvitationEmail(email string, anonymizedEmail string) (err error) {
+	return r.invitations().Where("email = ?", email).Update("email", anonymizedEmail).Error
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createInvitationIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "invitation_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "invited_by", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+type MongoInvitationRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoInvitationRepository(db *mongo.Database) *MongoInvitationRepository {
+	col := db.Collection("invitations")
+
+	if err := createInvitationIndex(col); err != nil {
+		fmt.Println("Error ensuring invitation index:", err)
+	}
+
+	return &MongoInvitationRepository{
+		col: col,
+	}
+}
+
+func (r *MongoInvitationRepository) CreateInvitation(invitation user.Invitation) (err error) {
+	_, err = r.col.InsertOne(context.Background(), invitation)
+	return
+}
+
+func (r *MongoInvitationRepository) GetInvitationByID(id string) (invitation user.Invitation, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"invitation_id": id}).Decode(&invitation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoInvitationRepository) GetPendingInvitationByEmail(email string) (invitation user.Invitation, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"email": email, "accepted_at": nil, "revoked_at": nil}).Decode(&invitation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoInvitationRepository) GetPendingInvitations() (invitations []user.Invitation, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.col.Find(context.Background(), bson.M{"accepted_at": nil, "revoked_at": nil}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &invitations)
+	return
+}
+
+func (r *MongoInvitationRepository) UpdateInvitationExpiry(id string, expiresAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"invitation_id": id},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+	)
+	return
+}
+
+func (r *MongoInvitationRepository) AcceptInvitation(id string, acceptedAt time.Time) (accepted bool, err error) {
+	res, err := r.col.UpdateOne(context.Background(),
+		bson.M{"invitation_id": id, "accepted_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"accepted_at": acceptedAt}},
+	)
+	if err != nil {
+		return
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoInvitationRepository) RevokeInvitation(id string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"invitation_id": id, "accepted_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
+
+func (r *MongoInvitationRepository) GetInvitationsByInviter(userID string) (invitations []user.Invitation, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.col.Find(context.Background(), bson.M{"invited_by": userID}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &invitations)
+	return
+}
+
+func (r *MongoInvitationRepository) AnonymizeInvitationEmail(email string, anonymizedEmail string) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"email": email},
+		bson.M{"$set": bson.M{"email": anonymizedEmail}},
+	)
+	return
+}
@@ -0,0 +1,230 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keep the users in a map keyed by email, for tests and local runs without a database
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	users map[string]user.User
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users: map[string]user.User{},
+	}
+}
+
+func (r *MemoryRepository) Create(usr user.User) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[usr.Email]; ok {
+		return user.ErrDuplicateEmail
+	}
+
+	r.users[usr.Email] = usr
+	return
+}
+
+func (r *MemoryRepository) GetByEmail(email string) (user user.User, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user = r.users[email]
+	return
+}
+
+func (r *MemoryRepository) GetByID(id string) (user user.User, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return
+}
+
+func (r *MemoryRepository) UpdateEmailVerification(user user.User) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.users[user.Email]; ok {
+		existing.IsEmailVerified = user.IsEmailVerified
+		r.users[user.Email] = existing
+	}
+	return
+}
+
+func (r *MemoryRepository) UpdatePassword(user user.User) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.users[user.Email]; ok {
+		existing.Password = user.Password
+		r.users[user.Email] = existing
+	}
+	return
+}
+
+func (r *MemoryRepository) GetAll(filter user.Filter, page int, limit int) (users []user.User, total int64, err error) {
+	r.mu.RLock()
+	matched := []user.User{}
+	query := strings.ToLower(filter.Query)
+	for _, u := range r.users {
+		if query != "" && !strings.Contains(strings.ToLower(u.Email), query) && !strings.Contains(strings.ToLower(u.Fullname), query) {
+			continue
+		}
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Disabled != nil && u.IsDisabled != *filter.Disabled {
+			continue
+		}
+		matched = append(matched, u)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Email < matched[j].Email
+	})
+	total = int64(len(matched))
+
+	offset := max((page-1)*limit, 0)
+	if offset >= len(matched) {
+		return
+	}
+
+	users = matched[offset:min(offset+limit, len(matched))]
+	return
+}
+
+func (r *MemoryRepository) update(id string, fn func(u *user.User)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for email, u := range r.users {
+		if u.ID == id {
+			fn(&u)
+			r.users[email] = u
+			return
+		}
+	}
+}
+
+// updateEmail move the user to the new email key, the users are keyed by email
+func (r *MemoryRepository) updateEmail(id string, newEmail string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for email, u := range r.users {
+		if u.ID != id {
+			continue
+		}
+		if email == newEmail {
+			return nil
+		}
+		if _, ok := r.users[newEmail]; ok {
+			return user.ErrDuplicateEmail
+		}
+
+		delete(r.users, email)
+		u.Email = newEmail
+		r.users[newEmail] = u
+		return nil
+	}
+	return nil
+}
+
+func (r *MemoryRepository) UpdateRole(id string, role string) (err error) {
+	r.update(id, func(u *user.User) { u.Role = role })
+	return
+}
+
+func (r *MemoryRepository) UpdateDisabled(id string, disabled bool) (err error) {
+	r.update(id, func(u *user.User) { u.IsDisabled = disabled })
+	return
+}
+
+func (r *MemoryRepository) Update(id string, patch user.Patch) (err error) {
+	if patch.Email != nil {
+		if err = r.updateEmail(id, *patch.Email); err != nil {
+			return
+		}
+	}
+
+	r.update(id, func(u *user.User) {
+		if patch.Fullname != nil {
+			u.Fullname = *patch.Fullname
+		}
+		if patch.Password != nil {
+			u.Password = *patch.Password
+		}
+		if patch.Role != nil {
+			u.Role = *patch.Role
+		}
+		if patch.IsEmailVerified != nil {
+			u.IsEmailVerified = *patch.IsEmailVerified
+		}
+		if patch.IsDisabled != nil {
+			u.IsDisabled = *patch.IsDisabled
+		}
+		if patch.OrganizationID != nil {
+			u.OrganizationID = *patch.OrganizationID
+		}
+	})
+	return
+}
+
+func (r *MemoryRepository) UpdateTwoFactor(usr user.User) (err error) {
+	r.update(usr.ID, func(u *user.User) {
+		u.TOTPSecret = usr.TOTPSecret
+		u.IsTOTPEnabled = usr.IsTOTPEnabled
+		u.RecoveryCodes = slices.Clone(usr.RecoveryCodes)
+	})
+	return
+}
+
+func (r *MemoryRepository) Delete(id string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for email, u := range r.users {
+		if u.ID == id {
+			delete(r.users, email)
+			return
+		}
+	}
+	return
+}
+
+func (r *MemoryRepository) ScheduleDeletion(id string, deleteAt *time.Time) (err error) {
+	r.update(id, func(u *user.User) { u.DeleteAt = deleteAt })
+	return
+}
+
+func (r *MemoryRepository) GetDueDeletions(now time.Time, limit int) (users []user.User, err error) {
+	r.mu.RLock()
+	for _, u := range r.users {
+		if u.DeleteAt != nil && !u.DeleteAt.After(now) {
+			users = append(users, u)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].DeleteAt.Before(*users[j].DeleteAt)
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return
+}
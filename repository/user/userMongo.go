@@ -3,10 +3,16 @@ package user
 import (
 	"belajarGo2/service/user"
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoRepository struct {
@@ -14,13 +20,36 @@ type MongoRepository struct {
 }
 
 func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	col := db.Collection("users")
+
+	// same constraint as the sql table, creating an existing index is a no-op
+	model := mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := col.Indexes().CreateOne(context.TODO(), model); err != nil {
+		fmt.Println("Error ensuring unique index:", err)
+	}
+
+	// the accounts due for deletion are looked up by the scheduled job
+	deleteAtModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "delete_at", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+	if _, err := col.Indexes().CreateOne(context.TODO(), deleteAtModel); err != nil {
+		fmt.Println("Error ensuring delete_at index:", err)
+	}
+
 	return &MongoRepository{
-		col: db.Collection("users"),
+		col: col,
 	}
 }
 
-func (r *MongoRepository) Create(user user.User) (err error) {
-	_, err = r.col.InsertOne(context.Background(), user)
+func (r *MongoRepository) Create(usr user.User) (err error) {
+	_, err = r.col.InsertOne(context.Background(), usr)
+	if mongo.IsDuplicateKeyError(err) {
+		err = user.ErrDuplicateEmail
+	}
 	return
 }
 
@@ -35,7 +64,114 @@ func (r *MongoRepository) GetByEmail(email string) (user user.User, err error) {
 	return
 }
 
+func (r *MongoRepository) GetByID(id string) (user user.User, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"user_id": id}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
 func (r *MongoRepository) UpdateEmailVerification(user user.User) (err error) {
-	_, err = r.col.UpdateOne(context.Background(), bson.M{"email": user.Email}, bson.M{"$set": user})
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"email": user.Email}, bson.M{"$set": bson.M{"is_email_verified": user.IsEmailVerified}})
+	return
+}
+
+func (r *MongoRepository) UpdatePassword(user user.User) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"email": user.Email}, bson.M{"$set": bson.M{"password": user.Password}})
+	return
+}
+
+func (r *MongoRepository) GetAll(filter user.Filter, page int, limit int) (users []user.User, total int64, err error) {
+	ctx := context.Background()
+
+	query := bson.M{}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"fullname": pattern}}
+	}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	if filter.Disabled != nil {
+		// users created before the flag existed have no is_disabled field
+		if *filter.Disabled {
+			query["is_disabled"] = true
+		} else {
+			query["is_disabled"] = bson.M{"$ne": true}
+		}
+	}
+
+	if total, err = r.col.CountDocuments(ctx, query); err != nil {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "email", Value: 1}}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := r.col.Find(ctx, query, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &users)
+	return
+}
+
+func (r *MongoRepository) UpdateRole(id string, role string) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"user_id": id}, bson.M{"$set": bson.M{"role": role}})
+	return
+}
+
+func (r *MongoRepository) UpdateDisabled(id string, disabled bool) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"user_id": id}, bson.M{"$set": bson.M{"is_disabled": disabled}})
+	return
+}
+
+func (r *MongoRepository) Update(id string, patch user.Patch) (err error) {
+	fields := patchFields(patch)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"user_id": id}, bson.M{"$set": bson.M(fields)})
+	if mongo.IsDuplicateKeyError(err) {
+		err = user.ErrDuplicateEmail
+	}
+	return
+}
+
+func (r *MongoRepository) UpdateTwoFactor(user user.User) (err error) {
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"user_id": user.ID}, bson.M{"$set": bson.M{
+		"totp_secret":     user.TOTPSecret,
+		"is_totp_enabled": user.IsTOTPEnabled,
+		"recovery_codes":  user.RecoveryCodes,
+	}})
+	return
+}
+
+func (r *MongoRepository) Delete(id string) (err error) {
+	_, err = r.col.DeleteOne(context.Background(), bson.M{"user_id": id})
+	return
+}
+
+func (r *MongoRepository) ScheduleDeletion(id string, deleteAt *time.Time) (err error) {
+	update := bson.M{"$set": bson.M{"delete_at": deleteAt}}
+	if deleteAt == nil {
+		update = bson.M{"$unset": bson.M{"delete_at": ""}}
+	}
+
+	_, err = r.col.UpdateOne(context.Background(), bson.M{"user_id": id}, update)
+	return
+}
+
+func (r *MongoRepository) GetDueDeletions(now time.Time, limit int) (users []user.User, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "delete_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.col.Find(context.Background(), bson.M{"delete_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &users)
 	return
 }
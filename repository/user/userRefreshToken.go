@@ -0,0 +1,47 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormRefreshTokenRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRefreshTokenRepository(db *gorm.DB) *GormRefreshTokenRepository {
+	return &GormRefreshTokenRepository{
+		db.Table("bg_refresh_tokens"),
+	}
+}
+
+func (r *GormRefreshTokenRepository) CreateRefreshToken(token user.RefreshToken) (err error) {
+	return r.DB.WithContext(context.Background()).Create(&token).Error
+}
+
+func (r *GormRefreshTokenRepository) GetRefreshTokenByHash(tokenHash string) (token user.RefreshToken, err error) {
+	err = r.DB.WithContext(context.Background()).First(&token, "token_hash = ?", tokenHash).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormRefreshTokenRepository) RotateRefreshToken(id string, rotatedAt time.Time) (rotated bool, err error) {
+	res := r.DB.WithContext(context.Background()).Where("id = ? AND rotated_at IS NULL", id).Update("rotated_at", rotatedAt)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *GormRefreshTokenRepository) RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (err error) {
+	return r.DB.WithContext(context.Background()).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", revokedAt).Error
+}
+
+func (r *GormRefreshTokenRepository) RevokeUserRefreshTokens(userID string, revokedAt time.Time) (err error) {
+	return r.DB.WithContext(context.Background()).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createRefreshTokenIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "family_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+		{
+			// expired tokens are useless, let mongo drop them
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+type MongoRefreshTokenRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoRefreshTokenRepository(db *mongo.Database) *MongoRefreshTokenRepository {
+	col := db.Collection("refresh_tokens")
+
+	if err := createRefreshTokenIndex(col); err != nil {
+		fmt.Println("Error ensuring refresh token index:", err)
+	}
+
+	return &MongoRefreshTokenRepository{
+		col: col,
+	}
+}
+
+func (r *MongoRefreshTokenRepository) CreateRefreshToken(token user.RefreshToken) (err error) {
+	_, err = r.col.InsertOne(context.Background(), token)
+	return
+}
+
+func (r *MongoRefreshTokenRepository) GetRefreshTokenByHash(tokenHash string) (token user.RefreshToken, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"token_hash": tokenHash}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoRefreshTokenRepository) RotateRefreshToken(id string, rotatedAt time.Time) (rotated bool, err error) {
+	res, err := r.col.UpdateOne(context.Background(),
+		bson.M{"refresh_token_id": id, "rotated_at": nil},
+		bson.M{"$set": bson.M{"rotated_at": rotatedAt}},
+	)
+	if err != nil {
+		return
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoRefreshTokenRepository) RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"family_id": familyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
+
+func (r *MongoRefreshTokenRepository) RevokeUserRefreshTokens(userID string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
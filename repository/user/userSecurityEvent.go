@@ -0,0 +1,66 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormSecurityEventRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormSecurityEventRepository(db *gorm.DB) *GormSecurityEventRepository {
+	return &GormSecurityEventRepository{
+		db,
+	}
+}
+
+func (r *GormSecurityEventRepository) securityEvents() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_security_events")
+}
+
+func (r *GormSecurityEventRepository) CreateSecurityEvent(event user.SecurityEvent) (err error) {
+	return r.securityEvents().Create(&event).Error
+}
+
+func (r *GormSecurityEventRepository) GetSecurityEvents(filter user.SecurityEventFilter, page int, limit int) (events []user.SecurityEvent, total int64, err error) {
+	filterScope := func(db *gorm.DB) *gorm.DB {
+		if filter.EventType != "" {
+			db = db.Where("event_type = ?", filter.EventType)
+		}
+		if filter.UserID != "" {
+			db = db.Where("actor_id = ? OR subject_id = ?", filter.UserID, filter.UserID)
+		}
+		if filter.IPAddress != "" {
+			db = db.Where("ip_address = ?", filter.IPAddress)
+		}
+		if filter.Outcome != "" {
+			db = db.Where("outcome = ?", filter.Outcome)
+		}
+		if filter.From != nil {
+			db = db.Where("created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			db = db.Where("created_at < ?", *filter.To)
+		}
+		return db
+	}
+
+	if err = r.securityEvents().Scopes(filterScope).Count(&total).Error; err != nil {
+		return
+	}
+
+	err = r.securityEvents().Scopes(filterScope).Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error
+	return
+}
+
+func (r *GormSecurityEventRepository) AnonymizeUserSecurityEvents(userID string) (err error) {
+	return r.securityEvents().Where("actor_id = ? OR subject_id = ?", userID, userID).Updates(map[string]interface{}{
+		"ip_address": "",
+		"user_agent": "",
+	}).Error
+}
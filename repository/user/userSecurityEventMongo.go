@@ -0,0 +1,101 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createSecurityEventIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "security_event_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	})
+	return err
+}
+
+type MongoSecurityEventRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoSecurityEventRepository(db *mongo.Database) *MongoSecurityEventRepository {
+	col := db.Collection("security_events")
+
+	if err := createSecurityEventIndex(col); err != nil {
+		fmt.Println("Error ensuring security event index:", err)
+	}
+
+	return &MongoSecurityEventRepository{
+		col: col,
+	}
+}
+
+func (r *MongoSecurityEventRepository) CreateSecurityEvent(event user.SecurityEvent) (err error) {
+	_, err = r.col.InsertOne(context.Background(), event)
+	return
+}
+
+func (r *MongoSecurityEventRepository) GetSecurityEvents(filter user.SecurityEventFilter, page int, limit int) (events []user.SecurityEvent, total int64, err error) {
+	ctx := context.Background()
+
+	query := bson.M{}
+	if filter.EventType != "" {
+		query["event_type"] = filter.EventType
+	}
+	if filter.UserID != "" {
+		query["$or"] = bson.A{bson.M{"actor_id": filter.UserID}, bson.M{"subject_id": filter.UserID}}
+	}
+	if filter.IPAddress != "" {
+		query["ip_address"] = filter.IPAddress
+	}
+	if filter.Outcome != "" {
+		query["outcome"] = filter.Outcome
+	}
+	if filter.From != nil || filter.To != nil {
+		createdAt := bson.M{}
+		if filter.From != nil {
+			createdAt["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			createdAt["$lt"] = *filter.To
+		}
+		query["created_at"] = createdAt
+	}
+
+	if total, err = r.col.CountDocuments(ctx, query); err != nil {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "security_event_id", Value: -1}}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := r.col.Find(ctx, query, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &events)
+	return
+}
+
+func (r *MongoSecurityEventRepository) AnonymizeUserSecurityEvents(userID string) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"$or": bson.A{bson.M{"actor_id": userID}, bson.M{"subject_id": userID}}},
+		bson.M{"$set": bson.M{"ip_address": "", "user_agent": ""}},
+	)
+	return
+}
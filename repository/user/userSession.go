@@ -0,0 +1,67 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	GormSessionRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormSessionRepository(db *gorm.DB) *GormSessionRepository {
+	return &GormSessionRepository{
+		db,
+	}
+}
+
+func (r *GormSessionRepository) sessions() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_sessions")
+}
+
+func (r *GormSessionRepository) CreateSession(session user.Session) (err error) {
+	return r.sessions().Create(&session).Error
+}
+
+func (r *GormSessionRepository) GetSessionByID(id string) (session user.Session, err error) {
+	err = r.sessions().First(&session, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return
+}
+
+func (r *GormSessionRepository) GetActiveSessions(userID string, now time.Time) (sessions []user.Session, err error) {
+	err = r.sessions().Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).Order("last_seen_at DESC").Find(&sessions).Error
+	return
+}
+
+func (r *GormSessionRepository) TouchSession(id string, lastSeenAt time.Time, expiresAt time.Time) (err error) {
+	return r.sessions().Where("id = ?", id).Updates(map[string]interface{}{
+		"last_seen_at": lastSeenAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+func (r *GormSessionRepository) RevokeSession(id string, revokedAt time.Time) (err error) {
+	return r.sessions().Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", revokedAt).Error
+}
+
+func (r *GormSessionRepository) RevokeUserSessions(userID string, revokedAt time.Time) (err error) {
+	return r.sessions().Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", revokedAt).Error
+}
+
+func (r *GormSessionRepository) GetUserSessions(userID string) (sessions []user.Session, err error) {
+	err = r.sessions().Where("user_id = ?", userID).Order("created_at DESC").Find(&sessions).Error
+	return
+}
+
+func (r *GormSessionRepository) DeleteUserSessions(userID string) (err error) {
+	return r.sessions().Where("user_id = ?", userID).Delete(&user.Session{}).Error
+}
@@ -0,0 +1,117 @@
+package user
+
+import (
+	"belajarGo2/service/user"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createSessionIndex(col *mongo.Collection) error {
+	_, err := col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}},
+		},
+		{
+			// expired sessions are not listed anymore, let mongo drop them
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+type MongoSessionRepository struct {
+	col *mongo.Collection
+}
+
+func NewMongoSessionRepository(db *mongo.Database) *MongoSessionRepository {
+	col := db.Collection("sessions")
+
+	if err := createSessionIndex(col); err != nil {
+		fmt.Println("Error ensuring session index:", err)
+	}
+
+	return &MongoSessionRepository{
+		col: col,
+	}
+}
+
+func (r *MongoSessionRepository) CreateSession(session user.Session) (err error) {
+	_, err = r.col.InsertOne(context.Background(), session)
+	return
+}
+
+func (r *MongoSessionRepository) GetSessionByID(id string) (session user.Session, err error) {
+	err = r.col.FindOne(context.Background(), bson.M{"session_id": id}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return
+}
+
+func (r *MongoSessionRepository) GetActiveSessions(userID string, now time.Time) (sessions []user.Session, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+	cursor, err := r.col.Find(context.Background(), bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &sessions)
+	return
+}
+
+func (r *MongoSessionRepository) TouchSession(id string, lastSeenAt time.Time, expiresAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"session_id": id},
+		bson.M{"$set": bson.M{"last_seen_at": lastSeenAt, "expires_at": expiresAt}},
+	)
+	return
+}
+
+func (r *MongoSessionRepository) RevokeSession(id string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateOne(context.Background(),
+		bson.M{"session_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
+
+func (r *MongoSessionRepository) RevokeUserSessions(userID string, revokedAt time.Time) (err error) {
+	_, err = r.col.UpdateMany(context.Background(),
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt}},
+	)
+	return
+}
+
+func (r *MongoSessionRepository) GetUserSessions(userID string) (sessions []user.Session, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.col.Find(context.Background(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &sessions)
+	return
+}
+
+func (r *MongoSessionRepository) DeleteUserSessions(userID string) (err error) {
+	_, err = r.col.DeleteMany(context.Background(), bson.M{"user_id": userID})
+	return
+}
@@ -0,0 +1,39 @@
+package user_test
+
+import (
+	userRepo "belajarGo2/repository/user"
+	"belajarGo2/repository/user/usertest"
+	"belajarGo2/service/user"
+	"belajarGo2/util/database/databasetest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	usertest.RunRepositorySuite(t, func(t *testing.T) user.Repository {
+		return userRepo.NewMemoryRepository()
+	})
+}
+
+func TestGormRepository(t *testing.T) {
+	usertest.RunRepositorySuite(t, func(t *testing.T) user.Repository {
+		return userRepo.NewGormRepository(databasetest.NewSQLite(t, "user.sql", "session.sql", "invitation.sql", "security_event.sql"))
+	})
+}
+
+func TestGormSessionRepository(t *testing.T) {
+	usertest.RunSessionRepositorySuite(t, func(t *testing.T) user.SessionRepository {
+		return userRepo.NewGormSessionRepository(databasetest.NewSQLite(t, "user.sql", "session.sql", "invitation.sql", "security_event.sql"))
+	})
+}
+
+func TestGormInvitationRepository(t *testing.T) {
+	usertest.RunInvitationRepositorySuite(t, func(t *testing.T) user.InvitationRepository {
+		return userRepo.NewGormInvitationRepository(databasetest.NewSQLite(t, "user.sql", "session.sql", "invitation.sql", "security_event.sql"))
+	})
+}
+
+func TestGormSecurityEventRepository(t *testing.T) {
+	usertest.RunSecurityEventRepositorySuite(t, func(t *testing.T) user.SecurityEventRepository {
+		return userRepo.NewGormSecurityEventRepository(databasetest.NewSQLite(t, "user.sql", "session.sql", "invitation.sql", "security_event.sql"))
+	})
+}
@@ -0,0 +1,652 @@
+// Package usertest hold the conformance suite every user.Repository backend must pass
+package usertest
+
+import (
+	"belajarGo2/service/user"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunRepositorySuite run the suite, newRepo must return an empty repository on every call
+func RunRepositorySuite(t *testing.T, newRepo func(t *testing.T) user.Repository) {
+	usr := user.User{
+		ID:       "0b6f3c1e-6a47-4d0c-9a53-3c1f0f3b8a10",
+		Email:    "email@mail.com",
+		Password: "$2a$10$hashedpassword",
+		Fullname: "Full Name",
+		Role:     "user",
+	}
+
+	t.Run("get missing email return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetByEmail("nope@mail.com")
+		assert.NoError(t, err)
+		assert.Equal(t, user.User{}, got)
+	})
+
+	t.Run("create then get by email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		got, err := repo.GetByEmail(usr.Email)
+		assert.NoError(t, err)
+		assert.Equal(t, usr, got)
+	})
+
+	t.Run("get by id", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		got, err := repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, usr, got)
+
+		got, err = repo.GetByID("missing-id")
+		assert.NoError(t, err)
+		assert.Equal(t, user.User{}, got)
+	})
+
+	t.Run("create duplicate email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		other := usr
+		other.ID = "5e7d7c1a-2b8e-4f43-8f4e-9d2a6c0b7e21"
+		assert.ErrorIs(t, repo.Create(other), user.ErrDuplicateEmail)
+	})
+
+	t.Run("update email verification only touch the flag", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		require.NoError(t, repo.UpdateEmailVerification(user.User{Email: usr.Email, IsEmailVerified: true}))
+		got, err := repo.GetByEmail(usr.Email)
+		assert.NoError(t, err)
+
+		want := usr
+		want.IsEmailVerified = true
+		assert.Equal(t, want, got)
+
+		require.NoError(t, repo.UpdateEmailVerification(user.User{Email: usr.Email, IsEmailVerified: false}))
+		got, err = repo.GetByEmail(usr.Email)
+		assert.NoError(t, err)
+		assert.Equal(t, usr, got)
+	})
+
+	t.Run("update email verification of missing email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.NoError(t, repo.UpdateEmailVerification(user.User{Email: usr.Email, IsEmailVerified: true}))
+
+		got, err := repo.GetByEmail(usr.Email)
+		assert.NoError(t, err)
+		assert.Equal(t, user.User{}, got)
+	})
+
+	t.Run("update password only touch the password", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		require.NoError(t, repo.UpdatePassword(user.User{Email: usr.Email, Password: "$2a$10$newhashedpassword"}))
+		got, err := repo.GetByEmail(usr.Email)
+		assert.NoError(t, err)
+
+		want := usr
+		want.Password = "$2a$10$newhashedpassword"
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("get all filter sort and page", func(t *testing.T) {
+		repo := newRepo(t)
+
+		users := []user.User{
+			{ID: "id-c", Email: "carol@mail.com", Password: "x", Fullname: "Carol Admin", Role: "admin"},
+			{ID: "id-a", Email: "alice@mail.com", Password: "x", Fullname: "Alice", Role: "user"},
+			{ID: "id-b", Email: "bob@mail.com", Password: "x", Fullname: "Bob", Role: "user", IsDisabled: true},
+		}
+		for _, u := range users {
+			require.NoError(t, repo.Create(u))
+		}
+
+		emails := func(users []user.User) []string {
+			out := []string{}
+			for _, u := range users {
+				out = append(out, u.Email)
+			}
+			return out
+		}
+
+		got, total, err := repo.GetAll(user.Filter{}, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []string{"alice@mail.com", "bob@mail.com"}, emails(got))
+
+		got, total, err = repo.GetAll(user.Filter{}, 2, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []string{"carol@mail.com"}, emails(got))
+
+		got, total, err = repo.GetAll(user.Filter{Query: "ADMIN"}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []string{"carol@mail.com"}, emails(got))
+
+		got, _, err = repo.GetAll(user.Filter{Role: "user"}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice@mail.com", "bob@mail.com"}, emails(got))
+
+		disabled := false
+		got, _, err = repo.GetAll(user.Filter{Disabled: &disabled}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice@mail.com", "carol@mail.com"}, emails(got))
+	})
+
+	t.Run("update role and disabled, then delete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(usr))
+
+		require.NoError(t, repo.UpdateRole(usr.ID, "admin"))
+		require.NoError(t, repo.UpdateDisabled(usr.ID, true))
+
+		got, err := repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		want := usr
+		want.Role = "admin"
+		want.IsDisabled = true
+		assert.Equal(t, want, got)
+
+		require.NoError(t, repo.UpdateDisabled(usr.ID, false))
+		got, err = repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		assert.False(t, got.IsDisabled)
+
+		require.NoError(t, repo.Delete(usr.ID))
+		got, err = repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user.User{}, got)
+
+		assert.NoError(t, repo.Delete("missing-id"))
+	})
+
+	t.Run("update only change the fields of the patch", func(t *testing.T) {
+		repo := newRepo(t)
+
+		usr := user.User{ID: "id-1", Email: "patch@mail.com", Password: "x", Fullname: "Before", Role: "user", IsEmailVerified: true}
+		other := user.User{ID: "id-2", Email: "other@mail.com", Password: "y", Fullname: "Other", Role: "user"}
+		require.NoError(t, repo.Create(usr))
+		require.NoError(t, repo.Create(other))
+
+		fullname := "After"
+		require.NoError(t, repo.Update(usr.ID, user.Patch{Fullname: &fullname}))
+		got, err := repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		want := usr
+		want.Fullname = "After"
+		assert.Equal(t, want, got)
+
+		password, verified := "$2a$10$newhashedpassword", false
+		require.NoError(t, repo.Update(usr.ID, user.Patch{Password: &password, IsEmailVerified: &verified}))
+		got, err = repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		want.Password = password
+		want.IsEmailVerified = false
+		assert.Equal(t, want, got)
+
+		// an empty patch and a missing id are no-ops
+		assert.NoError(t, repo.Update(usr.ID, user.Patch{}))
+		assert.NoError(t, repo.Update("missing-id", user.Patch{Fullname: &fullname}))
+
+		got, err = repo.GetByID(other.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, other, got)
+	})
+
+	t.Run("update email keep the user and refuse a registered email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		usr := user.User{ID: "id-1", Email: "before@mail.com", Password: "x", Fullname: "Before", Role: "user"}
+		other := user.User{ID: "id-2", Email: "other@mail.com", Password: "y", Fullname: "Other", Role: "user", IsEmailVerified: true}
+		require.NoError(t, repo.Create(usr))
+		require.NoError(t, repo.Create(other))
+
+		email, verified := "after@mail.com", true
+		require.NoError(t, repo.Update(usr.ID, user.Patch{Email: &email, IsEmailVerified: &verified}))
+		got, err := repo.GetByEmail("after@mail.com")
+		assert.NoError(t, err)
+		want := usr
+		want.Email = email
+		want.IsEmailVerified = true
+		assert.Equal(t, want, got)
+
+		got, err = repo.GetByEmail("before@mail.com")
+		assert.NoError(t, err)
+		assert.Equal(t, user.User{}, got)
+
+		err = repo.Update(usr.ID, user.Patch{Email: &other.Email})
+		assert.ErrorIs(t, err, user.ErrDuplicateEmail)
+		got, err = repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("schedule deletion then list the due users", func(t *testing.T) {
+		repo := newRepo(t)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		early, late, future := now.Add(-2*time.Hour), now.Add(-time.Hour), now.Add(time.Hour)
+		require.NoError(t, repo.Create(user.User{ID: "id-1", Email: "late@mail.com", Password: "x", Fullname: "Late", Role: "user"}))
+		require.NoError(t, repo.Create(user.User{ID: "id-2", Email: "early@mail.com", Password: "x", Fullname: "Early", Role: "user"}))
+		require.NoError(t, repo.Create(user.User{ID: "id-3", Email: "future@mail.com", Password: "x", Fullname: "Future", Role: "user"}))
+		require.NoError(t, repo.Create(user.User{ID: "id-4", Email: "kept@mail.com", Password: "x", Fullname: "Kept", Role: "user"}))
+
+		require.NoError(t, repo.ScheduleDeletion("id-1", &late))
+		require.NoError(t, repo.ScheduleDeletion("id-2", &early))
+		require.NoError(t, repo.ScheduleDeletion("id-3", &future))
+
+		got, err := repo.GetByID("id-1")
+		assert.NoError(t, err)
+		require.NotNil(t, got.DeleteAt)
+		assert.True(t, late.Equal(*got.DeleteAt))
+
+		due, err := repo.GetDueDeletions(now, 10)
+		assert.NoError(t, err)
+		require.Len(t, due, 2)
+		assert.Equal(t, "id-2", due[0].ID)
+		assert.Equal(t, "id-1", due[1].ID)
+
+		due, err = repo.GetDueDeletions(now, 1)
+		assert.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, "id-2", due[0].ID)
+
+		require.NoError(t, repo.ScheduleDeletion("id-2", nil))
+		got, err = repo.GetByID("id-2")
+		assert.NoError(t, err)
+		assert.Nil(t, got.DeleteAt)
+
+		due, err = repo.GetDueDeletions(now, 10)
+		assert.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, "id-1", due[0].ID)
+	})
+
+	t.Run("update two factor only change the 2fa fields", func(t *testing.T) {
+		repo := newRepo(t)
+
+		usr := user.User{ID: "id-1", Email: "twofactor@mail.com", Password: "x", Fullname: "Two Factor", Role: "admin", IsEmailVerified: true}
+		require.NoError(t, repo.Create(usr))
+
+		require.NoError(t, repo.UpdateTwoFactor(user.User{ID: usr.ID, TOTPSecret: "encrypted", IsTOTPEnabled: true, RecoveryCodes: []string{"hash-1", "hash-2"}}))
+		got, err := repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		want := usr
+		want.TOTPSecret = "encrypted"
+		want.IsTOTPEnabled = true
+		want.RecoveryCodes = []string{"hash-1", "hash-2"}
+		assert.Equal(t, want, got)
+
+		require.NoError(t, repo.UpdateTwoFactor(user.User{ID: usr.ID}))
+		got, err = repo.GetByID(usr.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, usr, got)
+	})
+}
+
+// RunSessionRepositorySuite run the suite of the session backends, newRepo must return an empty repository on every call
+func RunSessionRepositorySuite(t *testing.T, newRepo func(t *testing.T) user.SessionRepository) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newSession := func(id string, userID string, lastSeenAt time.Time) user.Session {
+		return user.Session{
+			ID:         id,
+			UserID:     userID,
+			UserAgent:  "Mozilla/5.0",
+			IPAddress:  "10.0.0.1",
+			CreatedAt:  lastSeenAt,
+			LastSeenAt: lastSeenAt,
+			ExpiresAt:  lastSeenAt.Add(time.Hour),
+		}
+	}
+	sessionIDs := func(sessions []user.Session) (ids []string) {
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		return
+	}
+
+	t.Run("get missing session return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetSessionByID("missing")
+		assert.NoError(t, err)
+		assert.Empty(t, got.ID)
+	})
+
+	t.Run("active sessions are the last seen first", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateSession(newSession("session-1", "user-1", now.Add(-2*time.Minute))))
+		require.NoError(t, repo.CreateSession(newSession("session-2", "user-1", now.Add(-time.Minute))))
+		require.NoError(t, repo.CreateSession(newSession("session-3", "user-2", now)))
+		expired := newSession("session-4", "user-1", now.Add(-2*time.Hour))
+		require.NoError(t, repo.CreateSession(expired))
+
+		got, err := repo.GetSessionByID("session-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", got.UserID)
+		assert.Equal(t, "Mozilla/5.0", got.UserAgent)
+		assert.Equal(t, "10.0.0.1", got.IPAddress)
+		assert.Nil(t, got.RevokedAt)
+
+		sessions, err := repo.GetActiveSessions("user-1", now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-2", "session-1"}, sessionIDs(sessions))
+
+		require.NoError(t, repo.TouchSession("session-1", now, now.Add(time.Hour)))
+		sessions, err = repo.GetActiveSessions("user-1", now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-1", "session-2"}, sessionIDs(sessions))
+		assert.True(t, now.Equal(sessions[0].LastSeenAt))
+	})
+
+	t.Run("revoked sessions are not active", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateSession(newSession("session-1", "user-1", now)))
+		require.NoError(t, repo.CreateSession(newSession("session-2", "user-1", now)))
+		require.NoError(t, repo.CreateSession(newSession("session-3", "user-2", now)))
+
+		require.NoError(t, repo.RevokeSession("session-1", now))
+		got, err := repo.GetSessionByID("session-1")
+		assert.NoError(t, err)
+		require.NotNil(t, got.RevokedAt)
+
+		sessions, err := repo.GetActiveSessions("user-1", now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-2"}, sessionIDs(sessions))
+
+		require.NoError(t, repo.RevokeUserSessions("user-1", now))
+		sessions, err = repo.GetActiveSessions("user-1", now)
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		sessions, err = repo.GetActiveSessions("user-2", now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-3"}, sessionIDs(sessions))
+	})
+	t.Run("user sessions include the revoked ones, then delete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateSession(newSession("session-1", "user-1", now.Add(-time.Minute))))
+		require.NoError(t, repo.CreateSession(newSession("session-2", "user-1", now)))
+		require.NoError(t, repo.CreateSession(newSession("session-3", "user-2", now)))
+		require.NoError(t, repo.RevokeSession("session-2", now))
+
+		sessions, err := repo.GetUserSessions("user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-2", "session-1"}, sessionIDs(sessions))
+
+		require.NoError(t, repo.DeleteUserSessions("user-1"))
+		sessions, err = repo.GetUserSessions("user-1")
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		got, err := repo.GetSessionByID("session-1")
+		assert.NoError(t, err)
+		assert.Empty(t, got.ID)
+
+		sessions, err = repo.GetUserSessions("user-2")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session-3"}, sessionIDs(sessions))
+	})
+}
+
+// RunInvitationRepositorySuite run the suite of the invitation backends, newRepo must return an empty repository on every call
+func RunInvitationRepositorySuite(t *testing.T, newRepo func(t *testing.T) user.InvitationRepository) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newInvitation := func(id string, email string, createdAt time.Time) user.Invitation {
+		return user.Invitation{
+			ID:        id,
+			Email:     email,
+			Role:      "admin",
+			InvitedBy: "superadmin-1",
+			CreatedAt: createdAt,
+			ExpiresAt: createdAt.Add(time.Hour),
+		}
+	}
+	invitationIDs := func(invitations []user.Invitation) (ids []string) {
+		for _, invitation := range invitations {
+			ids = append(ids, invitation.ID)
+		}
+		return
+	}
+
+	t.Run("get missing invitation return zero value", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetInvitationByID("missing")
+		assert.NoError(t, err)
+		assert.Empty(t, got.ID)
+
+		got, err = repo.GetPendingInvitationByEmail("missing@mail.com")
+		assert.NoError(t, err)
+		assert.Empty(t, got.ID)
+	})
+
+	t.Run("create, get and extend", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-1", "invitee@mail.com", now)))
+
+		got, err := repo.GetInvitationByID("invitation-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "invitee@mail.com", got.Email)
+		assert.Equal(t, "admin", got.Role)
+		assert.Equal(t, "superadmin-1", got.InvitedBy)
+		assert.True(t, now.Add(time.Hour).Equal(got.ExpiresAt))
+		assert.Nil(t, got.AcceptedAt)
+		assert.Nil(t, got.RevokedAt)
+
+		require.NoError(t, repo.UpdateInvitationExpiry("invitation-1", now.Add(2*time.Hour)))
+		got, err = repo.GetPendingInvitationByEmail("invitee@mail.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "invitation-1", got.ID)
+		assert.True(t, now.Add(2*time.Hour).Equal(got.ExpiresAt))
+	})
+
+	t.Run("accepted and revoked invitations are not pending", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-1", "one@mail.com", now.Add(-2*time.Minute))))
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-2", "two@mail.com", now.Add(-time.Minute))))
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-3", "three@mail.com", now)))
+
+		pending, err := repo.GetPendingInvitations()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"invitation-3", "invitation-2", "invitation-1"}, invitationIDs(pending))
+
+		accepted, err := repo.AcceptInvitation("invitation-1", now)
+		assert.NoError(t, err)
+		assert.True(t, accepted)
+
+		// an invitation is accepted once
+		accepted, err = repo.AcceptInvitation("invitation-1", now)
+		assert.NoError(t, err)
+		assert.False(t, accepted)
+
+		require.NoError(t, repo.RevokeInvitation("invitation-2", now))
+		accepted, err = repo.AcceptInvitation("invitation-2", now)
+		assert.NoError(t, err)
+		assert.False(t, accepted)
+
+		got, err := repo.GetInvitationByID("invitation-2")
+		assert.NoError(t, err)
+		require.NotNil(t, got.RevokedAt)
+		assert.Nil(t, got.AcceptedAt)
+
+		got, err = repo.GetPendingInvitationByEmail("one@mail.com")
+		assert.NoError(t, err)
+		assert.Empty(t, got.ID)
+
+		pending, err = repo.GetPendingInvitations()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"invitation-3"}, invitationIDs(pending))
+	})
+
+	t.Run("get by inviter and anonymize the email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-1", "invitee@mail.com", now.Add(-time.Minute))))
+		require.NoError(t, repo.CreateInvitation(newInvitation("invitation-2", "invitee@mail.com", now)))
+		other := newInvitation("invitation-3", "other@mail.com", now)
+		other.InvitedBy = "admin-1"
+		require.NoError(t, repo.CreateInvitation(other))
+
+		sent, err := repo.GetInvitationsByInviter("superadmin-1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"invitation-2", "invitation-1"}, invitationIDs(sent))
+
+		sent, err = repo.GetInvitationsByInviter("missing")
+		assert.NoError(t, err)
+		assert.Empty(t, sent)
+
+		require.NoError(t, repo.AnonymizeInvitationEmail("invitee@mail.com", "deleted-user-1@anonymized.invalid"))
+		for _, id := range []string{"invitation-1", "invitation-2"} {
+			got, err := repo.GetInvitationByID(id)
+			assert.NoError(t, err)
+			assert.Equal(t, "deleted-user-1@anonymized.invalid", got.Email)
+		}
+
+		got, err := repo.GetInvitationByID("invitation-3")
+		assert.NoError(t, err)
+		assert.Equal(t, "other@mail.com", got.Email)
+	})
+}
+
+// RunSecurityEventRepositorySuite run the suite of the security event backends, newRepo must return an empty repository on every call
+func RunSecurityEventRepositorySuite(t *testing.T, newRepo func(t *testing.T) user.SecurityEventRepository) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newEvent := func(id string, eventType string, subjectID string, createdAt time.Time) user.SecurityEvent {
+		return user.SecurityEvent{
+			ID:        id,
+			EventType: eventType,
+			SubjectID: subjectID,
+			IPAddress: "10.0.0.1",
+			UserAgent: "Mozilla/5.0",
+			Outcome:   user.OutcomeSuccess,
+			CreatedAt: createdAt,
+		}
+	}
+	eventIDs := func(events []user.SecurityEvent) (ids []string) {
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return
+	}
+
+	t.Run("empty log", func(t *testing.T) {
+		repo := newRepo(t)
+
+		events, total, err := repo.GetSecurityEvents(user.SecurityEventFilter{}, 1, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+		assert.Zero(t, total)
+	})
+
+	t.Run("create and get the newest first", func(t *testing.T) {
+		repo := newRepo(t)
+
+		failed := newEvent("event-1", user.SecurityLoginFailed, "user-1", now.Add(-2*time.Minute))
+		failed.Outcome = user.OutcomeFailure
+		failed.Detail = "wrong password"
+		require.NoError(t, repo.CreateSecurityEvent(failed))
+		require.NoError(t, repo.CreateSecurityEvent(newEvent("event-2", user.SecurityLoginSucceeded, "user-1", now.Add(-time.Minute))))
+		require.NoError(t, repo.CreateSecurityEvent(newEvent("event-3", user.SecurityLogout, "user-1", now)))
+
+		events, total, err := repo.GetSecurityEvents(user.SecurityEventFilter{}, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []string{"event-3", "event-2"}, eventIDs(events))
+
+		events, _, err = repo.GetSecurityEvents(user.SecurityEventFilter{}, 2, 2)
+		assert.NoError(t, err)
+		require.Equal(t, []string{"event-1"}, eventIDs(events))
+		assert.Equal(t, user.SecurityLoginFailed, events[0].EventType)
+		assert.Equal(t, user.OutcomeFailure, events[0].Outcome)
+		assert.Equal(t, "wrong password", events[0].Detail)
+		assert.Equal(t, "10.0.0.1", events[0].IPAddress)
+		assert.Equal(t, "Mozilla/5.0", events[0].UserAgent)
+		assert.True(t, now.Add(-2*time.Minute).Equal(events[0].CreatedAt))
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		repo := newRepo(t)
+
+		roleChanged := newEvent("event-1", user.SecurityRoleChanged, "user-2", now.Add(-2*time.Hour))
+		roleChanged.ActorID = "user-1"
+		require.NoError(t, repo.CreateSecurityEvent(roleChanged))
+		failed := newEvent("event-2", user.SecurityLoginFailed, "user-2", now.Add(-time.Hour))
+		failed.Outcome = user.OutcomeFailure
+		failed.IPAddress = "10.0.0.2"
+		require.NoError(t, repo.CreateSecurityEvent(failed))
+		require.NoError(t, repo.CreateSecurityEvent(newEvent("event-3", user.SecurityLoginSucceeded, "user-3", now)))
+
+		from := now.Add(-90 * time.Minute)
+		to := now
+		tests := []struct {
+			name   string
+			filter user.SecurityEventFilter
+			want   []string
+		}{
+			{name: "event type", filter: user.SecurityEventFilter{EventType: user.SecurityLoginFailed}, want: []string{"event-2"}},
+			{name: "user is the actor or the subject", filter: user.SecurityEventFilter{UserID: "user-1"}, want: []string{"event-1"}},
+			{name: "user is the subject", filter: user.SecurityEventFilter{UserID: "user-2"}, want: []string{"event-2", "event-1"}},
+			{name: "ip address", filter: user.SecurityEventFilter{IPAddress: "10.0.0.2"}, want: []string{"event-2"}},
+			{name: "outcome", filter: user.SecurityEventFilter{Outcome: user.OutcomeSuccess}, want: []string{"event-3", "event-1"}},
+			{name: "from is inclusive and to exclusive", filter: user.SecurityEventFilter{From: &from, To: &to}, want: []string{"event-2"}},
+			{name: "combined", filter: user.SecurityEventFilter{UserID: "user-2", Outcome: user.OutcomeSuccess}, want: []string{"event-1"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				events, total, err := repo.GetSecurityEvents(tt.filter, 1, 10)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(tt.want)), total)
+				assert.Equal(t, tt.want, eventIDs(events))
+			})
+		}
+	})
+
+	t.Run("anonymize the events of a user", func(t *testing.T) {
+		repo := newRepo(t)
+
+		roleChanged := newEvent("event-1", user.SecurityRoleChanged, "user-2", now.Add(-time.Minute))
+		roleChanged.ActorID = "user-1"
+		require.NoError(t, repo.CreateSecurityEvent(roleChanged))
+		require.NoError(t, repo.CreateSecurityEvent(newEvent("event-2", user.SecurityLoginSucceeded, "user-1", now)))
+		require.NoError(t, repo.CreateSecurityEvent(newEvent("event-3", user.SecurityLoginSucceeded, "user-2", now)))
+
+		require.NoError(t, repo.AnonymizeUserSecurityEvents("user-1"))
+
+		events, total, err := repo.GetSecurityEvents(user.SecurityEventFilter{}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		for _, event := range events {
+			if event.ID == "event-3" {
+				assert.Equal(t, "10.0.0.1", event.IPAddress)
+				assert.Equal(t, "Mozilla/5.0", event.UserAgent)
+				continue
+			}
+			assert.Empty(t, event.IPAddress, event.ID)
+			assert.Empty(t, event.UserAgent, event.ID)
+		}
+	})
+}
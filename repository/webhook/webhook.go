@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"belajarGo2/service/webhook"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	GormRepository struct {
+		*gorm.DB
+	}
+)
+
+func NewGormRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		db,
+	}
+}
+
+func (r *GormRepository) subscriptions() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_webhook_subscriptions")
+}
+
+func (r *GormRepository) deliveries() *gorm.DB {
+	return r.DB.WithContext(context.Background()).Table("bg_webhook_deliveries")
+}
+
+func (r *GormRepository) CreateSubscription(sub webhook.Subscription) (err error) {
+	return r.subscriptions().Create(&sub).Error
+}
+
+func (r *GormRepository) GetSubscriptions(organizationID string) (subs []webhook.Subscription, err error) {
+	err = r.subscriptions().Where("organization_id = ?", organizationID).Order("created_at ASC").Find(&subs).Error
+	return
+}
+
+func (r *GormRepository) GetSubscriptionByID(organizationID string, id string) (sub webhook.Subscription, err error) {
+	err = r.subscriptions().Where("organization_id = ? AND id = ?", organizationID, id).Limit(1).Find(&sub).Error
+	return
+}
+
+func (r *GormRepository) DeleteSubscription(organizationID string, id string) (err error) {
+	return r.subscriptions().Where("organization_id = ? AND id = ?", organizationID, id).Delete(&webhook.Subscription{}).Error
+}
+
+func (r *GormRepository) GetDeliverySubscription(id string) (sub webhook.Subscription, err error) {
+	err = r.subscriptions().Where("id = ?", id).Limit(1).Find(&sub).Error
+	return
+}
+
+func (r *GormRepository) CreateDeliveries(deliveries []webhook.Delivery) (err error) {
+	return r.deliveries().Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+func (r *GormRepository) GetDeliveryByID(id string) (delivery webhook.Delivery, err error) {
+	err = r.deliveries().Where("id = ?", id).Limit(1).Find(&delivery).Error
+	return
+}
+
+func (r *GormRepository) GetDeliveries(subscriptionID string, page int, limit int) (deliveries []webhook.Delivery, err error) {
+	err = r.deliveries().Where("subscription_id = ?", subscriptionID).Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error
+	return
+}
+
+func (r *GormRepository) GetDueDeliveries(now time.Time, limit int) (deliveries []webhook.Delivery, err error) {
+	err = r.deliveries().Where("status = ? AND next_attempt_at <= ?", webhook.DeliveryStatusPending, now).Order("next_attempt_at ASC").Limit(limit).Find(&deliveries).Error
+	return
+}
+
+func (r *GormRepository) UpdateDelivery(delivery webhook.Delivery) (err error) {
+	return r.deliveries().Where("id = ?", delivery.ID).Select("*").Updates(&delivery).Error
+}
+
+func (r *GormRepository) GetAggregateDeliveries(aggregateType string, aggregateID string) (deliveries []webhook.Delivery, err error) {
+	err = r.deliveries().Where("aggregate_type = ? AND aggregate_id = ?", aggregateType, aggregateID).Order("created_at ASC").Find(&deliveries).Error
+	return
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"belajarGo2/service/webhook"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func createWebhookIndex(subCol *mongo.Collection, deliveryCol *mongo.Collection) error {
+	ctx := context.TODO()
+
+	_, err := subCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "organization_id", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = deliveryCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "delivery_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// one delivery per subscription and event, relay retries must not duplicate it
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "aggregate_type", Value: 1}, {Key: "aggregate_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+type MongoRepository struct {
+	subCol      *mongo.Collection
+	deliveryCol *mongo.Collection
+}
+
+func NewMongoRepository(db *mongo.Database) *MongoRepository {
+	subCol := db.Collection("webhook_subscriptions")
+	deliveryCol := db.Collection("webhook_deliveries")
+
+	if err := createWebhookIndex(subCol, deliveryCol); err != nil {
+		fmt.Println("Error ensuring webhook index:", err)
+	}
+
+	return &MongoRepository{
+		subCol:      subCol,
+		deliveryCol: deliveryCol,
+	}
+}
+
+func (r *MongoRepository) CreateSubscription(sub webhook.Subscription) (err error) {
+	_, err = r.subCol.InsertOne(context.Background(), sub)
+	return
+}
+
+func (r *MongoRepository) GetSubscriptions(organizationID string) (subs []webhook.Subscription, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.subCol.Find(context.Background(), organizationFilter(organizationID, bson.M{}), opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &subs)
+	return
+}
+
+func (r *MongoRepository) GetSubscriptionByID(organizationID string, id string) (sub webhook.Subscription, err error) {
+	return r.findSubscription(organizationFilter(organizationID, bson.M{"subscription_id": id}))
+}
+
+func (r *MongoRepository) DeleteSubscription(organizationID string, id string) (err error) {
+	_, err = r.subCol.DeleteOne(context.Background(), organizationFilter(organizationID, bson.M{"subscription_id": id}))
+	return
+}
+
+func (r *MongoRepository) GetDeliverySubscription(id string) (sub webhook.Subscription, err error) {
+	return r.findSubscription(bson.M{"subscription_id": id})
+}
+
+func (r *MongoRepository) findSubscription(filter bson.M) (sub webhook.Subscription, err error) {
+	err = r.subCol.FindOne(context.Background(), filter).Decode(&sub)
+	if err != nil {
+		if strings.Contains(err.Error(), "no documents") {
+			err = nil
+			return
+		}
+	}
+	return
+}
+
+// organizationFilter match the subscriptions of the organization, the subscriptions stored before the
+// organizations have no organization_id and belong to no organization
+func organizationFilter(organizationID string, filter bson.M) bson.M {
+	if organizationID == "" {
+		filter["organization_id"] = bson.M{"$in": bson.A{"", nil}}
+		return filter
+	}
+
+	filter["organization_id"] = organizationID
+	return filter
+}
+
+func (r *MongoRepository) CreateDeliveries(deliveries []webhook.Delivery) (err error) {
+	docs := make([]interface{}, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		docs = append(docs, delivery)
+	}
+
+	_, err = r.deliveryCol.InsertMany(context.Background(), docs, options.InsertMany().SetOrdered(false))
+	if err != nil && isOnlyDuplicateKeyError(err) {
+		return nil
+	}
+	return
+}
+
+func isOnlyDuplicateKeyError(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) {
+		return mongo.IsDuplicateKeyError(err)
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+
+	return bulkErr.WriteConcernError == nil
+}
+
+func (r *MongoRepository) GetDeliveryByID(id string) (delivery webhook.Delivery, err error) {
+	err = r.deliveryCol.FindOne(context.Background(), bson.M{"delivery_id": id}).Decode(&delivery)
+	if err != nil {
+		if strings.Contains(err.Error(), "no documents") {
+			err = nil
+			return
+		}
+	}
+	return
+}
+
+func (r *MongoRepository) GetDeliveries(subscriptionID string, page int, limit int) (deliveries []webhook.Delivery, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := r.deliveryCol.Find(context.Background(), bson.M{"subscription_id": subscriptionID}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &deliveries)
+	return
+}
+
+func (r *MongoRepository) GetDueDeliveries(now time.Time, limit int) (deliveries []webhook.Delivery, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.deliveryCol.Find(context.Background(), bson.M{
+		"status":          webhook.DeliveryStatusPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &deliveries)
+	return
+}
+
+func (r *MongoRepository) UpdateDelivery(delivery webhook.Delivery) (err error) {
+	_, err = r.deliveryCol.UpdateOne(context.Background(), bson.M{"delivery_id": delivery.ID}, bson.M{"$set": delivery})
+	return
+}
+
+func (r *MongoRepository) GetAggregateDeliveries(aggregateType string, aggregateID string) (deliveries []webhook.Delivery, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.deliveryCol.Find(context.Background(), bson.M{"aggregate_type": aggregateType, "aggregate_id": aggregateID}, opts)
+	if err != nil {
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	err = cursor.All(context.Background(), &deliveries)
+	return
+}
@@ -0,0 +1,43 @@
+package apikey
+
+import "time"
+
+type (
+	// APIKey is owned by a user and act with the owner role, restricted to its scopes and to the
+	// organization active when it was created. Only the sha256 of the key is stored, Prefix is kept to recognize the key in the listing
+	APIKey struct {
+		ID     string `json:"id" bson:"api_key_id"`
+		UserID string `json:"user_id" bson:"user_id"`
+		// OrganizationID scope the inventory requests made with the key
+		OrganizationID string     `json:"organization_id" bson:"organization_id"`
+		Name           string     `json:"name"`
+		Prefix         string     `json:"prefix"`
+		KeyHash        string     `json:"-" bson:"key_hash"`
+		Scopes         []string   `json:"scopes" gorm:"serializer:json"`
+		ExpiresAt      *time.Time `json:"expires_at" bson:"expires_at"`
+		LastUsedAt     *time.Time `json:"last_used_at" bson:"last_used_at"`
+		RevokedAt      *time.Time `json:"revoked_at" bson:"revoked_at"`
+		CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	}
+)
+
+const (
+	ScopeInventoryRead   = "inventory:read"
+	ScopeInventoryWrite  = "inventory:write"
+	ScopeInventoryDelete = "inventory:delete"
+	ScopeWebhookAdmin    = "webhook:admin"
+
+	// KeyPrefix start every plaintext key, so a leaked key is easy to spot
+	KeyPrefix = "bgk_"
+)
+
+var Scopes = []string{ScopeInventoryRead, ScopeInventoryWrite, ScopeInventoryDelete, ScopeWebhookAdmin}
+
+// IsActive report whether the key can still be used
+func (k APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,19 @@
+package apikey
+
+import (
+	"belajarGo2/service/user"
+	"time"
+)
+
+type Repository interface {
+	Create(key APIKey) (err error)
+	GetByHash(keyHash string) (key APIKey, err error)
+	GetByUserID(userID string) (keys []APIKey, err error)
+	Revoke(id string, revokedAt time.Time) (err error)
+	UpdateLastUsed(id string, lastUsedAt time.Time) (err error)
+}
+
+// UserRepository resolve the owner of a key, user.Repository satisfy it
+type UserRepository interface {
+	GetByID(id string) (usr user.User, err error)
+}
@@ -0,0 +1,167 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	// LastUsedInterval limit how often the last used time is written, a key used on every request
+	// would otherwise write on every request
+	LastUsedInterval time.Duration
+}
+
+type service struct {
+	logger   *slog.Logger
+	repo     Repository
+	userRepo UserRepository
+	config   Config
+}
+
+type Service interface {
+	// Create return the plaintext key, it is not stored and can't be shown again
+	Create(userID string, organizationID string, name string, scopes []string, expiresAt *time.Time) (key APIKey, plaintext string, err error)
+	GetAll(userID string) (keys []APIKey, err error)
+	Revoke(userID string, id string) (err error)
+
+	// Authenticate resolve a plaintext key to its owner and organization, it is used by the X-API-Key middleware
+	Authenticate(plaintext string) (userID string, role string, organizationID string, scopes []string, err error)
+
+	// ExportUserData list every key of the user for the personal data export, it satisfy user.DataExporter
+	ExportUserData(userID string) (data interface{}, err error)
+}
+
+var (
+	ErrKeyNotFound   = errors.New("api key not found")
+	ErrInvalidKey    = errors.New("invalid, expired or revoked api key")
+	ErrInvalidScope  = errors.New("invalid api key scope")
+	ErrInvalidExpiry = errors.New("api key expiry must be in the future")
+)
+
+func NewService(logger *slog.Logger, repo Repository, userRepo UserRepository, cfg Config) Service {
+	if cfg.LastUsedInterval == 0 {
+		cfg.LastUsedInterval = time.Minute
+	}
+
+	return &service{
+		logger:   logger,
+		repo:     repo,
+		userRepo: userRepo,
+		config:   cfg,
+	}
+}
+
+func (s *service) Create(userID string, organizationID string, name string, scopes []string, expiresAt *time.Time) (key APIKey, plaintext string, err error) {
+	if len(scopes) == 0 {
+		return key, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return key, "", ErrInvalidScope
+		}
+	}
+
+	timeNow := time.Now()
+	if expiresAt != nil && !expiresAt.After(timeNow) {
+		return key, "", ErrInvalidExpiry
+	}
+
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	plaintext = KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key = APIKey{
+		ID:             uuid.NewString(),
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Name:           name,
+		Prefix:         plaintext[:len(KeyPrefix)+8],
+		KeyHash:        hashKey(plaintext),
+		Scopes:         slices.Compact(slices.Sorted(slices.Values(scopes))),
+		ExpiresAt:      expiresAt,
+		CreatedAt:      timeNow,
+	}
+	if err = s.repo.Create(key); err != nil {
+		return APIKey{}, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+func (s *service) GetAll(userID string) (keys []APIKey, err error) {
+	return s.repo.GetByUserID(userID)
+}
+
+func (s *service) ExportUserData(userID string) (data interface{}, err error) {
+	keys, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return
+	}
+
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	return keys, nil
+}
+
+func (s *service) Revoke(userID string, id string) (err error) {
+	keys, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return
+	}
+
+	// a user only see and revoke the own keys
+	idx := slices.IndexFunc(keys, func(k APIKey) bool { return k.ID == id })
+	if idx < 0 {
+		return ErrKeyNotFound
+	}
+	if keys[idx].RevokedAt != nil {
+		return nil
+	}
+
+	return s.repo.Revoke(id, time.Now())
+}
+
+func (s *service) Authenticate(plaintext string) (userID string, role string, organizationID string, scopes []string, err error) {
+	key, err := s.repo.GetByHash(hashKey(plaintext))
+	if err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	if key.ID == "" || !key.IsActive(timeNow) {
+		return "", "", "", nil, ErrInvalidKey
+	}
+
+	// the key act with the current role of the owner, a disabled or deleted owner disable the key
+	owner, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return
+	}
+	if owner.ID == "" || owner.IsDisabled {
+		return "", "", "", nil, ErrInvalidKey
+	}
+
+	if key.LastUsedAt == nil || timeNow.Sub(*key.LastUsedAt) >= s.config.LastUsedInterval {
+		if err := s.repo.UpdateLastUsed(key.ID, timeNow); err != nil {
+			s.logger.Error("api key last used err", slog.String("id", key.ID), slog.Any("err", err.Error()))
+		}
+	}
+
+	return owner.ID, owner.Role, key.OrganizationID, key.Scopes, nil
+}
+
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package apikey_test
+
+import (
+	"belajarGo2/service/apikey"
+	mock_apikey "belajarGo2/service/apikey/mock"
+	"belajarGo2/service/user"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+func TestCreate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		scopes    []string
+		expiresAt *time.Time
+		mockRepo  func(m *mock_apikey.MockRepository)
+		wantErr   error
+	}{
+		{
+			name:    "no scope",
+			wantErr: apikey.ErrInvalidScope,
+		},
+		{
+			name:    "unknown scope",
+			scopes:  []string{apikey.ScopeInventoryRead, "user:admin"},
+			wantErr: apikey.ErrInvalidScope,
+		},
+		{
+			name:      "expiry in the past",
+			scopes:    []string{apikey.ScopeInventoryRead},
+			expiresAt: &past,
+			wantErr:   apikey.ErrInvalidExpiry,
+		},
+		{
+			name:   "error on Create",
+			scopes: []string{apikey.ScopeInventoryRead},
+			mockRepo: func(m *mock_apikey.MockRepository) {
+				m.EXPECT().Create(gomock.Any()).Return(errors.New("db error"))
+			},
+			wantErr: errors.New("db error"),
+		},
+		{
+			name:      "created",
+			scopes:    []string{apikey.ScopeInventoryWrite, apikey.ScopeInventoryRead, apikey.ScopeInventoryRead},
+			expiresAt: &future,
+			mockRepo: func(m *mock_apikey.MockRepository) {
+				m.EXPECT().Create(gomock.Any()).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mock_apikey.NewMockRepository(ctrl)
+			if tt.mockRepo != nil {
+				tt.mockRepo(repo)
+			}
+
+			svc := apikey.NewService(logger, repo, mock_apikey.NewMockUserRepository(ctrl), apikey.Config{})
+			key, plaintext, err := svc.Create("user-1", "org-1", "backup script", tt.scopes, tt.expiresAt)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(plaintext, apikey.KeyPrefix))
+			assert.True(t, strings.HasPrefix(plaintext, key.Prefix))
+			assert.NotContains(t, key.KeyHash, plaintext)
+			assert.Equal(t, "user-1", key.UserID)
+			assert.Equal(t, "org-1", key.OrganizationID)
+			assert.Equal(t, []string{apikey.ScopeInventoryRead, apikey.ScopeInventoryWrite}, key.Scopes)
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	var stored apikey.APIKey
+	ctrl := gomock.NewController(t)
+	repo := mock_apikey.NewMockRepository(ctrl)
+	userRepo := mock_apikey.NewMockUserRepository(ctrl)
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(key apikey.APIKey) error {
+		stored = key
+		return nil
+	})
+
+	svc := apikey.NewService(logger, repo, userRepo, apikey.Config{})
+	_, plaintext, err := svc.Create("user-1", "org-1", "backup script", []string{apikey.ScopeInventoryRead}, nil)
+	require.NoError(t, err)
+
+	t.Run("unknown key", func(t *testing.T) {
+		repo.EXPECT().GetByHash(gomock.Any()).Return(apikey.APIKey{}, nil)
+
+		_, _, _, _, err := svc.Authenticate("bgk_unknown")
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("authenticated with the owner role and record the last use", func(t *testing.T) {
+		repo.EXPECT().GetByHash(stored.KeyHash).Return(stored, nil)
+		userRepo.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Role: user.RoleAdmin}, nil)
+		repo.EXPECT().UpdateLastUsed(stored.ID, gomock.Any()).Return(nil)
+
+		userID, role, organizationID, scopes, err := svc.Authenticate(plaintext)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", userID)
+		assert.Equal(t, user.RoleAdmin, role)
+		assert.Equal(t, "org-1", organizationID)
+		assert.Equal(t, []string{apikey.ScopeInventoryRead}, scopes)
+	})
+
+	t.Run("last use is not written on every request", func(t *testing.T) {
+		usedAt := time.Now()
+		recent := stored
+		recent.LastUsedAt = &usedAt
+		repo.EXPECT().GetByHash(stored.KeyHash).Return(recent, nil)
+		userRepo.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Role: user.RoleAdmin}, nil)
+
+		_, _, _, _, err := svc.Authenticate(plaintext)
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled owner", func(t *testing.T) {
+		repo.EXPECT().GetByHash(stored.KeyHash).Return(stored, nil)
+		userRepo.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Role: user.RoleAdmin, IsDisabled: true}, nil)
+
+		_, _, _, _, err := svc.Authenticate(plaintext)
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		expiredAt := time.Now().Add(-time.Minute)
+		expired := stored
+		expired.ExpiresAt = &expiredAt
+		repo.EXPECT().GetByHash(stored.KeyHash).Return(expired, nil)
+
+		_, _, _, _, err := svc.Authenticate(plaintext)
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		revokedAt := time.Now()
+		revoked := stored
+		revoked.RevokedAt = &revokedAt
+		repo.EXPECT().GetByHash(stored.KeyHash).Return(revoked, nil)
+
+		_, _, _, _, err := svc.Authenticate(plaintext)
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_apikey.NewMockRepository(ctrl)
+	svc := apikey.NewService(logger, repo, mock_apikey.NewMockUserRepository(ctrl), apikey.Config{})
+
+	repo.EXPECT().GetByUserID("user-1").Return([]apikey.APIKey{{ID: "key-1", UserID: "user-1"}}, nil).Times(2)
+	repo.EXPECT().Revoke("key-1", gomock.Any()).Return(nil)
+
+	assert.NoError(t, svc.Revoke("user-1", "key-1"))
+	// the key of another user is not found
+	assert.ErrorIs(t, svc.Revoke("user-1", "key-2"), apikey.ErrKeyNotFound)
+}
@@ -1,11 +1,41 @@
 package inventory
 
 type (
+	// Inventory belong to an organization, the code is unique in the organization only
 	Inventory struct {
-		Code        string `json:"code"`
-		Name        string `json:"name"`
-		Stock       int    `json:"stock"`
-		Description string `json:"description"`
-		Status      string `json:"status"`
+		OrganizationID string `json:"organization_id" bson:"organization_id"`
+		Code           string `json:"code"`
+		Name           string `json:"name"`
+		Stock          int    `json:"stock"`
+		Description    string `json:"description"`
+		Status         string `json:"status"`
 	}
+
+	StockAdjustment struct {
+		OrganizationID string `json:"organization_id"`
+		Code           string `json:"code"`
+		PreviousStock  int    `json:"previous_stock"`
+		Stock          int    `json:"stock"`
+		Delta          int    `json:"delta"`
+	}
+
+	SearchHit struct {
+		Inventory
+		Highlights map[string][]string `json:"highlights,omitempty"`
+	}
+
+	SearchResult struct {
+		Total  int64            `json:"total"`
+		Hits   []SearchHit      `json:"hits"`
+		Facets map[string]int64 `json:"facets"`
+	}
+)
+
+const (
+	EventAggregateType = "inventory"
+
+	EventCreated       = "inventory.created"
+	EventUpdated       = "inventory.updated"
+	EventStockAdjusted = "inventory.stock_adjusted"
+	EventDeleted       = "inventory.deleted"
 )
@@ -0,0 +1,188 @@
+package inventory
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type CacheConfig struct {
+	KeyPrefix string
+	ItemTTL   time.Duration
+	ListTTL   time.Duration
+}
+
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+type cachedService struct {
+	Service
+	logger *slog.Logger
+	cache  Cache
+	config CacheConfig
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type CachedService interface {
+	Service
+	Stats() CacheStats
+}
+
+// NewCachedService wrap the service with a read-through cache on GetByCode and GetAll.
+// Concurrent misses on the same key only hit the database once, writes invalidate the item
+// and every cached page of the organization (pages are versioned since the cache cannot delete by prefix).
+// Every key contain the organization id.
+func NewCachedService(logger *slog.Logger, next Service, c Cache, cfg CacheConfig) CachedService {
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "inventory:"
+	}
+	if cfg.ItemTTL == 0 {
+		cfg.ItemTTL = 5 * time.Minute
+	}
+	if cfg.ListTTL == 0 {
+		cfg.ListTTL = time.Minute
+	}
+
+	return &cachedService{
+		Service: next,
+		logger:  logger,
+		cache:   c,
+		config:  cfg,
+	}
+}
+
+func (s *cachedService) Stats() CacheStats {
+	return CacheStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+	}
+}
+
+func (s *cachedService) itemKey(organizationID string, code string) string {
+	return s.config.KeyPrefix + organizationID + ":code:" + code
+}
+
+func (s *cachedService) listVersionKey(organizationID string) string {
+	return s.config.KeyPrefix + organizationID + ":list:version"
+}
+
+func (s *cachedService) listKey(organizationID string, page int, limit int) string {
+	var version string
+	_ = s.cache.Get(s.listVersionKey(organizationID), &version)
+
+	return fmt.Sprintf("%v%v:list:%v:%v:%v", s.config.KeyPrefix, organizationID, version, page, limit)
+}
+
+func (s *cachedService) GetByCode(organizationID string, code string) (inv Inventory, err error) {
+	key := s.itemKey(organizationID, code)
+
+	var cached *Inventory
+	if err := s.cache.Get(key, &cached); err != nil {
+		s.logger.Error("inventory cache get err", slog.String("key", key), slog.Any("err", err.Error()))
+	}
+	if cached != nil {
+		s.hits.Add(1)
+		return *cached, nil
+	}
+	s.misses.Add(1)
+
+	// not found is cached as well, an empty code is what the controller expect for it
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		inv, err := s.Service.GetByCode(organizationID, code)
+		if err != nil {
+			return inv, err
+		}
+
+		if err := s.cache.Set(key, inv, s.config.ItemTTL); err != nil {
+			s.logger.Error("inventory cache set err", slog.String("key", key), slog.Any("err", err.Error()))
+		}
+
+		return inv, nil
+	})
+	if err != nil {
+		return
+	}
+
+	return v.(Inventory), nil
+}
+
+func (s *cachedService) GetAll(organizationID string, page int, limit int) (invs []Inventory, err error) {
+	key := s.listKey(organizationID, page, limit)
+
+	var cached *[]Inventory
+	if err := s.cache.Get(key, &cached); err != nil {
+		s.logger.Error("inventory cache get err", slog.String("key", key), slog.Any("err", err.Error()))
+	}
+	if cached != nil {
+		s.hits.Add(1)
+		return *cached, nil
+	}
+	s.misses.Add(1)
+
+	v, err, _ := s.group.Do(key, func() (interface{}, error) {
+		invs, err := s.Service.GetAll(organizationID, page, limit)
+		if err != nil {
+			return invs, err
+		}
+
+		if invs == nil {
+			invs = []Inventory{}
+		}
+
+		if err := s.cache.Set(key, invs, s.config.ListTTL); err != nil {
+			s.logger.Error("inventory cache set err", slog.String("key", key), slog.Any("err", err.Error()))
+		}
+
+		return invs, nil
+	})
+	if err != nil {
+		return
+	}
+
+	return v.([]Inventory), nil
+}
+
+func (s *cachedService) Create(organizationID string, inv Inventory) (err error) {
+	if err = s.Service.Create(organizationID, inv); err != nil {
+		return
+	}
+
+	s.invalidate(organizationID, inv.Code)
+	return nil
+}
+
+func (s *cachedService) Update(organizationID string, inv Inventory) (err error) {
+	if err = s.Service.Update(organizationID, inv); err != nil {
+		return
+	}
+
+	s.invalidate(organizationID, inv.Code)
+	return nil
+}
+
+func (s *cachedService) Delete(organizationID string, code string) (err error) {
+	if err = s.Service.Delete(organizationID, code); err != nil {
+		return
+	}
+
+	s.invalidate(organizationID, code)
+	return nil
+}
+
+// invalidate drop the item and move every page of the organization to a new version, old pages expire by their ttl
+func (s *cachedService) invalidate(organizationID string, code string) {
+	s.cache.Delete(s.itemKey(organizationID, code))
+
+	version := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := s.cache.Set(s.listVersionKey(organizationID), version, 0); err != nil {
+		s.logger.Error("inventory cache invalidate err", slog.String("code", code), slog.Any("err", err.Error()))
+	}
+}
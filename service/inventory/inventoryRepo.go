@@ -1,10 +1,45 @@
 package inventory
 
+import (
+	"belajarGo2/service/outbox"
+	"errors"
+	"time"
+)
+
+// ErrDuplicateCode is returned by every repository when the inventory code is already used,
+// the message keep "duplicate key" since the controllers still match on it
+var ErrDuplicateCode = errors.New("duplicate key: inventory code already exists")
+
+// ErrOrganizationRequired is returned by every repository for an empty organization id,
+// there is no query across the organizations
+var ErrOrganizationRequired = errors.New("inventory organization is required")
+
+// Repository only read and write the inventories of the given organization, Create and Update
+// set the organization of the inventory. The write operations receive the outbox events to be stored
+// in the same transaction. A missing record is not an error: ReadByCode return a zero Inventory,
+// Update and Delete do nothing
 type Repository interface {
-	Create(inv Inventory) (err error)
-	ReadAll(page int, limit int) (invs []Inventory, err error)
-	ReadByCode(code string) (inv Inventory, err error)
+	Create(organizationID string, inv Inventory, evts ...outbox.Event) (err error)
+	ReadAll(organizationID string, page int, limit int) (invs []Inventory, err error)
+	ReadByCode(organizationID string, code string) (inv Inventory, err error)
 	// Update(code string) (err error)
-	Update(inv Inventory) (err error)
-	Delete(code string) (err error)
+	Update(organizationID string, inv Inventory, evts ...outbox.Event) (err error)
+	Delete(organizationID string, code string, evts ...outbox.Event) (err error)
+}
+
+// SearchRepository keep a search index of the inventories, the database stay the source of truth.
+// Index use the organization of the inventory, the other operations are scoped like Repository
+type SearchRepository interface {
+	Index(inv Inventory) (err error)
+	BulkIndex(invs []Inventory) (err error)
+	Delete(organizationID string, code string) (err error)
+	// Search return fuzzy matches on code, name and description, facets count hits per status
+	Search(organizationID string, query string, status string, page int, limit int) (result SearchResult, err error)
+}
+
+// Cache is satisfied by the go-cache memory and redis repositories
+type Cache interface {
+	Set(key string, value interface{}, expiration time.Duration) (err error)
+	Get(key string, data interface{}) (err error)
+	Delete(key string)
 }
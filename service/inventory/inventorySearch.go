@@ -0,0 +1,104 @@
+package inventory
+
+import "log/slog"
+
+type searchService struct {
+	repo       Repository
+	searchRepo SearchRepository
+}
+
+type SearchService interface {
+	Search(organizationID string, query string, status string, page int, limit int) (result SearchResult, err error)
+	// Reindex push the inventories of one organization, the index is shared by the organizations
+	Reindex(organizationID string, batchSize int) (indexed int, err error)
+}
+
+func NewSearchService(r Repository, searchRepo SearchRepository) SearchService {
+	return &searchService{
+		repo:       r,
+		searchRepo: searchRepo,
+	}
+}
+
+func (s *searchService) Search(organizationID string, query string, status string, page int, limit int) (result SearchResult, err error) {
+	return s.searchRepo.Search(organizationID, query, status, page, limit)
+}
+
+// Reindex push every inventory of the organization from the database to the search index
+func (s *searchService) Reindex(organizationID string, batchSize int) (indexed int, err error) {
+	for page := 1; ; page++ {
+		invs, err := s.repo.ReadAll(organizationID, page, batchSize)
+		if err != nil {
+			return indexed, err
+		}
+
+		if len(invs) == 0 {
+			return indexed, nil
+		}
+
+		if err = s.searchRepo.BulkIndex(invs); err != nil {
+			return indexed, err
+		}
+		indexed += len(invs)
+
+		if len(invs) < batchSize {
+			return indexed, nil
+		}
+	}
+}
+
+type indexingService struct {
+	Service
+	logger     *slog.Logger
+	searchRepo SearchRepository
+}
+
+// NewIndexingService wrap the service to sync the search index on every write,
+// an index failure is only logged since a reindex will repair it
+func NewIndexingService(logger *slog.Logger, next Service, searchRepo SearchRepository) Service {
+	return &indexingService{
+		Service:    next,
+		logger:     logger,
+		searchRepo: searchRepo,
+	}
+}
+
+func (s *indexingService) Create(organizationID string, inv Inventory) (err error) {
+	if err = s.Service.Create(organizationID, inv); err != nil {
+		return
+	}
+
+	inv.OrganizationID = organizationID
+
+	if errIndex := s.searchRepo.Index(inv); errIndex != nil {
+		s.logger.Error("inventory index err", slog.String("code", inv.Code), slog.Any("err", errIndex.Error()))
+	}
+
+	return nil
+}
+
+func (s *indexingService) Update(organizationID string, inv Inventory) (err error) {
+	if err = s.Service.Update(organizationID, inv); err != nil {
+		return
+	}
+
+	inv.OrganizationID = organizationID
+
+	if errIndex := s.searchRepo.Index(inv); errIndex != nil {
+		s.logger.Error("inventory index err", slog.String("code", inv.Code), slog.Any("err", errIndex.Error()))
+	}
+
+	return nil
+}
+
+func (s *indexingService) Delete(organizationID string, code string) (err error) {
+	if err = s.Service.Delete(organizationID, code); err != nil {
+		return
+	}
+
+	if errIndex := s.searchRepo.Delete(organizationID, code); errIndex != nil {
+		s.logger.Error("inventory index delete err", slog.String("code", code), slog.Any("err", errIndex.Error()))
+	}
+
+	return nil
+}
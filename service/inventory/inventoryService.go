@@ -1,16 +1,26 @@
 package inventory
 
+import (
+	"belajarGo2/service/outbox"
+	"errors"
+)
+
+// ErrNotFound is returned by Update and Delete for a code missing in the organization, no event is written for it
+var ErrNotFound = errors.New("inventory not found")
+
 type service struct {
 	repo Repository
 }
 
+// Service is scoped by the organization of the caller, the organization id come from the access token
+// or the api key and the organization id of the given inventory is ignored
 type Service interface {
-	Create(inv Inventory) (err error)
-	GetAll(page int, limit int) (invs []Inventory, err error)
-	GetByCode(code string) (inv Inventory, err error)
+	Create(organizationID string, inv Inventory) (err error)
+	GetAll(organizationID string, page int, limit int) (invs []Inventory, err error)
+	GetByCode(organizationID string, code string) (inv Inventory, err error)
 	// Update(code string) (err error)
-	Update(inv Inventory) (err error)
-	Delete(code string) (err error)
+	Update(organizationID string, inv Inventory) (err error)
+	Delete(organizationID string, code string) (err error)
 }
 
 func NewService(r Repository) Service {
@@ -19,25 +29,73 @@ func NewService(r Repository) Service {
 	}
 }
 
-func (s *service) Create(inv Inventory) (err error) {
-	return s.repo.Create(inv)
+func (s *service) Create(organizationID string, inv Inventory) (err error) {
+	inv.OrganizationID = organizationID
+	evt, err := outbox.NewEvent(EventAggregateType, inv.Code, EventCreated, inv)
+	if err != nil {
+		return
+	}
+
+	return s.repo.Create(organizationID, inv, evt)
 }
 
-func (s *service) GetAll(page int, limit int) (invs []Inventory, err error) {
-	return s.repo.ReadAll(page, limit)
+func (s *service) GetAll(organizationID string, page int, limit int) (invs []Inventory, err error) {
+	return s.repo.ReadAll(organizationID, page, limit)
 }
 
-func (s *service) GetByCode(code string) (inv Inventory, err error) {
-	return s.repo.ReadByCode(code)
+func (s *service) GetByCode(organizationID string, code string) (inv Inventory, err error) {
+	return s.repo.ReadByCode(organizationID, code)
 }
 
 //	func (s *service) Update(code string) (err error) {
 //		return s.repo.Update(code)
 //	}
-func (s *service) Update(inv Inventory) (err error) {
-	return s.repo.Update(inv)
+func (s *service) Update(organizationID string, inv Inventory) (err error) {
+	inv.OrganizationID = organizationID
+	current, err := s.repo.ReadByCode(organizationID, inv.Code)
+	if err != nil {
+		return
+	}
+	if current.Code == "" {
+		return ErrNotFound
+	}
+
+	evt, err := outbox.NewEvent(EventAggregateType, inv.Code, EventUpdated, inv)
+	if err != nil {
+		return
+	}
+	evts := []outbox.Event{evt}
+
+	if current.Stock != inv.Stock {
+		adjustEvt, err := outbox.NewEvent(EventAggregateType, inv.Code, EventStockAdjusted, StockAdjustment{
+			OrganizationID: organizationID,
+			Code:           inv.Code,
+			PreviousStock:  current.Stock,
+			Stock:          inv.Stock,
+			Delta:          inv.Stock - current.Stock,
+		})
+		if err != nil {
+			return err
+		}
+		evts = append(evts, adjustEvt)
+	}
+
+	return s.repo.Update(organizationID, inv, evts...)
 }
 
-func (s *service) Delete(code string) (err error) {
-	return s.repo.Delete(code)
+func (s *service) Delete(organizationID string, code string) (err error) {
+	current, err := s.repo.ReadByCode(organizationID, code)
+	if err != nil {
+		return
+	}
+	if current.Code == "" {
+		return ErrNotFound
+	}
+
+	evt, err := outbox.NewEvent(EventAggregateType, code, EventDeleted, map[string]string{"organization_id": organizationID, "code": code})
+	if err != nil {
+		return
+	}
+
+	return s.repo.Delete(organizationID, code, evt)
 }
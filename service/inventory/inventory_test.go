@@ -0,0 +1,270 @@
+package inventory_test
+
+import (
+	"belajarGo2/service/inventory"
+	mock_inventory "belajarGo2/service/inventory/mock"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pobyzaarif/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+const org = "org-1"
+
+func TestReindex(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockRepo    func(m *mock_inventory.MockRepository)
+		mockSearch  func(m *mock_inventory.MockSearchRepository)
+		wantIndexed int
+		wantErr     bool
+	}{
+		{
+			name: "error on ReadAll",
+			mockRepo: func(m *mock_inventory.MockRepository) {
+				m.EXPECT().ReadAll(org, 1, 2).Return(nil, errors.New("db error"))
+			},
+			mockSearch:  func(m *mock_inventory.MockSearchRepository) {},
+			wantIndexed: 0,
+			wantErr:     true,
+		},
+		{
+			name: "error on BulkIndex",
+			mockRepo: func(m *mock_inventory.MockRepository) {
+				m.EXPECT().ReadAll(org, 1, 2).Return([]inventory.Inventory{{Code: "INV003"}, {Code: "INV002"}}, nil)
+			},
+			mockSearch: func(m *mock_inventory.MockSearchRepository) {
+				m.EXPECT().BulkIndex(gomock.Any()).Return(errors.New("es error"))
+			},
+			wantIndexed: 0,
+			wantErr:     true,
+		},
+		{
+			name: "success stop on the last partial page",
+			mockRepo: func(m *mock_inventory.MockRepository) {
+				m.EXPECT().ReadAll(org, 1, 2).Return([]inventory.Inventory{{Code: "INV003"}, {Code: "INV002"}}, nil)
+				m.EXPECT().ReadAll(org, 2, 2).Return([]inventory.Inventory{{Code: "INV001"}}, nil)
+			},
+			mockSearch: func(m *mock_inventory.MockSearchRepository) {
+				m.EXPECT().BulkIndex(gomock.Any()).Return(nil).Times(2)
+			},
+			wantIndexed: 3,
+			wantErr:     false,
+		},
+		{
+			name: "success stop on the empty page",
+			mockRepo: func(m *mock_inventory.MockRepository) {
+				m.EXPECT().ReadAll(org, 1, 2).Return([]inventory.Inventory{{Code: "INV002"}, {Code: "INV001"}}, nil)
+				m.EXPECT().ReadAll(org, 2, 2).Return([]inventory.Inventory{}, nil)
+			},
+			mockSearch: func(m *mock_inventory.MockSearchRepository) {
+				m.EXPECT().BulkIndex(gomock.Any()).Return(nil)
+			},
+			wantIndexed: 2,
+			wantErr:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+			mock_searchRepo := mock_inventory.NewMockSearchRepository(ctrl)
+
+			tt.mockRepo(mock_inventoryRepo)
+			tt.mockSearch(mock_searchRepo)
+
+			searchService := inventory.NewSearchService(mock_inventoryRepo, mock_searchRepo)
+
+			indexed, err := searchService.Reindex(org, 2)
+			assert.Equal(t, tt.wantIndexed, indexed)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestIndexingService(t *testing.T) {
+	inv := inventory.Inventory{Code: "INV001", Name: "Laptop", Stock: 25, Status: "active"}
+
+	stored := inv
+	stored.OrganizationID = org
+
+	t.Run("index after create and skip on error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		mock_searchRepo := mock_inventory.NewMockSearchRepository(ctrl)
+
+		mock_inventoryRepo.EXPECT().Create(org, stored, gomock.Any()).Return(errors.New("duplicate key"))
+		mock_inventoryRepo.EXPECT().Create(org, stored, gomock.Any()).Return(nil)
+		mock_searchRepo.EXPECT().Index(stored).Return(errors.New("es down"))
+
+		inventoryService := inventory.NewIndexingService(logger, inventory.NewService(mock_inventoryRepo), mock_searchRepo)
+
+		assert.NotNil(t, inventoryService.Create(org, inv))
+		// the database write succeed, the index error is only logged
+		assert.Nil(t, inventoryService.Create(org, inv))
+	})
+
+	t.Run("remove from index after delete", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		mock_searchRepo := mock_inventory.NewMockSearchRepository(ctrl)
+
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").Return(stored, nil)
+		mock_inventoryRepo.EXPECT().Delete(org, "INV001", gomock.Any()).Return(nil)
+		mock_searchRepo.EXPECT().Delete(org, "INV001").Return(nil)
+
+		inventoryService := inventory.NewIndexingService(logger, inventory.NewService(mock_inventoryRepo), mock_searchRepo)
+
+		assert.Nil(t, inventoryService.Delete(org, "INV001"))
+	})
+
+	t.Run("no event for a missing code", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		mock_searchRepo := mock_inventory.NewMockSearchRepository(ctrl)
+
+		// neither the repository write nor the index is called
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV404").Return(inventory.Inventory{}, nil).Times(2)
+
+		inventoryService := inventory.NewIndexingService(logger, inventory.NewService(mock_inventoryRepo), mock_searchRepo)
+
+		assert.ErrorIs(t, inventoryService.Update(org, inventory.Inventory{Code: "INV404", Stock: 1}), inventory.ErrNotFound)
+		assert.ErrorIs(t, inventoryService.Delete(org, "INV404"), inventory.ErrNotFound)
+	})
+}
+
+func TestCachedService(t *testing.T) {
+	inv := inventory.Inventory{Code: "INV001", Name: "Laptop", Stock: 25, Status: "active"}
+
+	t.Run("read through and invalidate on update", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		memoryCache, _ := cache.NewMemoryARCCacheRepository(100)
+
+		gomock.InOrder(
+			mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").Return(inv, nil),
+			mock_inventoryRepo.EXPECT().ReadAll(org, 1, 10).Return([]inventory.Inventory{inv}, nil),
+			// update read the current stock for the stock adjusted event
+			mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").Return(inv, nil),
+			mock_inventoryRepo.EXPECT().Update(org, gomock.Any(), gomock.Any()).Return(nil),
+			mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").Return(inventory.Inventory{Code: "INV001", Stock: 5}, nil),
+			mock_inventoryRepo.EXPECT().ReadAll(org, 1, 10).Return([]inventory.Inventory{{Code: "INV001", Stock: 5}}, nil),
+		)
+
+		inventoryService := inventory.NewCachedService(logger, inventory.NewService(mock_inventoryRepo), memoryCache, inventory.CacheConfig{})
+
+		for i := 0; i < 3; i++ {
+			got, err := inventoryService.GetByCode(org, "INV001")
+			assert.Nil(t, err)
+			assert.Equal(t, inv, got)
+
+			invs, err := inventoryService.GetAll(org, 1, 10)
+			assert.Nil(t, err)
+			assert.Equal(t, []inventory.Inventory{inv}, invs)
+		}
+		assert.Equal(t, inventory.CacheStats{Hits: 4, Misses: 2}, inventoryService.Stats())
+
+		assert.Nil(t, inventoryService.Update(org, inventory.Inventory{Code: "INV001", Stock: 5}))
+
+		got, err := inventoryService.GetByCode(org, "INV001")
+		assert.Nil(t, err)
+		assert.Equal(t, 5, got.Stock)
+
+		invs, err := inventoryService.GetAll(org, 1, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, invs[0].Stock)
+		assert.Equal(t, inventory.CacheStats{Hits: 4, Misses: 4}, inventoryService.Stats())
+	})
+
+	t.Run("not found is cached and error is not", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		memoryCache, _ := cache.NewMemoryARCCacheRepository(100)
+
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV404").Return(inventory.Inventory{}, nil).Times(1)
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV500").Return(inventory.Inventory{}, errors.New("db error")).Times(2)
+
+		inventoryService := inventory.NewCachedService(logger, inventory.NewService(mock_inventoryRepo), memoryCache, inventory.CacheConfig{})
+
+		for i := 0; i < 2; i++ {
+			got, err := inventoryService.GetByCode(org, "INV404")
+			assert.Nil(t, err)
+			assert.Equal(t, "", got.Code)
+
+			_, err = inventoryService.GetByCode(org, "INV500")
+			assert.NotNil(t, err)
+		}
+	})
+
+	t.Run("cache is not shared between organizations", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		memoryCache, _ := cache.NewMemoryARCCacheRepository(100)
+
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").Return(inv, nil).Times(1)
+		mock_inventoryRepo.EXPECT().ReadByCode("org-2", "INV001").Return(inventory.Inventory{}, nil).Times(1)
+
+		inventoryService := inventory.NewCachedService(logger, inventory.NewService(mock_inventoryRepo), memoryCache, inventory.CacheConfig{})
+
+		for i := 0; i < 2; i++ {
+			got, err := inventoryService.GetByCode(org, "INV001")
+			assert.Nil(t, err)
+			assert.Equal(t, inv, got)
+
+			got, err = inventoryService.GetByCode("org-2", "INV001")
+			assert.Nil(t, err)
+			assert.Equal(t, "", got.Code)
+		}
+	})
+
+	t.Run("concurrent misses hit the repository once", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_inventoryRepo := mock_inventory.NewMockRepository(ctrl)
+		memoryCache, _ := cache.NewMemoryARCCacheRepository(100)
+
+		release := make(chan struct{})
+		mock_inventoryRepo.EXPECT().ReadByCode(org, "INV001").DoAndReturn(func(organizationID string, code string) (inventory.Inventory, error) {
+			<-release
+			return inv, nil
+		}).Times(1)
+
+		inventoryService := inventory.NewCachedService(logger, inventory.NewService(mock_inventoryRepo), memoryCache, inventory.CacheConfig{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := inventoryService.GetByCode(org, "INV001")
+				assert.Nil(t, err)
+				assert.Equal(t, inv, got)
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+}
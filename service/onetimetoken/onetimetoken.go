@@ -0,0 +1,20 @@
+package onetimetoken
+
+import "time"
+
+type (
+	// Token is a single use secret sent by email, only the sha256 of the token is stored.
+	// Purpose tell what the token is for and Subject what it act on, like the id of the user
+	Token struct {
+		ID        string `bson:"one_time_token_id"`
+		TokenHash string `bson:"token_hash"`
+		Purpose   string
+		Subject   string
+		// Payload is the extra data of the purpose, like the new email of an email change
+		Payload   string
+		ExpiresAt time.Time `bson:"expires_at"`
+		CreatedAt time.Time `bson:"created_at"`
+		// ConsumedAt is set when the token is used or revoked, it can't be used again
+		ConsumedAt *time.Time `bson:"consumed_at"`
+	}
+)
@@ -0,0 +1,14 @@
+package onetimetoken
+
+import "time"
+
+// Repository return a zero Token and no error when the hash is not found
+type Repository interface {
+	Create(token Token) (err error)
+	GetByHash(tokenHash string) (token Token, err error)
+	// Consume set ConsumedAt only if the token was not consumed yet,
+	// consumed is false when another request used the token first
+	Consume(id string, consumedAt time.Time) (consumed bool, err error)
+	// ConsumeBySubject consume every unused token of the purpose and the subject
+	ConsumeBySubject(purpose string, subject string, consumedAt time.Time) (err error)
+}
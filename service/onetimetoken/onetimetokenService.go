@@ -0,0 +1,99 @@
+package onetimetoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type service struct {
+	logger *slog.Logger
+	repo   Repository
+}
+
+type Service interface {
+	// Issue return the plaintext token, it is not stored and can only be sent once
+	Issue(purpose string, subject string, payload string, ttl time.Duration) (plaintext string, err error)
+	// Consume return the token and mark it used, ErrInvalidToken for an unknown, expired,
+	// used or revoked token, or a token of another purpose
+	Consume(purpose string, plaintext string) (token Token, err error)
+	// Revoke the unused tokens of the purpose and the subject, like the links sent before a new one
+	Revoke(purpose string, subject string) (err error)
+}
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+func NewService(logger *slog.Logger, repo Repository) Service {
+	return &service{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (s *service) Issue(purpose string, subject string, payload string, ttl time.Duration) (plaintext string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	plaintext = base64.RawURLEncoding.EncodeToString(b)
+
+	timeNow := time.Now()
+	err = s.repo.Create(Token{
+		ID:        uuid.NewString(),
+		TokenHash: hashToken(plaintext),
+		Purpose:   purpose,
+		Subject:   subject,
+		Payload:   payload,
+		ExpiresAt: timeNow.Add(ttl),
+		CreatedAt: timeNow,
+	})
+	if err != nil {
+		s.logger.Error("store one-time token err", slog.String("purpose", purpose), slog.Any("err", err.Error()))
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+func (s *service) Consume(purpose string, plaintext string) (token Token, err error) {
+	if plaintext == "" {
+		return token, ErrInvalidToken
+	}
+
+	token, err = s.repo.GetByHash(hashToken(plaintext))
+	if err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	if token.ID == "" || token.Purpose != purpose || token.ConsumedAt != nil || timeNow.After(token.ExpiresAt) {
+		return Token{}, ErrInvalidToken
+	}
+
+	consumed, err := s.repo.Consume(token.ID, timeNow)
+	if err != nil {
+		return Token{}, err
+	}
+	if !consumed {
+		// a concurrent request used the same token
+		return Token{}, ErrInvalidToken
+	}
+
+	token.ConsumedAt = &timeNow
+	return token, nil
+}
+
+func (s *service) Revoke(purpose string, subject string) (err error) {
+	return s.repo.ConsumeBySubject(purpose, subject, time.Now())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
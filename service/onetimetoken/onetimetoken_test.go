@@ -0,0 +1,107 @@
+package onetimetoken_test
+
+import (
+	"belajarGo2/service/onetimetoken"
+	mock_onetimetoken "belajarGo2/service/onetimetoken/mock"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+func TestIssueAndConsume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_onetimetoken.NewMockRepository(ctrl)
+	svc := onetimetoken.NewService(logger, repo)
+
+	var stored onetimetoken.Token
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(token onetimetoken.Token) error {
+		stored = token
+		return nil
+	})
+
+	plaintext, err := svc.Issue("email_change", "user-1", "new@mail.com", time.Hour)
+	require.NoError(t, err)
+	assert.NotEmpty(t, plaintext)
+	// only the hash is stored
+	assert.NotEqual(t, plaintext, stored.TokenHash)
+	assert.Equal(t, "email_change", stored.Purpose)
+	assert.Equal(t, "user-1", stored.Subject)
+	assert.Equal(t, "new@mail.com", stored.Payload)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+
+	t.Run("token of another purpose", func(t *testing.T) {
+		repo.EXPECT().GetByHash(stored.TokenHash).Return(stored, nil)
+
+		_, err := svc.Consume("reset_password", plaintext)
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := stored
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		repo.EXPECT().GetByHash(stored.TokenHash).Return(expired, nil)
+
+		_, err := svc.Consume("email_change", plaintext)
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		repo.EXPECT().GetByHash(gomock.Any()).Return(onetimetoken.Token{}, nil)
+
+		_, err := svc.Consume("email_change", "unknown")
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+
+		_, err = svc.Consume("email_change", "")
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+	})
+
+	t.Run("consume once", func(t *testing.T) {
+		repo.EXPECT().GetByHash(stored.TokenHash).Return(stored, nil)
+		repo.EXPECT().Consume(stored.ID, gomock.Any()).Return(true, nil)
+
+		token, err := svc.Consume("email_change", plaintext)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", token.Subject)
+		assert.NotNil(t, token.ConsumedAt)
+
+		consumedAt := time.Now()
+		consumed := stored
+		consumed.ConsumedAt = &consumedAt
+		repo.EXPECT().GetByHash(stored.TokenHash).Return(consumed, nil)
+
+		_, err = svc.Consume("email_change", plaintext)
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+	})
+
+	t.Run("used by a concurrent request", func(t *testing.T) {
+		repo.EXPECT().GetByHash(stored.TokenHash).Return(stored, nil)
+		repo.EXPECT().Consume(stored.ID, gomock.Any()).Return(false, nil)
+
+		_, err := svc.Consume("email_change", plaintext)
+		assert.ErrorIs(t, err, onetimetoken.ErrInvalidToken)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		repo.EXPECT().Create(gomock.Any()).Return(errors.New("db down"))
+
+		plaintext, err := svc.Issue("email_change", "user-1", "", time.Hour)
+		assert.Error(t, err)
+		assert.Empty(t, plaintext)
+	})
+
+	t.Run("revoke the tokens of the subject", func(t *testing.T) {
+		repo.EXPECT().ConsumeBySubject("email_change", "user-1", gomock.Any()).Return(nil)
+
+		assert.NoError(t, svc.Revoke("email_change", "user-1"))
+	})
+}
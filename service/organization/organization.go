@@ -0,0 +1,23 @@
+package organization
+
+import "time"
+
+type (
+	// Organization scope the inventories, a user only reach the inventories of the organization
+	// of the access token or of the api key
+	Organization struct {
+		ID        string    `json:"id" bson:"organization_id"`
+		Name      string    `json:"name"`
+		CreatedBy string    `json:"created_by" bson:"created_by"`
+		CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	}
+
+	// Member give a user a role in the organization, the inventory routes check the permissions
+	// of this role instead of the role of the user
+	Member struct {
+		OrganizationID string    `json:"organization_id" bson:"organization_id"`
+		UserID         string    `json:"user_id" bson:"user_id"`
+		Role           string    `json:"role"`
+		CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	}
+)
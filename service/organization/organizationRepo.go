@@ -0,0 +1,36 @@
+package organization
+
+import (
+	"belajarGo2/service/user"
+	"errors"
+)
+
+// ErrDuplicateMember is returned by every repository when the user is a member of the organization already
+var ErrDuplicateMember = errors.New("duplicate key: user is a member already")
+
+// Repository return a zero value for a missing organization or member
+type Repository interface {
+	// Create store the organization with its first member
+	Create(org Organization, owner Member) (err error)
+	GetByID(id string) (org Organization, err error)
+	GetAll() (orgs []Organization, err error)
+
+	AddMember(member Member) (err error)
+	GetMember(organizationID string, userID string) (member Member, err error)
+	GetMembers(organizationID string) (members []Member, err error)
+	GetUserMemberships(userID string) (members []Member, err error)
+	UpdateMemberRole(organizationID string, userID string, role string) (err error)
+	RemoveMember(organizationID string, userID string) (err error)
+}
+
+// UserRepository check the member exists, user.Repository satisfy it
+type UserRepository interface {
+	GetByID(id string) (usr user.User, err error)
+}
+
+// RoleValidator check the role given to a member exists and is not above the role of the actor,
+// role.Service check the roles stored in the database
+type RoleValidator interface {
+	Exists(name string) (exists bool, err error)
+	Permissions(roleName string) (permissions []string, err error)
+}
@@ -0,0 +1,298 @@
+package organization
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	// OwnerRole is the role of the first member of a new organization
+	OwnerRole string
+}
+
+type service struct {
+	logger        *slog.Logger
+	repo          Repository
+	userRepo      UserRepository
+	roleValidator RoleValidator
+	config        Config
+}
+
+type Service interface {
+	// Create add the owner as the first member of the organization
+	Create(actorID string, name string, ownerID string) (org Organization, err error)
+	GetAll() (orgs []Organization, err error)
+	GetByID(id string) (org Organization, err error)
+
+	// GetUserMemberships list the organizations the user can switch to
+	GetUserMemberships(userID string) (members []Member, err error)
+	GetMembers(organizationID string) (members []Member, err error)
+	// AddMember and ChangeMemberRole refuse a role with a permission the actorRole, the role of the actor
+	// in the organization, does not have. ChangeMemberRole also refuse a member whose role has one
+	AddMember(actorRole string, organizationID string, userID string, role string) (member Member, err error)
+	// ChangeMemberRole and RemoveMember refuse the own membership of the actor, it could leave nobody
+	// able to manage the members
+	ChangeMemberRole(actorID string, actorRole string, organizationID string, userID string, role string) (err error)
+	RemoveMember(actorID string, organizationID string, userID string) (err error)
+
+	// MemberRole return the role of the user in the organization, it is empty when the user is not a member.
+	// It is resolved on every request so a removed member lose the access at once
+	MemberRole(organizationID string, userID string) (role string, err error)
+
+	// ExportUserData list the memberships of the user for the personal data export, it satisfy user.DataExporter
+	ExportUserData(userID string) (data interface{}, err error)
+}
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrInvalidName          = errors.New("invalid organization name")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrMemberExists         = errors.New("user is a member already")
+	ErrInvalidRole          = errors.New("invalid role")
+	ErrOwnMembership        = errors.New("can not be done on your own membership")
+	ErrRoleNotAllowed       = errors.New("role has a permission your role does not have")
+)
+
+func NewService(logger *slog.Logger, repo Repository, userRepo UserRepository, roleValidator RoleValidator, cfg Config) Service {
+	if cfg.OwnerRole == "" {
+		cfg.OwnerRole = "admin"
+	}
+
+	return &service{
+		logger:        logger,
+		repo:          repo,
+		userRepo:      userRepo,
+		roleValidator: roleValidator,
+		config:        cfg,
+	}
+}
+
+func (s *service) Create(actorID string, name string, ownerID string) (org Organization, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return org, ErrInvalidName
+	}
+
+	if err = s.checkUser(ownerID); err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	org = Organization{
+		ID:        uuid.NewString(),
+		Name:      name,
+		CreatedBy: actorID,
+		CreatedAt: timeNow,
+	}
+	owner := Member{
+		OrganizationID: org.ID,
+		UserID:         ownerID,
+		Role:           s.config.OwnerRole,
+		CreatedAt:      timeNow,
+	}
+	if err = s.repo.Create(org, owner); err != nil {
+		return Organization{}, err
+	}
+
+	return org, nil
+}
+
+func (s *service) GetAll() (orgs []Organization, err error) {
+	return s.repo.GetAll()
+}
+
+func (s *service) GetByID(id string) (org Organization, err error) {
+	org, err = s.repo.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	if org.ID == "" {
+		return org, ErrOrganizationNotFound
+	}
+	return
+}
+
+func (s *service) GetUserMemberships(userID string) (members []Member, err error) {
+	return s.repo.GetUserMemberships(userID)
+}
+
+func (s *service) ExportUserData(userID string) (data interface{}, err error) {
+	members, err := s.repo.GetUserMemberships(userID)
+	if err != nil {
+		return
+	}
+
+	if members == nil {
+		members = []Member{}
+	}
+	return members, nil
+}
+
+func (s *service) GetMembers(organizationID string) (members []Member, err error) {
+	if _, err = s.GetByID(organizationID); err != nil {
+		return
+	}
+
+	return s.repo.GetMembers(organizationID)
+}
+
+func (s *service) AddMember(actorRole string, organizationID string, userID string, role string) (member Member, err error) {
+	if _, err = s.GetByID(organizationID); err != nil {
+		return
+	}
+
+	if err = s.validateRole(role); err != nil {
+		return
+	}
+
+	if err = s.checkGrantable(actorRole, role); err != nil {
+		return
+	}
+
+	if err = s.checkUser(userID); err != nil {
+		return
+	}
+
+	member = Member{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      time.Now(),
+	}
+	err = s.repo.AddMember(member)
+	if errors.Is(err, ErrDuplicateMember) {
+		return Member{}, ErrMemberExists
+	}
+	if err != nil {
+		return Member{}, err
+	}
+
+	return member, nil
+}
+
+func (s *service) ChangeMemberRole(actorID string, actorRole string, organizationID string, userID string, role string) (err error) {
+	if actorID == userID {
+		return ErrOwnMembership
+	}
+
+	if err = s.validateRole(role); err != nil {
+		return
+	}
+
+	if err = s.checkGrantable(actorRole, role); err != nil {
+		return
+	}
+
+	member, err := s.getMember(organizationID, userID)
+	if err != nil {
+		return
+	}
+
+	if err = s.checkGrantable(actorRole, member.Role); err != nil {
+		return
+	}
+
+	return s.repo.UpdateMemberRole(organizationID, userID, role)
+}
+
+func (s *service) RemoveMember(actorID string, organizationID string, userID string) (err error) {
+	if actorID == userID {
+		return ErrOwnMembership
+	}
+
+	if _, err = s.getMember(organizationID, userID); err != nil {
+		return
+	}
+
+	return s.repo.RemoveMember(organizationID, userID)
+}
+
+func (s *service) MemberRole(organizationID string, userID string) (role string, err error) {
+	if organizationID == "" || userID == "" {
+		return "", nil
+	}
+
+	member, err := s.repo.GetMember(organizationID, userID)
+	if err != nil {
+		return
+	}
+
+	return member.Role, nil
+}
+
+func (s *service) getMember(organizationID string, userID string) (member Member, err error) {
+	member, err = s.repo.GetMember(organizationID, userID)
+	if err != nil {
+		return
+	}
+
+	if member.UserID == "" {
+		return member, ErrMemberNotFound
+	}
+	return
+}
+
+func (s *service) checkUser(userID string) (err error) {
+	usr, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if usr.ID == "" {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// validateRole use the role validator when there is one, the roles are defined at runtime
+func (s *service) validateRole(role string) (err error) {
+	if role == "" {
+		return ErrInvalidRole
+	}
+
+	if s.roleValidator == nil {
+		return nil
+	}
+
+	exists, err := s.roleValidator.Exists(role)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// checkGrantable refuse a role with a permission the role of the actor does not have,
+// without a role validator the permissions are unknown and every role is allowed
+func (s *service) checkGrantable(actorRole string, role string) (err error) {
+	if s.roleValidator == nil || role == actorRole {
+		return nil
+	}
+
+	actorPermissions, err := s.roleValidator.Permissions(actorRole)
+	if err != nil {
+		return
+	}
+
+	permissions, err := s.roleValidator.Permissions(role)
+	if err != nil {
+		return
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(actorPermissions, permission) {
+			return ErrRoleNotAllowed
+		}
+	}
+	return nil
+}
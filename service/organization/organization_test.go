@@ -0,0 +1,158 @@
+package organization_test
+
+import (
+	"belajarGo2/service/organization"
+	mock_organization "belajarGo2/service/organization/mock"
+	"belajarGo2/service/user"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_organization.NewMockRepository(ctrl)
+	userRepo := mock_organization.NewMockUserRepository(ctrl)
+	svc := organization.NewService(logger, repo, userRepo, nil, organization.Config{})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, err := svc.Create("admin-1", "   ", "user-1")
+		assert.ErrorIs(t, err, organization.ErrInvalidName)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		userRepo.EXPECT().GetByID("user-404").Return(user.User{}, nil)
+
+		_, err := svc.Create("admin-1", "Acme", "user-404")
+		assert.ErrorIs(t, err, organization.ErrUserNotFound)
+	})
+
+	t.Run("owner is the first member with the owner role", func(t *testing.T) {
+		userRepo.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1"}, nil)
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(org organization.Organization, owner organization.Member) error {
+			assert.Equal(t, org.ID, owner.OrganizationID)
+			assert.Equal(t, "user-1", owner.UserID)
+			assert.Equal(t, "admin", owner.Role)
+			return nil
+		})
+
+		org, err := svc.Create("admin-1", " Acme ", "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Acme", org.Name)
+		assert.Equal(t, "admin-1", org.CreatedBy)
+		assert.NotEmpty(t, org.ID)
+	})
+}
+
+func TestMembers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_organization.NewMockRepository(ctrl)
+	userRepo := mock_organization.NewMockUserRepository(ctrl)
+	roleValidator := mock_organization.NewMockRoleValidator(ctrl)
+	svc := organization.NewService(logger, repo, userRepo, roleValidator, organization.Config{})
+
+	acme := organization.Organization{ID: "org-1", Name: "Acme"}
+
+	t.Run("add to an unknown organization", func(t *testing.T) {
+		repo.EXPECT().GetByID("org-404").Return(organization.Organization{}, nil)
+
+		_, err := svc.AddMember("admin", "org-404", "user-2", "user")
+		assert.ErrorIs(t, err, organization.ErrOrganizationNotFound)
+	})
+
+	t.Run("add with an unknown role", func(t *testing.T) {
+		repo.EXPECT().GetByID("org-1").Return(acme, nil)
+		roleValidator.EXPECT().Exists("owner").Return(false, nil)
+
+		_, err := svc.AddMember("admin", "org-1", "user-2", "owner")
+		assert.ErrorIs(t, err, organization.ErrInvalidRole)
+	})
+
+	t.Run("add a member twice", func(t *testing.T) {
+		repo.EXPECT().GetByID("org-1").Return(acme, nil)
+		roleValidator.EXPECT().Exists("user").Return(true, nil)
+		roleValidator.EXPECT().Permissions("admin").Return([]string{"inventory:read", "organization:admin"}, nil)
+		roleValidator.EXPECT().Permissions("user").Return([]string{"inventory:read"}, nil)
+		userRepo.EXPECT().GetByID("user-2").Return(user.User{ID: "user-2"}, nil)
+		repo.EXPECT().AddMember(gomock.Any()).Return(organization.ErrDuplicateMember)
+
+		_, err := svc.AddMember("admin", "org-1", "user-2", "user")
+		assert.ErrorIs(t, err, organization.ErrMemberExists)
+	})
+
+	t.Run("own membership can't be changed", func(t *testing.T) {
+		assert.ErrorIs(t, svc.ChangeMemberRole("user-1", "admin", "org-1", "user-1", "user"), organization.ErrOwnMembership)
+		assert.ErrorIs(t, svc.RemoveMember("user-1", "org-1", "user-1"), organization.ErrOwnMembership)
+	})
+
+	t.Run("remove a non member", func(t *testing.T) {
+		repo.EXPECT().GetMember("org-1", "user-3").Return(organization.Member{}, nil)
+
+		assert.ErrorIs(t, svc.RemoveMember("user-1", "org-1", "user-3"), organization.ErrMemberNotFound)
+	})
+
+	t.Run("change the role of a member", func(t *testing.T) {
+		roleValidator.EXPECT().Exists("admin").Return(true, nil)
+		repo.EXPECT().GetMember("org-1", "user-2").Return(organization.Member{OrganizationID: "org-1", UserID: "user-2", Role: "user"}, nil)
+		roleValidator.EXPECT().Permissions("admin").Return([]string{"inventory:read", "inventory:write", "organization:admin"}, nil)
+		roleValidator.EXPECT().Permissions("user").Return([]string{"inventory:read"}, nil)
+		repo.EXPECT().UpdateMemberRole("org-1", "user-2", "admin").Return(nil)
+
+		assert.NoError(t, svc.ChangeMemberRole("user-1", "admin", "org-1", "user-2", "admin"))
+	})
+
+	t.Run("a role above the role of the actor can't be granted", func(t *testing.T) {
+		roleValidator.EXPECT().Permissions("admin").Return([]string{"inventory:read", "organization:admin"}, nil).Times(2)
+		roleValidator.EXPECT().Permissions("superadmin").Return([]string{"inventory:read", "organization:admin", "user:admin"}, nil).Times(2)
+
+		repo.EXPECT().GetByID("org-1").Return(acme, nil)
+		roleValidator.EXPECT().Exists("superadmin").Return(true, nil).Times(2)
+		_, err := svc.AddMember("admin", "org-1", "user-2", "superadmin")
+		assert.ErrorIs(t, err, organization.ErrRoleNotAllowed)
+
+		assert.ErrorIs(t, svc.ChangeMemberRole("user-1", "admin", "org-1", "user-2", "superadmin"), organization.ErrRoleNotAllowed)
+	})
+
+	t.Run("a member above the actor can't be changed", func(t *testing.T) {
+		roleValidator.EXPECT().Exists("user").Return(true, nil)
+		roleValidator.EXPECT().Permissions("admin").Return([]string{"inventory:read", "organization:admin"}, nil).Times(2)
+		roleValidator.EXPECT().Permissions("user").Return([]string{"inventory:read"}, nil)
+		roleValidator.EXPECT().Permissions("superadmin").Return([]string{"inventory:read", "organization:admin", "user:admin"}, nil)
+		repo.EXPECT().GetMember("org-1", "user-3").Return(organization.Member{OrganizationID: "org-1", UserID: "user-3", Role: "superadmin"}, nil)
+
+		assert.ErrorIs(t, svc.ChangeMemberRole("user-1", "admin", "org-1", "user-3", "user"), organization.ErrRoleNotAllowed)
+	})
+
+	t.Run("member role is empty for a non member", func(t *testing.T) {
+		repo.EXPECT().GetMember("org-2", "user-2").Return(organization.Member{}, nil)
+
+		role, err := svc.MemberRole("org-2", "user-2")
+		assert.NoError(t, err)
+		assert.Empty(t, role)
+
+		// no organization in the token
+		role, err = svc.MemberRole("", "user-2")
+		assert.NoError(t, err)
+		assert.Empty(t, role)
+	})
+
+	t.Run("export the memberships of the user", func(t *testing.T) {
+		repo.EXPECT().GetUserMemberships("user-2").Return([]organization.Member{{OrganizationID: "org-1", UserID: "user-2", Role: "user"}}, nil)
+		repo.EXPECT().GetUserMemberships("user-3").Return(nil, nil)
+
+		data, err := svc.ExportUserData("user-2")
+		assert.NoError(t, err)
+		assert.Equal(t, []organization.Member{{OrganizationID: "org-1", UserID: "user-2", Role: "user"}}, data)
+
+		data, err = svc.ExportUserData("user-3")
+		assert.NoError(t, err)
+		assert.Equal(t, []organization.Member{}, data)
+	})
+}
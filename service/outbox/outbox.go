@@ -0,0 +1,39 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	Event struct {
+		ID            string     `json:"id" bson:"event_id"`
+		AggregateType string     `json:"aggregate_type" bson:"aggregate_type"`
+		AggregateID   string     `json:"aggregate_id" bson:"aggregate_id"`
+		EventType     string     `json:"event_type" bson:"event_type"`
+		Payload       string     `json:"payload"`
+		Attempts      int        `json:"attempts"`
+		LastError     string     `json:"last_error" bson:"last_error"`
+		CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+		PublishedAt   *time.Time `json:"published_at" bson:"published_at"`
+	}
+)
+
+// NewEvent build an unpublished event, payload is stored as json
+func NewEvent(aggregateType, aggregateID, eventType string, payload interface{}) (evt Event, err error) {
+	payloadByte, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	return Event{
+		ID:            uuid.NewString(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       string(payloadByte),
+		CreatedAt:     time.Now(),
+	}, nil
+}
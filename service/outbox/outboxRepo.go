@@ -0,0 +1,16 @@
+package outbox
+
+import "time"
+
+type Repository interface {
+	Create(evt Event) (err error)
+	GetUnpublished(limit int) (evts []Event, err error)
+	MarkPublished(id string, publishedAt time.Time) (err error)
+	MarkFailed(id string, lastError string) (err error)
+	// RedactAggregate replace the payload of every event of the aggregate, it erase the personal data of a deleted account
+	RedactAggregate(aggregateType string, aggregateID string, payload string) (err error)
+}
+
+type Publisher interface {
+	Publish(evt Event) (err error)
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"log/slog"
+	"time"
+)
+
+type service struct {
+	logger    *slog.Logger
+	repo      Repository
+	publisher Publisher
+}
+
+type Service interface {
+	Relay(limit int) (published int, err error)
+}
+
+func NewService(logger *slog.Logger, repo Repository, publisher Publisher) Service {
+	return &service{
+		logger:    logger,
+		repo:      repo,
+		publisher: publisher,
+	}
+}
+
+// Relay publish pending events in the order they were written.
+// An event is only marked as published after the publisher confirmed it, so a crash
+// in between will publish it again (at-least-once), consumer should dedupe by event id.
+func (s *service) Relay(limit int) (published int, err error) {
+	evts, err := s.repo.GetUnpublished(limit)
+	if err != nil {
+		return
+	}
+
+	for _, evt := range evts {
+		if err = s.publisher.Publish(evt); err != nil {
+			s.logger.Error("outbox publish err", slog.String("event_id", evt.ID), slog.Any("err", err.Error()))
+
+			if errMark := s.repo.MarkFailed(evt.ID, err.Error()); errMark != nil {
+				s.logger.Error("outbox mark failed err", slog.String("event_id", evt.ID), slog.Any("err", errMark.Error()))
+			}
+
+			// stop here to keep the order, the rest will be picked up on the next run
+			return
+		}
+
+		if err = s.repo.MarkPublished(evt.ID, time.Now()); err != nil {
+			s.logger.Error("outbox mark published err", slog.String("event_id", evt.ID), slog.Any("err", err.Error()))
+			return
+		}
+
+		published++
+	}
+
+	return published, nil
+}
+
+type multiPublisher []Publisher
+
+// NewMultiPublisher publish every event to all the given publishers, when one of them fail
+// the event stay unpublished and will be sent again to all of them on the next relay
+func NewMultiPublisher(publishers ...Publisher) Publisher {
+	return multiPublisher(publishers)
+}
+
+func (m multiPublisher) Publish(evt Event) (err error) {
+	for _, p := range m {
+		if err = p.Publish(evt); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package outbox_test
+
+import (
+	"belajarGo2/service/outbox"
+	mock_outbox "belajarGo2/service/outbox/mock"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+func TestRelay(t *testing.T) {
+	evts := []outbox.Event{
+		{ID: "1", EventType: "inventory.created"},
+		{ID: "2", EventType: "inventory.updated"},
+	}
+
+	tests := []struct {
+		name          string
+		mockRepo      func(m *mock_outbox.MockRepository)
+		mockPublisher func(m *mock_outbox.MockPublisher)
+		wantPublished int
+		wantErr       bool
+	}{
+		{
+			name: "error on GetUnpublished",
+			mockRepo: func(m *mock_outbox.MockRepository) {
+				m.EXPECT().GetUnpublished(10).Return(nil, errors.New("db error"))
+			},
+			mockPublisher: func(m *mock_outbox.MockPublisher) {},
+			wantPublished: 0,
+			wantErr:       true,
+		},
+		{
+			name: "error on publish stop the batch and record the failure",
+			mockRepo: func(m *mock_outbox.MockRepository) {
+				m.EXPECT().GetUnpublished(10).Return(evts, nil)
+				m.EXPECT().MarkPublished("1", gomock.Any()).Return(nil)
+				m.EXPECT().MarkFailed("2", "broker down").Return(nil)
+			},
+			mockPublisher: func(m *mock_outbox.MockPublisher) {
+				m.EXPECT().Publish(evts[0]).Return(nil)
+				m.EXPECT().Publish(evts[1]).Return(errors.New("broker down"))
+			},
+			wantPublished: 1,
+			wantErr:       true,
+		},
+		{
+			name: "error on MarkPublished",
+			mockRepo: func(m *mock_outbox.MockRepository) {
+				m.EXPECT().GetUnpublished(10).Return(evts, nil)
+				m.EXPECT().MarkPublished("1", gomock.Any()).Return(errors.New("db error"))
+			},
+			mockPublisher: func(m *mock_outbox.MockPublisher) {
+				m.EXPECT().Publish(evts[0]).Return(nil)
+			},
+			wantPublished: 0,
+			wantErr:       true,
+		},
+		{
+			name: "success",
+			mockRepo: func(m *mock_outbox.MockRepository) {
+				m.EXPECT().GetUnpublished(10).Return(evts, nil)
+				m.EXPECT().MarkPublished("1", gomock.Any()).Return(nil)
+				m.EXPECT().MarkPublished("2", gomock.Any()).Return(nil)
+			},
+			mockPublisher: func(m *mock_outbox.MockPublisher) {
+				gomock.InOrder(
+					m.EXPECT().Publish(evts[0]).Return(nil),
+					m.EXPECT().Publish(evts[1]).Return(nil),
+				)
+			},
+			wantPublished: 2,
+			wantErr:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_outboxRepo := mock_outbox.NewMockRepository(ctrl)
+			mock_publisher := mock_outbox.NewMockPublisher(ctrl)
+
+			tt.mockRepo(mock_outboxRepo)
+			tt.mockPublisher(mock_publisher)
+
+			outboxService := outbox.NewService(logger, mock_outboxRepo, mock_publisher)
+
+			published, err := outboxService.Relay(10)
+			assert.Equal(t, tt.wantPublished, published)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package role
+
+import "time"
+
+type (
+	// Role grant its permissions to the users having it, the role name is in the access token
+	// and the permissions are resolved on every request, so a role change apply without a new login
+	Role struct {
+		Name        string    `json:"name" bson:"name" gorm:"primaryKey"`
+		Description string    `json:"description"`
+		Permissions []string  `json:"permissions" gorm:"serializer:json"`
+		IsSystem    bool      `json:"is_system" bson:"is_system"`
+		CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+	}
+)
+
+const (
+	PermissionInventoryRead   = "inventory:read"
+	PermissionInventoryWrite  = "inventory:write"
+	PermissionInventoryDelete = "inventory:delete"
+	PermissionUserAdmin       = "user:admin"
+	PermissionWebhookAdmin    = "webhook:admin"
+	// PermissionOrganizationAdmin is checked against the role in the organization, it manage its members
+	PermissionOrganizationAdmin = "organization:admin"
+	// PermissionSecurityAudit read the security log, only superadmin has it by default
+	PermissionSecurityAudit = "security:audit"
+
+	RoleSuperadmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+)
+
+var Permissions = []string{PermissionInventoryRead, PermissionInventoryWrite, PermissionInventoryDelete, PermissionUserAdmin, PermissionWebhookAdmin, PermissionOrganizationAdmin, PermissionSecurityAudit}
+
+// DefaultRoles are created when missing, they keep the access of the roles hard-coded before.
+// They can't be deleted, and superadmin always has every permission
+var DefaultRoles = []Role{
+	{
+		Name:        RoleSuperadmin,
+		Description: "Every permission",
+		Permissions: Permissions,
+		IsSystem:    true,
+	},
+	{
+		Name:        RoleAdmin,
+		Description: "Manage the inventories, the webhooks and the members of the organization",
+		Permissions: []string{PermissionInventoryRead, PermissionInventoryWrite, PermissionWebhookAdmin, PermissionOrganizationAdmin},
+		IsSystem:    true,
+	},
+	{
+		Name:        RoleUser,
+		Description: "Read the inventories",
+		Permissions: []string{PermissionInventoryRead},
+		IsSystem:    true,
+	},
+}
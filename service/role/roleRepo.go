@@ -0,0 +1,13 @@
+package role
+
+import "errors"
+
+// ErrDuplicateRole is returned by Create when the role name is taken
+var ErrDuplicateRole = errors.New("duplicate key: role already exists")
+
+type Repository interface {
+	Create(role Role) (err error)
+	GetAll() (roles []Role, err error)
+	Update(role Role) (err error)
+	Delete(name string) (err error)
+}
@@ -0,0 +1,309 @@
+package role
+
+import (
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// RefreshInterval is how long the roles are cached, a role changed by another instance
+	// apply here after at most this interval. The changes made by this instance apply at once
+	RefreshInterval time.Duration
+}
+
+type service struct {
+	logger *slog.Logger
+	repo   Repository
+	config Config
+
+	mu       sync.RWMutex
+	roles    map[string]Role
+	loadedAt time.Time
+}
+
+type Service interface {
+	// Create and Update refuse a permission the actorRole, the role of the actor, does not have.
+	// Update also refuse a role which has one already
+	Create(actorRole string, role Role) (created Role, err error)
+	GetAll() (roles []Role, err error)
+	GetByName(name string) (role Role, err error)
+	Update(actorRole string, name string, description string, permissions []string) (updated Role, err error)
+	// Delete refuse the default roles, the users still having a deleted role get no permission
+	Delete(name string) (err error)
+
+	// EnsureDefaults create the missing default roles, and give superadmin the permissions added since
+	EnsureDefaults() (err error)
+	// Exists is used to validate the role given to a user
+	Exists(name string) (exists bool, err error)
+	// HasPermission is the policy checked by the echo and the grpc middlewares, an unknown role has no permission
+	HasPermission(roleName string, permission string) (allowed bool, err error)
+	// Permissions return the permissions of the role, an unknown role has none
+	Permissions(roleName string) (permissions []string, err error)
+}
+
+var (
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrInvalidRoleName      = errors.New("invalid role name")
+	ErrInvalidPermission    = errors.New("invalid permission")
+	ErrSystemRole           = errors.New("default role can not be changed")
+	ErrPermissionNotAllowed = errors.New("role has a permission your role does not have")
+)
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,39}$`)
+
+func NewService(logger *slog.Logger, repo Repository, cfg Config) Service {
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = 30 * time.Second
+	}
+
+	return &service{
+		logger: logger,
+		repo:   repo,
+		config: cfg,
+	}
+}
+
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !slices.Contains(Permissions, permission) {
+			return ErrInvalidPermission
+		}
+	}
+
+	return nil
+}
+
+// checkGrantable refuse a permission the actorRole does not have, a role could otherwise grant more than its creator holds
+func (s *service) checkGrantable(actorRole string, permissions []string) (err error) {
+	actorPermissions, err := s.Permissions(actorRole)
+	if err != nil {
+		return
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(actorPermissions, permission) {
+			return ErrPermissionNotAllowed
+		}
+	}
+	return nil
+}
+
+func (s *service) Create(actorRole string, role Role) (created Role, err error) {
+	if !roleNameRegexp.MatchString(role.Name) {
+		return created, ErrInvalidRoleName
+	}
+	if err = validatePermissions(role.Permissions); err != nil {
+		return
+	}
+	if err = s.checkGrantable(actorRole, role.Permissions); err != nil {
+		return
+	}
+
+	timeNow := time.Now().UTC()
+	created = Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: slices.Compact(slices.Sorted(slices.Values(role.Permissions))),
+		CreatedAt:   timeNow,
+		UpdatedAt:   timeNow,
+	}
+	if created.Permissions == nil {
+		created.Permissions = []string{}
+	}
+
+	if err = s.repo.Create(created); err != nil {
+		return Role{}, err
+	}
+
+	s.invalidate()
+	return created, nil
+}
+
+func (s *service) GetAll() (roles []Role, err error) {
+	cached, err := s.cachedRoles()
+	if err != nil {
+		return
+	}
+
+	roles = make([]Role, 0, len(cached))
+	for _, role := range cached {
+		roles = append(roles, role)
+	}
+	slices.SortFunc(roles, func(a, b Role) int { return strings.Compare(a.Name, b.Name) })
+
+	return roles, nil
+}
+
+func (s *service) GetByName(name string) (role Role, err error) {
+	cached, err := s.cachedRoles()
+	if err != nil {
+		return
+	}
+
+	role, ok := cached[name]
+	if !ok {
+		return role, ErrRoleNotFound
+	}
+
+	return role, nil
+}
+
+func (s *service) Update(actorRole string, name string, description string, permissions []string) (updated Role, err error) {
+	if name == RoleSuperadmin {
+		return updated, ErrSystemRole
+	}
+	if err = validatePermissions(permissions); err != nil {
+		return
+	}
+	if err = s.checkGrantable(actorRole, permissions); err != nil {
+		return
+	}
+
+	updated, err = s.GetByName(name)
+	if err != nil {
+		return
+	}
+
+	// removing the permissions of a role above the actor is a way to take them away from its users
+	if err = s.checkGrantable(actorRole, updated.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	updated.Description = description
+	updated.Permissions = slices.Compact(slices.Sorted(slices.Values(permissions)))
+	if updated.Permissions == nil {
+		updated.Permissions = []string{}
+	}
+	updated.UpdatedAt = time.Now().UTC()
+
+	if err = s.repo.Update(updated); err != nil {
+		return Role{}, err
+	}
+
+	s.invalidate()
+	return updated, nil
+}
+
+func (s *service) Delete(name string) (err error) {
+	role, err := s.GetByName(name)
+	if err != nil {
+		return
+	}
+
+	if role.IsSystem {
+		return ErrSystemRole
+	}
+
+	if err = s.repo.Delete(name); err != nil {
+		return
+	}
+
+	s.invalidate()
+	return nil
+}
+
+func (s *service) EnsureDefaults() (err error) {
+	roles, err := s.repo.GetAll()
+	if err != nil {
+		return
+	}
+
+	existing := make(map[string]Role, len(roles))
+	for _, role := range roles {
+		existing[role.Name] = role
+	}
+
+	timeNow := time.Now().UTC()
+	for _, role := range DefaultRoles {
+		current, ok := existing[role.Name]
+		if !ok {
+			role.CreatedAt = timeNow
+			role.UpdatedAt = timeNow
+			// another instance may create it at the same time
+			if err = s.repo.Create(role); err != nil && !errors.Is(err, ErrDuplicateRole) {
+				return
+			}
+			continue
+		}
+
+		if role.Name == RoleSuperadmin && !slices.Equal(slices.Sorted(slices.Values(current.Permissions)), slices.Sorted(slices.Values(Permissions))) {
+			current.Permissions = Permissions
+			current.UpdatedAt = timeNow
+			if err = s.repo.Update(current); err != nil {
+				return
+			}
+		}
+	}
+
+	s.invalidate()
+	return nil
+}
+
+func (s *service) Exists(name string) (exists bool, err error) {
+	cached, err := s.cachedRoles()
+	if err != nil {
+		return
+	}
+
+	_, exists = cached[name]
+	return
+}
+
+func (s *service) HasPermission(roleName string, permission string) (allowed bool, err error) {
+	cached, err := s.cachedRoles()
+	if err != nil {
+		return
+	}
+
+	role, ok := cached[roleName]
+	return ok && slices.Contains(role.Permissions, permission), nil
+}
+
+func (s *service) Permissions(roleName string) (permissions []string, err error) {
+	cached, err := s.cachedRoles()
+	if err != nil {
+		return
+	}
+
+	return slices.Clone(cached[roleName].Permissions), nil
+}
+
+// cachedRoles read the roles again once the refresh interval passed, the permissions are checked on every request
+func (s *service) cachedRoles() (roles map[string]Role, err error) {
+	s.mu.RLock()
+	roles, loadedAt := s.roles, s.loadedAt
+	s.mu.RUnlock()
+
+	if roles != nil && time.Since(loadedAt) < s.config.RefreshInterval {
+		return roles, nil
+	}
+
+	all, err := s.repo.GetAll()
+	if err != nil {
+		s.logger.Error("load roles err", slog.Any("err", err.Error()))
+		return nil, err
+	}
+
+	roles = make(map[string]Role, len(all))
+	for _, role := range all {
+		roles[role.Name] = role
+	}
+
+	s.mu.Lock()
+	s.roles = roles
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return roles, nil
+}
+
+func (s *service) invalidate() {
+	s.mu.Lock()
+	s.roles = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,279 @@
+package role_test
+
+import (
+	"belajarGo2/service/role"
+	mock_role "belajarGo2/service/role/mock"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+var storedRoles = []role.Role{
+	{Name: role.RoleSuperadmin, Permissions: role.Permissions, IsSystem: true},
+	{Name: role.RoleUser, Permissions: []string{role.PermissionInventoryRead}, IsSystem: true},
+	{Name: "auditor", Permissions: []string{role.PermissionInventoryRead, role.PermissionWebhookAdmin}},
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		actorRole string
+		input     role.Role
+		mockRepo  func(m *mock_role.MockRepository)
+		want      []string
+		wantErr   error
+	}{
+		{
+			name:    "invalid name",
+			input:   role.Role{Name: "Not Valid"},
+			wantErr: role.ErrInvalidRoleName,
+		},
+		{
+			name:    "unknown permission",
+			input:   role.Role{Name: "auditor", Permissions: []string{"inventory:purge"}},
+			wantErr: role.ErrInvalidPermission,
+		},
+		{
+			name:      "permission the actor does not have",
+			actorRole: role.RoleUser,
+			input:     role.Role{Name: "auditor", Permissions: []string{role.PermissionInventoryRead, role.PermissionWebhookAdmin}},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+			},
+			wantErr: role.ErrPermissionNotAllowed,
+		},
+		{
+			name:  "duplicate role",
+			input: role.Role{Name: "auditor", Permissions: []string{role.PermissionInventoryRead}},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+				m.EXPECT().Create(gomock.Any()).Return(role.ErrDuplicateRole)
+			},
+			wantErr: role.ErrDuplicateRole,
+		},
+		{
+			name:  "created with sorted unique permissions",
+			input: role.Role{Name: "auditor", Permissions: []string{role.PermissionWebhookAdmin, role.PermissionInventoryRead, role.PermissionWebhookAdmin}, IsSystem: true},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+				m.EXPECT().Create(gomock.Any()).DoAndReturn(func(created role.Role) error {
+					assert.False(t, created.IsSystem)
+					return nil
+				})
+			},
+			want: []string{role.PermissionInventoryRead, role.PermissionWebhookAdmin},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mock_role.NewMockRepository(ctrl)
+			if tt.mockRepo != nil {
+				tt.mockRepo(repo)
+			}
+
+			actorRole := tt.actorRole
+			if actorRole == "" {
+				actorRole = role.RoleSuperadmin
+			}
+
+			svc := role.NewService(logger, repo, role.Config{})
+			created, err := svc.Create(actorRole, tt.input)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, created.Permissions)
+			assert.False(t, created.CreatedAt.IsZero())
+		})
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(svc role.Service) error
+		mockRepo func(m *mock_role.MockRepository)
+		wantErr  error
+	}{
+		{
+			name: "superadmin can not be updated",
+			call: func(svc role.Service) error {
+				_, err := svc.Update(role.RoleSuperadmin, role.RoleSuperadmin, "", nil)
+				return err
+			},
+			wantErr: role.ErrSystemRole,
+		},
+		{
+			name: "update unknown role",
+			call: func(svc role.Service) error {
+				_, err := svc.Update(role.RoleSuperadmin, "missing", "", []string{role.PermissionInventoryRead})
+				return err
+			},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+			},
+			wantErr: role.ErrRoleNotFound,
+		},
+		{
+			name: "update with a permission the actor does not have",
+			call: func(svc role.Service) error {
+				_, err := svc.Update(role.RoleUser, "auditor", "", []string{role.PermissionWebhookAdmin})
+				return err
+			},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+			},
+			wantErr: role.ErrPermissionNotAllowed,
+		},
+		{
+			name: "update a role having a permission the actor does not have",
+			call: func(svc role.Service) error {
+				_, err := svc.Update(role.RoleUser, "auditor", "", []string{role.PermissionInventoryRead})
+				return err
+			},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+			},
+			wantErr: role.ErrPermissionNotAllowed,
+		},
+		{
+			name: "update replace the permissions",
+			call: func(svc role.Service) error {
+				updated, err := svc.Update(role.RoleSuperadmin, "auditor", "Read only", []string{role.PermissionInventoryRead})
+				assert.Equal(t, []string{role.PermissionInventoryRead}, updated.Permissions)
+				return err
+			},
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+				m.EXPECT().Update(gomock.Any()).DoAndReturn(func(updated role.Role) error {
+					assert.Equal(t, "auditor", updated.Name)
+					assert.Equal(t, "Read only", updated.Description)
+					return nil
+				})
+			},
+		},
+		{
+			name: "default role can not be deleted",
+			call: func(svc role.Service) error { return svc.Delete(role.RoleUser) },
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+			},
+			wantErr: role.ErrSystemRole,
+		},
+		{
+			name: "deleted",
+			call: func(svc role.Service) error { return svc.Delete("auditor") },
+			mockRepo: func(m *mock_role.MockRepository) {
+				m.EXPECT().GetAll().Return(storedRoles, nil)
+				m.EXPECT().Delete("auditor").Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mock_role.NewMockRepository(ctrl)
+			if tt.mockRepo != nil {
+				tt.mockRepo(repo)
+			}
+
+			err := tt.call(role.NewService(logger, repo, role.Config{}))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	t.Run("roles are cached until refresh or change", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mock_role.NewMockRepository(ctrl)
+		svc := role.NewService(logger, repo, role.Config{RefreshInterval: time.Hour})
+
+		repo.EXPECT().GetAll().Return(storedRoles, nil)
+		for _, tc := range []struct {
+			role, permission string
+			want             bool
+		}{
+			{role.RoleSuperadmin, role.PermissionUserAdmin, true},
+			{role.RoleUser, role.PermissionInventoryRead, true},
+			{role.RoleUser, role.PermissionInventoryWrite, false},
+			{"auditor", role.PermissionWebhookAdmin, true},
+			{"unknown", role.PermissionInventoryRead, false},
+		} {
+			allowed, err := svc.HasPermission(tc.role, tc.permission)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, allowed, tc.role+" "+tc.permission)
+		}
+
+		// a change made by this instance apply at once
+		repo.EXPECT().Update(gomock.Any()).Return(nil)
+		_, err := svc.Update(role.RoleSuperadmin, "auditor", "", []string{role.PermissionInventoryRead})
+		require.NoError(t, err)
+
+		repo.EXPECT().GetAll().Return([]role.Role{{Name: "auditor", Permissions: []string{role.PermissionInventoryRead}}}, nil)
+		allowed, err := svc.HasPermission("auditor", role.PermissionWebhookAdmin)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+
+		permissions, err := svc.Permissions("auditor")
+		require.NoError(t, err)
+		assert.Equal(t, []string{role.PermissionInventoryRead}, permissions)
+
+		permissions, err = svc.Permissions("unknown")
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("fail closed when the roles can't be read", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mock_role.NewMockRepository(ctrl)
+		svc := role.NewService(logger, repo, role.Config{})
+
+		repo.EXPECT().GetAll().Return(nil, errors.New("db error"))
+		allowed, err := svc.HasPermission(role.RoleSuperadmin, role.PermissionUserAdmin)
+		assert.Error(t, err)
+		assert.False(t, allowed)
+	})
+}
+
+func TestEnsureDefaults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_role.NewMockRepository(ctrl)
+	svc := role.NewService(logger, repo, role.Config{})
+
+	// superadmin miss a permission added later, admin was created by another instance meanwhile
+	repo.EXPECT().GetAll().Return([]role.Role{
+		{Name: role.RoleSuperadmin, Permissions: []string{role.PermissionInventoryRead}, IsSystem: true},
+		{Name: role.RoleUser, Permissions: []string{}, IsSystem: true},
+	}, nil)
+	repo.EXPECT().Update(gomock.Any()).DoAndReturn(func(updated role.Role) error {
+		assert.Equal(t, role.RoleSuperadmin, updated.Name)
+		assert.ElementsMatch(t, role.Permissions, updated.Permissions)
+		return nil
+	})
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(created role.Role) error {
+		assert.Equal(t, role.RoleAdmin, created.Name)
+		assert.True(t, created.IsSystem)
+		return role.ErrDuplicateRole
+	})
+
+	assert.NoError(t, svc.EnsureDefaults())
+}
@@ -0,0 +1,36 @@
+package signingkey
+
+import "time"
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+type (
+	// Key is a signing key, the private key is stored encrypted as a PEM in PrivateKey
+	Key struct {
+		ID         string    `json:"kid" bson:"kid" gorm:"column:kid;primaryKey"`
+		Algorithm  string    `json:"alg" bson:"algorithm"`
+		PrivateKey string    `json:"-" bson:"private_key"`
+		CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	}
+
+	// JWK is the public part of a key as published in the jwks endpoint (RFC 7517)
+	JWK struct {
+		KeyType   string `json:"kty"`
+		KeyID     string `json:"kid"`
+		Use       string `json:"use"`
+		Algorithm string `json:"alg"`
+		// RSA
+		N string `json:"n,omitempty"`
+		E string `json:"e,omitempty"`
+		// OKP (Ed25519)
+		Curve string `json:"crv,omitempty"`
+		X     string `json:"x,omitempty"`
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+)
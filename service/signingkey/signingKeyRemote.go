@@ -0,0 +1,111 @@
+package signingkey
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RemoteKeySet verify tokens with the keys published by a jwks endpoint, for the services that
+// don't own the signing keys. The keys are refetched when a token carry an unknown kid
+type RemoteKeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]remoteKey
+	lastFetchAt time.Time
+}
+
+type remoteKey struct {
+	algorithm string
+	publicKey crypto.PublicKey
+}
+
+func NewRemoteKeySet(jwksURL string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &RemoteKeySet{
+		url:    jwksURL,
+		client: client,
+		// an unknown kid can't trigger more than one fetch per interval
+		refreshInterval: 30 * time.Second,
+		keys:            map[string]remoteKey{},
+	}
+}
+
+// Keyfunc resolve the public key of a token by its kid, to be used with jwt.Parse
+func (r *RemoteKeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := r.get(kid)
+	if !ok {
+		if err := r.refresh(); err != nil {
+			return nil, err
+		}
+		if key, ok = r.get(kid); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	// a jwks may omit the alg, the key type then restrict the methods the key can verify
+	if key.algorithm != "" && t.Method.Alg() != key.algorithm {
+		return nil, ErrKeyAlgorithm
+	}
+	return key.publicKey, nil
+}
+
+func (r *RemoteKeySet) get(kid string) (key remoteKey, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok = r.keys[kid]
+	return
+}
+
+func (r *RemoteKeySet) refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastFetchAt) < r.refreshInterval {
+		return nil
+	}
+	r.lastFetchAt = time.Now()
+
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %v", resp.StatusCode)
+	}
+
+	jwks := JWKS{}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return err
+	}
+
+	keys := map[string]remoteKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		publicKey, err := fromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = remoteKey{algorithm: jwk.Algorithm, publicKey: publicKey}
+	}
+	r.keys = keys
+	return nil
+}
@@ -0,0 +1,15 @@
+package signingkey
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDuplicateKey is returned by Create when another instance already created the key of the same rotation
+var ErrDuplicateKey = errors.New("duplicate key: signing key already exists")
+
+type Repository interface {
+	Create(key Key) (err error)
+	GetAll() (keys []Key, err error)
+	DeleteCreatedBefore(createdBefore time.Time) (err error)
+}
@@ -0,0 +1,349 @@
+package signingkey
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey  = errors.New("no signing key loaded")
+	ErrUnknownKey    = errors.New("unknown signing key id")
+	ErrKeyAlgorithm  = errors.New("token algorithm does not match the key")
+	ErrInvalidConfig = errors.New("invalid signing key config")
+)
+
+// Config of the key set, a zero value keep the default
+type Config struct {
+	// Algorithm of the new keys, RS256 or EdDSA
+	Algorithm string
+	// RotationInterval is how long a key is used for signing before a new one is created
+	RotationInterval time.Duration
+	// Retention keep a key in the jwks after it stopped signing, it must be longer than the access token lifetime
+	Retention time.Duration
+	// EncryptionKey encrypt the private keys stored in the repository, 16, 24 or 32 bytes
+	EncryptionKey string
+}
+
+func (c *Config) setDefault() {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmRS256
+	}
+	if c.RotationInterval <= 0 {
+		c.RotationInterval = 30 * 24 * time.Hour
+	}
+	if c.Retention <= 0 {
+		c.Retention = 24 * time.Hour
+	}
+}
+
+type loadedKey struct {
+	Key
+	signer crypto.Signer
+}
+
+// KeySet sign the access tokens with the newest key and verify them with any key still in the set.
+// Every instance load the keys from the repository, so they all sign and verify with the same keys
+type KeySet struct {
+	logger *slog.Logger
+	repo   Repository
+	cfg    Config
+	aead   cipher.AEAD
+
+	mu      sync.RWMutex
+	keys    map[string]loadedKey
+	current string
+}
+
+func NewKeySet(logger *slog.Logger, repo Repository, cfg Config) (*KeySet, error) {
+	cfg.setDefault()
+	if cfg.Algorithm != AlgorithmRS256 && cfg.Algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("%w: unsupported algorithm %v", ErrInvalidConfig, cfg.Algorithm)
+	}
+
+	block, err := aes.NewCipher([]byte(cfg.EncryptionKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: encryption key: %v", ErrInvalidConfig, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySet{
+		logger: logger,
+		repo:   repo,
+		cfg:    cfg,
+		aead:   aead,
+		keys:   map[string]loadedKey{},
+	}, nil
+}
+
+// Load replace the keys in memory with the ones in the repository
+func (k *KeySet) Load() (err error) {
+	keys, err := k.repo.GetAll()
+	if err != nil {
+		return
+	}
+
+	loaded := map[string]loadedKey{}
+	current := ""
+	var currentCreatedAt time.Time
+	for _, key := range keys {
+		signer, err := k.decodePrivateKey(key)
+		if err != nil {
+			k.logger.Error("load signing key err", slog.String("kid", key.ID), slog.Any("err", err.Error()))
+			continue
+		}
+
+		loaded[key.ID] = loadedKey{Key: key, signer: signer}
+		if key.Algorithm == k.cfg.Algorithm && key.CreatedAt.After(currentCreatedAt) {
+			current = key.ID
+			currentCreatedAt = key.CreatedAt
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = loaded
+	k.current = current
+	k.mu.Unlock()
+	return nil
+}
+
+// Rotate create a new key when the newest one is older than the rotation interval, drop the keys
+// past the retention, then reload the set. It is safe to run on every instance at the same time
+func (k *KeySet) Rotate() (rotated bool, err error) {
+	keys, err := k.repo.GetAll()
+	if err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	var newest *Key
+	for i := range keys {
+		if keys[i].Algorithm == k.cfg.Algorithm && (newest == nil || keys[i].CreatedAt.After(newest.CreatedAt)) {
+			newest = &keys[i]
+		}
+	}
+
+	if newest == nil || timeNow.Sub(newest.CreatedAt) >= k.cfg.RotationInterval {
+		key, err := k.generateKey(timeNow)
+		if err != nil {
+			return false, err
+		}
+
+		err = k.repo.Create(key)
+		switch {
+		case errors.Is(err, ErrDuplicateKey):
+			// another instance rotated first
+		case err != nil:
+			return false, err
+		default:
+			rotated = true
+			k.logger.Info("signing key rotated", slog.String("kid", key.ID))
+		}
+	}
+
+	if err = k.repo.DeleteCreatedBefore(timeNow.Add(-(k.cfg.RotationInterval + k.cfg.Retention))); err != nil {
+		return
+	}
+
+	return rotated, k.Load()
+}
+
+// Sign the claims with the current key, the kid header tell the verifiers which key to use
+func (k *KeySet) Sign(claims jwt.Claims) (signedToken string, err error) {
+	k.mu.RLock()
+	key, ok := k.keys[k.current]
+	k.mu.RUnlock()
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// Keyfunc resolve the public key of a token by its kid, to be used with jwt.Parse
+func (k *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if t.Method.Alg() != key.Algorithm {
+		return nil, ErrKeyAlgorithm
+	}
+	return key.signer.Public(), nil
+}
+
+// JWKS return the public keys, sorted by kid
+func (k *KeySet) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk, err := toJWK(key.ID, key.Algorithm, key.signer.Public())
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+	return jwks
+}
+
+func (k *KeySet) generateKey(timeNow time.Time) (key Key, err error) {
+	var privateKey crypto.Signer
+	switch k.cfg.Algorithm {
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return
+	}
+	encrypted, err := k.encrypt(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return
+	}
+
+	// the kid is derived from the rotation period, two instances rotating together get the same kid
+	period := timeNow.Truncate(k.cfg.RotationInterval).UTC()
+	return Key{
+		ID:         strings.ToLower(k.cfg.Algorithm) + "-" + period.Format("20060102T150405"),
+		Algorithm:  k.cfg.Algorithm,
+		PrivateKey: encrypted,
+		CreatedAt:  timeNow,
+	}, nil
+}
+
+func (k *KeySet) decodePrivateKey(key Key) (signer crypto.Signer, err error) {
+	pemBytes, err := k.decrypt(key.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+func (k *KeySet) encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(k.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (k *KeySet) decrypt(encoded string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := k.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("encrypted private key too short")
+	}
+	return k.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func toJWK(kid string, algorithm string, publicKey crypto.PublicKey) (jwk JWK, err error) {
+	jwk = JWK{KeyID: kid, Use: "sig", Algorithm: algorithm}
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		err = errors.New("unsupported public key type")
+	}
+	return
+}
+
+// fromJWK is the reverse of toJWK, used by the remote key set
+func fromJWK(jwk JWK) (publicKey crypto.PublicKey, err error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported key type")
+}
@@ -0,0 +1,207 @@
+package signingkey_test
+
+import (
+	"belajarGo2/service/signingkey"
+	mock_signingkey "belajarGo2/service/signingkey/mock"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+const encryptionKey = "32character32character32characte"
+
+// newKeySet return a key set backed by a mock repository storing the created keys in memory
+func newKeySet(t *testing.T, cfg signingkey.Config) (*signingkey.KeySet, *[]signingkey.Key) {
+	ctrl := gomock.NewController(t)
+	repo := mock_signingkey.NewMockRepository(ctrl)
+
+	keys := &[]signingkey.Key{}
+	repo.EXPECT().GetAll().DoAndReturn(func() ([]signingkey.Key, error) {
+		return append([]signingkey.Key{}, *keys...), nil
+	}).AnyTimes()
+	repo.EXPECT().Create(gomock.Any()).DoAndReturn(func(key signingkey.Key) error {
+		for _, k := range *keys {
+			if k.ID == key.ID {
+				return signingkey.ErrDuplicateKey
+			}
+		}
+		*keys = append(*keys, key)
+		return nil
+	}).AnyTimes()
+	repo.EXPECT().DeleteCreatedBefore(gomock.Any()).DoAndReturn(func(createdBefore time.Time) error {
+		kept := []signingkey.Key{}
+		for _, k := range *keys {
+			if !k.CreatedAt.Before(createdBefore) {
+				kept = append(kept, k)
+			}
+		}
+		*keys = kept
+		return nil
+	}).AnyTimes()
+
+	cfg.EncryptionKey = encryptionKey
+	keySet, err := signingkey.NewKeySet(logger, repo, cfg)
+	require.NoError(t, err)
+	return keySet, keys
+}
+
+func TestNewKeySet(t *testing.T) {
+	_, err := signingkey.NewKeySet(logger, nil, signingkey.Config{Algorithm: "HS256", EncryptionKey: encryptionKey})
+	assert.ErrorIs(t, err, signingkey.ErrInvalidConfig)
+
+	_, err = signingkey.NewKeySet(logger, nil, signingkey.Config{EncryptionKey: "short"})
+	assert.ErrorIs(t, err, signingkey.ErrInvalidConfig)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{signingkey.AlgorithmRS256, signingkey.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keySet, keys := newKeySet(t, signingkey.Config{Algorithm: algorithm})
+
+			_, err := keySet.Sign(jwt.MapClaims{"id": "1"})
+			assert.ErrorIs(t, err, signingkey.ErrNoSigningKey)
+
+			rotated, err := keySet.Rotate()
+			require.NoError(t, err)
+			assert.True(t, rotated)
+			require.Len(t, *keys, 1)
+			assert.NotContains(t, (*keys)[0].PrivateKey, "PRIVATE KEY")
+
+			signedToken, err := keySet.Sign(jwt.MapClaims{"id": "1"})
+			require.NoError(t, err)
+
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(signedToken, claims, keySet.Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, (*keys)[0].ID, token.Header["kid"])
+			assert.Equal(t, algorithm, token.Method.Alg())
+			assert.Equal(t, "1", claims["id"])
+
+			jwks := keySet.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, (*keys)[0].ID, jwks.Keys[0].KeyID)
+			assert.Equal(t, algorithm, jwks.Keys[0].Algorithm)
+
+			// the same key can't be used with another algorithm
+			hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "1"}).SignedString([]byte("secret"))
+			_, err = jwt.Parse(hmacToken, keySet.Keyfunc)
+			assert.Error(t, err)
+
+			// a new instance load the same keys from the repository
+			otherKeySet, _ := newKeySet(t, signingkey.Config{Algorithm: algorithm})
+			_, err = jwt.Parse(signedToken, otherKeySet.Keyfunc)
+			assert.ErrorIs(t, err, signingkey.ErrUnknownKey)
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	t.Run("keep the current key until the rotation interval", func(t *testing.T) {
+		keySet, keys := newKeySet(t, signingkey.Config{RotationInterval: time.Hour, Retention: time.Hour})
+
+		_, err := keySet.Rotate()
+		require.NoError(t, err)
+
+		rotated, err := keySet.Rotate()
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		assert.Len(t, *keys, 1)
+	})
+
+	t.Run("rotate and keep the previous key for verification", func(t *testing.T) {
+		keySet, keys := newKeySet(t, signingkey.Config{Algorithm: signingkey.AlgorithmEdDSA, RotationInterval: time.Hour, Retention: time.Hour})
+
+		_, err := keySet.Rotate()
+		require.NoError(t, err)
+
+		// age the first key past the rotation interval, as if it was created in the previous period
+		(*keys)[0].ID = "eddsa-previous"
+		(*keys)[0].CreatedAt = time.Now().Add(-90 * time.Minute)
+		require.NoError(t, keySet.Load())
+		oldToken, err := keySet.Sign(jwt.MapClaims{"id": "1"})
+		require.NoError(t, err)
+
+		rotated, err := keySet.Rotate()
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		require.Len(t, *keys, 2)
+
+		newToken, err := keySet.Sign(jwt.MapClaims{"id": "1"})
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, (*keys)[1].ID, parsed.Header["kid"])
+
+		_, err = jwt.Parse(oldToken, keySet.Keyfunc)
+		assert.NoError(t, err)
+		assert.Len(t, keySet.JWKS().Keys, 2)
+
+		// past the retention the old key is dropped
+		(*keys)[0].CreatedAt = time.Now().Add(-3 * time.Hour)
+		_, err = keySet.Rotate()
+		require.NoError(t, err)
+		assert.Len(t, *keys, 1)
+		_, err = jwt.Parse(oldToken, keySet.Keyfunc)
+		assert.Error(t, err)
+	})
+
+	t.Run("error on GetAll", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mock_signingkey.NewMockRepository(ctrl)
+		repo.EXPECT().GetAll().Return(nil, errors.New("db error"))
+
+		keySet, err := signingkey.NewKeySet(logger, repo, signingkey.Config{EncryptionKey: encryptionKey})
+		require.NoError(t, err)
+
+		_, err = keySet.Rotate()
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	keySet, _ := newKeySet(t, signingkey.Config{})
+	_, err := keySet.Rotate()
+	require.NoError(t, err)
+
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		_ = json.NewEncoder(w).Encode(keySet.JWKS())
+	}))
+	defer server.Close()
+
+	remoteKeySet := signingkey.NewRemoteKeySet(server.URL, server.Client())
+
+	signedToken, err := keySet.Sign(jwt.MapClaims{"id": "1"})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(signedToken, claims, remoteKeySet.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "1", claims["id"])
+
+	// known kid are served from memory, unknown kid refetch at most once per interval
+	_, err = jwt.Parse(signedToken, remoteKeySet.Keyfunc)
+	require.NoError(t, err)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"id": "1"})
+	unknown.Header["kid"] = "unknown"
+	unknownToken, err := unknown.SigningString()
+	require.NoError(t, err)
+	_, err = jwt.Parse(unknownToken+".c2lnbmF0dXJl", remoteKeySet.Keyfunc)
+	assert.ErrorIs(t, err, signingkey.ErrUnknownKey)
+	assert.Equal(t, 1, fetched)
+}
@@ -1,12 +1,190 @@
 package user
 
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
 type (
 	User struct {
-		ID              string `bson:"user_id"`
-		Email           string
-		Password        string
-		Fullname        string
-		Role            string
-		IsEmailVerified bool `bson:"is_email_verified"`
+		ID              string `json:"id" bson:"user_id"`
+		Email           string `json:"email"`
+		Password        string `json:"-"`
+		Fullname        string `json:"fullname"`
+		Role            string `json:"role"`
+		IsEmailVerified bool   `json:"is_email_verified" bson:"is_email_verified"`
+		IsDisabled      bool   `json:"is_disabled" bson:"is_disabled"`
+		// TOTPSecret is encrypted, it is set on enrollment and only used once IsTOTPEnabled is confirmed
+		TOTPSecret    string `json:"-" bson:"totp_secret"`
+		IsTOTPEnabled bool   `json:"is_totp_enabled" bson:"is_totp_enabled"`
+		// RecoveryCodes are the sha256 of the unused recovery codes
+		RecoveryCodes []string `json:"-" bson:"recovery_codes" gorm:"serializer:json"`
+		// DeleteAt is set when the user asked to delete the account, it is anonymized after this time
+		DeleteAt *time.Time `json:"delete_at,omitempty" bson:"delete_at,omitempty"`
+		// OrganizationID is the active organization of the user, it is put in the access tokens
+		OrganizationID string `json:"organization_id" bson:"organization_id"`
+	}
+
+	// Patch is a partial update, the nil fields are left untouched
+	Patch struct {
+		Email           *string
+		Fullname        *string
+		Password        *string
+		Role            *string
+		IsEmailVerified *bool
+		IsDisabled      *bool
+		OrganizationID  *string
+	}
+
+	// Filter is used by the admin user listing, empty fields are ignored
+	Filter struct {
+		// Query match a part of the email or the fullname
+		Query    string
+		Role     string
+		Disabled *bool
+	}
+
+	// ClientInfo describe where a login or a security sensitive request come from
+	ClientInfo struct {
+		IPAddress string
+		UserAgent string
+	}
+
+	// Claims is the access token payload, SessionID is the refresh token family of the login
+	// and the jti (RegisteredClaims.ID) identify the token for the revocation
+	Claims struct {
+		ID        string `json:"id"`
+		Role      string `json:"role"`
+		SessionID string `json:"sid,omitempty"`
+		// OrganizationID scope the inventory routes, the role in the organization is resolved on every request
+		OrganizationID string `json:"org,omitempty"`
+		// TwoFactorPending is set when the role require 2FA and the login didn't use it,
+		// the token is then refused by the role checks until the user enroll and login again
+		TwoFactorPending bool `json:"2fa_pending,omitempty"`
+		jwt.RegisteredClaims
+	}
+
+	// Signer sign the access tokens, signingkey.KeySet sign them with the current rotated key
+	Signer interface {
+		Sign(claims jwt.Claims) (signedToken string, err error)
+	}
+
+	// RoleValidator check the role given to a user exists, role.Service check the roles stored in the database
+	RoleValidator interface {
+		Exists(name string) (exists bool, err error)
+		Permissions(roleName string) (permissions []string, err error)
+	}
+
+	// Token is returned on login and refresh, RefreshToken is empty when refresh tokens are disabled.
+	// When the user enabled 2FA, Login only return TwoFactorToken to complete with LoginTwoFactor
+	Token struct {
+		TwoFactorToken string `json:"two_factor_token,omitempty"`
+		AccessToken    string `json:"access_token"`
+		RefreshToken   string `json:"refresh_token,omitempty"`
+		TokenType      string `json:"token_type"`
+		ExpiresIn      int64  `json:"expires_in"`
+	}
+
+	// Session is a login of the user on a device, its id is the sid claim of the access tokens
+	// and the family of its refresh tokens. LastSeenAt is updated on every token refresh
+	Session struct {
+		ID         string     `json:"id" bson:"session_id"`
+		UserID     string     `json:"-" bson:"user_id"`
+		UserAgent  string     `json:"user_agent" bson:"user_agent"`
+		IPAddress  string     `json:"ip_address" bson:"ip_address"`
+		CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+		LastSeenAt time.Time  `json:"last_seen_at" bson:"last_seen_at"`
+		ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+		RevokedAt  *time.Time `json:"-" bson:"revoked_at"`
+		// Current is set on the session of the token listing the sessions
+		Current bool `json:"current" bson:"-" gorm:"-"`
+	}
+
+	// Invitation let an admin create an account with a role, the user is created when the invitee
+	// accept it. It is pending until accepted or revoked, an expired invitation can be resent
+	Invitation struct {
+		ID         string     `json:"id" bson:"invitation_id"`
+		Email      string     `json:"email"`
+		Role       string     `json:"role"`
+		InvitedBy  string     `json:"invited_by" bson:"invited_by"`
+		CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+		ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+		AcceptedAt *time.Time `json:"accepted_at,omitempty" bson:"accepted_at"`
+		RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at"`
+	}
+
+	// SecurityEvent is an entry of the security audit log, the ActorID did the action on the SubjectID.
+	// The actor is empty when nobody is authenticated, a failed login or a link opened
+	SecurityEvent struct {
+		ID        string    `json:"id" bson:"security_event_id"`
+		EventType string    `json:"event_type" bson:"event_type"`
+		ActorID   string    `json:"actor_id" bson:"actor_id"`
+		SubjectID string    `json:"subject_id" bson:"subject_id"`
+		IPAddress string    `json:"ip_address" bson:"ip_address"`
+		UserAgent string    `json:"user_agent" bson:"user_agent"`
+		Outcome   string    `json:"outcome"`
+		Detail    string    `json:"detail"`
+		CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	}
+
+	// SecurityEventFilter is used by the audit log query, empty fields are ignored.
+	// UserID match the actor or the subject
+	SecurityEventFilter struct {
+		EventType string
+		UserID    string
+		IPAddress string
+		Outcome   string
+		From      *time.Time
+		To        *time.Time
+	}
+
+	// RefreshToken is stored server side, only the sha256 of the token is kept.
+	// Every rotation issue a new token in the same family, RotatedAt mark the used ones
+	RefreshToken struct {
+		ID        string     `bson:"refresh_token_id"`
+		UserID    string     `bson:"user_id"`
+		FamilyID  string     `bson:"family_id"`
+		TokenHash string     `bson:"token_hash"`
+		ExpiresAt time.Time  `bson:"expires_at"`
+		CreatedAt time.Time  `bson:"created_at"`
+		RotatedAt *time.Time `bson:"rotated_at"`
+		RevokedAt *time.Time `bson:"revoked_at"`
+		// IsTwoFactor is kept on refresh so the new access token keep the 2FA of the login
+		IsTwoFactor bool `bson:"is_two_factor"`
 	}
+
+	EventPayload struct {
+		ID              string `json:"id"`
+		Email           string `json:"email"`
+		Fullname        string `json:"fullname"`
+		Role            string `json:"role"`
+		IsEmailVerified bool   `json:"is_email_verified"`
+		IsDisabled      bool   `json:"is_disabled"`
+	}
+)
+
+const (
+	RoleSuperadmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleUser       = "user"
+)
+
+// Roles are the roles accepted by ChangeRole without a RoleValidator
+var Roles = []string{RoleSuperadmin, RoleAdmin, RoleUser}
+
+const (
+	EventAggregateType = "user"
+
+	EventRegistered    = "user.registered"
+	EventEmailVerified = "user.email_verified"
+	EventEmailChanged  = "user.email_changed"
+	EventRoleChanged   = "user.role_changed"
+	EventDisabled      = "user.disabled"
+	EventEnabled       = "user.enabled"
+	EventDeleted       = "user.deleted"
+	EventAnonymized    = "user.anonymized"
+
+	EventTwoFactorEnabled  = "user.2fa_enabled"
+	EventTwoFactorDisabled = "user.2fa_disabled"
 )
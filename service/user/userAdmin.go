@@ -0,0 +1,168 @@
+package user
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrUserDisabled   = errors.New("account is disabled")
+	ErrInvalidRole    = errors.New("invalid role")
+	ErrOwnAccountOnly = errors.New("can not be done on your own account")
+	ErrRoleNotAllowed = errors.New("role has a permission your role does not have")
+)
+
+func (s *service) GetAll(filter Filter, page int, limit int) (users []User, total int64, err error) {
+	return s.repo.GetAll(filter, page, limit)
+}
+
+func (s *service) GetByID(id string) (user User, err error) {
+	user, err = s.repo.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	if user.ID == "" {
+		return user, ErrUserNotFound
+	}
+	return
+}
+
+// validateRole accept the runtime roles when a RoleValidator is set, otherwise the built-in Roles
+func (s *service) validateRole(role string) (err error) {
+	if s.roleValidator == nil {
+		if !slices.Contains(Roles, role) {
+			return ErrInvalidRole
+		}
+		return nil
+	}
+
+	exists, err := s.roleValidator.Exists(role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// checkGrantable refuse a role with a permission the actorRole does not have. Without a RoleValidator the
+// built-in Roles are ranked, superadmin first, and the actor can only grant its role or a lower one
+func (s *service) checkGrantable(actorRole string, role string) (err error) {
+	if role == actorRole {
+		return nil
+	}
+
+	if s.roleValidator == nil {
+		actorRank := slices.Index(Roles, actorRole)
+		if actorRank == -1 || slices.Index(Roles, role) < actorRank {
+			return ErrRoleNotAllowed
+		}
+		return nil
+	}
+
+	actorPermissions, err := s.roleValidator.Permissions(actorRole)
+	if err != nil {
+		return
+	}
+
+	permissions, err := s.roleValidator.Permissions(role)
+	if err != nil {
+		return
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(actorPermissions, permission) {
+			return ErrRoleNotAllowed
+		}
+	}
+	return nil
+}
+
+func (s *service) ChangeRole(actorID string, actorRole string, id string, role string, client ClientInfo) (err error) {
+	if err = s.validateRole(role); err != nil {
+		return
+	}
+
+	// demoting your own account could leave nobody able to manage the users
+	if actorID == id {
+		return ErrOwnAccountOnly
+	}
+
+	if err = s.checkGrantable(actorRole, role); err != nil {
+		return
+	}
+
+	getUser, err := s.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	// demoting a user above the actor is a way to take its permissions away
+	if err = s.checkGrantable(actorRole, getUser.Role); err != nil {
+		return
+	}
+
+	if err = s.repo.UpdateRole(id, role); err != nil {
+		return
+	}
+
+	// the role is in the access token, the user must login again to get the new one.
+	// The role is changed even when the sessions can't be ended, the error is returned after the events
+	err = s.revokeUserTokens(id)
+
+	s.recordSecurityEvent(SecurityRoleChanged, OutcomeSuccess, actorID, id, client, getUser.Role+" -> "+role)
+	getUser.Role = role
+	s.recordEvent(EventRoleChanged, getUser)
+	return
+}
+
+func (s *service) SetDisabled(actorID string, id string, disabled bool, client ClientInfo) (err error) {
+	if actorID == id {
+		return ErrOwnAccountOnly
+	}
+
+	getUser, err := s.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.UpdateDisabled(id, disabled); err != nil {
+		return
+	}
+
+	getUser.IsDisabled = disabled
+	if disabled {
+		// Login and RefreshToken refuse a disabled user, the jwt middleware only refuse the tokens
+		// already issued through this revocation so its error is returned
+		err = s.revokeUserTokens(id)
+		s.recordEvent(EventDisabled, getUser)
+		s.recordSecurityEvent(SecurityUserDisabled, OutcomeSuccess, actorID, id, client, "")
+	} else {
+		s.recordEvent(EventEnabled, getUser)
+		s.recordSecurityEvent(SecurityUserEnabled, OutcomeSuccess, actorID, id, client, "")
+	}
+	return
+}
+
+func (s *service) Delete(actorID string, id string, client ClientInfo) (err error) {
+	if actorID == id {
+		return ErrOwnAccountOnly
+	}
+
+	getUser, err := s.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.Delete(id); err != nil {
+		return
+	}
+
+	err = s.revokeUserTokens(id)
+	s.recordEvent(EventDeleted, getUser)
+	s.recordSecurityEvent(SecurityUserDeleted, OutcomeSuccess, actorID, id, client, "")
+	return
+}
@@ -0,0 +1,142 @@
+package user
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSecurityLogUnavailable = errors.New("security log is not enabled")
+
+// the security event types, they are recorded with the outcome of the action
+const (
+	SecurityLoginSucceeded     = "login_succeeded"
+	SecurityLoginFailed        = "login_failed"
+	SecurityLoginLocked        = "login_locked"
+	SecurityTwoFactorFailed    = "2fa_failed"
+	SecurityRefreshTokenReused = "refresh_token_reused"
+	SecurityLogout             = "logout"
+	SecurityLogoutAll          = "logout_all"
+	SecuritySessionRevoked     = "session_revoked"
+	SecurityEmailVerified      = "email_verified"
+	SecurityPasswordReset      = "password_reset"
+	SecurityPasswordChanged    = "password_changed"
+	SecurityRoleChanged        = "role_changed"
+	SecurityUserDisabled       = "user_disabled"
+	SecurityUserEnabled        = "user_enabled"
+	SecurityUserDeleted        = "user_deleted"
+	SecurityTwoFactorReset     = "2fa_reset"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// maxSecurityEventExport bound the csv export, narrow the filter to export more
+const maxSecurityEventExport = 10000
+
+// securityEventExportBatch is the page size read from the repository by the export
+const securityEventExportBatch = 500
+
+var securityEventCSVHeader = []string{"id", "created_at", "event_type", "outcome", "actor_id", "subject_id", "ip_address", "user_agent", "detail"}
+
+// WithSecurityEventRepository record the authentication and account security events in an audit log
+func WithSecurityEventRepository(securityRepo SecurityEventRepository) Option {
+	return func(s *service) {
+		s.securityRepo = securityRepo
+	}
+}
+
+// recordSecurityEvent is best effort like recordEvent, a failure is logged and never fail the action
+func (s *service) recordSecurityEvent(eventType string, outcome string, actorID string, subjectID string, client ClientInfo, detail string) {
+	if s.securityRepo == nil {
+		return
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	err := s.securityRepo.CreateSecurityEvent(SecurityEvent{
+		ID:        uuid.NewString(),
+		EventType: eventType,
+		ActorID:   actorID,
+		SubjectID: subjectID,
+		IPAddress: client.IPAddress,
+		UserAgent: userAgent,
+		Outcome:   outcome,
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		s.logger.Error("record security event err", slog.String("event_type", eventType), slog.Any("err", err.Error()))
+	}
+}
+
+func (s *service) GetSecurityEvents(filter SecurityEventFilter, page int, limit int) (events []SecurityEvent, total int64, err error) {
+	if s.securityRepo == nil {
+		return nil, 0, ErrSecurityLogUnavailable
+	}
+
+	return s.securityRepo.GetSecurityEvents(filter, page, limit)
+}
+
+// ExportSecurityEvents write the events matching the filter as csv, the newest first
+func (s *service) ExportSecurityEvents(filter SecurityEventFilter, w io.Writer) (err error) {
+	if s.securityRepo == nil {
+		return ErrSecurityLogUnavailable
+	}
+
+	writer := csv.NewWriter(w)
+	if err = writer.Write(securityEventCSVHeader); err != nil {
+		return
+	}
+
+	for page, written := 1, 0; written < maxSecurityEventExport; page++ {
+		events, _, err := s.securityRepo.GetSecurityEvents(filter, page, securityEventExportBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events[:min(len(events), maxSecurityEventExport-written)] {
+			if err = writer.Write(securityEventCSVRecord(event)); err != nil {
+				return err
+			}
+		}
+		written += len(events)
+
+		if len(events) < securityEventExportBatch {
+			break
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func securityEventCSVRecord(event SecurityEvent) []string {
+	return []string{
+		event.ID,
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.EventType,
+		event.Outcome,
+		csvCell(event.ActorID),
+		csvCell(event.SubjectID),
+		csvCell(event.IPAddress),
+		csvCell(event.UserAgent),
+		csvCell(event.Detail),
+	}
+}
+
+// csvCell neutralize a value a spreadsheet would run as a formula, the user agent is sent by the client
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	ErrEmailRegistered = errors.New("email registered already")
+	ErrSameEmail       = errors.New("new email is the current email")
+)
+
+const (
+	SubjectChangeEmail = "Confirm Your New Email Address"
+	// EmailBodyChangeEmail is sent to the new address, the change is only applied once the link is opened
+	EmailBodyChangeEmail = `Halo, %v, Konfirmasi alamat email baru anda dengan membuka tautan dibawah<br><br/>%v<br/>catatan: link hanya berlaku %v menit`
+
+	SubjectEmailChangeRequested = "Your Email Address Is Being Changed"
+	// EmailBodyEmailChangeRequested is sent to the current address, it only show a part of the new one
+	EmailBodyEmailChangeRequested = `Halo, %v, Ada permintaan untuk mengganti email akun anda menjadi %v<br/>Jika bukan anda, segera ganti password anda`
+)
+
+// RequestEmailChange need the current password. The confirmation link is sent to the new address and
+// a notice to the current one, the email is not changed until ConfirmEmailChange
+func (s *service) RequestEmailChange(userID string, newEmail string, password string) (err error) {
+	newEmail = strings.TrimSpace(newEmail)
+
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if valid, _, err := s.verifyPassword(getUser.Password, password); err != nil || !valid {
+		return ErrWrongPassword
+	}
+
+	if strings.EqualFold(getUser.Email, newEmail) {
+		return ErrSameEmail
+	}
+
+	if throttled := s.throttle("email-change:"+userID, s.resendInterval); throttled {
+		return ErrTooManyRequests
+	}
+
+	registered, err := s.repo.GetByEmail(newEmail)
+	if err != nil {
+		return
+	}
+	if registered.ID != "" {
+		return ErrEmailRegistered
+	}
+
+	// a new request replace the link sent before
+	token, err := s.issueLinkToken(PurposeEmailChange, getUser.ID, newEmail, s.emailVerificationTTL)
+	if err != nil {
+		return
+	}
+	confirmLink := s.appDeploymentUrl + "/users/email-change/" + token
+
+	if err = s.notifRepo.SendEmail(getUser.Fullname, newEmail, SubjectChangeEmail, fmt.Sprintf(EmailBodyChangeEmail, getUser.Fullname, confirmLink, int(s.emailVerificationTTL.Minutes()))); err != nil {
+		s.logger.Error("send change email err", slog.Any("err", err.Error()))
+		return
+	}
+
+	if err := s.notifRepo.SendEmail(getUser.Fullname, getUser.Email, SubjectEmailChangeRequested, fmt.Sprintf(EmailBodyEmailChangeRequested, getUser.Fullname, maskEmail(newEmail))); err != nil {
+		s.logger.Error("send email change notice err", slog.Any("err", err.Error()))
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange swap the email of the user, opening the link prove the new address so it is verified
+func (s *service) ConfirmEmailChange(changeToken string) (err error) {
+	token, err := s.consumeLinkToken(PurposeEmailChange, changeToken)
+	if err != nil {
+		return
+	}
+
+	getUser, err := s.repo.GetByID(token.Subject)
+	if err != nil {
+		s.logger.Error("confirm email change err", slog.Any("err", err))
+		return err
+	}
+
+	if getUser.ID == "" {
+		return errors.New("invalid or expired url")
+	}
+	newEmail := token.Payload
+
+	// the address could have been registered since the request
+	registered, err := s.repo.GetByEmail(newEmail)
+	if err != nil {
+		return
+	}
+	if registered.ID != "" {
+		return ErrEmailRegistered
+	}
+
+	verified := true
+	err = s.repo.Update(getUser.ID, Patch{Email: &newEmail, IsEmailVerified: &verified})
+	if errors.Is(err, ErrDuplicateEmail) {
+		return ErrEmailRegistered
+	}
+	if err != nil {
+		s.logger.Error("confirm email change err", slog.Any("err", err))
+		return err
+	}
+
+	getUser.Email = newEmail
+	getUser.IsEmailVerified = true
+	s.recordEvent(EventEmailChanged, getUser)
+
+	return nil
+}
+
+// maskEmail keep the first letter of the local part and the domain, j***@mail.com
+func maskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain
+}
@@ -0,0 +1,226 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationPending      = errors.New("email is invited already")
+	ErrInvitationsUnavailable = errors.New("invitations are not enabled")
+)
+
+const (
+	defaultInvitationTTL = 72 * time.Hour
+
+	// InvitationAcceptPath is the path of the invitation link, a GET serve the form posting the account to it
+	InvitationAcceptPath = "/users/invitations/accept"
+
+	SubjectInvitation = "You Are Invited"
+	// EmailBodyInvitation is sent to the invitee, the account is created when the link is opened with a password
+	EmailBodyInvitation = `Halo, anda diundang untuk bergabung sebagai %v, Buat akun anda dengan membuka tautan dibawah<br><br/>%v<br/>catatan: link hanya berlaku %v jam`
+)
+
+// WithInvitationRepository enable the invitations, ttl is how long a link is valid, a zero value keep the default
+func WithInvitationRepository(invitationRepo InvitationRepository, ttl time.Duration) Option {
+	return func(s *service) {
+		s.invitationRepo = invitationRepo
+		s.invitationTTL = defaultInvitationTTL
+		if ttl > 0 {
+			s.invitationTTL = ttl
+		}
+	}
+}
+
+// Invite email a link to create an account with the role, actorID is the admin inviting
+func (s *service) Invite(actorID string, actorRole string, email string, role string) (invitation Invitation, err error) {
+	if s.invitationRepo == nil {
+		return invitation, ErrInvitationsUnavailable
+	}
+
+	if err = s.validateRole(role); err != nil {
+		return
+	}
+
+	if err = s.checkGrantable(actorRole, role); err != nil {
+		return
+	}
+
+	registered, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+	if registered.ID != "" {
+		return invitation, ErrEmailRegistered
+	}
+
+	pending, err := s.invitationRepo.GetPendingInvitationByEmail(email)
+	if err != nil {
+		return
+	}
+	if pending.ID != "" {
+		return invitation, ErrInvitationPending
+	}
+
+	timeNow := time.Now()
+	invitation = Invitation{
+		ID:        uuid.NewString(),
+		Email:     email,
+		Role:      role,
+		InvitedBy: actorID,
+		CreatedAt: timeNow,
+		ExpiresAt: timeNow.Add(s.invitationTTL),
+	}
+	if err = s.invitationRepo.CreateInvitation(invitation); err != nil {
+		return Invitation{}, err
+	}
+
+	if err := s.sendInvitationEmail(invitation); err != nil {
+		s.logger.Error("send invitation email err", slog.Any("err", err.Error()))
+	}
+
+	return invitation, nil
+}
+
+func (s *service) sendInvitationEmail(invitation Invitation) (err error) {
+	// a resend replace the link sent before
+	token, err := s.issueLinkToken(PurposeInvitation, invitation.ID, "", time.Until(invitation.ExpiresAt))
+	if err != nil {
+		return
+	}
+	invitationLink := s.appDeploymentUrl + InvitationAcceptPath + "?code=" + token
+
+	return s.notifRepo.SendEmail(invitation.Email, invitation.Email, SubjectInvitation, fmt.Sprintf(EmailBodyInvitation, invitation.Role, invitationLink, int(s.invitationTTL.Hours())))
+}
+
+func (s *service) GetInvitations() (invitations []Invitation, err error) {
+	if s.invitationRepo == nil {
+		return nil, ErrInvitationsUnavailable
+	}
+
+	return s.invitationRepo.GetPendingInvitations()
+}
+
+// getPendingInvitation return ErrInvitationNotFound for an accepted or revoked invitation too
+func (s *service) getPendingInvitation(id string) (invitation Invitation, err error) {
+	if s.invitationRepo == nil {
+		return invitation, ErrInvitationsUnavailable
+	}
+
+	invitation, err = s.invitationRepo.GetInvitationByID(id)
+	if err != nil {
+		return
+	}
+
+	if invitation.ID == "" || invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return Invitation{}, ErrInvitationNotFound
+	}
+	return
+}
+
+// ResendInvitation send a new link and extend the expiry, an expired invitation can be resent
+func (s *service) ResendInvitation(id string) (invitation Invitation, err error) {
+	invitation, err = s.getPendingInvitation(id)
+	if err != nil {
+		return
+	}
+
+	if throttled := s.throttle("invitation:"+id, s.resendInterval); throttled {
+		return Invitation{}, ErrTooManyRequests
+	}
+
+	invitation.ExpiresAt = time.Now().Add(s.invitationTTL)
+	if err = s.invitationRepo.UpdateInvitationExpiry(id, invitation.ExpiresAt); err != nil {
+		return Invitation{}, err
+	}
+
+	if err = s.sendInvitationEmail(invitation); err != nil {
+		s.logger.Error("resend invitation email err", slog.Any("err", err.Error()))
+		return Invitation{}, err
+	}
+
+	return invitation, nil
+}
+
+func (s *service) RevokeInvitation(id string) (err error) {
+	if _, err = s.getPendingInvitation(id); err != nil {
+		return
+	}
+
+	if err = s.invitationRepo.RevokeInvitation(id, time.Now()); err != nil {
+		return
+	}
+
+	if s.oneTimeTokens != nil {
+		if err := s.oneTimeTokens.Revoke(PurposeInvitation, id); err != nil {
+			s.logger.Error("revoke invitation link err", slog.String("invitation_id", id), slog.Any("err", err.Error()))
+		}
+	}
+	return nil
+}
+
+// AcceptInvitation create the invited user with the role of the invitation,
+// the email is verified since the link was received on it
+func (s *service) AcceptInvitation(invitationToken string, fullname string, password string) (id string, err error) {
+	if s.invitationRepo == nil {
+		return id, ErrInvitationsUnavailable
+	}
+
+	fullname = strings.TrimSpace(fullname)
+	if fullname == "" {
+		return id, ErrInvalidFullname
+	}
+
+	if err = s.checkPassword(password); err != nil {
+		return
+	}
+
+	token, err := s.consumeLinkToken(PurposeInvitation, invitationToken)
+	if err != nil {
+		return
+	}
+
+	invitation, err := s.getPendingInvitation(token.Subject)
+	if errors.Is(err, ErrInvitationNotFound) {
+		return id, errors.New("invalid or expired url")
+	}
+	if err != nil {
+		return
+	}
+
+	encPassword, err := s.hashPassword(password)
+	if err != nil {
+		return
+	}
+
+	// the unique email refuse a second user when the same invitation is accepted twice at once
+	user := User{
+		ID:              uuid.NewString(),
+		Email:           invitation.Email,
+		Password:        encPassword,
+		Fullname:        fullname,
+		Role:            invitation.Role,
+		IsEmailVerified: true,
+	}
+	err = s.repo.Create(user)
+	if errors.Is(err, ErrDuplicateEmail) {
+		return id, ErrEmailRegistered
+	}
+	if err != nil {
+		return
+	}
+
+	if _, err := s.invitationRepo.AcceptInvitation(invitation.ID, time.Now()); err != nil {
+		s.logger.Error("mark invitation accepted err", slog.String("invitation_id", invitation.ID), slog.Any("err", err.Error()))
+	}
+
+	s.recordEvent(EventRegistered, user)
+
+	return user.ID, nil
+}
@@ -0,0 +1,53 @@
+package user
+
+import (
+	"belajarGo2/service/onetimetoken"
+	"errors"
+	"time"
+)
+
+// ErrLinksUnavailable is returned by the actions sending a link by email when there is no token store
+var ErrLinksUnavailable = errors.New("emailed links are not enabled")
+
+// the purposes of the one-time tokens sent in the links, the subject is the user id
+// or the invitation id for an invitation
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeResetPassword     = "reset_password"
+	PurposeEmailChange       = "email_change"
+	PurposeInvitation        = "invitation"
+)
+
+// WithOneTimeTokens store the tokens of the emailed links, the verification, reset password,
+// email change and invitation links need it
+func WithOneTimeTokens(oneTimeTokens onetimetoken.Service) Option {
+	return func(s *service) {
+		s.oneTimeTokens = oneTimeTokens
+	}
+}
+
+// issueLinkToken revoke the links of the same purpose sent before, only the last one can be used
+func (s *service) issueLinkToken(purpose string, subject string, payload string, ttl time.Duration) (token string, err error) {
+	if s.oneTimeTokens == nil {
+		return "", ErrLinksUnavailable
+	}
+
+	if err = s.oneTimeTokens.Revoke(purpose, subject); err != nil {
+		return
+	}
+
+	return s.oneTimeTokens.Issue(purpose, subject, payload, ttl)
+}
+
+// consumeLinkToken return the same error for every invalid token so the response never tell why
+func (s *service) consumeLinkToken(purpose string, token string) (consumed onetimetoken.Token, err error) {
+	if s.oneTimeTokens == nil {
+		return consumed, ErrLinksUnavailable
+	}
+
+	consumed, err = s.oneTimeTokens.Consume(purpose, token)
+	if errors.Is(err, onetimetoken.ErrInvalidToken) {
+		return consumed, errors.New("invalid or expired url")
+	}
+	return
+}
@@ -0,0 +1,173 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var ErrLoginLocked = errors.New("too many failed login attempts, try again later")
+
+// LoginProtectionConfig tune the brute-force protection of Login, a zero value keep the default
+type LoginProtectionConfig struct {
+	// MaxAttempts failures for an email before the email is locked
+	MaxAttempts int
+	// MaxAttemptsPerIP failures from an ip, whatever the email, before the ip is locked
+	MaxAttemptsPerIP int
+	// Window is how long a failure is remembered
+	Window time.Duration
+	// LockoutDuration is how long the email or ip is locked
+	LockoutDuration time.Duration
+	// BaseDelay is doubled on every failure up to MaxDelay, it is applied before checking the password
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (c *LoginProtectionConfig) setDefault() {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.MaxAttemptsPerIP <= 0 {
+		c.MaxAttemptsPerIP = 20
+	}
+	if c.Window <= 0 {
+		c.Window = 15 * time.Minute
+	}
+	if c.LockoutDuration <= 0 {
+		c.LockoutDuration = 15 * time.Minute
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 250 * time.Millisecond
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 4 * time.Second
+	}
+}
+
+// WithLoginProtection enable the failed login tracking, it need the cache given by WithCache
+func WithLoginProtection(cfg LoginProtectionConfig) Option {
+	return func(s *service) {
+		cfg.setDefault()
+		s.loginProtection = &cfg
+	}
+}
+
+const (
+	SubjectAccountLocked = "Your Account Has Been Temporarily Locked"
+	// EmailBodyAccountLocked is sent once per lockout
+	EmailBodyAccountLocked = `Halo, %v, Akun anda dikunci sementara karena terlalu banyak percobaan login yang gagal.<br/>Silakan coba lagi dalam %v menit, atau atur ulang password anda jika itu bukan anda.`
+)
+
+func loginFailureKey(kind string, value string) string {
+	return "user:login:fail:" + kind + ":" + strings.ToLower(value)
+}
+
+func loginLockKey(kind string, value string) string {
+	return "user:login:lock:" + kind + ":" + strings.ToLower(value)
+}
+
+func (s *service) loginGuardEnabled() bool {
+	return s.loginProtection != nil && s.cache != nil
+}
+
+// checkLogin refuse a locked email or ip, otherwise wait the progressive delay of the previous failures
+func (s *service) checkLogin(email string, client ClientInfo) (err error) {
+	if !s.loginGuardEnabled() {
+		return nil
+	}
+
+	if s.isLocked("email", email) || (client.IPAddress != "" && s.isLocked("ip", client.IPAddress)) {
+		return ErrLoginLocked
+	}
+
+	failures := s.getCount(loginFailureKey("email", email))
+	if client.IPAddress != "" {
+		failures = max(failures, s.getCount(loginFailureKey("ip", client.IPAddress)))
+	}
+
+	if failures > 0 {
+		delay := s.loginProtection.BaseDelay << min(failures-1, 16)
+		time.Sleep(min(delay, s.loginProtection.MaxDelay))
+	}
+	return nil
+}
+
+// loginFailed count the failure, the counters are read then written back so concurrent
+// failures can be counted once, the lockout is only delayed by it
+func (s *service) loginFailed(email string, client ClientInfo, user User) {
+	if !s.loginGuardEnabled() {
+		return
+	}
+	cfg := s.loginProtection
+
+	emailFailures := s.incrCount(loginFailureKey("email", email))
+	if emailFailures >= cfg.MaxAttempts {
+		s.lock("email", email)
+		s.cache.Delete(loginFailureKey("email", email))
+		s.logger.Warn("login locked", slog.String("email", email), slog.String("ip", client.IPAddress))
+		s.recordSecurityEvent(SecurityLoginLocked, OutcomeFailure, "", user.ID, client, "email "+maskEmail(email))
+
+		// unknown emails are locked too, but there is nobody to notify
+		if user.Email != "" {
+			body := fmt.Sprintf(EmailBodyAccountLocked, user.Fullname, int(cfg.LockoutDuration.Minutes()))
+			if err := s.notifRepo.SendEmail(user.Fullname, user.Email, SubjectAccountLocked, body); err != nil {
+				s.logger.Error("send lockout email err", slog.Any("err", err.Error()))
+			}
+		}
+	}
+
+	if client.IPAddress == "" {
+		return
+	}
+
+	ipFailures := s.incrCount(loginFailureKey("ip", client.IPAddress))
+	if ipFailures >= cfg.MaxAttemptsPerIP {
+		s.lock("ip", client.IPAddress)
+		s.cache.Delete(loginFailureKey("ip", client.IPAddress))
+		s.logger.Warn("login locked", slog.String("ip", client.IPAddress))
+		s.recordSecurityEvent(SecurityLoginLocked, OutcomeFailure, "", "", client, "ip")
+	}
+}
+
+// loginSucceeded reset the email counter, the ip counter is kept so a password spraying
+// from the same ip still get locked
+func (s *service) loginSucceeded(email string) {
+	if !s.loginGuardEnabled() {
+		return
+	}
+
+	s.cache.Delete(loginFailureKey("email", email))
+}
+
+func (s *service) isLocked(kind string, value string) bool {
+	var lockedUntil int64
+	if err := s.cache.Get(loginLockKey(kind, value), &lockedUntil); err != nil {
+		s.logger.Error("login lock cache get err", slog.Any("err", err.Error()))
+	}
+
+	return lockedUntil > 0 && time.Now().Unix() < lockedUntil
+}
+
+func (s *service) lock(kind string, value string) {
+	lockedUntil := time.Now().Add(s.loginProtection.LockoutDuration).Unix()
+	if err := s.cache.Set(loginLockKey(kind, value), lockedUntil, s.loginProtection.LockoutDuration); err != nil {
+		s.logger.Error("login lock cache set err", slog.Any("err", err.Error()))
+	}
+}
+
+func (s *service) getCount(key string) (count int) {
+	if err := s.cache.Get(key, &count); err != nil {
+		s.logger.Error("login counter cache get err", slog.Any("err", err.Error()))
+	}
+	return
+}
+
+func (s *service) incrCount(key string) (count int) {
+	count = s.getCount(key) + 1
+	if err := s.cache.Set(key, count, s.loginProtection.Window); err != nil {
+		s.logger.Error("login counter cache set err", slog.Any("err", err.Error()))
+	}
+	return
+}
@@ -0,0 +1,337 @@
+package user
+
+import (
+	"belajarGo2/service/signingkey"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOIDCUnavailable      = errors.New("single sign-on is not configured")
+	ErrInvalidOIDCState     = errors.New("invalid or expired single sign-on state")
+	ErrOIDCLoginFailed      = errors.New("single sign-on login failed")
+	ErrOIDCEmailNotVerified = errors.New("single sign-on email is not verified")
+	ErrOIDCUserNotFound     = errors.New("no account for the single sign-on email")
+)
+
+// OIDCConfig is the OpenID Connect provider used for the single sign-on,
+// the provider endpoints are read from its discovery document
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered at the provider
+	RedirectURL string
+	Scopes      []string
+	// AutoProvision create an account for a verified email without one, otherwise only the existing accounts can login
+	AutoProvision bool
+	// DefaultRole is the role of the provisioned accounts
+	DefaultRole string
+	// StateTTL is how long the user has to login at the provider
+	StateTTL   time.Duration
+	HTTPClient *http.Client
+}
+
+func (c *OIDCConfig) setDefault() {
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "email", "profile"}
+	}
+	if c.DefaultRole == "" {
+		c.DefaultRole = RoleUser
+	}
+	if c.StateTTL == 0 {
+		c.StateTTL = 10 * time.Minute
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+}
+
+// WithOIDC enable the single sign-on, it need the cache to keep the login state
+func WithOIDC(cfg OIDCConfig) Option {
+	return func(s *service) {
+		cfg.setDefault()
+		s.oidc = &oidcProvider{config: cfg}
+	}
+}
+
+type oidcProvider struct {
+	config OIDCConfig
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keySet    *signingkey.RemoteKeySet
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcState is kept in the cache between the redirect to the provider and the callback
+type oidcState struct {
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+type oidcIDTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func oidcStateKey(state string) string {
+	return "user:oidc:state:" + hashToken(state)
+}
+
+// discover read the discovery document once, a failure is retried on the next login
+func (p *oidcProvider) discover() (discovery oidcDiscovery, keySet *signingkey.RemoteKeySet, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return *p.discovery, p.keySet, nil
+	}
+
+	resp, err := p.config.HTTPClient.Get(strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return discovery, nil, fmt.Errorf("oidc discovery: unexpected status %v", resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return discovery, nil, errors.New("oidc discovery: missing endpoint")
+	}
+
+	p.discovery = &discovery
+	p.keySet = signingkey.NewRemoteKeySet(discovery.JWKSURI, p.config.HTTPClient)
+	return discovery, p.keySet, nil
+}
+
+func (s *service) OIDCAuthURL() (authURL string, err error) {
+	if s.oidc == nil || s.cache == nil {
+		return "", ErrOIDCUnavailable
+	}
+
+	discovery, _, err := s.oidc.discover()
+	if err != nil {
+		s.logger.Error("oidc discovery err", slog.Any("err", err.Error()))
+		return "", ErrOIDCUnavailable
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return
+	}
+	codeVerifier, err := generateOpaqueToken()
+	if err != nil {
+		return
+	}
+
+	cfg := s.oidc.config
+	expAt := time.Now().Add(cfg.StateTTL)
+	if err = s.cache.Set(oidcStateKey(state), oidcState{CodeVerifier: codeVerifier, Nonce: nonce, ExpiresAt: expAt.Unix()}, cfg.StateTTL); err != nil {
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (s *service) OIDCCallback(state string, code string, client ClientInfo) (token Token, err error) {
+	if s.oidc == nil || s.cache == nil {
+		return token, ErrOIDCUnavailable
+	}
+
+	// the state is single use
+	key := oidcStateKey(state)
+	loginState := oidcState{}
+	if err = s.cache.Get(key, &loginState); err != nil {
+		return
+	}
+	s.cache.Delete(key)
+	if loginState.CodeVerifier == "" || time.Now().Unix() >= loginState.ExpiresAt {
+		return token, ErrInvalidOIDCState
+	}
+
+	claims, err := s.exchangeOIDCCode(code, loginState)
+	if err != nil {
+		s.logger.Error("oidc login err", slog.Any("err", err.Error()), slog.String("ip", client.IPAddress))
+		return token, ErrOIDCLoginFailed
+	}
+
+	verified, _ := claims.EmailVerified.(bool)
+	if verifiedString, ok := claims.EmailVerified.(string); ok {
+		verified = verifiedString == "true"
+	}
+	if claims.Email == "" || !verified {
+		return token, ErrOIDCEmailNotVerified
+	}
+
+	getUser, err := s.oidcUser(claims)
+	if err != nil {
+		return
+	}
+
+	if getUser.IsDisabled {
+		return token, ErrUserDisabled
+	}
+
+	if getUser.IsTOTPEnabled {
+		return s.twoFactorChallenge(getUser)
+	}
+
+	return s.startSession(getUser, client, false)
+}
+
+// exchangeOIDCCode redeem the code with the PKCE verifier and verify the id token
+func (s *service) exchangeOIDCCode(code string, loginState oidcState) (claims oidcIDTokenClaims, err error) {
+	discovery, keySet, err := s.oidc.discover()
+	if err != nil {
+		return
+	}
+
+	cfg := s.oidc.config
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {loginState.CodeVerifier},
+	}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	resp, err := cfg.HTTPClient.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return claims, fmt.Errorf("oidc token: unexpected status %v", resp.StatusCode)
+	}
+
+	tokenResponse := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return
+	}
+
+	issuer := discovery.Issuer
+	if issuer == "" {
+		issuer = cfg.IssuerURL
+	}
+	_, err = jwt.ParseWithClaims(tokenResponse.IDToken, &claims, keySet.Keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return
+	}
+
+	if claims.Nonce != loginState.Nonce {
+		return claims, errors.New("oidc token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// oidcUser link the provider email to the existing account, or provision a new one
+func (s *service) oidcUser(claims oidcIDTokenClaims) (user User, err error) {
+	email := claims.Email
+	user, err = s.repo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+
+	if user.ID != "" {
+		// the provider proved the address, the pending email verification is not needed anymore.
+		// Anybody could have registered the unverified account before the owner of the address: its
+		// password is cleared and its tokens revoked, the account can only login with the provider
+		// until a password reset
+		if !user.IsEmailVerified {
+			verified := true
+			noPassword := ""
+			if err = s.repo.Update(user.ID, Patch{IsEmailVerified: &verified, Password: &noPassword}); err != nil {
+				return
+			}
+			if err = s.revokeUserTokens(user.ID); err != nil {
+				return User{}, err
+			}
+			user.IsEmailVerified = true
+			user.Password = ""
+			s.recordEvent(EventEmailVerified, user)
+		}
+		return user, nil
+	}
+
+	if !s.oidc.config.AutoProvision {
+		return user, ErrOIDCUserNotFound
+	}
+
+	fullname := strings.TrimSpace(claims.Name)
+	if fullname == "" {
+		fullname = email
+	}
+
+	// no local password, the account can only login with the provider until a password reset
+	user = User{
+		ID:              uuid.NewString(),
+		Email:           email,
+		Fullname:        fullname,
+		Role:            s.oidc.config.DefaultRole,
+		IsEmailVerified: true,
+	}
+	if err = s.repo.Create(user); err != nil {
+		return User{}, err
+	}
+
+	s.recordEvent(EventRegistered, user)
+	return user, nil
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var (
+	ErrOrganizationsUnavailable = errors.New("organizations are not enabled")
+	ErrNotMember                = errors.New("user is not a member of the organization")
+)
+
+// OrganizationMembership resolve the role of a user in an organization, the role is empty for a non member
+type OrganizationMembership interface {
+	MemberRole(organizationID string, userID string) (role string, err error)
+}
+
+// WithOrganizations let the user switch the active organization put in the access tokens
+func WithOrganizations(memberships OrganizationMembership) Option {
+	return func(s *service) {
+		s.memberships = memberships
+	}
+}
+
+// SwitchOrganization only return an access token, the refresh token of the session is kept and
+// the next refreshed token read the active organization from the user
+func (s *service) SwitchOrganization(claims Claims, organizationID string) (token Token, err error) {
+	if s.memberships == nil {
+		return token, ErrOrganizationsUnavailable
+	}
+
+	role, err := s.memberships.MemberRole(organizationID, claims.ID)
+	if err != nil {
+		return
+	}
+	if role == "" {
+		return token, ErrNotMember
+	}
+
+	getUser, err := s.GetByID(claims.ID)
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.Update(getUser.ID, Patch{OrganizationID: &organizationID}); err != nil {
+		s.logger.Error("switch organization err", slog.Any("err", err.Error()))
+		return
+	}
+	getUser.OrganizationID = organizationID
+
+	accessToken, err := s.generateToken(s.jwtSign, getUser, claims.SessionID, claims.TwoFactorPending)
+	if err != nil {
+		s.logger.Error("generate token err", slog.Any("err", err.Error()))
+		return token, errors.New("generate token error")
+	}
+
+	return Token{
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(s.accessTokenTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	resetPasswordCodeTTL = 30
+
+	// ResetPasswordPath is the path of the reset link, a GET serve the form posting the new password to it
+	ResetPasswordPath = "/users/password/reset"
+
+	SubjectResetPassword = "Reset Your Password"
+	// EmailBodyResetPassword do not say anything about the account, the email is sent to registered address only
+	EmailBodyResetPassword = `Halo, %v, Atur ulang password anda dengan membuka tautan dibawah<br><br/>%v<br/>catatan: link hanya berlaku %v menit dan hanya bisa dipakai sekali`
+)
+
+// ForgotPassword send a reset link when the email is registered. It return no error for an unknown
+// email so the response never tell whether an account exist
+func (s *service) ForgotPassword(email string) (err error) {
+	getUser, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+
+	if getUser.Email == "" {
+		s.logger.Info("forgot password for unknown email")
+		return nil
+	}
+
+	token, err := s.issueLinkToken(PurposeResetPassword, getUser.ID, "", time.Minute*resetPasswordCodeTTL)
+	if err != nil {
+		return
+	}
+	resetLink := s.appDeploymentUrl + ResetPasswordPath + "?code=" + token
+
+	if err := s.notifRepo.SendEmail(getUser.Fullname, getUser.Email, SubjectResetPassword, fmt.Sprintf(EmailBodyResetPassword, getUser.Fullname, resetLink, resetPasswordCodeTTL)); err != nil {
+		s.logger.Error("send reset password email err", slog.Any("err", err.Error()))
+	}
+
+	return nil
+}
+
+// ResetPassword set the new password and revoke every token of the user
+func (s *service) ResetPassword(resetToken string, newPassword string, client ClientInfo) (err error) {
+	if err = s.checkPassword(newPassword); err != nil {
+		return
+	}
+
+	token, err := s.consumeLinkToken(PurposeResetPassword, resetToken)
+	if err != nil {
+		return
+	}
+
+	getUser, err := s.repo.GetByID(token.Subject)
+	if err != nil {
+		s.logger.Error("reset password err", slog.Any("err", err))
+		return err
+	}
+
+	if getUser.ID == "" {
+		return errors.New("invalid or expired url")
+	}
+
+	encPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return
+	}
+
+	getUser.Password = encPassword
+	if err := s.repo.UpdatePassword(getUser); err != nil {
+		s.logger.Error("reset password err", slog.Any("err", err))
+		return err
+	}
+
+	// the old password may be known by someone else, end every session
+	err = s.revokeUserTokens(getUser.ID)
+	s.recordSecurityEvent(SecurityPasswordReset, OutcomeSuccess, "", getUser.ID, client, "")
+
+	return
+}
@@ -0,0 +1,154 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// PasswordHashConfig choose how the new passwords are hashed, the empty fields keep the default.
+// Once configured, Login rehash a password stored with another algorithm or other parameters
+type PasswordHashConfig struct {
+	// Algorithm is bcrypt or argon2id
+	Algorithm  string
+	BcryptCost int
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+func (c *PasswordHashConfig) setDefault() {
+	if c.Algorithm == "" {
+		c.Algorithm = PasswordAlgorithmBcrypt
+	}
+	if c.BcryptCost == 0 {
+		c.BcryptCost = bcrypt.DefaultCost
+	}
+	// the second recommended option of RFC 9106
+	if c.Argon2Memory == 0 {
+		c.Argon2Memory = 64 * 1024
+	}
+	if c.Argon2Iterations == 0 {
+		c.Argon2Iterations = 3
+	}
+	if c.Argon2Parallelism == 0 {
+		c.Argon2Parallelism = 4
+	}
+}
+
+// WithPasswordHash override the password hashing, without it the passwords are hashed
+// with bcrypt at the default cost and never rehashed
+func WithPasswordHash(cfg PasswordHashConfig) Option {
+	return func(s *service) {
+		cfg.setDefault()
+		s.passwordHash = &cfg
+	}
+}
+
+func (s *service) passwordHashConfig() PasswordHashConfig {
+	if s.passwordHash != nil {
+		return *s.passwordHash
+	}
+
+	cfg := PasswordHashConfig{}
+	cfg.setDefault()
+	return cfg
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+func (s *service) hashPassword(password string) (hash string, err error) {
+	cfg := s.passwordHashConfig()
+	if cfg.Algorithm != PasswordAlgorithmArgon2id {
+		encPassword, err := bcrypt.GenerateFromPassword([]byte(password), cfg.BcryptCost)
+		return string(encPassword), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+
+	key := argon2.IDKey([]byte(password), salt, cfg.Argon2Iterations, cfg.Argon2Memory, cfg.Argon2Parallelism, argon2KeyLength)
+
+	// PHC string format, the parameters are kept with the hash
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword check a password against a bcrypt or argon2id hash,
+// needRehash is true when the hash doesn't use the configured algorithm and parameters
+func (s *service) verifyPassword(hash string, password string) (valid bool, needRehash bool, err error) {
+	cfg := s.passwordHashConfig()
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			return false, false, nil
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		needRehash = cfg.Algorithm != PasswordAlgorithmBcrypt || err != nil || cost != cfg.BcryptCost
+		return true, s.passwordHash != nil && needRehash, nil
+	}
+
+	var (
+		version            int
+		memory, iterations uint32
+		parallelism        uint8
+	)
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, errInvalidPasswordHash
+	}
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+
+	needRehash = cfg.Algorithm != PasswordAlgorithmArgon2id || version != argon2.Version ||
+		memory != cfg.Argon2Memory || iterations != cfg.Argon2Iterations || parallelism != cfg.Argon2Parallelism
+	return true, s.passwordHash != nil && needRehash, nil
+}
+
+// rehashPassword is best effort, the login already succeeded
+func (s *service) rehashPassword(user User, password string) {
+	hash, err := s.hashPassword(password)
+	if err == nil {
+		err = s.repo.Update(user.ID, Patch{Password: &hash})
+	}
+	if err != nil {
+		s.logger.Error("rehash password err", "user_id", user.ID, "err", err.Error())
+	}
+}
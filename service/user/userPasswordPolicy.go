@@ -0,0 +1,127 @@
+package user
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("weak password")
+
+// PasswordPolicy is checked on register, password reset and password change
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength bound the hashing cost, bcrypt ignore everything after 72 bytes
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DenyList is compared case insensitively, see ReadDenyList
+	DenyList []string
+
+	denied map[string]struct{}
+}
+
+func (p *PasswordPolicy) setDefault() {
+	if p.MinLength == 0 {
+		p.MinLength = 8
+	}
+	if p.MaxLength == 0 {
+		p.MaxLength = 72
+	}
+
+	p.denied = make(map[string]struct{}, len(p.DenyList))
+	for _, password := range p.DenyList {
+		p.denied[strings.ToLower(password)] = struct{}{}
+	}
+}
+
+// WithPasswordPolicy reject the passwords not matching the policy, without it any password is accepted
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(s *service) {
+		policy.setDefault()
+		s.passwordPolicy = &policy
+	}
+}
+
+// ReadDenyList read one password per line, the empty lines and the lines starting with # are skipped
+func ReadDenyList(path string) (denyList []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denyList = append(denyList, line)
+	}
+
+	return denyList, scanner.Err()
+}
+
+// Validate return ErrWeakPassword wrapped with every rule the password break
+func (p PasswordPolicy) Validate(password string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if _, ok := p.denied[strings.ToLower(password)]; ok {
+		violations = append(violations, "is too common")
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: password %s", ErrWeakPassword, strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+func (s *service) checkPassword(password string) error {
+	if s.passwordPolicy == nil {
+		return nil
+	}
+
+	return s.passwordPolicy.Validate(password)
+}
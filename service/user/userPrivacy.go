@@ -0,0 +1,279 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrDeletionScheduled    = errors.New("account deletion is scheduled already")
+	ErrDeletionNotScheduled = errors.New("account deletion is not scheduled")
+)
+
+const (
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+	// anonymizedFullname replace the name of a deleted account, its id is kept for the history referencing it
+	anonymizedFullname = "Deleted User"
+
+	SubjectAccountDeletion = "Your Account Will Be Deleted"
+	// EmailBodyAccountDeletion is sent when the deletion is requested, the account can still be restored until the date
+	EmailBodyAccountDeletion = `Halo, %v, Akun anda akan dihapus pada %v<br/>Jika bukan anda atau anda berubah pikiran, login dan batalkan penghapusan sebelum tanggal tersebut`
+)
+
+type (
+	// DataExporter add the data another service hold about the user to the export, like the api keys
+	DataExporter interface {
+		ExportUserData(userID string) (data interface{}, err error)
+	}
+
+	// DataEraser remove the data another service hold about the user once the account is anonymized,
+	// like the user events queued for the webhooks
+	DataEraser interface {
+		EraseUserData(userID string) (err error)
+	}
+
+	// DataExport is everything held about the user, Data is keyed by the name of the exporter.
+	// SecurityEvents is bounded like the csv export of the audit log
+	DataExport struct {
+		ExportedAt      time.Time              `json:"exported_at"`
+		Profile         User                   `json:"profile"`
+		Sessions        []Session              `json:"sessions"`
+		SecurityEvents  []SecurityEvent        `json:"security_events"`
+		InvitationsSent []Invitation           `json:"invitations_sent"`
+		Data            map[string]interface{} `json:"data,omitempty"`
+	}
+)
+
+// WithDataExporter add a section to the personal data export, name is the key of the section
+func WithDataExporter(name string, exporter DataExporter) Option {
+	return func(s *service) {
+		if s.dataExporters == nil {
+			s.dataExporters = map[string]DataExporter{}
+		}
+		s.dataExporters[name] = exporter
+	}
+}
+
+// WithDataEraser add a service whose user data is erased when the account is anonymized
+func WithDataEraser(name string, eraser DataEraser) Option {
+	return func(s *service) {
+		if s.dataErasers == nil {
+			s.dataErasers = map[string]DataEraser{}
+		}
+		s.dataErasers[name] = eraser
+	}
+}
+
+// WithDeletionGracePeriod override how long a deleted account can be restored before it is anonymized
+func WithDeletionGracePeriod(gracePeriod time.Duration) Option {
+	return func(s *service) {
+		if gracePeriod > 0 {
+			s.deletionGracePeriod = gracePeriod
+		}
+	}
+}
+
+func (s *service) ExportData(userID string) (export DataExport, err error) {
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	export = DataExport{
+		ExportedAt:      time.Now(),
+		Profile:         getUser,
+		Sessions:        []Session{},
+		SecurityEvents:  []SecurityEvent{},
+		InvitationsSent: []Invitation{},
+	}
+
+	if s.sessionRepo != nil {
+		sessions, err := s.sessionRepo.GetUserSessions(userID)
+		if err != nil {
+			return DataExport{}, err
+		}
+		if sessions != nil {
+			export.Sessions = sessions
+		}
+	}
+
+	if s.securityRepo != nil {
+		for page := 1; len(export.SecurityEvents) < maxSecurityEventExport; page++ {
+			events, _, err := s.securityRepo.GetSecurityEvents(SecurityEventFilter{UserID: userID}, page, securityEventExportBatch)
+			if err != nil {
+				return DataExport{}, err
+			}
+
+			export.SecurityEvents = append(export.SecurityEvents, events[:min(len(events), maxSecurityEventExport-len(export.SecurityEvents))]...)
+			if len(events) < securityEventExportBatch {
+				break
+			}
+		}
+	}
+
+	if s.invitationRepo != nil {
+		invitations, err := s.invitationRepo.GetInvitationsByInviter(userID)
+		if err != nil {
+			return DataExport{}, err
+		}
+		if invitations != nil {
+			export.InvitationsSent = invitations
+		}
+	}
+
+	for name, exporter := range s.dataExporters {
+		data, err := exporter.ExportUserData(userID)
+		if err != nil {
+			s.logger.Error("export user data err", slog.String("exporter", name), slog.Any("err", err.Error()))
+			return DataExport{}, err
+		}
+
+		if export.Data == nil {
+			export.Data = map[string]interface{}{}
+		}
+		export.Data[name] = data
+	}
+
+	return export, nil
+}
+
+// RequestDeletion schedule the anonymization after the grace period, the user can still login and cancel it until then
+func (s *service) RequestDeletion(userID string) (deleteAt time.Time, err error) {
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if getUser.DeleteAt != nil {
+		return *getUser.DeleteAt, ErrDeletionScheduled
+	}
+
+	deleteAt = time.Now().Add(s.deletionGracePeriod).UTC().Truncate(time.Second)
+	if err = s.repo.ScheduleDeletion(userID, &deleteAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.notifRepo.SendEmail(getUser.Fullname, getUser.Email, SubjectAccountDeletion, fmt.Sprintf(EmailBodyAccountDeletion, getUser.Fullname, deleteAt.Format(time.RFC1123))); err != nil {
+		s.logger.Error("send account deletion email err", slog.Any("err", err.Error()))
+	}
+
+	return deleteAt, nil
+}
+
+func (s *service) CancelDeletion(userID string) (err error) {
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if getUser.DeleteAt == nil {
+		return ErrDeletionNotScheduled
+	}
+
+	return s.repo.ScheduleDeletion(userID, nil)
+}
+
+// AnonymizeDueAccounts is run by a scheduled job. The user record is kept with its id so the history
+// referencing it stay valid, but every personal data is replaced and the sessions are deleted
+func (s *service) AnonymizeDueAccounts(limit int) (anonymized int, err error) {
+	users, err := s.repo.GetDueDeletions(time.Now(), limit)
+	if err != nil {
+		return
+	}
+
+	for _, usr := range users {
+		if err = s.anonymize(usr); err != nil {
+			s.logger.Error("anonymize account err", slog.String("user_id", usr.ID), slog.Any("err", err.Error()))
+			return
+		}
+		anonymized++
+	}
+
+	return anonymized, nil
+}
+
+// anonymize clear the schedule last, an account failing in between is picked up again by the next run.
+// The invitations are matched by the email, so they are anonymized before the email is replaced
+func (s *service) anonymize(usr User) (err error) {
+	email := "deleted-" + usr.ID + "@anonymized.invalid"
+	fullname, password := anonymizedFullname, ""
+	verified, disabled := false, true
+
+	if s.invitationRepo != nil {
+		if err = s.invitationRepo.AnonymizeInvitationEmail(usr.Email, email); err != nil {
+			return
+		}
+	}
+
+	err = s.repo.Update(usr.ID, Patch{
+		Email:           &email,
+		Fullname:        &fullname,
+		Password:        &password,
+		IsEmailVerified: &verified,
+		IsDisabled:      &disabled,
+	})
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.UpdateTwoFactor(User{ID: usr.ID}); err != nil {
+		return
+	}
+
+	if err = s.revokeUserTokens(usr.ID); err != nil {
+		return
+	}
+	if s.sessionRepo != nil {
+		if err = s.sessionRepo.DeleteUserSessions(usr.ID); err != nil {
+			return
+		}
+	}
+
+	if s.securityRepo != nil {
+		if err = s.securityRepo.AnonymizeUserSecurityEvents(usr.ID); err != nil {
+			return
+		}
+	}
+
+	usr.Email = email
+	usr.Fullname = fullname
+	usr.IsEmailVerified = verified
+	usr.IsDisabled = disabled
+	usr.DeleteAt = nil
+
+	// the events recorded before carry the email and the name of the user
+	if s.eventRepo != nil {
+		payload, err := json.Marshal(EventPayload{
+			ID:              usr.ID,
+			Email:           usr.Email,
+			Fullname:        usr.Fullname,
+			Role:            usr.Role,
+			IsEmailVerified: usr.IsEmailVerified,
+			IsDisabled:      usr.IsDisabled,
+		})
+		if err != nil {
+			return err
+		}
+		if err = s.eventRepo.RedactAggregate(EventAggregateType, usr.ID, string(payload)); err != nil {
+			return err
+		}
+	}
+
+	for name, eraser := range s.dataErasers {
+		if err = eraser.EraseUserData(usr.ID); err != nil {
+			s.logger.Error("erase user data err", slog.String("eraser", name), slog.Any("err", err.Error()))
+			return
+		}
+	}
+
+	if err = s.repo.ScheduleDeletion(usr.ID, nil); err != nil {
+		return
+	}
+
+	s.recordEvent(EventAnonymized, usr)
+	return nil
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrWrongPassword   = errors.New("current password is wrong")
+	ErrInvalidFullname = errors.New("fullname can not be empty")
+)
+
+func (s *service) UpdateProfile(userID string, fullname string) (user User, err error) {
+	fullname = strings.TrimSpace(fullname)
+	if fullname == "" {
+		return user, ErrInvalidFullname
+	}
+
+	user, err = s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.Update(userID, Patch{Fullname: &fullname}); err != nil {
+		return User{}, err
+	}
+
+	user.Fullname = fullname
+	return user, nil
+}
+
+// ChangePassword need the current password, every session is ended like a password reset
+func (s *service) ChangePassword(userID string, currentPassword string, newPassword string, client ClientInfo) (err error) {
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+
+	if valid, _, err := s.verifyPassword(getUser.Password, currentPassword); err != nil || !valid {
+		s.recordSecurityEvent(SecurityPasswordChanged, OutcomeFailure, userID, userID, client, "wrong current password")
+		return ErrWrongPassword
+	}
+
+	if err = s.checkPassword(newPassword); err != nil {
+		return
+	}
+
+	password, err := s.hashPassword(newPassword)
+	if err != nil {
+		return
+	}
+
+	if err = s.repo.Update(userID, Patch{Password: &password}); err != nil {
+		return
+	}
+
+	err = s.revokeUserTokens(userID)
+	s.recordSecurityEvent(SecurityPasswordChanged, OutcomeSuccess, userID, userID, client, "")
+	return
+}
@@ -1,7 +1,88 @@
 package user
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrDuplicateEmail is returned by every repository when the email is already registered
+var ErrDuplicateEmail = errors.New("duplicate key: email already registered")
+
+// Repository return a zero User and no error when the email or id is not found
 type Repository interface {
 	Create(user User) (err error)
 	GetByEmail(email string) (user User, err error)
+	GetByID(id string) (user User, err error)
+	// UpdateEmailVerification only set the verification flag of the user with the same email
 	UpdateEmailVerification(user User) (err error)
+	// UpdatePassword only set the password hash of the user with the same email
+	UpdatePassword(user User) (err error)
+	// GetAll is sorted by email, total count every user matching the filter
+	GetAll(filter Filter, page int, limit int) (users []User, total int64, err error)
+	UpdateRole(id string, role string) (err error)
+	UpdateDisabled(id string, disabled bool) (err error)
+	// Update apply the non nil fields of the patch to the user with the same id,
+	// it return ErrDuplicateEmail when the new email is registered already
+	Update(id string, patch Patch) (err error)
+	// UpdateTwoFactor only set the TOTP secret, flag and recovery codes of the user with the same id
+	UpdateTwoFactor(user User) (err error)
+	Delete(id string) (err error)
+	// ScheduleDeletion set when the account is anonymized, a nil deleteAt cancel it
+	ScheduleDeletion(id string, deleteAt *time.Time) (err error)
+	// GetDueDeletions return the users scheduled for deletion at or before now, the oldest first
+	GetDueDeletions(now time.Time, limit int) (users []User, err error)
+}
+
+// RefreshTokenRepository return a zero RefreshToken and no error when the hash is not found
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token RefreshToken) (err error)
+	GetRefreshTokenByHash(tokenHash string) (token RefreshToken, err error)
+	// RotateRefreshToken set RotatedAt only if the token was not rotated yet,
+	// rotated is false when another request used the token first
+	RotateRefreshToken(id string, rotatedAt time.Time) (rotated bool, err error)
+	RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) (err error)
+	RevokeUserRefreshTokens(userID string, revokedAt time.Time) (err error)
+}
+
+// InvitationRepository return a zero Invitation and no error when the id or email is not found
+type InvitationRepository interface {
+	CreateInvitation(invitation Invitation) (err error)
+	GetInvitationByID(id string) (invitation Invitation, err error)
+	// GetPendingInvitationByEmail return the invitation of the email not accepted nor revoked, expired or not
+	GetPendingInvitationByEmail(email string) (invitation Invitation, err error)
+	// GetPendingInvitations return every invitation not accepted nor revoked, the newest first
+	GetPendingInvitations() (invitations []Invitation, err error)
+	UpdateInvitationExpiry(id string, expiresAt time.Time) (err error)
+	// AcceptInvitation set AcceptedAt only if the invitation is still pending,
+	// accepted is false when it was accepted or revoked first
+	AcceptInvitation(id string, acceptedAt time.Time) (accepted bool, err error)
+	RevokeInvitation(id string, revokedAt time.Time) (err error)
+	// GetInvitationsByInviter return every invitation sent by the user, the newest first
+	GetInvitationsByInviter(userID string) (invitations []Invitation, err error)
+	// AnonymizeInvitationEmail replace the email of every invitation sent to it
+	AnonymizeInvitationEmail(email string, anonymizedEmail string) (err error)
+}
+
+// SessionRepository return a zero Session and no error when the id is not found
+type SessionRepository interface {
+	CreateSession(session Session) (err error)
+	GetSessionByID(id string) (session Session, err error)
+	// GetActiveSessions return the sessions not revoked nor expired at now, the last seen first
+	GetActiveSessions(userID string, now time.Time) (sessions []Session, err error)
+	// TouchSession set the last seen and the expiry of a session
+	TouchSession(id string, lastSeenAt time.Time, expiresAt time.Time) (err error)
+	RevokeSession(id string, revokedAt time.Time) (err error)
+	RevokeUserSessions(userID string, revokedAt time.Time) (err error)
+	// GetUserSessions return every session of the user, revoked and expired included, the newest first
+	GetUserSessions(userID string) (sessions []Session, err error)
+	DeleteUserSessions(userID string) (err error)
+}
+
+// SecurityEventRepository is append only, the security events are only updated to anonymize a deleted account
+type SecurityEventRepository interface {
+	CreateSecurityEvent(event SecurityEvent) (err error)
+	// GetSecurityEvents is sorted by the newest first, total count every event matching the filter
+	GetSecurityEvents(filter SecurityEventFilter, page int, limit int) (events []SecurityEvent, total int64, err error)
+	// AnonymizeUserSecurityEvents clear the ip address and the user agent of the events where the user is the actor or the subject
+	AnonymizeUserSecurityEvents(userID string) (err error)
 }
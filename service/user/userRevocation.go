@@ -0,0 +1,81 @@
+package user
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cache is satisfied by the go-cache memory repository and the redis repository of repository/cache.
+// Get must return the errors of the store, the revocation check fail closed on them
+type Cache interface {
+	Set(key string, value interface{}, expiration time.Duration) (err error)
+	Get(key string, data interface{}) (err error)
+	Delete(key string)
+}
+
+// TokenRevocation keep the revoked access tokens until they would expire anyway. The cache must not evict
+// the entries before they expire: use the memory repository of repository/cache, not the bounded ARC cache,
+// and the redis cache with a noeviction policy when more than one instance verify the tokens
+type TokenRevocation struct {
+	cache     Cache
+	keyPrefix string
+}
+
+func NewTokenRevocation(c Cache) *TokenRevocation {
+	return &TokenRevocation{
+		cache:     c,
+		keyPrefix: "auth:revoked:",
+	}
+}
+
+// RevokeToken revoke a single access token by its jti
+func (r *TokenRevocation) RevokeToken(jti string, expiresAt time.Time) (err error) {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+
+	return r.cache.Set(r.keyPrefix+"jti:"+jti, true, ttl)
+}
+
+// RevokeUserTokens revoke every access token of the user issued until revokedAt,
+// ttl must be at least the access token lifetime
+func (r *TokenRevocation) RevokeUserTokens(userID string, revokedAt time.Time, ttl time.Duration) (err error) {
+	return r.cache.Set(r.keyPrefix+"user:"+userID, revokedAt.Unix(), ttl)
+}
+
+// RevokeSession revoke every access token of a login, ttl must be at least the access token lifetime
+func (r *TokenRevocation) RevokeSession(sessionID string, ttl time.Duration) (err error) {
+	if sessionID == "" {
+		return nil
+	}
+
+	return r.cache.Set(r.keyPrefix+"sid:"+sessionID, true, ttl)
+}
+
+// IsSessionRevoked check the revocation of the login of a token, by its sid claim
+func (r *TokenRevocation) IsSessionRevoked(sessionID string) (revoked bool, err error) {
+	if sessionID == "" {
+		return false, nil
+	}
+
+	err = r.cache.Get(r.keyPrefix+"sid:"+sessionID, &revoked)
+	return
+}
+
+// IsRevoked check the jti and the user wide revocation. iat only has a second precision,
+// so a token issued in the same second as a "logout everywhere" is revoked too
+func (r *TokenRevocation) IsRevoked(jti string, userID string, issuedAt time.Time) (revoked bool, err error) {
+	if jti != "" {
+		if err = r.cache.Get(r.keyPrefix+"jti:"+jti, &revoked); err != nil || revoked {
+			return
+		}
+	}
+
+	var revokedSince int64
+	if err = r.cache.Get(r.keyPrefix+"user:"+userID, &revokedSince); err != nil {
+		return false, fmt.Errorf("get user revocation: %w", err)
+	}
+
+	return revokedSince > 0 && issuedAt.Unix() <= revokedSince, nil
+}
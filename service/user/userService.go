@@ -2,17 +2,17 @@ package user
 
 import (
 	"belajarGo2/service/notification"
+	"belajarGo2/service/onetimetoken"
+	"belajarGo2/service/outbox"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/pobyzaarif/goshortcute"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type service struct {
@@ -22,28 +22,213 @@ type service struct {
 	jwtSign                 string
 	appEmailVerificationKey string
 	notifRepo               notification.Repository
+	eventRepo               outbox.Repository
+	refreshRepo             RefreshTokenRepository
+	sessionRepo             SessionRepository
+	invitationRepo          InvitationRepository
+	revocation              *TokenRevocation
+	signer                  Signer
+	cache                   Cache
+	loginProtection         *LoginProtectionConfig
+	twoFactor               *TwoFactorConfig
+	passwordHash            *PasswordHashConfig
+	passwordPolicy          *PasswordPolicy
+	roleValidator           RoleValidator
+	oidc                    *oidcProvider
+	oneTimeTokens           onetimetoken.Service
+	memberships             OrganizationMembership
+	securityRepo            SecurityEventRepository
+	dataExporters           map[string]DataExporter
+	dataErasers             map[string]DataEraser
+	accessTokenTTL          time.Duration
+	refreshTokenTTL         time.Duration
+	emailVerificationTTL    time.Duration
+	resendInterval          time.Duration
+	deletionGracePeriod     time.Duration
+	invitationTTL           time.Duration
+}
+
+type Option func(*service)
+
+// WithEventRepository record user events into the outbox so they are relayed like the inventory events
+func WithEventRepository(eventRepo outbox.Repository) Option {
+	return func(s *service) {
+		s.eventRepo = eventRepo
+	}
+}
+
+// WithRefreshTokenRepository enable refresh tokens, Login then return a refresh token next to the access token
+func WithRefreshTokenRepository(refreshRepo RefreshTokenRepository) Option {
+	return func(s *service) {
+		s.refreshRepo = refreshRepo
+	}
+}
+
+// WithRoleValidator let ChangeRole and Invite accept the roles defined at runtime and compare their permissions
+func WithRoleValidator(roleValidator RoleValidator) Option {
+	return func(s *service) {
+		s.roleValidator = roleValidator
+	}
+}
+
+// WithTokenRevocation enable Logout and LogoutAll, the same store must be given to the jwt middlewares
+func WithTokenRevocation(revocation *TokenRevocation) Option {
+	return func(s *service) {
+		s.revocation = revocation
+	}
+}
+
+// WithSigner sign the access tokens with an asymmetric key instead of the shared jwt secret
+func WithSigner(signer Signer) Option {
+	return func(s *service) {
+		s.signer = signer
+	}
+}
+
+// WithCache is used to throttle the emails sent on request, without it there is no throttling
+func WithCache(c Cache) Option {
+	return func(s *service) {
+		s.cache = c
+	}
+}
+
+// WithEmailVerification override how long a verification link is valid
+// and how often a new one can be requested for the same address, a zero value keep the default
+func WithEmailVerification(ttl time.Duration, resendInterval time.Duration) Option {
+	return func(s *service) {
+		if ttl > 0 {
+			s.emailVerificationTTL = ttl
+		}
+		if resendInterval > 0 {
+			s.resendInterval = resendInterval
+		}
+	}
+}
+
+// WithTokenTTL override the access and refresh token lifetime, a zero value keep the default
+func WithTokenTTL(accessTokenTTL time.Duration, refreshTokenTTL time.Duration) Option {
+	return func(s *service) {
+		if accessTokenTTL > 0 {
+			s.accessTokenTTL = accessTokenTTL
+		}
+		if refreshTokenTTL > 0 {
+			s.refreshTokenTTL = refreshTokenTTL
+		}
+	}
 }
 
 const (
-	verificationCodeTTL = 5
+	defaultEmailVerificationTTL = 5 * time.Minute
+	defaultResendInterval       = time.Minute
+
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
 )
 
+var ErrTooManyRequests = errors.New("too many requests, try again later")
+
 type Service interface {
 	Register(user User) (id string, err error)
-	Login(username string, password string) (accessToken string, err error)
+	Login(username string, password string, client ClientInfo) (token Token, err error)
+	// RefreshToken rotate the refresh token, using an already rotated token revoke its whole family
+	RefreshToken(refreshToken string, client ClientInfo) (token Token, err error)
+	// Logout revoke the access token and the refresh tokens of the same login
+	Logout(claims Claims, client ClientInfo) (err error)
+	// LogoutAll revoke every access and refresh token of the user
+	LogoutAll(userID string, client ClientInfo) (err error)
+	// GetSessions list the active logins of the user, currentSessionID is the sid of the calling token
+	GetSessions(userID string, currentSessionID string) (sessions []Session, err error)
+	// RevokeSession end a login of the user, its tokens are refused from the next request
+	RevokeSession(userID string, sessionID string, client ClientInfo) (err error)
+	// SwitchOrganization make the organization the active one of the user and return an access token for it
+	SwitchOrganization(claims Claims, organizationID string) (token Token, err error)
+	// ForgotPassword email a reset link, an unknown email is not an error
+	ForgotPassword(email string) (err error)
+	ResetPassword(resetToken string, newPassword string, client ClientInfo) (err error)
 	GetByEmail(email string) (user User, err error)
-	VerifyEmail(verificationCodeEncrypt string) (err error)
+	VerifyEmail(verificationToken string, client ClientInfo) (err error)
+	// ResendVerification email a new link to an unverified address, an unknown email is not an error
+	ResendVerification(email string) (err error)
+
+	// UpdateProfile change the fullname of the user and return the updated user
+	UpdateProfile(userID string, fullname string) (user User, err error)
+	// ChangePassword need the current password and end every session of the user
+	ChangePassword(userID string, currentPassword string, newPassword string, client ClientInfo) (err error)
+	// RequestEmailChange need the current password and email a confirmation link to the new address
+	RequestEmailChange(userID string, newEmail string, password string) (err error)
+	// ConfirmEmailChange apply the email change of the link, the new email is verified
+	ConfirmEmailChange(changeToken string) (err error)
+	// ExportData return everything held about the user
+	ExportData(userID string) (export DataExport, err error)
+	// RequestDeletion schedule the account anonymization after the grace period
+	RequestDeletion(userID string) (deleteAt time.Time, err error)
+	CancelDeletion(userID string) (err error)
+	// AnonymizeDueAccounts anonymize up to limit accounts whose grace period is over
+	AnonymizeDueAccounts(limit int) (anonymized int, err error)
+
+	// EnrollTwoFactor generate a new TOTP secret, 2FA is only enabled once ConfirmTwoFactor accept a code
+	EnrollTwoFactor(userID string) (secret string, provisioningURI string, err error)
+	// ConfirmTwoFactor enable 2FA and return the recovery codes, they are only shown once
+	ConfirmTwoFactor(userID string, code string) (recoveryCodes []string, err error)
+	// DisableTwoFactor need a current TOTP or recovery code
+	DisableTwoFactor(userID string, code string) (err error)
+	// LoginTwoFactor complete a Login which returned a TwoFactorToken, code is a TOTP or a recovery code
+	LoginTwoFactor(twoFactorToken string, code string, client ClientInfo) (token Token, err error)
+
+	// OIDCAuthURL start a single sign-on, the user is redirected to the returned provider url
+	OIDCAuthURL() (authURL string, err error)
+	// OIDCCallback complete the single sign-on with the code the provider redirected back with,
+	// the account is found or provisioned by the verified email and get the same token as Login
+	OIDCCallback(state string, code string, client ClientInfo) (token Token, err error)
+
+	// admin user management, actorID is the id of the superadmin doing it
+	GetAll(filter Filter, page int, limit int) (users []User, total int64, err error)
+	GetByID(id string) (user User, err error)
+	// ChangeRole refuse a role with a permission the actorRole, the role of the actor, does not have.
+	// It also refuse a user whose current role has one
+	ChangeRole(actorID string, actorRole string, id string, role string, client ClientInfo) (err error)
+	SetDisabled(actorID string, id string, disabled bool, client ClientInfo) (err error)
+	Delete(actorID string, id string, client ClientInfo) (err error)
+	// ResetTwoFactor disable the 2FA of a user who lost the device and the recovery codes
+	ResetTwoFactor(actorID string, id string, client ClientInfo) (err error)
+
+	// GetSecurityEvents query the security audit log, the newest first
+	GetSecurityEvents(filter SecurityEventFilter, page int, limit int) (events []SecurityEvent, total int64, err error)
+	// ExportSecurityEvents write the security events matching the filter as csv
+	ExportSecurityEvents(filter SecurityEventFilter, w io.Writer) (err error)
+
+	// Invite email a link to create an account with a preassigned role, actorID is the admin inviting.
+	// A role with a permission the actorRole does not have is refused
+	Invite(actorID string, actorRole string, email string, role string) (invitation Invitation, err error)
+	// GetInvitations list the invitations not accepted nor revoked
+	GetInvitations() (invitations []Invitation, err error)
+	// ResendInvitation email a new link and extend the expiry
+	ResendInvitation(id string) (invitation Invitation, err error)
+	RevokeInvitation(id string) (err error)
+	// AcceptInvitation create the verified user of the invitation link and return its id
+	AcceptInvitation(invitationToken string, fullname string, password string) (id string, err error)
 }
 
-func NewService(logger *slog.Logger, repo Repository, appDeploymentUrl string, jwtSign string, appEmailVerificationKey string, notifRepo notification.Repository) Service {
-	return &service{
+func NewService(logger *slog.Logger, repo Repository, appDeploymentUrl string, jwtSign string, appEmailVerificationKey string, notifRepo notification.Repository, opts ...Option) Service {
+	s := &service{
 		logger:                  logger,
 		repo:                    repo,
 		appDeploymentUrl:        appDeploymentUrl,
 		jwtSign:                 jwtSign,
 		appEmailVerificationKey: appEmailVerificationKey,
 		notifRepo:               notifRepo,
+		accessTokenTTL:          defaultAccessTokenTTL,
+		refreshTokenTTL:         defaultRefreshTokenTTL,
+		emailVerificationTTL:    defaultEmailVerificationTTL,
+		resendInterval:          defaultResendInterval,
+		deletionGracePeriod:     defaultDeletionGracePeriod,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 const (
@@ -53,6 +238,10 @@ const (
 )
 
 func (s *service) Register(user User) (id string, err error) {
+	if err = s.checkPassword(user.Password); err != nil {
+		return
+	}
+
 	// Find user by email
 	getUser, err := s.repo.GetByEmail(user.Email)
 	if err != nil {
@@ -60,76 +249,96 @@ func (s *service) Register(user User) (id string, err error) {
 	}
 
 	if getUser.Email != "" {
-		err = errors.New("email registered already")
-		return
+		return id, ErrEmailRegistered
 	}
 
 	// Hashing plain pass
-	encPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	encPassword, err := s.hashPassword(user.Password)
 	if err != nil {
 		return
 	}
 
 	user.ID = uuid.NewString()
-	user.Password = string(encPassword)
-	user.Role = "user"
+	user.Password = encPassword
+	user.Role = RoleUser
 
 	if err = s.repo.Create(user); err != nil {
 		return
 	}
 
-	// activationLink := "http://localhost:8080/users/verify-email"
-	timeNow := time.Now()
-	expAt := timeNow.Add(time.Duration(time.Minute * verificationCodeTTL)).Unix()
-
-	verificationCode := fmt.Sprintf("%v|%v", user.Email, expAt)
-	verificationCodeEncrypt, _ := goshortcute.AESCBCEncrypt([]byte(verificationCode), []byte(s.appEmailVerificationKey))
-	// activationLink := s.appDeploymentUrl + "/users/email-verification/" + verificationCodeEncrypt
-	verifCode := goshortcute.StringtoBase64Encode(verificationCodeEncrypt)
-	activationLink := s.appDeploymentUrl + "/users/email-verification/" + verifCode
+	_ = s.sendVerificationEmail(user)
 
-	_ = s.notifRepo.SendEmail(user.Fullname, user.Email, SubjectRegisterAccount, fmt.Sprintf(EmailBodyRegisterAccount, user.Fullname, activationLink, verificationCodeTTL))
+	s.recordEvent(EventRegistered, user)
 
 	// Create user
 	return user.ID, nil
 }
 
-func (s *service) VerifyEmail(verificationCodeEncrypt string) (err error) {
-	// verificationCodeDecrypt, err := goshortcute.AESCBCDecrypt([]byte(verificationCodeEncrypt), []byte(s.appEmailVerificationKey))
-	verifCodeDecode := goshortcute.StringtoBase64Decode(verificationCodeEncrypt)
-	verificationCodeDecrypt, err := goshortcute.AESCBCDecrypt([]byte(verifCodeDecode), []byte(s.appEmailVerificationKey))
+func (s *service) sendVerificationEmail(user User) (err error) {
+	// the email is in the payload, the link stop working if the email change before it is opened
+	token, err := s.issueLinkToken(PurposeEmailVerification, user.ID, user.Email, s.emailVerificationTTL)
 	if err != nil {
-		s.logger.Error("verify email err", slog.Any("err", err.Error()))
-		return errors.New("invalid or expired url")
+		return
 	}
+	activationLink := s.appDeploymentUrl + "/users/email-verification/" + token
 
-	verificationCode := strings.Split(verificationCodeDecrypt, "|")
-	if len(verificationCode) != 2 {
-		s.logger.Error("verify email err", slog.Any("err", verificationCodeDecrypt))
-		return errors.New("invalid or expired url")
-	}
+	return s.notifRepo.SendEmail(user.Fullname, user.Email, SubjectRegisterAccount, fmt.Sprintf(EmailBodyRegisterAccount, user.Fullname, activationLink, int(s.emailVerificationTTL.Minutes())))
+}
 
-	email := verificationCode[0]
-	expAtStr := verificationCode[1]
+func (s *service) ResendVerification(email string) (err error) {
+	// the throttle is applied before the lookup so it behave the same for unknown addresses
+	if throttled := s.throttle("resend-verification:"+strings.ToLower(email), s.resendInterval); throttled {
+		return ErrTooManyRequests
+	}
 
-	ts, err := strconv.ParseInt(expAtStr, 10, 64)
+	getUser, err := s.repo.GetByEmail(email)
 	if err != nil {
-		s.logger.Error("verify email err", slog.Any("err", verificationCodeDecrypt))
-		return errors.New("invalid or expired url")
+		return
 	}
-	expAt := time.Unix(ts, 0)
-	if time.Now().After(expAt) {
-		return errors.New("invalid or expired url")
+
+	if getUser.Email == "" || getUser.IsEmailVerified {
+		return nil
 	}
 
-	getUser, err := s.repo.GetByEmail(email)
+	if err := s.sendVerificationEmail(getUser); err != nil {
+		s.logger.Error("resend verification email err", slog.Any("err", err.Error()))
+	}
+	return nil
+}
+
+// throttle return true when key was already seen in the interval, otherwise it start a new interval
+func (s *service) throttle(key string, interval time.Duration) (throttled bool) {
+	if s.cache == nil {
+		return false
+	}
+
+	key = "user:throttle:" + key
+	if err := s.cache.Get(key, &throttled); err != nil {
+		s.logger.Error("throttle cache get err", slog.String("key", key), slog.Any("err", err.Error()))
+	}
+	if throttled {
+		return true
+	}
+
+	if err := s.cache.Set(key, true, interval); err != nil {
+		s.logger.Error("throttle cache set err", slog.String("key", key), slog.Any("err", err.Error()))
+	}
+	return false
+}
+
+func (s *service) VerifyEmail(verificationToken string, client ClientInfo) (err error) {
+	token, err := s.consumeLinkToken(PurposeEmailVerification, verificationToken)
+	if err != nil {
+		return
+	}
+
+	getUser, err := s.repo.GetByID(token.Subject)
 	if err != nil {
 		s.logger.Error("verify email err", slog.Any("err", err))
 		return err
 	}
 
-	if getUser.IsEmailVerified {
-		s.logger.Error("verify email err", slog.Any("err", "email already verified"))
+	if getUser.ID == "" || getUser.Email != token.Payload || getUser.IsEmailVerified {
 		return errors.New("invalid or expired url")
 	}
 
@@ -139,59 +348,90 @@ func (s *service) VerifyEmail(verificationCodeEncrypt string) (err error) {
 		return err
 	}
 
+	s.recordEvent(EventEmailVerified, getUser)
+	s.recordSecurityEvent(SecurityEmailVerified, OutcomeSuccess, "", getUser.ID, client, "")
+
 	return nil
 }
 
-func (s *service) Login(email string, password string) (accessToken string, err error) {
+func (s *service) Login(email string, password string, client ClientInfo) (token Token, err error) {
+	if err = s.checkLogin(email, client); err != nil {
+		s.recordSecurityEvent(SecurityLoginFailed, OutcomeFailure, "", "", client, "locked "+maskEmail(email))
+		return
+	}
+
 	getUser, err := s.repo.GetByEmail(email)
 	if err != nil {
-		return "", err
+		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(getUser.Password), []byte(password)); err != nil {
-		s.logger.Error("login err", slog.Any("err", err.Error()))
+	valid, needRehash, err := s.verifyPassword(getUser.Password, password)
+	if err != nil || !valid {
+		if err != nil {
+			s.logger.Error("login err", slog.Any("err", err.Error()))
+		}
+		s.loginFailed(email, client, getUser)
+
+		detail := "wrong password"
+		if getUser.ID == "" {
+			detail = "unknown email " + maskEmail(email)
+		}
+		s.recordSecurityEvent(SecurityLoginFailed, OutcomeFailure, "", getUser.ID, client, detail)
 
 		err = errors.New("wrong email or password")
-		return "", err
+		return token, err
+	}
+
+	if needRehash {
+		s.rehashPassword(getUser, password)
 	}
 
 	if !getUser.IsEmailVerified {
+		s.recordSecurityEvent(SecurityLoginFailed, OutcomeFailure, "", getUser.ID, client, "email not verified")
 		err = errors.New("email address has not been verified")
-		return "", err
+		return
 	}
 
-	token, err := s.generateToken(s.jwtSign, getUser.ID, getUser.Role)
-	if err != nil {
-		s.logger.Error("generate token err", slog.Any("err", err.Error()))
+	if getUser.IsDisabled {
+		s.recordSecurityEvent(SecurityLoginFailed, OutcomeFailure, "", getUser.ID, client, "user disabled")
+		return token, ErrUserDisabled
+	}
 
-		err = errors.New("generate token error")
-		return "", err
+	// the login succeed once the second factor is verified, the failures are kept until then
+	// so the TOTP failures of LoginTwoFactor add up with them
+	if getUser.IsTOTPEnabled {
+		return s.twoFactorChallenge(getUser)
 	}
+	s.loginSucceeded(email)
 
-	return token, err
+	// a new login start a new session
+	return s.startSession(getUser, client, false)
 }
 
 func (s *service) GetByEmail(email string) (user User, err error) {
 	return s.repo.GetByEmail(email)
 }
 
-func (s *service) generateToken(jwtSign string, id string, role string) (signedToken string, err error) {
-	type jwtClaims struct {
-		ID   string `json:"id"`
-		Role string `json:"role"`
-		jwt.RegisteredClaims
-	}
-
+func (s *service) generateToken(jwtSign string, user User, sessionID string, twoFactorPending bool) (signedToken string, err error) {
 	timeNow := time.Now()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
-		ID:   id,
-		Role: role,
+	claims := Claims{
+		ID:               user.ID,
+		Role:             user.Role,
+		SessionID:        sessionID,
+		OrganizationID:   user.OrganizationID,
+		TwoFactorPending: twoFactorPending,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			IssuedAt:  jwt.NewNumericDate(timeNow),
-			ExpiresAt: jwt.NewNumericDate(timeNow.Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(timeNow.Add(s.accessTokenTTL)),
 		},
-	})
+	}
 
+	if s.signer != nil {
+		return s.signer.Sign(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err = token.SignedString([]byte(jwtSign))
 	if err != nil {
 		return "", err
@@ -199,3 +439,26 @@ func (s *service) generateToken(jwtSign string, id string, role string) (signedT
 
 	return signedToken, nil
 }
+
+// recordEvent is best effort, a failure is logged and never fail the user action
+func (s *service) recordEvent(eventType string, user User) {
+	if s.eventRepo == nil {
+		return
+	}
+
+	evt, err := outbox.NewEvent(EventAggregateType, user.ID, eventType, EventPayload{
+		ID:              user.ID,
+		Email:           user.Email,
+		Fullname:        user.Fullname,
+		Role:            user.Role,
+		IsEmailVerified: user.IsEmailVerified,
+		IsDisabled:      user.IsDisabled,
+	})
+	if err == nil {
+		err = s.eventRepo.Create(evt)
+	}
+
+	if err != nil {
+		s.logger.Error("record user event err", slog.String("event_type", eventType), slog.Any("err", err.Error()))
+	}
+}
@@ -0,0 +1,145 @@
+package user
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionsUnavailable = errors.New("sessions are not enabled")
+)
+
+// maxUserAgentLength fit the user agent in the session table
+const maxUserAgentLength = 512
+
+// WithSessionRepository record a session per login, the user can list them and revoke one
+func WithSessionRepository(sessionRepo SessionRepository) Option {
+	return func(s *service) {
+		s.sessionRepo = sessionRepo
+	}
+}
+
+// startSession end every successful login, a new session start a new refresh token family
+func (s *service) startSession(user User, client ClientInfo, twoFactor bool) (token Token, err error) {
+	sessionID := uuid.NewString()
+	token, err = s.issueToken(user, sessionID, twoFactor)
+	if err != nil {
+		return
+	}
+
+	detail := "session " + sessionID
+	if twoFactor {
+		detail += " with 2fa"
+	}
+	if s.sessionRepo == nil {
+		s.recordSecurityEvent(SecurityLoginSucceeded, OutcomeSuccess, user.ID, user.ID, client, detail)
+		return
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	timeNow := time.Now()
+	err = s.sessionRepo.CreateSession(Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  timeNow,
+		LastSeenAt: timeNow,
+		ExpiresAt:  timeNow.Add(s.sessionTTL()),
+	})
+	if err != nil {
+		s.logger.Error("store session err", slog.Any("err", err.Error()))
+		return Token{}, err
+	}
+
+	s.recordSecurityEvent(SecurityLoginSucceeded, OutcomeSuccess, user.ID, user.ID, client, detail)
+	return
+}
+
+// sessionTTL is how long a session last without activity, the refresh token lifetime when enabled
+func (s *service) sessionTTL() time.Duration {
+	if s.refreshRepo != nil {
+		return s.refreshTokenTTL
+	}
+	return s.accessTokenTTL
+}
+
+// touchSession is best effort, it is called on every token refresh
+func (s *service) touchSession(sessionID string) {
+	if s.sessionRepo == nil {
+		return
+	}
+
+	timeNow := time.Now()
+	if err := s.sessionRepo.TouchSession(sessionID, timeNow, timeNow.Add(s.sessionTTL())); err != nil {
+		s.logger.Error("touch session err", slog.String("session_id", sessionID), slog.Any("err", err.Error()))
+	}
+}
+
+func (s *service) GetSessions(userID string, currentSessionID string) (sessions []Session, err error) {
+	if s.sessionRepo == nil {
+		return nil, ErrSessionsUnavailable
+	}
+
+	sessions, err = s.sessionRepo.GetActiveSessions(userID, time.Now())
+	if err != nil {
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *service) RevokeSession(userID string, sessionID string, client ClientInfo) (err error) {
+	if s.sessionRepo == nil {
+		return ErrSessionsUnavailable
+	}
+
+	session, err := s.sessionRepo.GetSessionByID(sessionID)
+	if err != nil {
+		return
+	}
+
+	// a session of another user is not found either
+	if session.ID == "" || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err = s.endSession(sessionID); err != nil {
+		return
+	}
+
+	s.recordSecurityEvent(SecuritySessionRevoked, OutcomeSuccess, userID, userID, client, "session "+sessionID)
+	return nil
+}
+
+// endSession revoke the session, its refresh tokens, and its access tokens when the revocation is enabled
+func (s *service) endSession(sessionID string) (err error) {
+	timeNow := time.Now()
+	if s.sessionRepo != nil {
+		if err = s.sessionRepo.RevokeSession(sessionID, timeNow); err != nil {
+			return
+		}
+	}
+
+	if s.refreshRepo != nil {
+		if err = s.refreshRepo.RevokeRefreshTokenFamily(sessionID, timeNow); err != nil {
+			return
+		}
+	}
+
+	if s.revocation != nil {
+		err = s.revocation.RevokeSession(sessionID, s.accessTokenTTL)
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+const tokenTypeBearer = "Bearer"
+
+// issueToken sign an access token and, when enabled, store a new refresh token in the given family.
+// twoFactor tell whether the login of the family used 2FA
+func (s *service) issueToken(user User, familyID string, twoFactor bool) (token Token, err error) {
+	accessToken, err := s.generateToken(s.jwtSign, user, familyID, s.twoFactorRequired(user) && !twoFactor)
+	if err != nil {
+		s.logger.Error("generate token err", slog.Any("err", err.Error()))
+
+		err = errors.New("generate token error")
+		return
+	}
+
+	token = Token{
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(s.accessTokenTTL.Seconds()),
+	}
+
+	if s.refreshRepo == nil {
+		return
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		s.logger.Error("generate refresh token err", slog.Any("err", err.Error()))
+
+		err = errors.New("generate token error")
+		return Token{}, err
+	}
+
+	timeNow := time.Now()
+	err = s.refreshRepo.CreateRefreshToken(RefreshToken{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		FamilyID:    familyID,
+		TokenHash:   hashToken(refreshToken),
+		ExpiresAt:   timeNow.Add(s.refreshTokenTTL),
+		CreatedAt:   timeNow,
+		IsTwoFactor: twoFactor,
+	})
+	if err != nil {
+		s.logger.Error("store refresh token err", slog.Any("err", err.Error()))
+		return Token{}, err
+	}
+
+	token.RefreshToken = refreshToken
+	return
+}
+
+func (s *service) RefreshToken(refreshToken string, client ClientInfo) (token Token, err error) {
+	if s.refreshRepo == nil || refreshToken == "" {
+		return token, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.refreshRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	if stored.ID == "" || stored.RevokedAt != nil || timeNow.After(stored.ExpiresAt) {
+		return token, ErrInvalidRefreshToken
+	}
+
+	if stored.RotatedAt != nil {
+		s.revokeFamily(stored, timeNow, client)
+		return token, ErrInvalidRefreshToken
+	}
+
+	rotated, err := s.refreshRepo.RotateRefreshToken(stored.ID, timeNow)
+	if err != nil {
+		return
+	}
+	if !rotated {
+		// a concurrent request used the same token, treat it as a reuse too
+		s.revokeFamily(stored, timeNow, client)
+		return token, ErrInvalidRefreshToken
+	}
+
+	// role could have changed since the login
+	getUser, err := s.repo.GetByID(stored.UserID)
+	if err != nil {
+		return
+	}
+	if getUser.ID == "" || getUser.IsDisabled {
+		return token, ErrInvalidRefreshToken
+	}
+
+	token, err = s.issueToken(getUser, stored.FamilyID, stored.IsTwoFactor)
+	if err != nil {
+		return
+	}
+
+	s.touchSession(stored.FamilyID)
+	return token, nil
+}
+
+func (s *service) Logout(claims Claims, client ClientInfo) (err error) {
+	if s.revocation == nil {
+		return errors.New("token revocation is not enabled")
+	}
+
+	if claims.ExpiresAt != nil {
+		if err = s.revocation.RevokeToken(claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err != nil {
+			return
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err = s.endSession(claims.SessionID); err != nil {
+			return
+		}
+	}
+
+	s.recordSecurityEvent(SecurityLogout, OutcomeSuccess, claims.ID, claims.ID, client, "session "+claims.SessionID)
+	return nil
+}
+
+func (s *service) LogoutAll(userID string, client ClientInfo) (err error) {
+	if s.revocation == nil {
+		return errors.New("token revocation is not enabled")
+	}
+
+	if err = s.revokeUserTokens(userID); err != nil {
+		return
+	}
+
+	s.recordSecurityEvent(SecurityLogoutAll, OutcomeSuccess, userID, userID, client, "")
+	return nil
+}
+
+// revokeUserTokens revoke every refresh token, session and access token of the user. The refresh tokens
+// and sessions are revoked in the database first, a failing cache write doesn't leave them valid
+func (s *service) revokeUserTokens(userID string) error {
+	timeNow := time.Now()
+	var errs []error
+	if s.refreshRepo != nil {
+		errs = append(errs, s.refreshRepo.RevokeUserRefreshTokens(userID, timeNow))
+	}
+
+	if s.sessionRepo != nil {
+		errs = append(errs, s.sessionRepo.RevokeUserSessions(userID, timeNow))
+	}
+
+	if s.revocation != nil {
+		errs = append(errs, s.revocation.RevokeUserTokens(userID, timeNow, s.accessTokenTTL))
+	}
+	return errors.Join(errs...)
+}
+
+// revokeFamily is called on refresh token reuse, the token was probably leaked so every token
+// issued from the same login stop working
+func (s *service) revokeFamily(stored RefreshToken, revokedAt time.Time, client ClientInfo) {
+	s.logger.Warn("refresh token reuse detected", slog.String("user_id", stored.UserID), slog.String("family_id", stored.FamilyID))
+	s.recordSecurityEvent(SecurityRefreshTokenReused, OutcomeFailure, "", stored.UserID, client, "session "+stored.FamilyID)
+
+	if err := s.refreshRepo.RevokeRefreshTokenFamily(stored.FamilyID, revokedAt); err != nil {
+		s.logger.Error("revoke refresh token family err", slog.Any("err", err.Error()))
+	}
+}
+
+func generateOpaqueToken() (token string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,458 @@
+package user
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pobyzaarif/goshortcute"
+)
+
+var (
+	ErrTwoFactorUnavailable    = errors.New("two factor authentication is not available")
+	ErrTwoFactorAlreadyEnabled = errors.New("two factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two factor authentication is not enrolled")
+	ErrTwoFactorRequired       = errors.New("two factor authentication is required for this role")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two factor code")
+	ErrInvalidTwoFactorToken   = errors.New("invalid or expired two factor token")
+)
+
+// TwoFactorConfig tune the TOTP 2FA, a zero value keep the default
+type TwoFactorConfig struct {
+	// Issuer is the account name prefix shown in the authenticator app
+	Issuer string
+	// RequiredRoles must use 2FA, their access tokens are refused by the role checks
+	// until they enroll and login again with 2FA
+	RequiredRoles []string
+	// ChallengeTTL is how long the TwoFactorToken returned by Login can be used
+	ChallengeTTL time.Duration
+	// MaxAttempts wrong codes before the TwoFactorToken is dropped
+	MaxAttempts int
+	// EncryptionKey encrypt the TOTP secrets stored in the repository, 16, 24 or 32 bytes
+	EncryptionKey string
+}
+
+func (c *TwoFactorConfig) setDefault() {
+	if c.Issuer == "" {
+		c.Issuer = "belajarGo"
+	}
+	if c.ChallengeTTL <= 0 {
+		c.ChallengeTTL = 5 * time.Minute
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+}
+
+// WithTwoFactor enable the TOTP 2FA, the second login step need the cache given by WithCache
+func WithTwoFactor(cfg TwoFactorConfig) Option {
+	return func(s *service) {
+		cfg.setDefault()
+		s.twoFactor = &cfg
+	}
+}
+
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	// totpSkew accept the code of the previous and next period, for the clock drift of the phone
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPCode return the RFC 6238 code (SHA1, 6 digits, 30 seconds) of a base32 secret at the given time
+func TOTPCode(secret string, at time.Time) (code string, err error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return
+	}
+
+	return totpCodeAt(key, uint64(at.Unix()/int64(totpPeriod.Seconds()))), nil
+}
+
+func totpCodeAt(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+func (s *service) twoFactorRequired(user User) bool {
+	return s.twoFactor != nil && slices.Contains(s.twoFactor.RequiredRoles, user.Role)
+}
+
+func (s *service) EnrollTwoFactor(userID string) (secret string, provisioningURI string, err error) {
+	if s.twoFactor == nil {
+		return "", "", ErrTwoFactorUnavailable
+	}
+
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+	if getUser.IsTOTPEnabled {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	key := make([]byte, 20)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	secret = totpEncoding.EncodeToString(key)
+
+	// enrolling again replace the secret which was never confirmed
+	getUser.TOTPSecret, err = s.encryptTOTPSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	getUser.RecoveryCodes = nil
+	if err = s.repo.UpdateTwoFactor(getUser); err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", s.twoFactor.Issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	provisioningURI = "otpauth://totp/" + url.PathEscape(s.twoFactor.Issuer+":"+getUser.Email) + "?" + query.Encode()
+
+	return secret, provisioningURI, nil
+}
+
+func (s *service) ConfirmTwoFactor(userID string, code string) (recoveryCodes []string, err error) {
+	if s.twoFactor == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+	if getUser.IsTOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if getUser.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	valid, err := s.verifyTOTP(getUser, code)
+	if err != nil {
+		return
+	}
+	if !valid {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return
+	}
+
+	getUser.IsTOTPEnabled = true
+	getUser.RecoveryCodes = hashes
+	if err = s.repo.UpdateTwoFactor(getUser); err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(EventTwoFactorEnabled, getUser)
+	return recoveryCodes, nil
+}
+
+func (s *service) DisableTwoFactor(userID string, code string) (err error) {
+	getUser, err := s.GetByID(userID)
+	if err != nil {
+		return
+	}
+	if !getUser.IsTOTPEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	if s.twoFactorRequired(getUser) {
+		return ErrTwoFactorRequired
+	}
+
+	valid, err := s.verifySecondFactor(&getUser, code)
+	if err != nil {
+		return
+	}
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return s.clearTwoFactor(getUser)
+}
+
+func (s *service) ResetTwoFactor(actorID string, id string, client ClientInfo) (err error) {
+	if actorID == id {
+		return ErrOwnAccountOnly
+	}
+
+	getUser, err := s.GetByID(id)
+	if err != nil {
+		return
+	}
+	if !getUser.IsTOTPEnabled && getUser.TOTPSecret == "" {
+		return nil
+	}
+
+	if err = s.clearTwoFactor(getUser); err != nil {
+		return
+	}
+
+	s.recordSecurityEvent(SecurityTwoFactorReset, OutcomeSuccess, actorID, id, client, "")
+	return nil
+}
+
+func (s *service) clearTwoFactor(user User) (err error) {
+	user.TOTPSecret = ""
+	user.IsTOTPEnabled = false
+	user.RecoveryCodes = nil
+	if err = s.repo.UpdateTwoFactor(user); err != nil {
+		return
+	}
+
+	s.recordEvent(EventTwoFactorDisabled, user)
+	return nil
+}
+
+// twoFactorChallenge is kept in the cache between the two login steps
+type twoFactorChallenge struct {
+	UserID    string `json:"user_id"`
+	Attempts  int    `json:"attempts"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func twoFactorChallengeKey(twoFactorToken string) string {
+	return "user:2fa:challenge:" + hashToken(twoFactorToken)
+}
+
+// twoFactorChallenge is the first login step of a user with 2FA, the password was right
+func (s *service) twoFactorChallenge(user User) (token Token, err error) {
+	if s.twoFactor == nil || s.cache == nil {
+		return token, ErrTwoFactorUnavailable
+	}
+
+	twoFactorToken, err := generateOpaqueToken()
+	if err != nil {
+		return
+	}
+
+	expAt := time.Now().Add(s.twoFactor.ChallengeTTL)
+	challenge := twoFactorChallenge{UserID: user.ID, ExpiresAt: expAt.Unix()}
+	if err = s.cache.Set(twoFactorChallengeKey(twoFactorToken), challenge, s.twoFactor.ChallengeTTL); err != nil {
+		return
+	}
+
+	return Token{TwoFactorToken: twoFactorToken}, nil
+}
+
+func (s *service) LoginTwoFactor(twoFactorToken string, code string, client ClientInfo) (token Token, err error) {
+	if s.twoFactor == nil || s.cache == nil {
+		return token, ErrTwoFactorUnavailable
+	}
+
+	key := twoFactorChallengeKey(twoFactorToken)
+	challenge := twoFactorChallenge{}
+	if err = s.cache.Get(key, &challenge); err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	if challenge.UserID == "" || timeNow.Unix() >= challenge.ExpiresAt {
+		return token, ErrInvalidTwoFactorToken
+	}
+
+	getUser, err := s.repo.GetByID(challenge.UserID)
+	if err != nil {
+		return
+	}
+	if getUser.ID == "" || !getUser.IsTOTPEnabled {
+		s.cache.Delete(key)
+		return token, ErrInvalidTwoFactorToken
+	}
+	if getUser.IsDisabled {
+		s.cache.Delete(key)
+		return token, ErrUserDisabled
+	}
+
+	// the account locked by the failures of the password or of the second factor can't be completed either
+	if err = s.checkLogin(getUser.Email, client); err != nil {
+		s.cache.Delete(key)
+		return
+	}
+
+	valid, err := s.verifySecondFactor(&getUser, code)
+	if err != nil {
+		return
+	}
+	if !valid {
+		s.logger.Warn("two factor login failed", slog.String("user_id", getUser.ID), slog.String("ip", client.IPAddress))
+		s.recordSecurityEvent(SecurityTwoFactorFailed, OutcomeFailure, "", getUser.ID, client, "")
+		// count per user like a wrong password, a new challenge does not give new guesses
+		s.loginFailed(getUser.Email, client, getUser)
+
+		challenge.Attempts++
+		if challenge.Attempts >= s.twoFactor.MaxAttempts {
+			s.cache.Delete(key)
+		} else if err := s.cache.Set(key, challenge, time.Unix(challenge.ExpiresAt, 0).Sub(timeNow)); err != nil {
+			s.cache.Delete(key)
+		}
+		return token, ErrInvalidTwoFactorCode
+	}
+
+	s.cache.Delete(key)
+	s.loginSucceeded(getUser.Email)
+	return s.startSession(getUser, client, true)
+}
+
+// verifySecondFactor accept a TOTP code or a recovery code, a recovery code is removed once used
+func (s *service) verifySecondFactor(user *User, code string) (valid bool, err error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) == totpDigits && strings.Trim(code, "0123456789") == "" {
+		return s.verifyTOTP(*user, code)
+	}
+
+	idx := slices.Index(user.RecoveryCodes, hashRecoveryCode(code))
+	if idx < 0 {
+		return false, nil
+	}
+
+	user.RecoveryCodes = slices.Delete(slices.Clone(user.RecoveryCodes), idx, idx+1)
+	if err = s.repo.UpdateTwoFactor(*user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifyTOTP accept a code of the current period, or of the next and previous one for the clock drift.
+// With the cache a code is only accepted once
+func (s *service) verifyTOTP(user User, code string) (valid bool, err error) {
+	secret, err := s.decryptTOTPSecret(user)
+	if err != nil {
+		return
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return
+	}
+
+	counter := time.Now().Unix() / int64(totpPeriod.Seconds())
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		if !hmac.Equal([]byte(totpCodeAt(key, uint64(counter+i))), []byte(code)) {
+			continue
+		}
+
+		if s.cache != nil {
+			usedKey := fmt.Sprintf("user:2fa:used:%v:%v", user.ID, counter+i)
+			used := false
+			if err := s.cache.Get(usedKey, &used); err == nil && used {
+				return false, nil
+			}
+			if err := s.cache.Set(usedKey, true, totpPeriod*(2*totpSkew+1)); err != nil {
+				s.logger.Error("two factor replay protection err", slog.Any("err", err.Error()))
+			}
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *service) totpAEAD() (cipher.AEAD, error) {
+	if s.twoFactor == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	block, err := aes.NewCipher([]byte(s.twoFactor.EncryptionKey))
+	if err != nil {
+		return nil, fmt.Errorf("two factor encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *service) encryptTOTPSecret(secret string) (string, error) {
+	aead, err := s.totpAEAD()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// decryptTOTPSecret also read the secrets enrolled before the dedicated key, which were encrypted
+// with the email verification key, and store them again with the dedicated key
+func (s *service) decryptTOTPSecret(user User) (secret string, err error) {
+	aead, err := s.totpAEAD()
+	if err != nil {
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(user.TOTPSecret)
+	if err == nil && len(data) >= aead.NonceSize() {
+		plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+
+	secret, err = goshortcute.AESCBCDecrypt([]byte(user.TOTPSecret), []byte(s.appEmailVerificationKey))
+	if err != nil {
+		return "", errors.New("invalid encrypted totp secret")
+	}
+
+	if user.TOTPSecret, err = s.encryptTOTPSecret(secret); err == nil {
+		err = s.repo.UpdateTwoFactor(user)
+	}
+	if err != nil {
+		s.logger.Error("two factor secret re-encryption err", slog.String("user_id", user.ID), slog.Any("err", err.Error()))
+	}
+	return secret, nil
+}
+
+// generateRecoveryCodes return the codes shown to the user and the hashes to store
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for range recoveryCodeCount {
+		b := make([]byte, 6)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(code, "-", "")))
+}
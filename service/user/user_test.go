@@ -1,19 +1,40 @@
 package user_test
 
 import (
+	oneTimeTokenRepo "belajarGo2/repository/onetimetoken"
 	mock_notification "belajarGo2/service/notification/mock"
+	"belajarGo2/service/onetimetoken"
+	"belajarGo2/service/outbox"
+	mock_outbox "belajarGo2/service/outbox/mock"
 	"belajarGo2/service/user"
 	mock_user "belajarGo2/service/user/mock"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/pobyzaarif/go-cache"
 	"github.com/pobyzaarif/goshortcute"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var loggerOption = slog.HandlerOptions{AddSource: true}
@@ -86,6 +107,7 @@ func TestRegister(t *testing.T) {
 				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
 				"32character32character32characte",
 				mock_notification,
+				user.WithOneTimeTokens(newOneTimeTokens()),
 			)
 
 			id, err := productService.Register(tt.inputUser)
@@ -100,86 +122,90 @@ func TestRegister(t *testing.T) {
 }
 
 func TestVerifyEmail(t *testing.T) {
-	key := []byte("32character32character32characte")
-	tsInTheFuture := time.Now().Add(time.Minute * 10).Unix()
-	notExpiredCode, _ := goshortcute.AESCBCEncrypt([]byte(fmt.Sprintf("%s|%d", "email@mail.com", tsInTheFuture)), key)
+	issue := func(purpose string, subject string, email string) func(tokens onetimetoken.Service) string {
+		return func(tokens onetimetoken.Service) string {
+			token, err := tokens.Issue(purpose, subject, email, time.Minute*10)
+			require.NoError(t, err)
+			return token
+		}
+	}
+	verifiedUser := user.User{ID: "user-1", Email: "email@mail.com", IsEmailVerified: true}
 
 	tests := []struct {
 		name      string
-		inputUser string
+		token     func(tokens onetimetoken.Service) string
 		mockUser  func(m *mock_user.MockRepository)
 		mockNotif func(m *mock_notification.MockRepository)
 		wantErr   bool
 	}{
 		{
-			name:      "error invalid decrypt",
-			inputUser: "dhslkashdlaskdh",
-			mockUser:  func(m *mock_user.MockRepository) {},
-			mockNotif: func(m *mock_notification.MockRepository) {},
-			wantErr:   true,
-		},
-		{
-			name:      "error invalid text format",
-			inputUser: "DNoioYCcyLIiSFnk2K86BA==",
+			name:      "error unknown token",
+			token:     func(tokens onetimetoken.Service) string { return "dhslkashdlaskdh" },
 			mockUser:  func(m *mock_user.MockRepository) {},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error invalid ts format",
-			inputUser: "DUvjHjyBKrkZ2k88+Pkj6g==",
+			name:      "error token of another purpose",
+			token:     issue(user.PurposeResetPassword, "user-1", "email@mail.com"),
 			mockUser:  func(m *mock_user.MockRepository) {},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error invalid ts format",
-			inputUser: "DUvjHjyBKrkZ2k88+Pkj6g==",
+			name: "error expired token",
+			token: func(tokens onetimetoken.Service) string {
+				token, err := tokens.Issue(user.PurposeEmailVerification, "user-1", "email@mail.com", -time.Second)
+				require.NoError(t, err)
+				return token
+			},
 			mockUser:  func(m *mock_user.MockRepository) {},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error invalid ts expired",
-			inputUser: "bkCPB5TdD40yVXwL+jGnjDpwRFE7nZ4vj0Aw3N3nkwQ=",
-			mockUser:  func(m *mock_user.MockRepository) {},
+			name:  "error valid token but get by id error",
+			token: issue(user.PurposeEmailVerification, "user-1", "email@mail.com"),
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-1").Return(user.User{}, errors.New("db error"))
+			},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error ts not expired but get by email error",
-			inputUser: notExpiredCode,
+			name:  "error valid token but the email already verified",
+			token: issue(user.PurposeEmailVerification, "user-1", "email@mail.com"),
 			mockUser: func(m *mock_user.MockRepository) {
-				m.EXPECT().GetByEmail("email@mail.com").Return(user.User{}, errors.New("db error"))
+				m.EXPECT().GetByID("user-1").Return(verifiedUser, nil)
 			},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error ts not expired but get by email succes but the email already verified",
-			inputUser: notExpiredCode,
+			name:  "error valid token but the email changed since",
+			token: issue(user.PurposeEmailVerification, "user-1", "old@mail.com"),
 			mockUser: func(m *mock_user.MockRepository) {
-				m.EXPECT().GetByEmail("email@mail.com").Return(user.User{IsEmailVerified: true}, nil)
+				m.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Email: "email@mail.com"}, nil)
 			},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "error ts not expired but get by email succes but error when update email verification",
-			inputUser: notExpiredCode,
+			name:  "error valid token but error when update email verification",
+			token: issue(user.PurposeEmailVerification, "user-1", "email@mail.com"),
 			mockUser: func(m *mock_user.MockRepository) {
-				m.EXPECT().GetByEmail("email@mail.com").Return(user.User{}, nil)
-				m.EXPECT().UpdateEmailVerification(user.User{IsEmailVerified: true}).Return(errors.New("db error"))
+				m.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Email: "email@mail.com"}, nil)
+				m.EXPECT().UpdateEmailVerification(verifiedUser).Return(errors.New("db error"))
 			},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   true,
 		},
 		{
-			name:      "success",
-			inputUser: notExpiredCode,
+			name:  "success",
+			token: issue(user.PurposeEmailVerification, "user-1", "email@mail.com"),
 			mockUser: func(m *mock_user.MockRepository) {
-				m.EXPECT().GetByEmail("email@mail.com").Return(user.User{}, nil)
-				m.EXPECT().UpdateEmailVerification(user.User{IsEmailVerified: true}).Return(nil)
+				m.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Email: "email@mail.com"}, nil)
+				m.EXPECT().UpdateEmailVerification(verifiedUser).Return(nil)
 			},
 			mockNotif: func(m *mock_notification.MockRepository) {},
 			wantErr:   false,
@@ -196,16 +222,18 @@ func TestVerifyEmail(t *testing.T) {
 			tt.mockUser(mock_userRepo)
 			tt.mockNotif(mock_notification)
 
+			tokens := newOneTimeTokens()
 			productService := user.NewService(
 				logger,
 				mock_userRepo,
 				"http://appDeploymentUrl.com",
 				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
-				string(key),
+				"32character32character32characte",
 				mock_notification,
+				user.WithOneTimeTokens(tokens),
 			)
 
-			err := productService.VerifyEmail(tt.inputUser)
+			err := productService.VerifyEmail(tt.token(tokens), user.ClientInfo{})
 			if tt.wantErr {
 				assert.NotNil(t, err)
 			} else {
@@ -213,4 +241,2105 @@ func TestVerifyEmail(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("no token store", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		userService := user.NewService(logger, mock_user.NewMockRepository(ctrl), "", "", "", mock_notification.NewMockRepository(ctrl))
+
+		assert.ErrorIs(t, userService.VerifyEmail("token", user.ClientInfo{}), user.ErrLinksUnavailable)
+	})
+}
+
+// newOneTimeTokens keep the tokens of the emailed links in memory
+func newOneTimeTokens() onetimetoken.Service {
+	return onetimetoken.NewService(logger, oneTimeTokenRepo.NewMemoryRepository())
+}
+
+// linkToken extract the token following the marker in the email body
+func linkToken(t *testing.T, message string, marker string) string {
+	start := strings.Index(message, marker)
+	require.GreaterOrEqual(t, start, 0)
+	start += len(marker)
+	end := strings.Index(message[start:], "<br/>")
+	require.Greater(t, end, 0)
+	return message[start : start+end]
+}
+
+func TestRefreshToken(t *testing.T) {
+	refreshToken := "refresh-token"
+	sum := sha256.Sum256([]byte(refreshToken))
+	refreshTokenHash := hex.EncodeToString(sum[:])
+
+	validToken := user.RefreshToken{
+		ID:        "token-1",
+		UserID:    "user-1",
+		FamilyID:  "family-1",
+		TokenHash: refreshTokenHash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	rotatedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		mockUser    func(m *mock_user.MockRepository)
+		mockRefresh func(m *mock_user.MockRefreshTokenRepository)
+		wantErr     error
+	}{
+		{
+			name:     "unknown token",
+			mockUser: func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(user.RefreshToken{}, nil)
+			},
+			wantErr: user.ErrInvalidRefreshToken,
+		},
+		{
+			name:     "expired token",
+			mockUser: func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				expired := validToken
+				expired.ExpiresAt = time.Now().Add(-time.Second)
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(expired, nil)
+			},
+			wantErr: user.ErrInvalidRefreshToken,
+		},
+		{
+			name:     "revoked token",
+			mockUser: func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				revoked := validToken
+				revoked.RevokedAt = &rotatedAt
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(revoked, nil)
+			},
+			wantErr: user.ErrInvalidRefreshToken,
+		},
+		{
+			name:     "reuse of a rotated token revoke the family",
+			mockUser: func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				rotated := validToken
+				rotated.RotatedAt = &rotatedAt
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(rotated, nil)
+				m.EXPECT().RevokeRefreshTokenFamily("family-1", gomock.Any()).Return(nil)
+			},
+			wantErr: user.ErrInvalidRefreshToken,
+		},
+		{
+			name:     "concurrent rotation revoke the family",
+			mockUser: func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(validToken, nil)
+				m.EXPECT().RotateRefreshToken("token-1", gomock.Any()).Return(false, nil)
+				m.EXPECT().RevokeRefreshTokenFamily("family-1", gomock.Any()).Return(nil)
+			},
+			wantErr: user.ErrInvalidRefreshToken,
+		},
+		{
+			name: "success issue a new token in the same family",
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-1").Return(user.User{ID: "user-1", Role: "user"}, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().GetRefreshTokenByHash(refreshTokenHash).Return(validToken, nil)
+				m.EXPECT().RotateRefreshToken("token-1", gomock.Any()).Return(true, nil)
+				m.EXPECT().CreateRefreshToken(gomock.Any()).DoAndReturn(func(token user.RefreshToken) error {
+					assert.Equal(t, "user-1", token.UserID)
+					assert.Equal(t, "family-1", token.FamilyID)
+					assert.NotEqual(t, refreshTokenHash, token.TokenHash)
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_userRepo := mock_user.NewMockRepository(ctrl)
+			mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+			mock_notification := mock_notification.NewMockRepository(ctrl)
+
+			tt.mockUser(mock_userRepo)
+			tt.mockRefresh(mock_refreshRepo)
+
+			userService := user.NewService(
+				logger,
+				mock_userRepo,
+				"http://appDeploymentUrl.com",
+				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+				"32character32character32characte",
+				mock_notification,
+				user.WithRefreshTokenRepository(mock_refreshRepo),
+			)
+
+			token, err := userService.RefreshToken(refreshToken, user.ClientInfo{})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, token.AccessToken)
+			} else {
+				assert.Nil(t, err)
+				assert.NotEmpty(t, token.AccessToken)
+				assert.NotEmpty(t, token.RefreshToken)
+				assert.NotEqual(t, refreshToken, token.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	assert.NoError(t, err)
+	revocation := user.NewTokenRevocation(memoryCache)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithRefreshTokenRepository(mock_refreshRepo),
+		user.WithTokenRevocation(revocation),
+	)
+
+	issuedAt := time.Now().Add(-time.Minute)
+	claims := user.Claims{ID: "user-1", SessionID: "family-1"}
+	claims.RegisteredClaims.ID = "jti-1"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+
+	t.Run("logout revoke the token and its refresh family", func(t *testing.T) {
+		mock_refreshRepo.EXPECT().RevokeRefreshTokenFamily("family-1", gomock.Any()).Return(nil)
+
+		assert.NoError(t, userService.Logout(claims, user.ClientInfo{}))
+
+		revoked, err := revocation.IsRevoked("jti-1", "user-1", issuedAt)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = revocation.IsRevoked("jti-2", "user-1", issuedAt)
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("logout all revoke every token issued before", func(t *testing.T) {
+		mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-2", gomock.Any()).Return(nil)
+
+		assert.NoError(t, userService.LogoutAll("user-2", user.ClientInfo{}))
+
+		revoked, err := revocation.IsRevoked("jti-3", "user-2", issuedAt)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = revocation.IsRevoked("jti-4", "user-2", time.Now().Add(2*time.Second))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("logout all revoke the refresh tokens when the cache fail", func(t *testing.T) {
+		failingService := user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification,
+			user.WithRefreshTokenRepository(mock_refreshRepo),
+			user.WithTokenRevocation(user.NewTokenRevocation(failingCache{})),
+		)
+
+		mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-3", gomock.Any()).Return(nil)
+
+		assert.ErrorIs(t, failingService.LogoutAll("user-3", user.ClientInfo{}), errCacheDown)
+	})
+}
+
+var errCacheDown = errors.New("cache down")
+
+// failingCache is a cache store that can't be reached
+type failingCache struct{}
+
+func (failingCache) Set(key string, value interface{}, expiration time.Duration) error {
+	return errCacheDown
+}
+
+func (failingCache) Get(key string, data interface{}) error {
+	return errCacheDown
+}
+
+func (failingCache) Delete(key string) {}
+
+func TestSessions(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: "user", IsEmailVerified: true}
+
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	assert.NoError(t, err)
+	revocation := user.NewTokenRevocation(memoryCache)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+	mock_sessionRepo := mock_user.NewMockSessionRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithRefreshTokenRepository(mock_refreshRepo),
+		user.WithTokenRevocation(revocation),
+		user.WithSessionRepository(mock_sessionRepo),
+	)
+
+	t.Run("login record the session of the device", func(t *testing.T) {
+		var stored user.RefreshToken
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil)
+		mock_refreshRepo.EXPECT().CreateRefreshToken(gomock.Any()).DoAndReturn(func(token user.RefreshToken) error {
+			stored = token
+			return nil
+		})
+		mock_sessionRepo.EXPECT().CreateSession(gomock.Any()).DoAndReturn(func(session user.Session) error {
+			assert.Equal(t, stored.FamilyID, session.ID)
+			assert.Equal(t, "user-1", session.UserID)
+			assert.Equal(t, "Mozilla/5.0", session.UserAgent)
+			assert.Equal(t, "10.0.0.1", session.IPAddress)
+			assert.True(t, session.ExpiresAt.After(session.LastSeenAt))
+			return nil
+		})
+
+		token, err := userService.Login("email@mail.com", "password", user.ClientInfo{IPAddress: "10.0.0.1", UserAgent: "Mozilla/5.0"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.RefreshToken)
+	})
+
+	t.Run("refresh touch the session", func(t *testing.T) {
+		stored := user.RefreshToken{ID: "refresh-1", UserID: "user-1", FamilyID: "session-1", ExpiresAt: time.Now().Add(time.Hour)}
+		mock_refreshRepo.EXPECT().GetRefreshTokenByHash(gomock.Any()).Return(stored, nil)
+		mock_refreshRepo.EXPECT().RotateRefreshToken("refresh-1", gomock.Any()).Return(true, nil)
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_refreshRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil)
+		mock_sessionRepo.EXPECT().TouchSession("session-1", gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := userService.RefreshToken("refresh-token", user.ClientInfo{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("list mark the current session", func(t *testing.T) {
+		mock_sessionRepo.EXPECT().GetActiveSessions("user-1", gomock.Any()).Return([]user.Session{{ID: "session-1"}, {ID: "session-2"}}, nil)
+
+		sessions, err := userService.GetSessions("user-1", "session-2")
+		assert.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+	})
+
+	t.Run("revoke a session of another user is not found", func(t *testing.T) {
+		mock_sessionRepo.EXPECT().GetSessionByID("session-3").Return(user.Session{ID: "session-3", UserID: "user-2"}, nil)
+
+		err := userService.RevokeSession("user-1", "session-3", user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrSessionNotFound)
+	})
+
+	t.Run("revoke a session end its tokens", func(t *testing.T) {
+		mock_sessionRepo.EXPECT().GetSessionByID("session-1").Return(user.Session{ID: "session-1", UserID: "user-1"}, nil)
+		mock_sessionRepo.EXPECT().RevokeSession("session-1", gomock.Any()).Return(nil)
+		mock_refreshRepo.EXPECT().RevokeRefreshTokenFamily("session-1", gomock.Any()).Return(nil)
+
+		assert.NoError(t, userService.RevokeSession("user-1", "session-1", user.ClientInfo{}))
+
+		revoked, err := revocation.IsSessionRevoked("session-1")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = revocation.IsSessionRevoked("session-2")
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+}
+
+// memberships is a fixed user:organization -> role table
+type memberships map[string]string
+
+func (m memberships) MemberRole(organizationID string, userID string) (role string, err error) {
+	return m[userID+":"+organizationID], nil
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	jwtSign := "exampleexampleexampleexampleexampleexampleexampleexampleexampleexample"
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Fullname: "Full Name", Role: "user", OrganizationID: "org-1"}
+	claims := user.Claims{ID: "user-1", SessionID: "session-1", OrganizationID: "org-1"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	t.Run("organizations not enabled", func(t *testing.T) {
+		userService := user.NewService(logger, mock_userRepo, "http://appDeploymentUrl.com", jwtSign, "32character32character32characte", mock_notification)
+
+		_, err := userService.SwitchOrganization(claims, "org-2")
+		assert.ErrorIs(t, err, user.ErrOrganizationsUnavailable)
+	})
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		jwtSign,
+		"32character32character32characte",
+		mock_notification,
+		user.WithOrganizations(memberships{"user-1:org-1": "admin", "user-1:org-2": "user"}),
+	)
+
+	t.Run("not a member of the organization", func(t *testing.T) {
+		_, err := userService.SwitchOrganization(claims, "org-3")
+		assert.ErrorIs(t, err, user.ErrNotMember)
+	})
+
+	t.Run("switch store the active organization and put it in the token", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).DoAndReturn(func(id string, patch user.Patch) error {
+			require.NotNil(t, patch.OrganizationID)
+			assert.Equal(t, "org-2", *patch.OrganizationID)
+			return nil
+		})
+
+		token, err := userService.SwitchOrganization(claims, "org-2")
+		require.NoError(t, err)
+		assert.Empty(t, token.RefreshToken)
+
+		switched := user.Claims{}
+		_, err = jwt.ParseWithClaims(token.AccessToken, &switched, func(*jwt.Token) (interface{}, error) { return []byte(jwtSign), nil })
+		require.NoError(t, err)
+		assert.Equal(t, "org-2", switched.OrganizationID)
+		assert.Equal(t, "session-1", switched.SessionID)
+		// the role in the organization is resolved per request, the token keep the global role
+		assert.Equal(t, "user", switched.Role)
+	})
+}
+
+func TestSecurityLog(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: user.RoleUser, IsEmailVerified: true}
+	client := user.ClientInfo{IPAddress: "10.0.0.1", UserAgent: "Mozilla/5.0"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_securityRepo := mock_user.NewMockSecurityEventRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	newService := func(opts ...user.Option) user.Service {
+		return user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification,
+			opts...,
+		)
+	}
+	userService := newService(user.WithSecurityEventRepository(mock_securityRepo))
+
+	t.Run("log not enabled", func(t *testing.T) {
+		_, _, err := newService().GetSecurityEvents(user.SecurityEventFilter{}, 1, 10)
+		assert.ErrorIs(t, err, user.ErrSecurityLogUnavailable)
+		assert.ErrorIs(t, newService().ExportSecurityEvents(user.SecurityEventFilter{}, &strings.Builder{}), user.ErrSecurityLogUnavailable)
+	})
+
+	t.Run("failed login is recorded with the client", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil)
+		mock_securityRepo.EXPECT().CreateSecurityEvent(gomock.Any()).DoAndReturn(func(event user.SecurityEvent) error {
+			assert.NotEmpty(t, event.ID)
+			assert.Equal(t, user.SecurityLoginFailed, event.EventType)
+			assert.Equal(t, user.OutcomeFailure, event.Outcome)
+			assert.Equal(t, "user-1", event.SubjectID)
+			assert.Empty(t, event.ActorID)
+			assert.Equal(t, "10.0.0.1", event.IPAddress)
+			assert.Equal(t, "Mozilla/5.0", event.UserAgent)
+			assert.Equal(t, "wrong password", event.Detail)
+			return nil
+		})
+
+		_, err := userService.Login("email@mail.com", "wrong", client)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown email only keep a masked email", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("nobody@mail.com").Return(user.User{}, nil)
+		mock_securityRepo.EXPECT().CreateSecurityEvent(gomock.Any()).DoAndReturn(func(event user.SecurityEvent) error {
+			assert.Empty(t, event.SubjectID)
+			assert.Equal(t, "unknown email n***@mail.com", event.Detail)
+			return nil
+		})
+
+		_, err := userService.Login("nobody@mail.com", "password", client)
+		assert.Error(t, err)
+	})
+
+	t.Run("successful login is recorded and a log failure is not an error", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil)
+		mock_securityRepo.EXPECT().CreateSecurityEvent(gomock.Any()).DoAndReturn(func(event user.SecurityEvent) error {
+			assert.Equal(t, user.SecurityLoginSucceeded, event.EventType)
+			assert.Equal(t, user.OutcomeSuccess, event.Outcome)
+			assert.Equal(t, "user-1", event.ActorID)
+			return errors.New("db error")
+		})
+
+		token, err := userService.Login("email@mail.com", "password", client)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+	})
+
+	t.Run("role change record the actor and the roles", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().UpdateRole("user-1", user.RoleAdmin).Return(nil)
+		mock_securityRepo.EXPECT().CreateSecurityEvent(gomock.Any()).DoAndReturn(func(event user.SecurityEvent) error {
+			assert.Equal(t, user.SecurityRoleChanged, event.EventType)
+			assert.Equal(t, "superadmin-1", event.ActorID)
+			assert.Equal(t, "user-1", event.SubjectID)
+			assert.Equal(t, "user -> admin", event.Detail)
+			return nil
+		})
+
+		assert.NoError(t, userService.ChangeRole("superadmin-1", user.RoleSuperadmin, "user-1", user.RoleAdmin, client))
+	})
+
+	t.Run("export page through the log and neutralize formulas", func(t *testing.T) {
+		createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		filter := user.SecurityEventFilter{Outcome: user.OutcomeFailure}
+		fullPage := make([]user.SecurityEvent, 500)
+		for i := range fullPage {
+			fullPage[i] = user.SecurityEvent{ID: fmt.Sprintf("event-%d", i), EventType: user.SecurityLoginFailed, Outcome: user.OutcomeFailure, CreatedAt: createdAt}
+		}
+		gomock.InOrder(
+			mock_securityRepo.EXPECT().GetSecurityEvents(filter, 1, 500).Return(fullPage, int64(501), nil),
+			mock_securityRepo.EXPECT().GetSecurityEvents(filter, 2, 500).Return([]user.SecurityEvent{
+				{ID: "event-500", EventType: user.SecurityLoginFailed, Outcome: user.OutcomeFailure, SubjectID: "user-1", IPAddress: "10.0.0.1", UserAgent: "=HYPERLINK(\"http://evil\")", Detail: "wrong password", CreatedAt: createdAt},
+			}, int64(501), nil),
+		)
+
+		var out strings.Builder
+		require.NoError(t, userService.ExportSecurityEvents(filter, &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 502)
+		assert.Equal(t, "id,created_at,event_type,outcome,actor_id,subject_id,ip_address,user_agent,detail", lines[0])
+		assert.Equal(t, `event-500,2026-01-02T03:04:05Z,login_failed,failure,,user-1,10.0.0.1,"'=HYPERLINK(""http://evil"")",wrong password`, lines[501])
+	})
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithRefreshTokenRepository(mock_refreshRepo),
+		user.WithOneTimeTokens(newOneTimeTokens()),
+	)
+
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: "$2a$10$oldhashedpassword", Fullname: "Full Name"}
+
+	t.Run("unknown email send nothing and return no error", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("unknown@mail.com").Return(user.User{}, nil)
+
+		assert.NoError(t, userService.ForgotPassword("unknown@mail.com"))
+	})
+
+	var resetLink string
+	t.Run("registered email receive a reset link", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil)
+		mock_notification.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectResetPassword, gomock.Any()).
+			DoAndReturn(func(toName, toEmail, subject, message string) error {
+				resetLink = message
+				return nil
+			})
+
+		assert.NoError(t, userService.ForgotPassword("email@mail.com"))
+	})
+
+	// the link open the form served by the api
+	assert.Contains(t, resetLink, "http://appDeploymentUrl.com"+user.ResetPasswordPath+"?code=")
+	resetCode := linkToken(t, resetLink, "code=")
+
+	t.Run("invalid code", func(t *testing.T) {
+		err := userService.ResetPassword("dhslkashdlaskdh", "new-password", user.ClientInfo{})
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	var newPasswordHash string
+	t.Run("reset update the password and revoke the sessions", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().UpdatePassword(gomock.Any()).DoAndReturn(func(u user.User) error {
+			newPasswordHash = u.Password
+			return nil
+		})
+		mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-1", gomock.Any()).Return(nil)
+
+		assert.NoError(t, userService.ResetPassword(resetCode, "new-password", user.ClientInfo{}))
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(newPasswordHash), []byte("new-password")))
+	})
+
+	t.Run("the link can not be used twice", func(t *testing.T) {
+		err := userService.ResetPassword(resetCode, "another-password", user.ClientInfo{})
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		mockUser  func(m *mock_user.MockRepository)
+		mockNotif func(m *mock_notification.MockRepository)
+		wantErr   error
+	}{
+		{
+			name:  "unknown email",
+			email: "unknown@mail.com",
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByEmail("unknown@mail.com").Return(user.User{}, nil)
+			},
+			mockNotif: func(m *mock_notification.MockRepository) {},
+		},
+		{
+			name:  "already verified",
+			email: "verified@mail.com",
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByEmail("verified@mail.com").Return(user.User{Email: "verified@mail.com", IsEmailVerified: true}, nil)
+			},
+			mockNotif: func(m *mock_notification.MockRepository) {},
+		},
+		{
+			name:  "send a new link",
+			email: "email@mail.com",
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByEmail("email@mail.com").Return(user.User{Email: "email@mail.com", Fullname: "Full Name"}, nil)
+			},
+			mockNotif: func(m *mock_notification.MockRepository) {
+				m.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectRegisterAccount, gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:      "throttled per address",
+			email:     "Email@mail.com",
+			mockUser:  func(m *mock_user.MockRepository) {},
+			mockNotif: func(m *mock_notification.MockRepository) {},
+			wantErr:   user.ErrTooManyRequests,
+		},
+	}
+
+	// the cache is shared so the last case hit the throttle of the previous one
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_userRepo := mock_user.NewMockRepository(ctrl)
+			mock_notification := mock_notification.NewMockRepository(ctrl)
+
+			tt.mockUser(mock_userRepo)
+			tt.mockNotif(mock_notification)
+
+			userService := user.NewService(
+				logger,
+				mock_userRepo,
+				"http://appDeploymentUrl.com",
+				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+				"32character32character32characte",
+				mock_notification,
+				user.WithCache(memoryCache),
+				user.WithEmailVerification(10*time.Minute, time.Minute),
+				user.WithOneTimeTokens(newOneTimeTokens()),
+			)
+
+			err := userService.ResendVerification(tt.email)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestLoginProtection(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: "user", IsEmailVerified: true}
+
+	newService := func(t *testing.T, mock_userRepo *mock_user.MockRepository, mock_notification *mock_notification.MockRepository) user.Service {
+		memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+		assert.NoError(t, err)
+
+		return user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification,
+			user.WithCache(memoryCache),
+			user.WithLoginProtection(user.LoginProtectionConfig{
+				MaxAttempts:      3,
+				MaxAttemptsPerIP: 4,
+				BaseDelay:        time.Millisecond,
+				MaxDelay:         2 * time.Millisecond,
+			}),
+		)
+	}
+
+	t.Run("email locked after max attempts and notified", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_notification := mock_notification.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, mock_notification)
+
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil).Times(3)
+		mock_notification.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectAccountLocked, gomock.Any()).Return(nil)
+
+		for i := 0; i < 3; i++ {
+			_, err := userService.Login("email@mail.com", "wrong", user.ClientInfo{IPAddress: "10.0.0.1"})
+			assert.ErrorContains(t, err, "wrong email or password")
+		}
+
+		// even the right password is refused while locked
+		_, err := userService.Login("email@mail.com", "password", user.ClientInfo{IPAddress: "10.0.0.2"})
+		assert.ErrorIs(t, err, user.ErrLoginLocked)
+	})
+
+	t.Run("success reset the email counter", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_notification := mock_notification.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, mock_notification)
+
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil).Times(5)
+
+		for _, password := range []string{"wrong", "wrong", "password", "wrong", "wrong"} {
+			_, _ = userService.Login("email@mail.com", password, user.ClientInfo{})
+		}
+	})
+
+	t.Run("ip locked after max attempts on different emails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_notification := mock_notification.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, mock_notification)
+
+		mock_userRepo.EXPECT().GetByEmail(gomock.Any()).Return(user.User{}, nil).Times(4)
+
+		for i := 0; i < 4; i++ {
+			_, err := userService.Login(fmt.Sprintf("user%d@mail.com", i), "wrong", user.ClientInfo{IPAddress: "10.0.0.1"})
+			assert.ErrorContains(t, err, "wrong email or password")
+		}
+
+		_, err := userService.Login("email@mail.com", "password", user.ClientInfo{IPAddress: "10.0.0.1"})
+		assert.ErrorIs(t, err, user.ErrLoginLocked)
+	})
+}
+
+func TestUserAdmin(t *testing.T) {
+	target := user.User{ID: "user-2", Email: "target@mail.com", Fullname: "Target", Role: user.RoleUser, IsEmailVerified: true}
+
+	tests := []struct {
+		name        string
+		call        func(s user.Service) error
+		mockUser    func(m *mock_user.MockRepository)
+		mockRefresh func(m *mock_user.MockRefreshTokenRepository)
+		wantErr     error
+	}{
+		{
+			name: "change role to an unknown role",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "user-2", "root", user.ClientInfo{})
+			},
+			mockUser:    func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrInvalidRole,
+		},
+		{
+			name: "change own role",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "admin-1", user.RoleUser, user.ClientInfo{})
+			},
+			mockUser:    func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrOwnAccountOnly,
+		},
+		{
+			name: "change role of a missing user",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "missing", user.RoleAdmin, user.ClientInfo{})
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("missing").Return(user.User{}, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrUserNotFound,
+		},
+		{
+			name: "change role to a role above the actor",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "user-2", user.RoleSuperadmin, user.ClientInfo{})
+			},
+			mockUser:    func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrRoleNotAllowed,
+		},
+		{
+			name: "change role of a user above the actor",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "superadmin-1", user.RoleUser, user.ClientInfo{})
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("superadmin-1").Return(user.User{ID: "superadmin-1", Role: user.RoleSuperadmin}, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrRoleNotAllowed,
+		},
+		{
+			name: "change role end the sessions",
+			call: func(s user.Service) error {
+				return s.ChangeRole("admin-1", user.RoleAdmin, "user-2", user.RoleAdmin, user.ClientInfo{})
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-2").Return(target, nil)
+				m.EXPECT().UpdateRole("user-2", user.RoleAdmin).Return(nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().RevokeUserRefreshTokens("user-2", gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "disable end the sessions",
+			call: func(s user.Service) error { return s.SetDisabled("admin-1", "user-2", true, user.ClientInfo{}) },
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-2").Return(target, nil)
+				m.EXPECT().UpdateDisabled("user-2", true).Return(nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().RevokeUserRefreshTokens("user-2", gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "enable",
+			call: func(s user.Service) error { return s.SetDisabled("admin-1", "user-2", false, user.ClientInfo{}) },
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-2").Return(target, nil)
+				m.EXPECT().UpdateDisabled("user-2", false).Return(nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+		},
+		{
+			name: "delete",
+			call: func(s user.Service) error { return s.Delete("admin-1", "user-2", user.ClientInfo{}) },
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-2").Return(target, nil)
+				m.EXPECT().Delete("user-2").Return(nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().RevokeUserRefreshTokens("user-2", gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "disabled user can not login",
+			call: func(s user.Service) error {
+				_, err := s.Login("target@mail.com", "password", user.ClientInfo{})
+				return err
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				passwordHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+				disabled := target
+				disabled.Password = string(passwordHash)
+				disabled.IsDisabled = true
+				m.EXPECT().GetByEmail("target@mail.com").Return(disabled, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrUserDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_userRepo := mock_user.NewMockRepository(ctrl)
+			mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+			mock_notification := mock_notification.NewMockRepository(ctrl)
+
+			tt.mockUser(mock_userRepo)
+			tt.mockRefresh(mock_refreshRepo)
+
+			userService := user.NewService(
+				logger,
+				mock_userRepo,
+				"http://appDeploymentUrl.com",
+				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+				"32character32character32characte",
+				mock_notification,
+				user.WithRefreshTokenRepository(mock_refreshRepo),
+			)
+
+			err := tt.call(userService)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+
+	// the jwt middleware refuse the tokens of a disabled user only through the revocation, so
+	// disabling must fail when it can't be written
+	t.Run("disable revoke the tokens already issued", func(t *testing.T) {
+		memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+		require.NoError(t, err)
+		revocation := user.NewTokenRevocation(memoryCache)
+
+		for _, tt := range []struct {
+			name       string
+			revocation *user.TokenRevocation
+			wantErr    error
+		}{
+			{name: "revoked", revocation: revocation},
+			{name: "cache down", revocation: user.NewTokenRevocation(failingCache{}), wantErr: errCacheDown},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				mock_userRepo := mock_user.NewMockRepository(ctrl)
+				mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+
+				mock_userRepo.EXPECT().GetByID("user-2").Return(target, nil)
+				mock_userRepo.EXPECT().UpdateDisabled("user-2", true).Return(nil)
+				// the refresh tokens are revoked even when the cache is down
+				mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-2", gomock.Any()).Return(nil)
+
+				userService := user.NewService(
+					logger,
+					mock_userRepo,
+					"http://appDeploymentUrl.com",
+					"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+					"32character32character32characte",
+					mock_notification.NewMockRepository(ctrl),
+					user.WithRefreshTokenRepository(mock_refreshRepo),
+					user.WithTokenRevocation(tt.revocation),
+				)
+
+				err := userService.SetDisabled("admin-1", "user-2", true, user.ClientInfo{})
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+
+				revoked, err := tt.revocation.IsRevoked("jti-1", "user-2", time.Now().Add(-time.Minute))
+				assert.NoError(t, err)
+				assert.True(t, revoked)
+			})
+		}
+	})
+
+	t.Run("change role to a role defined at runtime", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+
+		userService := user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification.NewMockRepository(ctrl),
+			user.WithRoleValidator(testRoles),
+		)
+
+		assert.ErrorIs(t, userService.ChangeRole("admin-1", "useradmin", "user-2", user.RoleAdmin, user.ClientInfo{}), user.ErrInvalidRole)
+
+		mock_userRepo.EXPECT().GetByID("user-2").Return(target, nil)
+		mock_userRepo.EXPECT().UpdateRole("user-2", "auditor").Return(nil)
+		assert.NoError(t, userService.ChangeRole("admin-1", user.RoleSuperadmin, "user-2", "auditor", user.ClientInfo{}))
+	})
+
+	t.Run("a custom role can not grant a permission it does not have", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+
+		userService := user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification.NewMockRepository(ctrl),
+			user.WithRoleValidator(testRoles),
+		)
+
+		assert.ErrorIs(t, userService.ChangeRole("admin-1", "useradmin", "user-2", user.RoleSuperadmin, user.ClientInfo{}), user.ErrRoleNotAllowed)
+		assert.ErrorIs(t, userService.ChangeRole("admin-1", "useradmin", "user-2", "auditor", user.ClientInfo{}), user.ErrRoleNotAllowed)
+
+		// nor take the permissions of a user above it away
+		mock_userRepo.EXPECT().GetByID("superadmin-1").Return(user.User{ID: "superadmin-1", Role: user.RoleSuperadmin}, nil)
+		assert.ErrorIs(t, userService.ChangeRole("admin-1", "useradmin", "superadmin-1", "useradmin", user.ClientInfo{}), user.ErrRoleNotAllowed)
+	})
+}
+
+// roleValidator give the permissions of the roles, a role not in the map does not exist
+type roleValidator map[string][]string
+
+func (v roleValidator) Exists(name string) (bool, error) {
+	_, ok := v[name]
+	return ok, nil
+}
+
+func (v roleValidator) Permissions(roleName string) ([]string, error) {
+	return v[roleName], nil
+}
+
+var testRoles = roleValidator{
+	user.RoleSuperadmin: {"inventory:read", "security:audit", "user:admin"},
+	"auditor":           {"inventory:read", "security:audit"},
+	"useradmin":         {"user:admin"},
+}
+
+func TestTwoFactor(t *testing.T) {
+	jwtSign := "exampleexampleexampleexampleexampleexampleexampleexampleexampleexample"
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	assert.NoError(t, err)
+
+	// the mock keep the 2fa fields like a repository would
+	stored := user.User{ID: "admin-1", Email: "admin@mail.com", Password: string(passwordHash), Fullname: "Admin", Role: user.RoleAdmin, IsEmailVerified: true}
+	mock_userRepo.EXPECT().GetByID("admin-1").DoAndReturn(func(string) (user.User, error) { return stored, nil }).AnyTimes()
+	mock_userRepo.EXPECT().GetByEmail("admin@mail.com").DoAndReturn(func(string) (user.User, error) { return stored, nil }).AnyTimes()
+	mock_userRepo.EXPECT().UpdateTwoFactor(gomock.Any()).DoAndReturn(func(u user.User) error {
+		stored.TOTPSecret, stored.IsTOTPEnabled, stored.RecoveryCodes = u.TOTPSecret, u.IsTOTPEnabled, u.RecoveryCodes
+		return nil
+	}).AnyTimes()
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		jwtSign,
+		"32character32character32characte",
+		mock_notification,
+		user.WithCache(memoryCache),
+		user.WithTwoFactor(user.TwoFactorConfig{RequiredRoles: []string{user.RoleAdmin}, MaxAttempts: 2, EncryptionKey: "16character16cha"}),
+	)
+
+	twoFactorPending := func(t *testing.T, accessToken string) bool {
+		claims := user.Claims{}
+		_, err := jwt.ParseWithClaims(accessToken, &claims, func(*jwt.Token) (interface{}, error) { return []byte(jwtSign), nil })
+		assert.NoError(t, err)
+		return claims.TwoFactorPending
+	}
+
+	// the role require 2FA, the token is pending until the user enroll and login with 2FA
+	token, err := userService.Login("admin@mail.com", "password", user.ClientInfo{})
+	assert.NoError(t, err)
+	assert.True(t, twoFactorPending(t, token.AccessToken))
+
+	secret, provisioningURI, err := userService.EnrollTwoFactor("admin-1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(provisioningURI, "otpauth://totp/belajarGo:admin@mail.com?"))
+	assert.Contains(t, provisioningURI, "secret="+secret)
+	assert.NotEqual(t, secret, stored.TOTPSecret)
+
+	_, err = userService.ConfirmTwoFactor("admin-1", "000000")
+	assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+
+	code, err := user.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	recoveryCodes, err := userService.ConfirmTwoFactor("admin-1", code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10)
+	assert.True(t, stored.IsTOTPEnabled)
+	assert.NotContains(t, stored.RecoveryCodes, recoveryCodes[0])
+
+	_, _, err = userService.EnrollTwoFactor("admin-1")
+	assert.ErrorIs(t, err, user.ErrTwoFactorAlreadyEnabled)
+
+	t.Run("login need the second step", func(t *testing.T) {
+		token, err := userService.Login("admin@mail.com", "password", user.ClientInfo{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.TwoFactorToken)
+		assert.Empty(t, token.AccessToken)
+
+		// the code used to confirm can't be replayed
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, code, user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+
+		completed, err := userService.LoginTwoFactor(token.TwoFactorToken, strings.ToUpper(recoveryCodes[0]), user.ClientInfo{})
+		assert.NoError(t, err)
+		assert.False(t, twoFactorPending(t, completed.AccessToken))
+		assert.Len(t, stored.RecoveryCodes, 9)
+
+		// the two factor token is single use
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, recoveryCodes[1], user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorToken)
+	})
+
+	t.Run("used recovery code and too many attempts", func(t *testing.T) {
+		token, err := userService.Login("admin@mail.com", "password", user.ClientInfo{})
+		assert.NoError(t, err)
+
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, recoveryCodes[0], user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, "123456", user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, recoveryCodes[1], user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorToken)
+	})
+
+	t.Run("disable refused by the role policy, reset by a superadmin", func(t *testing.T) {
+		assert.ErrorIs(t, userService.DisableTwoFactor("admin-1", recoveryCodes[1]), user.ErrTwoFactorRequired)
+
+		assert.ErrorIs(t, userService.ResetTwoFactor("admin-1", "admin-1", user.ClientInfo{}), user.ErrOwnAccountOnly)
+		assert.NoError(t, userService.ResetTwoFactor("superadmin-1", "admin-1", user.ClientInfo{}))
+		assert.False(t, stored.IsTOTPEnabled)
+		assert.Empty(t, stored.TOTPSecret)
+	})
+
+	t.Run("secret encrypted with the email verification key is encrypted again with the totp key", func(t *testing.T) {
+		legacySecret, err := goshortcute.AESCBCEncrypt([]byte(secret), []byte("32character32character32characte"))
+		assert.NoError(t, err)
+		stored.TOTPSecret, stored.IsTOTPEnabled = legacySecret, true
+
+		token, err := userService.Login("admin@mail.com", "password", user.ClientInfo{})
+		assert.NoError(t, err)
+		code, err := user.TOTPCode(secret, time.Now().Add(30*time.Second))
+		assert.NoError(t, err)
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, code, user.ClientInfo{})
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, legacySecret, stored.TOTPSecret)
+	})
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+	memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+	assert.NoError(t, err)
+
+	stored := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: user.RoleUser, IsEmailVerified: true}
+	mock_userRepo.EXPECT().GetByID("user-1").DoAndReturn(func(string) (user.User, error) { return stored, nil }).AnyTimes()
+	mock_userRepo.EXPECT().GetByEmail("email@mail.com").DoAndReturn(func(string) (user.User, error) { return stored, nil }).AnyTimes()
+	mock_userRepo.EXPECT().UpdateTwoFactor(gomock.Any()).DoAndReturn(func(u user.User) error {
+		stored.TOTPSecret, stored.IsTOTPEnabled, stored.RecoveryCodes = u.TOTPSecret, u.IsTOTPEnabled, u.RecoveryCodes
+		return nil
+	}).AnyTimes()
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithCache(memoryCache),
+		user.WithTwoFactor(user.TwoFactorConfig{MaxAttempts: 2, EncryptionKey: "16character16cha"}),
+		user.WithLoginProtection(user.LoginProtectionConfig{
+			MaxAttempts:      3,
+			MaxAttemptsPerIP: 100,
+			BaseDelay:        time.Millisecond,
+			MaxDelay:         time.Millisecond,
+		}),
+	)
+
+	secret, _, err := userService.EnrollTwoFactor("user-1")
+	assert.NoError(t, err)
+	code, err := user.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	_, err = userService.ConfirmTwoFactor("user-1", code)
+	assert.NoError(t, err)
+
+	// the password is right, a new challenge must not give new guesses
+	token, err := userService.Login("email@mail.com", "password", user.ClientInfo{})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = userService.LoginTwoFactor(token.TwoFactorToken, "not-a-code", user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+	}
+
+	token, err = userService.Login("email@mail.com", "password", user.ClientInfo{})
+	assert.NoError(t, err)
+
+	mock_notification.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectAccountLocked, gomock.Any()).Return(nil)
+	_, err = userService.LoginTwoFactor(token.TwoFactorToken, "not-a-code", user.ClientInfo{})
+	assert.ErrorIs(t, err, user.ErrInvalidTwoFactorCode)
+
+	// the account is locked for the password and for the pending challenge, even with the right code
+	_, err = userService.Login("email@mail.com", "password", user.ClientInfo{})
+	assert.ErrorIs(t, err, user.ErrLoginLocked)
+
+	code, err = user.TOTPCode(secret, time.Now().Add(30*time.Second))
+	assert.NoError(t, err)
+	_, err = userService.LoginTwoFactor(token.TwoFactorToken, code, user.ClientInfo{})
+	assert.ErrorIs(t, err, user.ErrLoginLocked)
+}
+
+func TestProfile(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: user.RoleUser, IsEmailVerified: true}
+
+	tests := []struct {
+		name        string
+		call        func(s user.Service) error
+		mockUser    func(m *mock_user.MockRepository)
+		mockRefresh func(m *mock_user.MockRefreshTokenRepository)
+		wantErr     error
+	}{
+		{
+			name: "update profile with an empty fullname",
+			call: func(s user.Service) error {
+				_, err := s.UpdateProfile("user-1", "  ")
+				return err
+			},
+			mockUser:    func(m *mock_user.MockRepository) {},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrInvalidFullname,
+		},
+		{
+			name: "update profile of a missing user",
+			call: func(s user.Service) error {
+				_, err := s.UpdateProfile("missing", "New Name")
+				return err
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("missing").Return(user.User{}, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrUserNotFound,
+		},
+		{
+			name: "update profile only patch the fullname",
+			call: func(s user.Service) error {
+				updated, err := s.UpdateProfile("user-1", " New Name ")
+				assert.Equal(t, "New Name", updated.Fullname)
+				return err
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				fullname := "New Name"
+				m.EXPECT().GetByID("user-1").Return(registered, nil)
+				m.EXPECT().Update("user-1", user.Patch{Fullname: &fullname}).Return(nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+		},
+		{
+			name: "change password with a wrong current password",
+			call: func(s user.Service) error {
+				return s.ChangePassword("user-1", "wrong", "new-password", user.ClientInfo{})
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-1").Return(registered, nil)
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {},
+			wantErr:     user.ErrWrongPassword,
+		},
+		{
+			name: "change password end the sessions",
+			call: func(s user.Service) error {
+				return s.ChangePassword("user-1", "password", "new-password", user.ClientInfo{})
+			},
+			mockUser: func(m *mock_user.MockRepository) {
+				m.EXPECT().GetByID("user-1").Return(registered, nil)
+				m.EXPECT().Update("user-1", gomock.Any()).DoAndReturn(func(id string, patch user.Patch) error {
+					assert.Nil(t, patch.Fullname)
+					assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(*patch.Password), []byte("new-password")))
+					return nil
+				})
+			},
+			mockRefresh: func(m *mock_user.MockRefreshTokenRepository) {
+				m.EXPECT().RevokeUserRefreshTokens("user-1", gomock.Any()).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_userRepo := mock_user.NewMockRepository(ctrl)
+			mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+			mock_notification := mock_notification.NewMockRepository(ctrl)
+
+			tt.mockUser(mock_userRepo)
+			tt.mockRefresh(mock_refreshRepo)
+
+			userService := user.NewService(
+				logger,
+				mock_userRepo,
+				"http://appDeploymentUrl.com",
+				"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+				"32character32character32characte",
+				mock_notification,
+				user.WithRefreshTokenRepository(mock_refreshRepo),
+			)
+
+			err := tt.call(userService)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(passwordHash), Fullname: "Full Name", Role: "user", IsEmailVerified: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithOneTimeTokens(newOneTimeTokens()),
+	)
+
+	t.Run("wrong password", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+
+		err := userService.RequestEmailChange("user-1", "new@mail.com", "wrong")
+		assert.ErrorIs(t, err, user.ErrWrongPassword)
+	})
+
+	t.Run("same email", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+
+		err := userService.RequestEmailChange("user-1", "Email@mail.com", "password")
+		assert.ErrorIs(t, err, user.ErrSameEmail)
+	})
+
+	t.Run("registered email", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().GetByEmail("other@mail.com").Return(user.User{ID: "user-2", Email: "other@mail.com"}, nil)
+
+		err := userService.RequestEmailChange("user-1", "other@mail.com", "password")
+		assert.ErrorIs(t, err, user.ErrEmailRegistered)
+	})
+
+	requestChange := func(t *testing.T) (changeToken string) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().GetByEmail("new@mail.com").Return(user.User{}, nil)
+		mock_notification.EXPECT().SendEmail("Full Name", "new@mail.com", user.SubjectChangeEmail, gomock.Any()).
+			DoAndReturn(func(toName, toEmail, subject, message string) error {
+				changeToken = linkToken(t, message, "/users/email-change/")
+				return nil
+			})
+		mock_notification.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectEmailChangeRequested, gomock.Any()).
+			DoAndReturn(func(toName, toEmail, subject, message string) error {
+				assert.Contains(t, message, "n***@mail.com")
+				return nil
+			})
+
+		require.NoError(t, userService.RequestEmailChange("user-1", "new@mail.com", "password"))
+		return changeToken
+	}
+
+	t.Run("invalid code", func(t *testing.T) {
+		err := userService.ConfirmEmailChange("dhslkashdlaskdh")
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	t.Run("a new request replace the link sent before", func(t *testing.T) {
+		previousToken := requestChange(t)
+		requestChange(t)
+
+		err := userService.ConfirmEmailChange(previousToken)
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	t.Run("new email registered since the request", func(t *testing.T) {
+		changeToken := requestChange(t)
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().GetByEmail("new@mail.com").Return(user.User{ID: "user-3", Email: "new@mail.com"}, nil)
+
+		err := userService.ConfirmEmailChange(changeToken)
+		assert.ErrorIs(t, err, user.ErrEmailRegistered)
+	})
+
+	changeCode := requestChange(t)
+
+	t.Run("confirm swap the email and keep it verified", func(t *testing.T) {
+		unverified := registered
+		unverified.IsEmailVerified = false
+		mock_userRepo.EXPECT().GetByID("user-1").Return(unverified, nil)
+		mock_userRepo.EXPECT().GetByEmail("new@mail.com").Return(user.User{}, nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).DoAndReturn(func(id string, patch user.Patch) error {
+			require.NotNil(t, patch.Email)
+			require.NotNil(t, patch.IsEmailVerified)
+			assert.Equal(t, "new@mail.com", *patch.Email)
+			assert.True(t, *patch.IsEmailVerified)
+			return nil
+		})
+
+		assert.NoError(t, userService.ConfirmEmailChange(changeCode))
+	})
+
+	t.Run("the link can not be used twice", func(t *testing.T) {
+		err := userService.ConfirmEmailChange(changeCode)
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+}
+
+type dataExporter []string
+
+func (e dataExporter) ExportUserData(userID string) (interface{}, error) {
+	return []string(e), nil
+}
+
+// dataEraser record the users whose data was erased
+type dataEraser struct {
+	erased []string
+}
+
+func (e *dataEraser) EraseUserData(userID string) error {
+	e.erased = append(e.erased, userID)
+	return nil
+}
+
+func TestPersonalData(t *testing.T) {
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: "$2a$10$hashedpassword", Fullname: "Full Name", Role: "user", IsEmailVerified: true, IsTOTPEnabled: true}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+	mock_sessionRepo := mock_user.NewMockSessionRepository(ctrl)
+	mock_securityRepo := mock_user.NewMockSecurityEventRepository(ctrl)
+	mock_invitationRepo := mock_user.NewMockInvitationRepository(ctrl)
+	mock_eventRepo := mock_outbox.NewMockRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+	eraser := &dataEraser{}
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithRefreshTokenRepository(mock_refreshRepo),
+		user.WithSessionRepository(mock_sessionRepo),
+		user.WithSecurityEventRepository(mock_securityRepo),
+		user.WithInvitationRepository(mock_invitationRepo, time.Hour),
+		user.WithEventRepository(mock_eventRepo),
+		user.WithDataExporter("api_keys", dataExporter{"key-1"}),
+		user.WithDataEraser("webhooks", eraser),
+		user.WithDeletionGracePeriod(48*time.Hour),
+	)
+
+	t.Run("export every data of the user", func(t *testing.T) {
+		events := []user.SecurityEvent{{ID: "event-1", EventType: user.SecurityLoginSucceeded, SubjectID: "user-1", IPAddress: "10.0.0.1"}}
+		invitations := []user.Invitation{{ID: "invitation-1", Email: "invitee@mail.com", InvitedBy: "user-1"}}
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_sessionRepo.EXPECT().GetUserSessions("user-1").Return([]user.Session{{ID: "session-1", UserID: "user-1"}}, nil)
+		mock_securityRepo.EXPECT().GetSecurityEvents(user.SecurityEventFilter{UserID: "user-1"}, 1, gomock.Any()).Return(events, int64(1), nil)
+		mock_invitationRepo.EXPECT().GetInvitationsByInviter("user-1").Return(invitations, nil)
+
+		export, err := userService.ExportData("user-1")
+		assert.NoError(t, err)
+		assert.Equal(t, registered, export.Profile)
+		assert.Equal(t, []user.Session{{ID: "session-1", UserID: "user-1"}}, export.Sessions)
+		assert.Equal(t, events, export.SecurityEvents)
+		assert.Equal(t, invitations, export.InvitationsSent)
+		assert.Equal(t, map[string]interface{}{"api_keys": []string{"key-1"}}, export.Data)
+
+		// the secrets are never part of the archive
+		archive, err := json.Marshal(export)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(archive), registered.Password)
+	})
+
+	t.Run("deletion is scheduled after the grace period", func(t *testing.T) {
+		var scheduled *time.Time
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		mock_userRepo.EXPECT().ScheduleDeletion("user-1", gomock.Any()).DoAndReturn(func(id string, deleteAt *time.Time) error {
+			scheduled = deleteAt
+			return nil
+		})
+		mock_notification.EXPECT().SendEmail("Full Name", "email@mail.com", user.SubjectAccountDeletion, gomock.Any()).Return(nil)
+
+		deleteAt, err := userService.RequestDeletion("user-1")
+		assert.NoError(t, err)
+		require.NotNil(t, scheduled)
+		assert.Equal(t, deleteAt, *scheduled)
+		assert.WithinDuration(t, time.Now().Add(48*time.Hour), deleteAt, time.Minute)
+	})
+
+	deleteAt := time.Now().Add(-time.Minute)
+	scheduled := registered
+	scheduled.DeleteAt = &deleteAt
+
+	t.Run("deletion requested twice", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(scheduled, nil)
+
+		got, err := userService.RequestDeletion("user-1")
+		assert.ErrorIs(t, err, user.ErrDeletionScheduled)
+		assert.Equal(t, deleteAt, got)
+	})
+
+	t.Run("cancel deletion", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		assert.ErrorIs(t, userService.CancelDeletion("user-1"), user.ErrDeletionNotScheduled)
+
+		mock_userRepo.EXPECT().GetByID("user-1").Return(scheduled, nil)
+		mock_userRepo.EXPECT().ScheduleDeletion("user-1", nil).Return(nil)
+		assert.NoError(t, userService.CancelDeletion("user-1"))
+	})
+
+	t.Run("due accounts are anonymized and keep their id", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetDueDeletions(gomock.Any(), 10).Return([]user.User{scheduled}, nil)
+		mock_invitationRepo.EXPECT().AnonymizeInvitationEmail("email@mail.com", "deleted-user-1@anonymized.invalid").Return(nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).DoAndReturn(func(id string, patch user.Patch) error {
+			assert.Equal(t, "deleted-user-1@anonymized.invalid", *patch.Email)
+			assert.Equal(t, "Deleted User", *patch.Fullname)
+			assert.Equal(t, "", *patch.Password)
+			assert.False(t, *patch.IsEmailVerified)
+			assert.True(t, *patch.IsDisabled)
+			assert.Nil(t, patch.Role)
+			return nil
+		})
+		mock_userRepo.EXPECT().UpdateTwoFactor(user.User{ID: "user-1"}).Return(nil)
+		mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-1", gomock.Any()).Return(nil)
+		mock_sessionRepo.EXPECT().RevokeUserSessions("user-1", gomock.Any()).Return(nil)
+		mock_sessionRepo.EXPECT().DeleteUserSessions("user-1").Return(nil)
+		mock_securityRepo.EXPECT().AnonymizeUserSecurityEvents("user-1").Return(nil)
+		mock_eventRepo.EXPECT().RedactAggregate(user.EventAggregateType, "user-1", gomock.Any()).DoAndReturn(func(aggregateType string, aggregateID string, payload string) error {
+			assert.Contains(t, payload, "deleted-user-1@anonymized.invalid")
+			assert.NotContains(t, payload, "email@mail.com")
+			assert.NotContains(t, payload, "Full Name")
+			return nil
+		})
+		mock_userRepo.EXPECT().ScheduleDeletion("user-1", nil).Return(nil)
+		mock_eventRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(evt outbox.Event) error {
+			assert.Equal(t, user.EventAnonymized, evt.EventType)
+			return nil
+		})
+
+		anonymized, err := userService.AnonymizeDueAccounts(10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, anonymized)
+		assert.Equal(t, []string{"user-1"}, eraser.erased)
+	})
+
+	t.Run("a failure stop the run, the account is picked up again", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetDueDeletions(gomock.Any(), 10).Return([]user.User{scheduled}, nil)
+		mock_invitationRepo.EXPECT().AnonymizeInvitationEmail("email@mail.com", "deleted-user-1@anonymized.invalid").Return(nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).Return(errors.New("db down"))
+
+		anonymized, err := userService.AnonymizeDueAccounts(10)
+		assert.Error(t, err)
+		assert.Equal(t, 0, anonymized)
+	})
+}
+
+func TestInvitations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_userRepo := mock_user.NewMockRepository(ctrl)
+	mock_invitationRepo := mock_user.NewMockInvitationRepository(ctrl)
+	mock_notification := mock_notification.NewMockRepository(ctrl)
+
+	userService := user.NewService(
+		logger,
+		mock_userRepo,
+		"http://appDeploymentUrl.com",
+		"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+		"32character32character32characte",
+		mock_notification,
+		user.WithInvitationRepository(mock_invitationRepo, time.Hour),
+		user.WithRoleValidator(roleValidator{user.RoleSuperadmin: {"user:admin", "webhook:admin"}, "admin": {"webhook:admin"}, "useradmin": {"user:admin"}}),
+		user.WithOneTimeTokens(newOneTimeTokens()),
+	)
+
+	t.Run("disabled without repository", func(t *testing.T) {
+		userService := user.NewService(logger, mock_userRepo, "", "", "", mock_notification)
+
+		_, err := userService.Invite("superadmin-1", user.RoleSuperadmin, "invitee@mail.com", "admin")
+		assert.ErrorIs(t, err, user.ErrInvitationsUnavailable)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		_, err := userService.Invite("superadmin-1", user.RoleSuperadmin, "invitee@mail.com", "unknown")
+		assert.ErrorIs(t, err, user.ErrInvalidRole)
+	})
+
+	t.Run("role with a permission the actor does not have", func(t *testing.T) {
+		_, err := userService.Invite("useradmin-1", "useradmin", "invitee@mail.com", "admin")
+		assert.ErrorIs(t, err, user.ErrRoleNotAllowed)
+	})
+
+	t.Run("registered email", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(user.User{ID: "user-1", Email: "email@mail.com"}, nil)
+
+		_, err := userService.Invite("superadmin-1", user.RoleSuperadmin, "email@mail.com", "admin")
+		assert.ErrorIs(t, err, user.ErrEmailRegistered)
+	})
+
+	t.Run("email invited already", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("invitee@mail.com").Return(user.User{}, nil)
+		mock_invitationRepo.EXPECT().GetPendingInvitationByEmail("invitee@mail.com").Return(user.Invitation{ID: "invitation-0"}, nil)
+
+		_, err := userService.Invite("superadmin-1", user.RoleSuperadmin, "invitee@mail.com", "admin")
+		assert.ErrorIs(t, err, user.ErrInvitationPending)
+	})
+
+	var invitation user.Invitation
+	var invitationLink string
+	t.Run("invite email a link", func(t *testing.T) {
+		mock_userRepo.EXPECT().GetByEmail("invitee@mail.com").Return(user.User{}, nil)
+		mock_invitationRepo.EXPECT().GetPendingInvitationByEmail("invitee@mail.com").Return(user.Invitation{}, nil)
+		mock_invitationRepo.EXPECT().CreateInvitation(gomock.Any()).Return(nil)
+		mock_notification.EXPECT().SendEmail("invitee@mail.com", "invitee@mail.com", user.SubjectInvitation, gomock.Any()).
+			DoAndReturn(func(toName, toEmail, subject, message string) error {
+				invitationLink = message
+				return nil
+			})
+
+		var err error
+		invitation, err = userService.Invite("superadmin-1", user.RoleSuperadmin, "invitee@mail.com", "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", invitation.Role)
+		assert.Equal(t, "superadmin-1", invitation.InvitedBy)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), invitation.ExpiresAt, time.Minute)
+	})
+
+	// the link open the form served by the api
+	assert.Contains(t, invitationLink, "http://appDeploymentUrl.com"+user.InvitationAcceptPath+"?code=")
+	invitationCode := linkToken(t, invitationLink, "code=")
+
+	t.Run("revoked invitation can not be resent nor accepted", func(t *testing.T) {
+		revokedAt := time.Now()
+		revoked := invitation
+		revoked.RevokedAt = &revokedAt
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(revoked, nil).Times(2)
+
+		_, err := userService.ResendInvitation(invitation.ID)
+		assert.ErrorIs(t, err, user.ErrInvitationNotFound)
+
+		_, err = userService.AcceptInvitation(invitationCode, "Full Name", "password")
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	resend := func(t *testing.T, current user.Invitation) (resent user.Invitation, invitationCode string) {
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(current, nil)
+		mock_invitationRepo.EXPECT().UpdateInvitationExpiry(invitation.ID, gomock.Any()).Return(nil)
+		mock_notification.EXPECT().SendEmail("invitee@mail.com", "invitee@mail.com", user.SubjectInvitation, gomock.Any()).
+			DoAndReturn(func(toName, toEmail, subject, message string) error {
+				invitationCode = linkToken(t, message, "code=")
+				return nil
+			})
+
+		resent, err := userService.ResendInvitation(invitation.ID)
+		require.NoError(t, err)
+		return resent, invitationCode
+	}
+
+	t.Run("resend extend the expiry", func(t *testing.T) {
+		expired := invitation
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+		resent, _ := resend(t, expired)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), resent.ExpiresAt, time.Minute)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		_, err := userService.AcceptInvitation("dhslkashdlaskdh", "Full Name", "password")
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	t.Run("email registered since the invitation", func(t *testing.T) {
+		_, invitationCode := resend(t, invitation)
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(invitation, nil)
+		mock_userRepo.EXPECT().Create(gomock.Any()).Return(user.ErrDuplicateEmail)
+
+		_, err := userService.AcceptInvitation(invitationCode, "Full Name", "password")
+		assert.ErrorIs(t, err, user.ErrEmailRegistered)
+	})
+
+	_, invitationCode = resend(t, invitation)
+
+	t.Run("accept create a verified user with the role", func(t *testing.T) {
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(invitation, nil)
+		mock_userRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(u user.User) error {
+			assert.Equal(t, "invitee@mail.com", u.Email)
+			assert.Equal(t, "Full Name", u.Fullname)
+			assert.Equal(t, "admin", u.Role)
+			assert.True(t, u.IsEmailVerified)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("password")))
+			return nil
+		})
+		mock_invitationRepo.EXPECT().AcceptInvitation(invitation.ID, gomock.Any()).Return(true, nil)
+
+		id, err := userService.AcceptInvitation(invitationCode, "Full Name", "password")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+	})
+
+	t.Run("the link can not be used twice", func(t *testing.T) {
+		_, err := userService.AcceptInvitation(invitationCode, "Full Name", "password")
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	t.Run("revoke invalidate the link", func(t *testing.T) {
+		_, invitationCode := resend(t, invitation)
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(invitation, nil)
+		mock_invitationRepo.EXPECT().RevokeInvitation(invitation.ID, gomock.Any()).Return(nil)
+		assert.NoError(t, userService.RevokeInvitation(invitation.ID))
+
+		_, err := userService.AcceptInvitation(invitationCode, "Full Name", "password")
+		assert.ErrorContains(t, err, "invalid or expired")
+	})
+
+	t.Run("list and revoke", func(t *testing.T) {
+		mock_invitationRepo.EXPECT().GetPendingInvitations().Return([]user.Invitation{invitation}, nil)
+		invitations, err := userService.GetInvitations()
+		assert.NoError(t, err)
+		assert.Equal(t, []user.Invitation{invitation}, invitations)
+
+		mock_invitationRepo.EXPECT().GetInvitationByID(invitation.ID).Return(invitation, nil)
+		mock_invitationRepo.EXPECT().RevokeInvitation(invitation.ID, gomock.Any()).Return(nil)
+		assert.NoError(t, userService.RevokeInvitation(invitation.ID))
+
+		mock_invitationRepo.EXPECT().GetInvitationByID("missing").Return(user.Invitation{}, nil)
+		assert.ErrorIs(t, userService.RevokeInvitation("missing"), user.ErrInvitationNotFound)
+	})
+}
+
+func TestPasswordPolicyAndRehash(t *testing.T) {
+	denyListPath := t.TempDir() + "/deny_list.txt"
+	assert.NoError(t, os.WriteFile(denyListPath, []byte("# common passwords\n\nSummer2024\n"), 0o600))
+	denyList, err := user.ReadDenyList(denyListPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Summer2024"}, denyList)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	registered := user.User{ID: "user-1", Email: "email@mail.com", Password: string(bcryptHash), Fullname: "Full Name", Role: user.RoleUser, IsEmailVerified: true}
+
+	newService := func(mock_userRepo *mock_user.MockRepository, mock_notification *mock_notification.MockRepository) user.Service {
+		return user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			"exampleexampleexampleexampleexampleexampleexampleexampleexampleexample",
+			"32character32character32characte",
+			mock_notification,
+			user.WithPasswordHash(user.PasswordHashConfig{
+				Algorithm:         user.PasswordAlgorithmArgon2id,
+				Argon2Memory:      1024,
+				Argon2Iterations:  1,
+				Argon2Parallelism: 1,
+			}),
+			user.WithPasswordPolicy(user.PasswordPolicy{
+				MinLength:    10,
+				RequireUpper: true,
+				RequireLower: true,
+				RequireDigit: true,
+				DenyList:     denyList,
+			}),
+			user.WithOneTimeTokens(newOneTimeTokens()),
+		)
+	}
+
+	t.Run("policy violations are listed", func(t *testing.T) {
+		policy := user.PasswordPolicy{MinLength: 10, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+		err := policy.Validate("short")
+		assert.ErrorIs(t, err, user.ErrWeakPassword)
+		for _, violation := range []string{"at least 10 characters", "uppercase", "digit", "symbol"} {
+			assert.ErrorContains(t, err, violation)
+		}
+
+		assert.NoError(t, policy.Validate("Long-enough-1"))
+	})
+
+	t.Run("register refuse a weak or deny listed password", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		userService := newService(mock_user.NewMockRepository(ctrl), mock_notification.NewMockRepository(ctrl))
+
+		for _, password := range []string{"", "alllowercase1", "summer2024"} {
+			_, err := userService.Register(user.User{Email: "new@mail.com", Password: password})
+			assert.ErrorIs(t, err, user.ErrWeakPassword, password)
+		}
+	})
+
+	t.Run("register hash with argon2id", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_notification := mock_notification.NewMockRepository(ctrl)
+		userService := newService(mock_userRepo, mock_notification)
+
+		var stored string
+		mock_userRepo.EXPECT().GetByEmail("new@mail.com").Return(user.User{}, nil)
+		mock_userRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(usr user.User) error {
+			stored = usr.Password
+			return nil
+		})
+		mock_notification.EXPECT().SendEmail(gomock.Any(), "new@mail.com", gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := userService.Register(user.User{Email: "new@mail.com", Password: "Strong-Passw0rd"})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(stored, "$argon2id$v=19$m=1024,t=1,p=1$"), stored)
+
+		// a hash with the configured parameters is not rehashed
+		mock_userRepo.EXPECT().GetByEmail("new@mail.com").Return(user.User{ID: "user-2", Email: "new@mail.com", Password: stored, IsEmailVerified: true}, nil)
+		_, err = userService.Login("new@mail.com", "Strong-Passw0rd", user.ClientInfo{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("login rehash a legacy bcrypt hash", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(mock_userRepo, mock_notification.NewMockRepository(ctrl))
+
+		var rehashed string
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(registered, nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).DoAndReturn(func(id string, patch user.Patch) error {
+			rehashed = *patch.Password
+			return nil
+		})
+
+		_, err := userService.Login("email@mail.com", "password", user.ClientInfo{})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rehashed, "$argon2id$"), rehashed)
+
+		// the new hash still match the password
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(user.User{ID: "user-1", Email: "email@mail.com", Password: rehashed, IsEmailVerified: true}, nil)
+		_, err = userService.Login("email@mail.com", "password", user.ClientInfo{})
+		assert.NoError(t, err)
+
+		mock_userRepo.EXPECT().GetByEmail("email@mail.com").Return(user.User{ID: "user-1", Email: "email@mail.com", Password: rehashed, IsEmailVerified: true}, nil)
+		_, err = userService.Login("email@mail.com", "wrong", user.ClientInfo{})
+		assert.ErrorContains(t, err, "wrong email or password")
+	})
+
+	t.Run("change password apply the policy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(mock_userRepo, mock_notification.NewMockRepository(ctrl))
+
+		mock_userRepo.EXPECT().GetByID("user-1").Return(registered, nil)
+		err := userService.ChangePassword("user-1", "password", "Summer2024", user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrWeakPassword)
+	})
+}
+
+// mockOIDCProvider is a local OpenID Connect provider, authorize stand for the user login at the provider
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	clientID string
+	key      *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockOIDCGrant
+}
+
+type mockOIDCGrant struct {
+	challenge, redirectURI, nonce string
+	claims                        jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T, clientID string) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &mockOIDCProvider{clientID: clientID, key: key, codes: map[string]mockOIDCGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "provider-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		grant, ok := p.codes[r.PostFormValue("code")]
+		delete(p.codes, r.PostFormValue("code"))
+		p.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("client_id") != p.clientID ||
+			r.PostFormValue("redirect_uri") != grant.redirectURI || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   p.server.URL,
+			"aud":   p.clientID,
+			"sub":   "provider-subject",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": grant.nonce,
+		}
+		for k, v := range grant.claims {
+			claims[k] = v
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "provider-key"
+		signed, err := idToken.SignedString(key)
+		require.NoError(t, err)
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "provider-access-token", "token_type": "Bearer", "id_token": signed})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// authorize accept the login of the auth url and return the state and the code of the redirect
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (state string, code string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(authURL, p.server.URL+"/authorize?"))
+
+	query := u.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+
+	code = uuid.NewString()
+	p.mu.Lock()
+	p.codes[code] = mockOIDCGrant{challenge: query.Get("code_challenge"), redirectURI: query.Get("redirect_uri"), nonce: query.Get("nonce"), claims: claims}
+	p.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+func TestOIDCLogin(t *testing.T) {
+	jwtSign := "exampleexampleexampleexampleexampleexampleexampleexampleexampleexample"
+	provider := newMockOIDCProvider(t, "belajargo-client")
+	staff := user.User{ID: "user-1", Email: "staff@company.com", Fullname: "Staff", Role: user.RoleUser, IsEmailVerified: true}
+	verifiedClaims := jwt.MapClaims{"email": "staff@company.com", "email_verified": true, "name": "Staff Member"}
+
+	newService := func(t *testing.T, mock_userRepo *mock_user.MockRepository, autoProvision bool, opts ...user.Option) user.Service {
+		memoryCache, err := cache.NewMemoryARCCacheRepository(100)
+		require.NoError(t, err)
+
+		opts = append(opts,
+			user.WithCache(memoryCache),
+			user.WithOIDC(user.OIDCConfig{
+				IssuerURL:     provider.server.URL,
+				ClientID:      "belajargo-client",
+				RedirectURL:   "http://appDeploymentUrl.com/users/oidc/callback",
+				AutoProvision: autoProvision,
+			}),
+		)
+		return user.NewService(
+			logger,
+			mock_userRepo,
+			"http://appDeploymentUrl.com",
+			jwtSign,
+			"32character32character32characte",
+			nil,
+			opts...,
+		)
+	}
+
+	login := func(t *testing.T, userService user.Service, claims jwt.MapClaims) (user.Token, error) {
+		authURL, err := userService.OIDCAuthURL()
+		require.NoError(t, err)
+
+		state, code := provider.authorize(t, authURL, claims)
+		return userService.OIDCCallback(state, code, user.ClientInfo{IPAddress: "10.0.0.1"})
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		userService := user.NewService(logger, mock_user.NewMockRepository(ctrl), "", jwtSign, "", nil)
+
+		_, err := userService.OIDCAuthURL()
+		assert.ErrorIs(t, err, user.ErrOIDCUnavailable)
+	})
+
+	t.Run("existing account get the normal token", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, false)
+
+		mock_userRepo.EXPECT().GetByEmail("staff@company.com").Return(staff, nil)
+		token, err := login(t, userService, verifiedClaims)
+		require.NoError(t, err)
+
+		claims := user.Claims{}
+		_, err = jwt.ParseWithClaims(token.AccessToken, &claims, func(t *jwt.Token) (interface{}, error) { return []byte(jwtSign), nil })
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.ID)
+		assert.Equal(t, user.RoleUser, claims.Role)
+	})
+
+	t.Run("existing unverified account is verified without its password", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+		userService := newService(t, mock_userRepo, false, user.WithRefreshTokenRepository(mock_refreshRepo))
+
+		// the account was registered with a password by somebody who never proved the address
+		unverified := staff
+		unverified.IsEmailVerified = false
+		unverified.Password = "$2a$10$preRegisteredPasswordHash"
+		verified := true
+		noPassword := ""
+		gomock.InOrder(
+			mock_userRepo.EXPECT().GetByEmail("staff@company.com").Return(unverified, nil),
+			mock_userRepo.EXPECT().Update("user-1", user.Patch{IsEmailVerified: &verified, Password: &noPassword}).Return(nil),
+			mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-1", gomock.Any()).Return(nil),
+			mock_refreshRepo.EXPECT().CreateRefreshToken(gomock.Any()).Return(nil),
+		)
+
+		token, err := login(t, userService, verifiedClaims)
+		require.NoError(t, err)
+		assert.NotEmpty(t, token.RefreshToken)
+	})
+
+	t.Run("unverified account is not linked when its tokens are not revoked", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		mock_refreshRepo := mock_user.NewMockRefreshTokenRepository(ctrl)
+		userService := newService(t, mock_userRepo, false, user.WithRefreshTokenRepository(mock_refreshRepo))
+
+		unverified := staff
+		unverified.IsEmailVerified = false
+		mock_userRepo.EXPECT().GetByEmail("staff@company.com").Return(unverified, nil)
+		mock_userRepo.EXPECT().Update("user-1", gomock.Any()).Return(nil)
+		mock_refreshRepo.EXPECT().RevokeUserRefreshTokens("user-1", gomock.Any()).Return(errors.New("db down"))
+
+		_, err := login(t, userService, verifiedClaims)
+		assert.Error(t, err)
+	})
+
+	t.Run("unverified provider email is refused", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		userService := newService(t, mock_user.NewMockRepository(ctrl), true)
+
+		_, err := login(t, userService, jwt.MapClaims{"email": "staff@company.com", "email_verified": false})
+		assert.ErrorIs(t, err, user.ErrOIDCEmailNotVerified)
+	})
+
+	t.Run("unknown email without auto provisioning", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, false)
+
+		mock_userRepo.EXPECT().GetByEmail("staff@company.com").Return(user.User{}, nil)
+		_, err := login(t, userService, verifiedClaims)
+		assert.ErrorIs(t, err, user.ErrOIDCUserNotFound)
+	})
+
+	t.Run("unknown email is provisioned", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, true)
+
+		mock_userRepo.EXPECT().GetByEmail("staff@company.com").Return(user.User{}, nil)
+		mock_userRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(usr user.User) error {
+			assert.NotEmpty(t, usr.ID)
+			assert.Equal(t, "Staff Member", usr.Fullname)
+			assert.Equal(t, user.RoleUser, usr.Role)
+			assert.True(t, usr.IsEmailVerified)
+			assert.Empty(t, usr.Password)
+			return nil
+		})
+
+		token, err := login(t, userService, verifiedClaims)
+		require.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+	})
+
+	t.Run("state is single use and bound to its code verifier", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mock_userRepo := mock_user.NewMockRepository(ctrl)
+		userService := newService(t, mock_userRepo, false)
+
+		firstURL, err := userService.OIDCAuthURL()
+		require.NoError(t, err)
+		secondURL, err := userService.OIDCAuthURL()
+		require.NoError(t, err)
+
+		firstState, _ := provider.authorize(t, firstURL, verifiedClaims)
+		_, secondCode := provider.authorize(t, secondURL, verifiedClaims)
+
+		// the code of another login fail the PKCE check at the provider
+		_, err = userService.OIDCCallback(firstState, secondCode, user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrOIDCLoginFailed)
+
+		_, err = userService.OIDCCallback(firstState, secondCode, user.ClientInfo{})
+		assert.ErrorIs(t, err, user.ErrInvalidOIDCState)
+	})
 }
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"strings"
+	"time"
+)
+
+type (
+	// Subscription receive the events of its organization, the one active when it was created.
+	// The user events belong to no organization, they go to the subscriptions of the organizations of the user
+	Subscription struct {
+		ID             string    `json:"id" bson:"subscription_id"`
+		OrganizationID string    `json:"organization_id" bson:"organization_id"`
+		URL            string    `json:"url"`
+		EventTypes     []string  `json:"event_types" bson:"event_types" gorm:"serializer:json"`
+		Secret         string    `json:"-"`
+		IsActive       bool      `json:"is_active" bson:"is_active"`
+		CreatedBy      string    `json:"created_by" bson:"created_by"`
+		CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	}
+
+	Delivery struct {
+		ID             string     `json:"id" bson:"delivery_id"`
+		SubscriptionID string     `json:"subscription_id" bson:"subscription_id"`
+		EventID        string     `json:"event_id" bson:"event_id"`
+		EventType      string     `json:"event_type" bson:"event_type"`
+		AggregateType  string     `json:"aggregate_type" bson:"aggregate_type"`
+		AggregateID    string     `json:"aggregate_id" bson:"aggregate_id"`
+		Payload        string     `json:"payload"`
+		Status         string     `json:"status"`
+		Attempts       int        `json:"attempts"`
+		ResponseStatus int        `json:"response_status" bson:"response_status"`
+		LastError      string     `json:"last_error" bson:"last_error"`
+		NextAttemptAt  time.Time  `json:"next_attempt_at" bson:"next_attempt_at"`
+		CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+		DeliveredAt    *time.Time `json:"delivered_at" bson:"delivered_at"`
+	}
+)
+
+const (
+	DeliveryStatusPending = "pending"
+	DeliveryStatusSuccess = "success"
+	DeliveryStatusFailed  = "failed"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Matches report whether the subscription listen to the event type,
+// "*" match every event and "inventory.*" match every inventory event
+func (sub Subscription) Matches(eventType string) bool {
+	for _, t := range sub.EventTypes {
+		if t == eventType || (strings.HasSuffix(t, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
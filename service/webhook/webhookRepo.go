@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"belajarGo2/service/organization"
+	"time"
+)
+
+type Repository interface {
+	CreateSubscription(sub Subscription) (err error)
+	// GetSubscriptions, GetSubscriptionByID and DeleteSubscription only see the subscriptions of the organization
+	GetSubscriptions(organizationID string) (subs []Subscription, err error)
+	GetSubscriptionByID(organizationID string, id string) (sub Subscription, err error)
+	DeleteSubscription(organizationID string, id string) (err error)
+	// GetDeliverySubscription return the subscription of a delivery whatever its organization, only the delivery job use it
+	GetDeliverySubscription(id string) (sub Subscription, err error)
+
+	// CreateDeliveries ignore deliveries already created for the same subscription and event
+	CreateDeliveries(deliveries []Delivery) (err error)
+	GetDeliveryByID(id string) (delivery Delivery, err error)
+	GetDeliveries(subscriptionID string, page int, limit int) (deliveries []Delivery, err error)
+	GetDueDeliveries(now time.Time, limit int) (deliveries []Delivery, err error)
+	UpdateDelivery(delivery Delivery) (err error)
+	// GetAggregateDeliveries return every delivery of the events of the aggregate
+	GetAggregateDeliveries(aggregateType string, aggregateID string) (deliveries []Delivery, err error)
+}
+
+// MembershipRepository list the organizations of a user, its user events go to their subscriptions.
+// organization.Repository satisfy it
+type MembershipRepository interface {
+	GetUserMemberships(userID string) (members []organization.Member, err error)
+}
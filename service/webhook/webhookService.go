@@ -0,0 +1,417 @@
+package webhook
+
+import (
+	"belajarGo2/service/outbox"
+	"belajarGo2/service/user"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+}
+
+type service struct {
+	logger      *slog.Logger
+	repo        Repository
+	config      Config
+	httpClient  *http.Client
+	memberships MembershipRepository
+}
+
+type Option func(*service)
+
+// WithMembershipRepository deliver the user events to the subscriptions of the organizations of the user,
+// without it they only go to the subscriptions without organization
+func WithMembershipRepository(memberships MembershipRepository) Option {
+	return func(s *service) {
+		s.memberships = memberships
+	}
+}
+
+// Service only let an organization see and manage its own subscriptions and their deliveries,
+// the subscription of another organization is not found
+type Service interface {
+	CreateSubscription(sub Subscription) (created Subscription, err error)
+	GetSubscriptions(organizationID string) (subs []Subscription, err error)
+	GetSubscriptionByID(organizationID string, id string) (sub Subscription, err error)
+	DeleteSubscription(organizationID string, id string) (err error)
+	GetDeliveries(organizationID string, subscriptionID string, page int, limit int) (deliveries []Delivery, err error)
+	Redeliver(organizationID string, deliveryID string) (delivery Delivery, err error)
+
+	// Publish implement outbox.Publisher, it queue a delivery for every matching subscription
+	Publish(evt outbox.Event) (err error)
+	DeliverDue(limit int) (delivered int, err error)
+
+	// EraseUserData implement user.DataEraser, it remove the user data from the payload of the deliveries of the user events
+	EraseUserData(userID string) (err error)
+}
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+	ErrInvalidURL           = errors.New("invalid webhook url")
+)
+
+func NewService(logger *slog.Logger, repo Repository, cfg Config, opts ...Option) Service {
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 6 * time.Hour
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	s := &service{
+		logger:     logger,
+		repo:       repo,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type payloadEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (s *service) CreateSubscription(sub Subscription) (created Subscription, err error) {
+	u, err := url.ParseRequestURI(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, ErrInvalidURL
+	}
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	sub.ID = uuid.NewString()
+	sub.IsActive = true
+	sub.CreatedAt = time.Now()
+
+	if err = s.repo.CreateSubscription(sub); err != nil {
+		return
+	}
+
+	return sub, nil
+}
+
+func (s *service) GetSubscriptions(organizationID string) (subs []Subscription, err error) {
+	return s.repo.GetSubscriptions(organizationID)
+}
+
+func (s *service) GetSubscriptionByID(organizationID string, id string) (sub Subscription, err error) {
+	sub, err = s.repo.GetSubscriptionByID(organizationID, id)
+	if err != nil {
+		return
+	}
+
+	if sub.ID == "" || sub.OrganizationID != organizationID {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+
+	return sub, nil
+}
+
+func (s *service) DeleteSubscription(organizationID string, id string) (err error) {
+	if _, err = s.GetSubscriptionByID(organizationID, id); err != nil {
+		return
+	}
+
+	return s.repo.DeleteSubscription(organizationID, id)
+}
+
+func (s *service) GetDeliveries(organizationID string, subscriptionID string, page int, limit int) (deliveries []Delivery, err error) {
+	if _, err = s.GetSubscriptionByID(organizationID, subscriptionID); err != nil {
+		return
+	}
+
+	return s.repo.GetDeliveries(subscriptionID, page, limit)
+}
+
+// Redeliver send the delivery right away regardless of its status, failed delivery get a new set of retries.
+// The delivery of a subscription of another organization is not found
+func (s *service) Redeliver(organizationID string, deliveryID string) (delivery Delivery, err error) {
+	delivery, err = s.repo.GetDeliveryByID(deliveryID)
+	if err != nil {
+		return
+	}
+
+	if delivery.ID == "" {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+
+	sub, err := s.GetSubscriptionByID(organizationID, delivery.SubscriptionID)
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if delivery.Status != DeliveryStatusPending {
+		delivery.Attempts = 0
+	}
+
+	delivery = s.deliver(sub, delivery)
+	if err = s.repo.UpdateDelivery(delivery); err != nil {
+		return
+	}
+
+	return delivery, nil
+}
+
+func (s *service) Publish(evt outbox.Event) (err error) {
+	organizationIDs, err := s.eventOrganizations(evt)
+	if err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(payloadEvent{
+		ID:        evt.ID,
+		Type:      evt.EventType,
+		CreatedAt: evt.CreatedAt,
+		Data:      json.RawMessage(evt.Payload),
+	})
+	if err != nil {
+		return
+	}
+
+	timeNow := time.Now()
+	deliveries := []Delivery{}
+	for _, organizationID := range organizationIDs {
+		subs, err := s.repo.GetSubscriptions(organizationID)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range subs {
+			if !sub.IsActive || sub.OrganizationID != organizationID || !sub.Matches(evt.EventType) {
+				continue
+			}
+
+			deliveries = append(deliveries, Delivery{
+				ID:             uuid.NewString(),
+				SubscriptionID: sub.ID,
+				EventID:        evt.ID,
+				EventType:      evt.EventType,
+				AggregateType:  evt.AggregateType,
+				AggregateID:    evt.AggregateID,
+				Payload:        string(payload),
+				Status:         DeliveryStatusPending,
+				NextAttemptAt:  timeNow,
+				CreatedAt:      timeNow,
+			})
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return s.repo.CreateDeliveries(deliveries)
+}
+
+// eventOrganizations read the organization_id of the payload. The user events belong to no organization,
+// they go to the organizations of the user when the memberships are known
+func (s *service) eventOrganizations(evt outbox.Event) (organizationIDs []string, err error) {
+	payload := struct {
+		OrganizationID string `json:"organization_id"`
+	}{}
+	if err = json.Unmarshal([]byte(evt.Payload), &payload); err == nil && payload.OrganizationID != "" {
+		return []string{payload.OrganizationID}, nil
+	}
+
+	if evt.AggregateType != user.EventAggregateType || s.memberships == nil {
+		return []string{""}, nil
+	}
+
+	members, err := s.memberships.GetUserMemberships(evt.AggregateID)
+	if err != nil {
+		return
+	}
+
+	for _, member := range members {
+		organizationIDs = append(organizationIDs, member.OrganizationID)
+	}
+	return organizationIDs, nil
+}
+
+// EraseUserData keep only the user id in the data of the deliveries, the receivers already got the full payload
+func (s *service) EraseUserData(userID string) (err error) {
+	deliveries, err := s.repo.GetAggregateDeliveries(user.EventAggregateType, userID)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(map[string]string{"id": userID})
+	if err != nil {
+		return
+	}
+
+	for _, delivery := range deliveries {
+		payload := payloadEvent{}
+		if err = json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+			return
+		}
+
+		payload.Data = data
+		payloadByte, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		delivery.Payload = string(payloadByte)
+		if err = s.repo.UpdateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) DeliverDue(limit int) (delivered int, err error) {
+	deliveries, err := s.repo.GetDueDeliveries(time.Now(), limit)
+	if err != nil {
+		return
+	}
+
+	subs := map[string]Subscription{}
+	for _, delivery := range deliveries {
+		sub, ok := subs[delivery.SubscriptionID]
+		if !ok {
+			sub, err = s.repo.GetDeliverySubscription(delivery.SubscriptionID)
+			if err != nil {
+				return
+			}
+			subs[delivery.SubscriptionID] = sub
+		}
+
+		if sub.ID == "" || !sub.IsActive {
+			delivery.Status = DeliveryStatusFailed
+			delivery.LastError = "subscription removed or inactive"
+		} else {
+			delivery = s.deliver(sub, delivery)
+		}
+
+		if err = s.repo.UpdateDelivery(delivery); err != nil {
+			return
+		}
+
+		if delivery.Status == DeliveryStatusSuccess {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// deliver do a single attempt and set the next state of the delivery
+func (s *service) deliver(sub Subscription, delivery Delivery) Delivery {
+	timeNow := time.Now()
+	delivery.Attempts++
+
+	statusCode, err := s.send(sub, delivery, timeNow)
+	delivery.ResponseStatus = statusCode
+	if err == nil {
+		delivery.Status = DeliveryStatusSuccess
+		delivery.LastError = ""
+		delivery.DeliveredAt = &timeNow
+		return delivery
+	}
+
+	s.logger.Error("webhook delivery err", slog.String("delivery_id", delivery.ID), slog.Int("attempts", delivery.Attempts), slog.Any("err", err.Error()))
+	delivery.LastError = err.Error()
+
+	if delivery.Attempts >= s.config.MaxAttempts {
+		delivery.Status = DeliveryStatusFailed
+		return delivery
+	}
+
+	delivery.Status = DeliveryStatusPending
+	delivery.NextAttemptAt = timeNow.Add(s.backoff(delivery.Attempts))
+	return delivery
+}
+
+// backoff double the wait after every failed attempt
+func (s *service) backoff(attempts int) time.Duration {
+	wait := s.config.RetryBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= s.config.MaxBackoff {
+			return s.config.MaxBackoff
+		}
+	}
+
+	return wait
+}
+
+func (s *service) send(sub Subscription, delivery Delivery, timeNow time.Time) (statusCode int, err error) {
+	timestamp := strconv.FormatInt(timeNow.Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, timestamp, []byte(delivery.Payload)))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return res.StatusCode, nil
+	}
+
+	return res.StatusCode, fmt.Errorf("receiver return negative response %v", res.StatusCode)
+}
+
+// Sign return the hex HMAC-SHA256 of "timestamp.body", receiver should compute the same
+// value and compare it with the X-Webhook-Signature header
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,364 @@
+package webhook_test
+
+import (
+	"belajarGo2/service/organization"
+	mock_organization "belajarGo2/service/organization/mock"
+	"belajarGo2/service/outbox"
+	"belajarGo2/service/webhook"
+	mock_webhook "belajarGo2/service/webhook/mock"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var loggerOption = slog.HandlerOptions{AddSource: true}
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, &loggerOption))
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+// newReceiver start a local receiver which verify the signature and answer with the given status code
+func newReceiver(t *testing.T, statusCode int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := "sha256=" + webhook.Sign(secret, r.Header.Get(webhook.HeaderTimestamp), body)
+		assert.Equal(t, expected, r.Header.Get(webhook.HeaderSignature))
+		assert.Equal(t, "inventory.created", r.Header.Get(webhook.HeaderEvent))
+		assert.Contains(t, string(body), `"data":{"code":"INV001"}`)
+
+		w.WriteHeader(statusCode)
+	}))
+}
+
+func TestPublish(t *testing.T) {
+	evt := outbox.Event{ID: "evt-1", AggregateType: "inventory", AggregateID: "INV001", EventType: "inventory.created", Payload: `{"organization_id":"org-1","code":"INV001"}`}
+
+	tests := []struct {
+		name     string
+		mockRepo func(m *mock_webhook.MockRepository)
+		wantErr  bool
+	}{
+		{
+			name: "error on GetSubscriptions",
+			mockRepo: func(m *mock_webhook.MockRepository) {
+				m.EXPECT().GetSubscriptions("org-1").Return(nil, errors.New("db error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "no matching subscription",
+			mockRepo: func(m *mock_webhook.MockRepository) {
+				m.EXPECT().GetSubscriptions("org-1").Return([]webhook.Subscription{
+					{ID: "1", OrganizationID: "org-1", EventTypes: []string{"user.*"}, IsActive: true},
+					{ID: "2", OrganizationID: "org-1", EventTypes: []string{"*"}, IsActive: false},
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "success queue a delivery per matching subscription",
+			mockRepo: func(m *mock_webhook.MockRepository) {
+				m.EXPECT().GetSubscriptions("org-1").Return([]webhook.Subscription{
+					{ID: "1", OrganizationID: "org-1", EventTypes: []string{"inventory.*"}, IsActive: true},
+					{ID: "2", OrganizationID: "org-1", EventTypes: []string{"inventory.created"}, IsActive: true},
+					{ID: "3", OrganizationID: "org-1", EventTypes: []string{"inventory.deleted"}, IsActive: true},
+				}, nil)
+				m.EXPECT().CreateDeliveries(gomock.Any()).DoAndReturn(func(deliveries []webhook.Delivery) error {
+					assert.Len(t, deliveries, 2)
+					for _, d := range deliveries {
+						assert.Equal(t, "evt-1", d.EventID)
+						assert.Equal(t, "INV001", d.AggregateID)
+						assert.Equal(t, webhook.DeliveryStatusPending, d.Status)
+					}
+					return nil
+				})
+			},
+			wantErr: false,
+		},
+		{
+			name: "subscriptions of another organization or without organization get nothing",
+			mockRepo: func(m *mock_webhook.MockRepository) {
+				m.EXPECT().GetSubscriptions("org-1").Return([]webhook.Subscription{
+					{ID: "1", OrganizationID: "org-2", EventTypes: []string{"*"}, IsActive: true},
+					{ID: "2", EventTypes: []string{"*"}, IsActive: true},
+					{ID: "3", OrganizationID: "org-1", EventTypes: []string{"*"}, IsActive: true},
+				}, nil)
+				m.EXPECT().CreateDeliveries(gomock.Any()).DoAndReturn(func(deliveries []webhook.Delivery) error {
+					require.Len(t, deliveries, 1)
+					assert.Equal(t, "3", deliveries[0].SubscriptionID)
+					return nil
+				})
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+			tt.mockRepo(mock_webhookRepo)
+
+			webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{})
+
+			err := webhookService.Publish(evt)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestPublishUserEvent(t *testing.T) {
+	evt := outbox.Event{ID: "evt-2", AggregateType: "user", AggregateID: "user-1", EventType: "user.registered", Payload: `{"id":"user-1","email":"email@mail.com"}`}
+
+	t.Run("without memberships the user events go to the subscriptions without organization", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+		mock_webhookRepo.EXPECT().GetSubscriptions("").Return([]webhook.Subscription{
+			{ID: "2", EventTypes: []string{"user.*"}, IsActive: true},
+		}, nil)
+		mock_webhookRepo.EXPECT().CreateDeliveries(gomock.Any()).DoAndReturn(func(deliveries []webhook.Delivery) error {
+			require.Len(t, deliveries, 1)
+			assert.Equal(t, "2", deliveries[0].SubscriptionID)
+			return nil
+		})
+
+		webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{})
+		assert.NoError(t, webhookService.Publish(evt))
+	})
+
+	t.Run("the user events go to the subscriptions of the organizations of the user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+		mock_organizationRepo := mock_organization.NewMockRepository(ctrl)
+
+		mock_organizationRepo.EXPECT().GetUserMemberships("user-1").Return([]organization.Member{
+			{OrganizationID: "org-1", UserID: "user-1"},
+			{OrganizationID: "org-2", UserID: "user-1"},
+		}, nil)
+		mock_webhookRepo.EXPECT().GetSubscriptions("org-1").Return([]webhook.Subscription{
+			{ID: "1", OrganizationID: "org-1", EventTypes: []string{"user.*"}, IsActive: true},
+		}, nil)
+		mock_webhookRepo.EXPECT().GetSubscriptions("org-2").Return([]webhook.Subscription{
+			{ID: "2", OrganizationID: "org-2", EventTypes: []string{"inventory.*"}, IsActive: true},
+		}, nil)
+		mock_webhookRepo.EXPECT().CreateDeliveries(gomock.Any()).DoAndReturn(func(deliveries []webhook.Delivery) error {
+			require.Len(t, deliveries, 1)
+			assert.Equal(t, "1", deliveries[0].SubscriptionID)
+			return nil
+		})
+
+		webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{}, webhook.WithMembershipRepository(mock_organizationRepo))
+		assert.NoError(t, webhookService.Publish(evt))
+	})
+
+	t.Run("error on GetUserMemberships", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+		mock_organizationRepo := mock_organization.NewMockRepository(ctrl)
+
+		mock_organizationRepo.EXPECT().GetUserMemberships("user-1").Return(nil, errors.New("db error"))
+
+		webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{}, webhook.WithMembershipRepository(mock_organizationRepo))
+		assert.Error(t, webhookService.Publish(evt))
+	})
+}
+
+func TestSubscriptionOfAnotherOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+	// the repository filter by organization, a subscription of another organization is never returned
+	mock_webhookRepo.EXPECT().GetSubscriptionByID("org-2", "1").Return(webhook.Subscription{}, nil).Times(3)
+	mock_webhookRepo.EXPECT().GetDeliveryByID("d-1").Return(webhook.Delivery{ID: "d-1", SubscriptionID: "1"}, nil)
+
+	webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{})
+
+	_, err := webhookService.GetSubscriptionByID("org-2", "1")
+	assert.ErrorIs(t, err, webhook.ErrSubscriptionNotFound)
+
+	err = webhookService.DeleteSubscription("org-2", "1")
+	assert.ErrorIs(t, err, webhook.ErrSubscriptionNotFound)
+
+	_, err = webhookService.Redeliver("org-2", "d-1")
+	assert.ErrorIs(t, err, webhook.ErrDeliveryNotFound)
+}
+
+func TestDeliverDue(t *testing.T) {
+	receiverOK := newReceiver(t, http.StatusOK)
+	defer receiverOK.Close()
+	receiverDown := newReceiver(t, http.StatusServiceUnavailable)
+	defer receiverDown.Close()
+
+	delivery := webhook.Delivery{
+		ID:             "d-1",
+		SubscriptionID: "1",
+		EventID:        "evt-1",
+		EventType:      "inventory.created",
+		Payload:        `{"id":"evt-1","type":"inventory.created","data":{"code":"INV001"}}`,
+		Status:         webhook.DeliveryStatusPending,
+	}
+
+	tests := []struct {
+		name          string
+		delivery      webhook.Delivery
+		mockRepo      func(m *mock_webhook.MockRepository, d webhook.Delivery)
+		wantDelivered int
+		wantErr       bool
+	}{
+		{
+			name:     "success signed delivery",
+			delivery: delivery,
+			mockRepo: func(m *mock_webhook.MockRepository, d webhook.Delivery) {
+				m.EXPECT().GetDueDeliveries(gomock.Any(), 10).Return([]webhook.Delivery{d}, nil)
+				m.EXPECT().GetDeliverySubscription("1").Return(webhook.Subscription{ID: "1", URL: receiverOK.URL, Secret: secret, IsActive: true}, nil)
+				m.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d webhook.Delivery) error {
+					assert.Equal(t, webhook.DeliveryStatusSuccess, d.Status)
+					assert.Equal(t, 1, d.Attempts)
+					assert.Equal(t, http.StatusOK, d.ResponseStatus)
+					assert.NotNil(t, d.DeliveredAt)
+					return nil
+				})
+			},
+			wantDelivered: 1,
+			wantErr:       false,
+		},
+		{
+			name:     "receiver down schedule a retry with backoff",
+			delivery: func() webhook.Delivery { d := delivery; d.Attempts = 2; return d }(),
+			mockRepo: func(m *mock_webhook.MockRepository, d webhook.Delivery) {
+				m.EXPECT().GetDueDeliveries(gomock.Any(), 10).Return([]webhook.Delivery{d}, nil)
+				m.EXPECT().GetDeliverySubscription("1").Return(webhook.Subscription{ID: "1", URL: receiverDown.URL, Secret: secret, IsActive: true}, nil)
+				m.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d webhook.Delivery) error {
+					assert.Equal(t, webhook.DeliveryStatusPending, d.Status)
+					assert.Equal(t, 3, d.Attempts)
+					assert.Equal(t, http.StatusServiceUnavailable, d.ResponseStatus)
+					// third attempt wait 4 times the base backoff
+					assert.WithinDuration(t, time.Now().Add(4*time.Minute), d.NextAttemptAt, 5*time.Second)
+					return nil
+				})
+			},
+			wantDelivered: 0,
+			wantErr:       false,
+		},
+		{
+			name:     "receiver down on the last attempt mark the delivery as failed",
+			delivery: func() webhook.Delivery { d := delivery; d.Attempts = 4; return d }(),
+			mockRepo: func(m *mock_webhook.MockRepository, d webhook.Delivery) {
+				m.EXPECT().GetDueDeliveries(gomock.Any(), 10).Return([]webhook.Delivery{d}, nil)
+				m.EXPECT().GetDeliverySubscription("1").Return(webhook.Subscription{ID: "1", URL: receiverDown.URL, Secret: secret, IsActive: true}, nil)
+				m.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(d webhook.Delivery) error {
+					assert.Equal(t, webhook.DeliveryStatusFailed, d.Status)
+					assert.True(t, strings.Contains(d.LastError, "503"))
+					return nil
+				})
+			},
+			wantDelivered: 0,
+			wantErr:       false,
+		},
+		{
+			name:     "error on UpdateDelivery",
+			delivery: delivery,
+			mockRepo: func(m *mock_webhook.MockRepository, d webhook.Delivery) {
+				m.EXPECT().GetDueDeliveries(gomock.Any(), 10).Return([]webhook.Delivery{d}, nil)
+				m.EXPECT().GetDeliverySubscription("1").Return(webhook.Subscription{ID: "1", URL: receiverOK.URL, Secret: secret, IsActive: true}, nil)
+				m.EXPECT().UpdateDelivery(gomock.Any()).Return(errors.New("db error"))
+			},
+			wantDelivered: 0,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+			tt.mockRepo(mock_webhookRepo, tt.delivery)
+
+			webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{
+				MaxAttempts:  5,
+				RetryBackoff: time.Minute,
+			})
+
+			delivered, err := webhookService.DeliverDue(10)
+			assert.Equal(t, tt.wantDelivered, delivered)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	receiverOK := newReceiver(t, http.StatusOK)
+	defer receiverOK.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+	mock_webhookRepo.EXPECT().GetDeliveryByID("unknown").Return(webhook.Delivery{}, nil)
+	mock_webhookRepo.EXPECT().GetDeliveryByID("d-1").Return(webhook.Delivery{
+		ID:             "d-1",
+		SubscriptionID: "1",
+		EventType:      "inventory.created",
+		Payload:        `{"data":{"code":"INV001"}}`,
+		Status:         webhook.DeliveryStatusFailed,
+		Attempts:       8,
+	}, nil)
+	mock_webhookRepo.EXPECT().GetSubscriptionByID("org-1", "1").Return(webhook.Subscription{ID: "1", OrganizationID: "org-1", URL: receiverOK.URL, Secret: secret, IsActive: true}, nil)
+	mock_webhookRepo.EXPECT().UpdateDelivery(gomock.Any()).Return(nil)
+
+	webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{})
+
+	_, err := webhookService.Redeliver("org-1", "unknown")
+	assert.ErrorIs(t, err, webhook.ErrDeliveryNotFound)
+
+	delivery, err := webhookService.Redeliver("org-1", "d-1")
+	assert.Nil(t, err)
+	assert.Equal(t, webhook.DeliveryStatusSuccess, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+}
+
+func TestEraseUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock_webhookRepo := mock_webhook.NewMockRepository(ctrl)
+
+	mock_webhookRepo.EXPECT().GetAggregateDeliveries("user", "user-1").Return([]webhook.Delivery{
+		{ID: "d-1", EventType: "user.registered", Payload: `{"id":"evt-1","type":"user.registered","created_at":"2026-01-02T03:04:05Z","data":{"id":"user-1","email":"email@mail.com"}}`},
+	}, nil)
+	mock_webhookRepo.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery webhook.Delivery) error {
+		assert.Equal(t, "d-1", delivery.ID)
+		assert.Contains(t, delivery.Payload, `"id":"evt-1"`)
+		assert.Contains(t, delivery.Payload, `"data":{"id":"user-1"}`)
+		assert.NotContains(t, delivery.Payload, "email@mail.com")
+		return nil
+	})
+
+	webhookService := webhook.NewService(logger, mock_webhookRepo, webhook.Config{})
+	assert.NoError(t, webhookService.EraseUserData("user-1"))
+}
@@ -0,0 +1,18 @@
+CREATE TABLE bg_api_keys (
+    id VARCHAR(40) PRIMARY KEY,
+    user_id VARCHAR(40) NOT NULL,
+    organization_id VARCHAR(40) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_bg_api_keys_user ON bg_api_keys (user_id);
+
+-- existing databases, the keys created before act in the default organization
+-- ALTER TABLE bg_api_keys ADD COLUMN organization_id VARCHAR(40) NOT NULL DEFAULT 'default';
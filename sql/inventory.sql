@@ -1,24 +1,29 @@
 CREATE TABLE bg_inventories (
-    code VARCHAR(50) PRIMARY KEY,
+    organization_id VARCHAR(40) NOT NULL,
+    code VARCHAR(50) NOT NULL,
     name VARCHAR(100) NOT NULL,
     stock INT NOT NULL DEFAULT 0,
     description TEXT,
-    status VARCHAR(50) NOT NULL DEFAULT ''
-)
+    status VARCHAR(50) NOT NULL DEFAULT '',
+    PRIMARY KEY (organization_id, code)
+);
 
-INSERT INTO bg_inventories (code, name, stock, description, status) VALUES
-('INV001', 'Laptop', 25, 'Dell Latitude 5420', 'active'),
-('INV002', 'Mouse', 100, 'Logitech wireless mouse', 'active'),
-('INV003', 'Keyboard', 75, 'Mechanical keyboard with RGB lights', 'active'),
-('INV004', 'Monitor', 30, '27-inch 4K UHD monitor', 'active'),
-('INV005', 'Printer', 10, 'HP LaserJet Pro multifunction printer', 'active'),
-('INV006', 'Desk Chair', 40, 'Ergonomic office chair', 'active'),
-('INV007', 'Webcam', 60, 'HD webcam with built-in mic', 'active'),
-('INV008', 'Router', 20, 'Wi-Fi 6 Dual-Band Router', 'active'),
-('INV009', 'USB Hub', 85, '7-port powered USB hub', 'active'),
-('INV010', 'External HDD', 15, '2TB Seagate USB 3.0 external hard drive', 'active'),
-('INV011', 'Projector', 5, 'Full HD business projector', 'broken'),
-('INV012', 'Scanner', 8, 'Flatbed document scanner', 'broken'),
-('INV013', 'Desk Lamp', 50, 'LED lamp with brightness control', 'active'),
-('INV014', 'Headphones', 35, 'Noise-cancelling over-ear headphones', 'active'),
-('INV015', 'Laptop Stand', 45, 'Adjustable aluminum laptop stand', 'active');
\ No newline at end of file
+-- existing databases get the organization_id column and the (organization_id, code) primary key from
+-- repository/inventory NewGormRepository, the inventories are given to APP_ORGANIZATION_ID
+
+INSERT INTO bg_inventories (organization_id, code, name, stock, description, status) VALUES
+('default', 'INV001', 'Laptop', 25, 'Dell Latitude 5420', 'active'),
+('default', 'INV002', 'Mouse', 100, 'Logitech wireless mouse', 'active'),
+('default', 'INV003', 'Keyboard', 75, 'Mechanical keyboard with RGB lights', 'active'),
+('default', 'INV004', 'Monitor', 30, '27-inch 4K UHD monitor', 'active'),
+('default', 'INV005', 'Printer', 10, 'HP LaserJet Pro multifunction printer', 'active'),
+('default', 'INV006', 'Desk Chair', 40, 'Ergonomic office chair', 'active'),
+('default', 'INV007', 'Webcam', 60, 'HD webcam with built-in mic', 'active'),
+('default', 'INV008', 'Router', 20, 'Wi-Fi 6 Dual-Band Router', 'active'),
+('default', 'INV009', 'USB Hub', 85, '7-port powered USB hub', 'active'),
+('default', 'INV010', 'External HDD', 15, '2TB Seagate USB 3.0 external hard drive', 'active'),
+('default', 'INV011', 'Projector', 5, 'Full HD business projector', 'broken'),
+('default', 'INV012', 'Scanner', 8, 'Flatbed document scanner', 'broken'),
+('default', 'INV013', 'Desk Lamp', 50, 'LED lamp with brightness control', 'active'),
+('default', 'INV014', 'Headphones', 35, 'Noise-cancelling over-ear headphones', 'active'),
+('default', 'INV015', 'Laptop Stand', 45, 'Adjustable aluminum laptop stand', 'active');
\ No newline at end of file
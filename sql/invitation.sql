@@ -0,0 +1,13 @@
+CREATE TABLE bg_invitations (
+    id VARCHAR(40) PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(40) NOT NULL,
+    invited_by VARCHAR(40) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_bg_invitations_email ON bg_invitations (email);
+CREATE INDEX idx_bg_invitations_invited_by ON bg_invitations (invited_by, created_at);
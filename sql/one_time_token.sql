@@ -0,0 +1,12 @@
+CREATE TABLE bg_one_time_tokens (
+    id VARCHAR(40) PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    purpose VARCHAR(40) NOT NULL,
+    subject VARCHAR(40) NOT NULL,
+    payload VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    consumed_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_bg_one_time_tokens_subject ON bg_one_time_tokens (purpose, subject);
@@ -0,0 +1,23 @@
+CREATE TABLE bg_organizations (
+    id VARCHAR(40) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_by VARCHAR(40) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE bg_organization_members (
+    organization_id VARCHAR(40) NOT NULL,
+    user_id VARCHAR(40) NOT NULL,
+    role VARCHAR(40) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_bg_organization_members_user ON bg_organization_members (user_id);
+
+-- the organization of the inventories existing before the organizations
+INSERT INTO bg_organizations (id, name, created_by, created_at) VALUES ('default', 'Default', '', NOW());
+
+-- existing databases, the users join the default organization with their current role and it is their active organization
+-- INSERT INTO bg_organization_members (organization_id, user_id, role, created_at) SELECT 'default', id, role, NOW() FROM bg_users;
+-- UPDATE bg_users SET organization_id = 'default';
@@ -0,0 +1,14 @@
+CREATE TABLE bg_outbox_events (
+    id VARCHAR(40) PRIMARY KEY,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id VARCHAR(100) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_bg_outbox_events_unpublished ON bg_outbox_events (published_at, created_at);
+CREATE INDEX idx_bg_outbox_events_aggregate ON bg_outbox_events (aggregate_type, aggregate_id);
@@ -0,0 +1,17 @@
+CREATE TABLE bg_refresh_tokens (
+    id VARCHAR(40) PRIMARY KEY,
+    user_id VARCHAR(40) NOT NULL,
+    family_id VARCHAR(40) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    rotated_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    is_two_factor BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_bg_refresh_tokens_family ON bg_refresh_tokens (family_id);
+CREATE INDEX idx_bg_refresh_tokens_user ON bg_refresh_tokens (user_id);
+
+-- existing databases
+-- ALTER TABLE bg_refresh_tokens ADD COLUMN is_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
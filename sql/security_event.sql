@@ -0,0 +1,16 @@
+-- append only, the rows are only updated to clear the ip address and the user agent of a deleted account
+CREATE TABLE bg_security_events (
+    id VARCHAR(40) PRIMARY KEY,
+    event_type VARCHAR(40) NOT NULL,
+    actor_id VARCHAR(40) NOT NULL DEFAULT '',
+    subject_id VARCHAR(40) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    outcome VARCHAR(20) NOT NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_bg_security_events_created_at ON bg_security_events (created_at);
+CREATE INDEX idx_bg_security_events_actor ON bg_security_events (actor_id, created_at);
+CREATE INDEX idx_bg_security_events_subject ON bg_security_events (subject_id, created_at);
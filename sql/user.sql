@@ -5,6 +5,27 @@ CREATE TABLE bg_users (
     email VARCHAR(255) NOT NULL UNIQUE,
     password VARCHAR(100) NOT NULL,
     fullname VARCHAR(100) NOT NULL,
-    role VARCHAR(20) NOT NULL CHECK (role IN ('superadmin', 'admin', 'user')),
-    is_email_verified BOOLEAN DEFAULT FALSE
-)
\ No newline at end of file
+    role VARCHAR(40) NOT NULL,
+    is_email_verified BOOLEAN DEFAULT FALSE,
+    is_disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(255) NOT NULL DEFAULT '',
+    is_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    recovery_codes TEXT NULL,
+    delete_at TIMESTAMP NULL,
+    organization_id VARCHAR(40) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_bg_users_delete_at ON bg_users (delete_at);
+
+-- existing databases
+-- ALTER TABLE bg_users ADD COLUMN is_disabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- ALTER TABLE bg_users ADD COLUMN totp_secret VARCHAR(255) NOT NULL DEFAULT '';
+-- ALTER TABLE bg_users ADD COLUMN is_totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- ALTER TABLE bg_users ADD COLUMN recovery_codes TEXT NULL;
+-- the roles are defined at runtime in bg_roles, drop the role check constraint
+-- ALTER TABLE bg_users DROP CONSTRAINT IF EXISTS bg_users_role_check;
+-- ALTER TABLE bg_users ALTER COLUMN role TYPE VARCHAR(40);
+-- ALTER TABLE bg_users ADD COLUMN delete_at TIMESTAMP NULL;
+-- CREATE INDEX idx_bg_users_delete_at ON bg_users (delete_at);
+-- the active organization of the user, put in the access tokens
+-- ALTER TABLE bg_users ADD COLUMN organization_id VARCHAR(40) NOT NULL DEFAULT '';
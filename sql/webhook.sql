@@ -0,0 +1,39 @@
+CREATE TABLE bg_webhook_subscriptions (
+    id VARCHAR(40) PRIMARY KEY,
+    organization_id VARCHAR(40) NOT NULL DEFAULT '',
+    url VARCHAR(2048) NOT NULL,
+    event_types TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(40) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE bg_webhook_deliveries (
+    id VARCHAR(40) PRIMARY KEY,
+    subscription_id VARCHAR(40) NOT NULL,
+    event_id VARCHAR(40) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_type VARCHAR(50) NOT NULL DEFAULT '',
+    aggregate_id VARCHAR(100) NOT NULL DEFAULT '',
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'success', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP NULL,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX idx_bg_webhook_subscriptions_organization ON bg_webhook_subscriptions (organization_id, created_at);
+CREATE INDEX idx_bg_webhook_deliveries_due ON bg_webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_bg_webhook_deliveries_aggregate ON bg_webhook_deliveries (aggregate_type, aggregate_id);
+
+-- existing databases, the subscriptions receive the events of the default organization of sql/organization.sql
+-- ALTER TABLE bg_webhook_subscriptions ADD COLUMN organization_id VARCHAR(40) NOT NULL DEFAULT '';
+-- UPDATE bg_webhook_subscriptions SET organization_id = 'default';
+-- ALTER TABLE bg_webhook_deliveries ADD COLUMN aggregate_type VARCHAR(50) NOT NULL DEFAULT '';
+-- ALTER TABLE bg_webhook_deliveries ADD COLUMN aggregate_id VARCHAR(100) NOT NULL DEFAULT '';
+-- UPDATE bg_webhook_deliveries d SET aggregate_type = e.aggregate_type, aggregate_id = e.aggregate_id FROM bg_outbox_events e WHERE e.id = d.event_id;
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -106,3 +107,15 @@ func (conf *Config) GetDatabaseConnection() *gorm.DB {
 
 	return db
 }
+
+// IsDuplicateKey report whether err is a unique constraint violation, whatever the gorm driver is
+func IsDuplicateKey(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
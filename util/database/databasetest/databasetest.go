@@ -0,0 +1,63 @@
+// Package databasetest open the sqlite databases of the gorm repository tests with the schema of sql/*.sql
+package databasetest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlDir is the sql directory at the root of the module
+func sqlDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "sql")
+}
+
+// NewSQLite open an empty in-memory sqlite database with the tables of the given files of sql/, e.g. "user.sql".
+// Only the CREATE TABLE and CREATE INDEX statements are run, the seed data and the migration comments are skipped
+func NewSQLite(t *testing.T, files ...string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	// every connection get its own :memory: database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(sqlDir(), file))
+		require.NoError(t, err)
+
+		for _, stmt := range schemaStatements(string(content)) {
+			require.NoError(t, db.Exec(stmt).Error, "%s: %s", file, stmt)
+		}
+	}
+	return db
+}
+
+// schemaStatements split a sql file in its statements and keep the CREATE TABLE and CREATE INDEX ones
+func schemaStatements(content string) (stmts []string) {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		stmt = strings.TrimSpace(stmt)
+		upper := strings.ToUpper(stmt)
+		if strings.HasPrefix(upper, "CREATE TABLE") || strings.HasPrefix(upper, "CREATE INDEX") {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return
+}